@@ -1,24 +1,37 @@
 package forest
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha512"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net"
 	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // Signer can sign any binary data
 type Signer interface {
 	Sign(data []byte) (signature []byte, err error)
 	PublicKey() (key []byte, err error)
+	// KeyType reports the format of the key returned by PublicKey.
+	KeyType() fields.KeyType
+	// SignatureType reports the format of the signatures returned by Sign.
+	SignatureType() fields.SignatureType
 }
 
 // NativeSigner uses golang's native openpgp operation for signing data. It
-// only supports private keys without a passphrase.
+// only supports private keys without a passphrase; use
+// NewNativeSignerFromEncryptedKey to decrypt a passphrase-protected key first.
 type NativeSigner openpgp.Entity
 
 // Sign signs the input data with the contained private key and returns the resulting signature.
@@ -32,7 +45,7 @@ func (s NativeSigner) Sign(data []byte) ([]byte, error) {
 }
 
 // NewNativeSigner creates a native Golang PGP signer. This will fail if the provided key is
-// encrypted. GPGSigner should be used for all encrypted keys.
+// encrypted. NewNativeSignerFromEncryptedKey or GPGSigner should be used for encrypted keys.
 func NewNativeSigner(privatekey *openpgp.Entity) (Signer, error) {
 	if privatekey.PrivateKey.Encrypted {
 		return nil, fmt.Errorf("Cannot build NativeSigner with an encrypted key")
@@ -40,6 +53,46 @@ func NewNativeSigner(privatekey *openpgp.Entity) (Signer, error) {
 	return NativeSigner(*privatekey), nil
 }
 
+// armorPrefix begins every ASCII-armored OpenPGP block.
+const armorPrefix = "-----BEGIN"
+
+// NewNativeSignerFromEncryptedKey reads an OpenPGP private key from r, which
+// may be either binary or ASCII-armored, decrypts it with passphrase, and
+// creates a NativeSigner from it. If r holds several keys, the first is used.
+// The key is only decrypted in memory, and keys that are not encrypted are
+// accepted as-is.
+func NewNativeSignerFromEncryptedKey(r io.Reader, passphrase []byte) (Signer, error) {
+	buffered := bufio.NewReader(r)
+	var (
+		entities openpgp.EntityList
+		err      error
+	)
+	if prefix, _ := buffered.Peek(len(armorPrefix)); string(prefix) == armorPrefix {
+		entities, err = openpgp.ReadArmoredKeyRing(buffered)
+	} else {
+		entities, err = openpgp.ReadKeyRing(buffered)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading private key: %w", err)
+	} else if len(entities) == 0 || entities[0].PrivateKey == nil {
+		return nil, fmt.Errorf("no private key found")
+	}
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+			return nil, fmt.Errorf("failed decrypting private key: %w", err)
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt(passphrase); err != nil {
+				return nil, fmt.Errorf("failed decrypting private subkey: %w", err)
+			}
+		}
+	}
+	return NewNativeSigner(entity)
+}
+
 // PublicKey returns the raw bytes of the binary openpgp public key used by this signer.
 func (s NativeSigner) PublicKey() ([]byte, error) {
 	keybuf := new(bytes.Buffer)
@@ -49,6 +102,16 @@ func (s NativeSigner) PublicKey() ([]byte, error) {
 	return keybuf.Bytes(), nil
 }
 
+// KeyType returns fields.KeyTypeOpenPGPRSA.
+func (s NativeSigner) KeyType() fields.KeyType {
+	return fields.KeyTypeOpenPGPRSA
+}
+
+// SignatureType returns fields.SignatureTypeOpenPGPRSA.
+func (s NativeSigner) SignatureType() fields.SignatureType {
+	return fields.SignatureTypeOpenPGPRSA
+}
+
 // FindGPG returns the path to the local gpg executable if one can be found. Otherwise it
 // returns an error.
 func FindGPG() (path string, err error) {
@@ -71,6 +134,61 @@ type GPGSigner struct {
 	// flags or any other property of the subcommand (environment variables). This is especially useful
 	// to control how GPG prompts for key passphrases.
 	Rewriter func(*exec.Cmd) error
+	// Retry determines whether and how signing is retried after gpg fails.
+	Retry GPGRetryPolicy
+	// Runner is invoked to run each gpg command after its input and outputs
+	// have been connected. If it is nil, the command's Run method is used.
+	// It exists mostly to simplify testing.
+	Runner func(*exec.Cmd) error
+}
+
+// GPGRetryPolicy describes how a GPGSigner retries signing after gpg fails.
+// The zero value never retries.
+type GPGRetryPolicy struct {
+	// Attempts is the maximum number of times to invoke gpg for a single
+	// signature. Values less than one are treated as one.
+	Attempts int
+	// Backoff is the delay before the second attempt. It doubles before
+	// each subsequent attempt.
+	Backoff time.Duration
+	// IsTransient reports whether gpg failed for a reason that may go away
+	// on its own, given everything that gpg wrote to stderr. Only transient
+	// failures are retried. If it is nil, IsTransientGPGFailure is used.
+	IsTransient func(stderr string) bool
+}
+
+// transientGPGFailures are fragments of the messages that gpg prints when it
+// fails for reasons that may resolve themselves, such as the agent being busy
+// or a passphrase prompt timing out.
+var transientGPGFailures = []string{
+	"can't connect to the agent",
+	"no gpg-agent running",
+	"waiting for lock",
+	"resource temporarily unavailable",
+	"timeout",
+}
+
+// IsTransientGPGFailure reports whether the given stderr output of gpg
+// describes a failure that may succeed if retried. Failures like a wrong
+// passphrase or a missing secret key are permanent.
+func IsTransientGPGFailure(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, fragment := range transientGPGFailures {
+		if strings.Contains(stderr, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// GPGSignerOptions configures the optional behavior of a GPGSigner. See the
+// fields of GPGSigner with the same names.
+type GPGSignerOptions struct {
+	// GPGBinary is the name or path of the gpg executable to use. If it is
+	// empty, the executable is located with FindGPG.
+	GPGBinary string
+	Retry     GPGRetryPolicy
+	Runner    func(*exec.Cmd) error
 }
 
 // NewGPGSigner wraps the private key so that it can sign using the local system's implementation of GPG.
@@ -85,38 +203,127 @@ func NewGPGSigner(gpgUserName string) (*GPGSigner, error) {
 	return g, nil
 }
 
-// Sign invokes gpg2 to sign the data as this Signer's configured PGP user. It returns the signature or
-// an error (if any).
-func (s *GPGSigner) Sign(data []byte) ([]byte, error) {
-	gpg2 := exec.Command(s.gpgExecutable, "--local-user", s.GPGUserName, "--detach-sign")
-	if err := s.Rewriter(gpg2); err != nil {
-		return nil, fmt.Errorf("Error invoking Rewrite: %v", err)
+// NewGPGSignerWithOptions works like NewGPGSigner, but configures the resulting
+// GPGSigner with the given options.
+func NewGPGSignerWithOptions(gpgUserName string, options GPGSignerOptions) (*GPGSigner, error) {
+	var g *GPGSigner
+	if options.GPGBinary != "" {
+		g = &GPGSigner{
+			gpgExecutable: options.GPGBinary,
+			GPGUserName:   gpgUserName,
+			Rewriter:      func(_ *exec.Cmd) error { return nil },
+		}
+	} else {
+		var err error
+		if g, err = NewGPGSigner(gpgUserName); err != nil {
+			return nil, err
+		}
 	}
-	in, err := gpg2.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("Error getting stdin pipe: %v", err)
+	g.Retry = options.Retry
+	g.Runner = options.Runner
+	return g, nil
+}
+
+// NewGPGSignerByFingerprint creates a GPGSigner that signs with exactly the
+// (sub)key with the given fingerprint, rather than letting gpg choose among all
+// of the keys that match a user ID. The fingerprint may also be a key ID (a
+// suffix of the fingerprint) so long as it identifies exactly one secret key.
+// It returns an error if no secret key or more than one secret key matches.
+func NewGPGSignerByFingerprint(fpr string) (*GPGSigner, error) {
+	return NewGPGSignerByFingerprintWithOptions(fpr, GPGSignerOptions{})
+}
+
+// NewGPGSignerByFingerprintWithOptions works like NewGPGSignerByFingerprint,
+// but configures the resulting GPGSigner with the given options. The options
+// also apply to the gpg command that lists the secret keys.
+func NewGPGSignerByFingerprintWithOptions(fpr string, options GPGSignerOptions) (*GPGSigner, error) {
+	fpr = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(fpr), "0x"))
+	if len(fpr) == 0 {
+		return nil, fmt.Errorf("fingerprint cannot be empty")
+	}
+	for _, char := range fpr {
+		if !strings.ContainsRune("0123456789ABCDEF", char) {
+			return nil, fmt.Errorf("fingerprint %q is not hexadecimal", fpr)
+		}
 	}
-	out, err := gpg2.StdoutPipe()
+	g, err := NewGPGSignerWithOptions(fpr, options)
 	if err != nil {
-		return nil, fmt.Errorf("Error getting stdout pipe: %v", err)
+		return nil, err
 	}
-	if _, err := in.Write(data); err != nil {
-		return nil, fmt.Errorf("Error writing data to stdin: %v", err)
+	gpg2 := exec.Command(g.gpgExecutable, "--list-secret-keys", "--with-colons")
+	if err := g.Rewriter(gpg2); err != nil {
+		return nil, fmt.Errorf("Error invoking Rewrite: %v", err)
 	}
-	if err := gpg2.Start(); err != nil {
-		return nil, fmt.Errorf("Error starting gpg command: %v", err)
+	var listing bytes.Buffer
+	gpg2.Stdout = &listing
+	if err := g.run(gpg2); err != nil {
+		return nil, fmt.Errorf("Error listing secret keys: %v", err)
+	}
+	var matches []string
+	for _, line := range strings.Split(listing.String(), "\n") {
+		// fpr records hold the fingerprint of the preceding key in their
+		// tenth field.
+		record := strings.Split(line, ":")
+		if len(record) < 10 || record[0] != "fpr" {
+			continue
+		}
+		if strings.HasSuffix(strings.ToUpper(record[9]), fpr) {
+			matches = append(matches, strings.ToUpper(record[9]))
+		}
 	}
-	if err := in.Close(); err != nil {
-		return nil, fmt.Errorf("Error closing stdin: %v", err)
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no secret key has fingerprint %s", fpr)
+	case 1:
+		// the trailing ! prevents gpg from substituting another subkey
+		g.GPGUserName = matches[0] + "!"
+		return g, nil
+	default:
+		return nil, fmt.Errorf("fingerprint %s is ambiguous, it matches secret keys %v", fpr, matches)
 	}
-	signature, err := ioutil.ReadAll(out)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading signature data: %v", err)
+}
+
+// Sign invokes gpg2 to sign the data as this Signer's configured PGP user. It returns the signature or
+// an error (if any). Transient failures of gpg are retried according to the Retry policy.
+func (s *GPGSigner) Sign(data []byte) ([]byte, error) {
+	isTransient := s.Retry.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransientGPGFailure
+	}
+	delay := s.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		signature, stderr, err := s.sign(data)
+		if err == nil {
+			return signature, nil
+		}
+		if attempt >= s.Retry.Attempts || !isTransient(stderr) {
+			return nil, err
+		}
+		time.Sleep(delay)
+		delay *= 2
 	}
-	if err := gpg2.Wait(); err != nil {
-		return nil, fmt.Errorf("Error running gpg: %v", err)
+}
+
+// sign invokes gpg2 once to sign the data. It returns everything that gpg
+// wrote to stderr along with the signature or error.
+func (s *GPGSigner) sign(data []byte) (signature []byte, stderr string, err error) {
+	gpg2 := exec.Command(s.gpgExecutable, "--local-user", s.GPGUserName, "--detach-sign")
+	if err := s.Rewriter(gpg2); err != nil {
+		return nil, "", fmt.Errorf("Error invoking Rewrite: %v", err)
 	}
-	return signature, nil
+	var out, errOut bytes.Buffer
+	gpg2.Stdin = bytes.NewReader(data)
+	gpg2.Stdout = &out
+	// preserve any stderr destination configured by the Rewriter
+	if gpg2.Stderr != nil {
+		gpg2.Stderr = io.MultiWriter(gpg2.Stderr, &errOut)
+	} else {
+		gpg2.Stderr = &errOut
+	}
+	if err := s.run(gpg2); err != nil {
+		return nil, errOut.String(), fmt.Errorf("Error running gpg: %v: %s", err, strings.TrimSpace(errOut.String()))
+	}
+	return out.Bytes(), errOut.String(), nil
 }
 
 // PublicKey returns the bytes of the OpenPGP public key used by this signer.
@@ -125,39 +332,190 @@ func (s GPGSigner) PublicKey() ([]byte, error) {
 	if err := s.Rewriter(gpg2); err != nil {
 		return nil, fmt.Errorf("Error invoking Rewrite: %v", err)
 	}
-	out, err := gpg2.StdoutPipe()
+	var pubkey bytes.Buffer
+	gpg2.Stdout = &pubkey
+	if err := s.run(gpg2); err != nil {
+		return nil, fmt.Errorf("Error running gpg: %v", err)
+	}
+	return pubkey.Bytes(), nil
+}
+
+// run runs the gpg command with the Runner, if there is one.
+func (s GPGSigner) run(cmd *exec.Cmd) error {
+	if s.Runner == nil {
+		return cmd.Run()
+	}
+	return s.Runner(cmd)
+}
+
+// KeyType returns fields.KeyTypeOpenPGPRSA.
+func (s GPGSigner) KeyType() fields.KeyType {
+	return fields.KeyTypeOpenPGPRSA
+}
+
+// SignatureType returns fields.SignatureTypeOpenPGPRSA.
+func (s GPGSigner) SignatureType() fields.SignatureType {
+	return fields.SignatureTypeOpenPGPRSA
+}
+
+// SSHAgentSigner uses a key held by an ssh-agent to sign data. The resulting
+// signatures are SSH signatures rather than OpenPGP signatures, so nodes built
+// with it have an SSH public key (fields.KeyTypeSSH) and SSH signatures
+// (fields.SignatureTypeSSH).
+type SSHAgentSigner struct {
+	agent agent.Agent
+	key   ssh.PublicKey
+}
+
+// NewSSHAgentSigner creates a signer that asks the ssh-agent on the other end of
+// agentConn to sign data with the private key corresponding to pubkey. It returns
+// an error if the agent does not hold that key.
+func NewSSHAgentSigner(agentConn net.Conn, pubkey ssh.PublicKey) (*SSHAgentSigner, error) {
+	if agentConn == nil {
+		return nil, fmt.Errorf("agent connection cannot be nil")
+	}
+	if pubkey == nil {
+		return nil, fmt.Errorf("public key cannot be nil")
+	}
+	client := agent.NewClient(agentConn)
+	keys, err := client.List()
 	if err != nil {
-		return nil, fmt.Errorf("Error getting stdout pipe: %v", err)
+		return nil, fmt.Errorf("Error listing ssh-agent keys: %v", err)
 	}
-	if err := gpg2.Start(); err != nil {
-		return nil, fmt.Errorf("Error starting gpg command: %v", err)
+	for _, key := range keys {
+		if bytes.Equal(key.Marshal(), pubkey.Marshal()) {
+			return &SSHAgentSigner{agent: client, key: pubkey}, nil
+		}
 	}
-	pubkey, err := ioutil.ReadAll(out)
+	return nil, fmt.Errorf("ssh-agent does not hold key %s", ssh.FingerprintSHA256(pubkey))
+}
+
+// Sign asks the ssh-agent to sign the data and returns the signature in the SSH
+// wire format.
+func (s *SSHAgentSigner) Sign(data []byte) ([]byte, error) {
+	signature, err := s.agent.Sign(s.key, data)
 	if err != nil {
-		return nil, fmt.Errorf("Error reading pubkey data: %v", err)
+		return nil, fmt.Errorf("Error signing with ssh-agent: %v", err)
 	}
-	if err := gpg2.Wait(); err != nil {
-		return nil, fmt.Errorf("Error running gpg: %v", err)
+	return ssh.Marshal(signature), nil
+}
+
+// PublicKey returns the SSH wire format of the public key used by this signer.
+func (s *SSHAgentSigner) PublicKey() ([]byte, error) {
+	return s.key.Marshal(), nil
+}
+
+// KeyType returns fields.KeyTypeSSH.
+func (s *SSHAgentSigner) KeyType() fields.KeyType {
+	return fields.KeyTypeSSH
+}
+
+// SignatureType returns fields.SignatureTypeSSH.
+func (s *SSHAgentSigner) SignatureType() fields.SignatureType {
+	return fields.SignatureTypeSSH
+}
+
+// CachingSigner wraps another Signer and remembers the signature that it
+// produced for each distinct input. Repeated calls to Sign with identical data
+// return the remembered signature instead of signing again.
+//
+// This relies on the assumption that a signature over a given sequence of
+// bytes made by a given key remains a valid signature for those bytes forever,
+// so that any previously-produced signature is as good as a new one. Note that
+// the cached signature will not be byte-for-byte identical to a fresh one for
+// signature schemes (like OpenPGP) that embed a signing time.
+type CachingSigner struct {
+	Signer
+	// mutex guards signatures
+	mutex      sync.Mutex
+	signatures map[[sha512.Size256]byte][]byte
+}
+
+// NewCachingSigner wraps the provided Signer so that its signatures are memoized.
+func NewCachingSigner(s Signer) *CachingSigner {
+	return &CachingSigner{
+		Signer:     s,
+		signatures: make(map[[sha512.Size256]byte][]byte),
+	}
+}
+
+// Sign returns a cached signature for data if one exists, and otherwise signs
+// data with the wrapped Signer and caches the result.
+func (c *CachingSigner) Sign(data []byte) ([]byte, error) {
+	key := sha512.Sum512_256(data)
+	c.mutex.Lock()
+	signature, cached := c.signatures[key]
+	c.mutex.Unlock()
+	if cached {
+		return signature, nil
+	}
+	signature, err := c.Signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.signatures[key] = signature
+	c.mutex.Unlock()
+	return signature, nil
+}
+
+// newContent wraps data as qualified content of the given type, returning a
+// descriptive error if it is too long for its length to be represented.
+// description names the data in error messages.
+func newContent(description string, contentType fields.ContentType, data []byte) (*fields.QualifiedContent, error) {
+	if len(data) > fields.MaxContentLength {
+		return nil, fmt.Errorf("%s is %d bytes long, exceeding the maximum of %d bytes", description, len(data), fields.MaxContentLength)
+	}
+	qcontent, err := fields.NewQualifiedContent(contentType, data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create qualified content of type %d for %s: %w", contentType, description, err)
+	}
+	return qcontent, nil
+}
+
+// newMetadata wraps metadata as qualified Twig content. Empty (or nil)
+// metadata always produces fields.EmptyContent, so that nodes without metadata
+// are serialized identically however they were built.
+func newMetadata(description string, metadata []byte) (*fields.QualifiedContent, error) {
+	if len(metadata) == 0 {
+		return fields.EmptyContent(), nil
 	}
-	return pubkey, nil
+	return newContent(description, fields.ContentTypeTwig, metadata)
+}
+
+// metadataOrEmpty returns metadata, or fields.EmptyContent if it is nil. A
+// nil Blob within metadata is replaced by an empty one.
+func metadataOrEmpty(metadata *fields.QualifiedContent) *fields.QualifiedContent {
+	if metadata == nil {
+		return fields.EmptyContent()
+	} else if metadata.Blob == nil {
+		normalized := *metadata
+		normalized.Blob = fields.Blob{}
+		return &normalized
+	}
+	return metadata
 }
 
 // NewIdentity builds an Identity node for the user with the given name and metadata, using
 // the OpenPGP Entity privkey to define the Identity. That Entity must contain a
 // private key with no passphrase.
 func NewIdentity(signer Signer, name string, metadata []byte) (*Identity, error) {
-	qname, err := fields.NewQualifiedContent(fields.ContentTypeUTF8String, []byte(name))
+	qname, err := newContent("identity name", fields.ContentTypeUTF8String, []byte(name))
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeUTF8String, name)
+		return nil, err
 	}
-	qmeta, err := fields.NewQualifiedContent(fields.ContentTypeTwig, metadata)
+	qmeta, err := newMetadata("identity metadata", metadata)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeTwig, metadata)
+		return nil, err
 	}
 	return NewIdentityQualified(signer, qname, qmeta)
 }
 
 func NewIdentityQualified(signer Signer, name *fields.QualifiedContent, metadata *fields.QualifiedContent) (*Identity, error) {
+	return newIdentityQualified(signer, name, metadata, fields.TimestampFrom(time.Now()))
+}
+
+func newIdentityQualified(signer Signer, name *fields.QualifiedContent, metadata *fields.QualifiedContent, created fields.Timestamp) (*Identity, error) {
 	// make an empty identity and populate all fields that need to be known before
 	// signing the data
 	identity := newIdentity()
@@ -166,8 +524,8 @@ func NewIdentityQualified(signer Signer, name *fields.QualifiedContent, metadata
 	identity.Parent = *fields.NullHash()
 	identity.Depth = 0
 	identity.Name = *name
-	identity.Metadata = *metadata
-	identity.Created = fields.TimestampFrom(time.Now())
+	identity.Metadata = *metadataOrEmpty(metadata)
+	identity.Created = created
 
 	// Check no newline in name
 	if name.ContainsString("\n") {
@@ -179,7 +537,7 @@ func NewIdentityQualified(signer Signer, name *fields.QualifiedContent, metadata
 	if err != nil {
 		return nil, err
 	}
-	qKey, err := fields.NewQualifiedKey(fields.KeyTypeOpenPGPRSA, pubkey)
+	qKey, err := fields.NewQualifiedKey(signer.KeyType(), pubkey)
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +559,7 @@ func NewIdentityQualified(signer Signer, name *fields.QualifiedContent, metadata
 		return nil, err
 	}
 
-	qs, err := fields.NewQualifiedSignature(fields.SignatureTypeOpenPGPRSA, signature)
+	qs, err := fields.NewQualifiedSignature(signer.SignatureType(), signature)
 	if err != nil {
 		return nil, err
 	}
@@ -218,9 +576,20 @@ func NewIdentityQualified(signer Signer, name *fields.QualifiedContent, metadata
 }
 
 // Builder creates nodes in the forest on behalf of the given user.
+//
+// The methods of a Builder never modify it, so a single Builder may be used by
+// multiple goroutines at once provided that its Signer is safe for concurrent
+// use (all of the Signers in this package are). Methods like WithCreated
+// return modified copies instead. The exported fields must not be changed
+// while the Builder is in use.
 type Builder struct {
 	User *Identity
 	Signer
+	// created, if non-nil, overrides the creation time of new nodes
+	created *fields.Timestamp
+	// maxDepth, if non-zero, lowers the depth beyond which replies will not
+	// be built
+	maxDepth fields.TreeDepth
 }
 
 // As creates a Builder that can write new nodes on behalf of the provided user.
@@ -234,15 +603,76 @@ func As(user *Identity, signer Signer) *Builder {
 	}
 }
 
+// WithCreated returns a shallow copy of the Builder that will use the provided
+// timestamp as the creation time of all nodes that it builds instead of the
+// current time. The ID of a node is computed entirely from its contents, so
+// nodes built this way from identical inputs will have identical IDs provided
+// that the Signer produces identical signatures for them. Note that OpenPGP
+// signatures embed their own creation time, so this only holds for
+// signatures made within the same second.
+func (n *Builder) WithCreated(t fields.Timestamp) *Builder {
+	copied := *n
+	copied.created = &t
+	return &copied
+}
+
+// WithMaxDepth returns a shallow copy of the Builder that refuses to build
+// replies deeper than depth. Limits above MaxTreeDepth have no effect, since
+// deeper nodes would not pass validation.
+func (n *Builder) WithMaxDepth(depth fields.TreeDepth) *Builder {
+	copied := *n
+	copied.maxDepth = depth
+	return &copied
+}
+
+// checkDepth returns an error wrapping ErrTooDeep if a reply at the given depth
+// is beyond the Builder's depth limit.
+func (n *Builder) checkDepth(depth fields.TreeDepth) error {
+	limit := MaxTreeDepth
+	if n.maxDepth != 0 && n.maxDepth < limit {
+		limit = n.maxDepth
+	}
+	if depth > limit {
+		return fmt.Errorf("reply at depth %d exceeds limit of %d: %w", depth, limit, ErrTooDeep)
+	}
+	return nil
+}
+
+// createdTime returns the creation time that should be used for a new node.
+func (n *Builder) createdTime() fields.Timestamp {
+	if n.created != nil {
+		return *n.created
+	}
+	return fields.TimestampFrom(time.Now())
+}
+
+// NewIdentity creates an Identity node signed by the Builder's Signer. The
+// Builder's User is not consulted.
+func (n *Builder) NewIdentity(name string, metadata []byte) (*Identity, error) {
+	qname, err := newContent("identity name", fields.ContentTypeUTF8String, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	qmeta, err := newMetadata("identity metadata", metadata)
+	if err != nil {
+		return nil, err
+	}
+	return n.NewIdentityQualified(qname, qmeta)
+}
+
+func (n *Builder) NewIdentityQualified(name *fields.QualifiedContent, metadata *fields.QualifiedContent) (*Identity, error) {
+	return newIdentityQualified(n.Signer, name, metadata, n.createdTime())
+}
+
 // NewCommunity creates a community node (signed by the given identity with the given privkey).
 func (n *Builder) NewCommunity(name string, metadata []byte) (*Community, error) {
-	qname, err := fields.NewQualifiedContent(fields.ContentTypeUTF8String, []byte(name))
+	qname, err := newContent("community name", fields.ContentTypeUTF8String, []byte(name))
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeUTF8String, name)
+		return nil, err
 	}
-	qmeta, err := fields.NewQualifiedContent(fields.ContentTypeTwig, metadata)
+	qmeta, err := newMetadata("community metadata", metadata)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeTwig, metadata)
+		return nil, err
 	}
 	return n.NewCommunityQualified(qname, qmeta)
 }
@@ -254,9 +684,9 @@ func (n *Builder) NewCommunityQualified(name *fields.QualifiedContent, metadata
 	c.Parent = *fields.NullHash()
 	c.Depth = 0
 	c.Name = *name
-	c.Metadata = *metadata
+	c.Metadata = *metadataOrEmpty(metadata)
 	c.Author = *n.User.ID()
-	c.Created = fields.TimestampFrom(time.Now())
+	c.Created = n.createdTime()
 	idDesc, err := fields.NewHashDescriptor(fields.HashTypeSHA512, int(fields.HashDigestLengthSHA512_256))
 	if err != nil {
 		return nil, err
@@ -277,7 +707,7 @@ func (n *Builder) NewCommunityQualified(name *fields.QualifiedContent, metadata
 	if err != nil {
 		return nil, err
 	}
-	qs, err := fields.NewQualifiedSignature(fields.SignatureTypeOpenPGPRSA, signature)
+	qs, err := fields.NewQualifiedSignature(n.Signer.SignatureType(), signature)
 	if err != nil {
 		return nil, err
 	}
@@ -295,22 +725,60 @@ func (n *Builder) NewCommunityQualified(name *fields.QualifiedContent, metadata
 
 // NewReply creates a reply node as a child of the given community or reply
 func (n *Builder) NewReply(parent interface{}, content string, metadata []byte) (*Reply, error) {
-	qcontent, err := fields.NewQualifiedContent(fields.ContentTypeUTF8String, []byte(content))
-	if err != nil {
-		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeUTF8String, content)
-	}
-	qmeta, err := fields.NewQualifiedContent(fields.ContentTypeTwig, metadata)
+	qcontent, qmeta, err := newReplyContent(content, metadata)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeTwig, metadata)
+		return nil, err
 	}
 	return n.NewReplyQualified(parent, qcontent, qmeta)
 }
 
 func (n *Builder) NewReplyQualified(parent interface{}, content, metadata *fields.QualifiedContent) (*Reply, error) {
+	r, err := n.prepareReply(parent, content, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.signReply(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newReplyContent converts the content and metadata of a reply into their
+// qualified forms.
+func newReplyContent(content string, metadata []byte) (qcontent, qmeta *fields.QualifiedContent, err error) {
+	qcontent, err = newContent("reply content", fields.ContentTypeUTF8String, []byte(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	qmeta, err = newMetadata("reply metadata", metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return qcontent, qmeta, nil
+}
+
+// prepareReply creates a reply to the given community or reply with every
+// field populated except for its signature and ID.
+func (n *Builder) prepareReply(parent interface{}, content, metadata *fields.QualifiedContent) (*Reply, error) {
 	r := newReply()
 	r.Version = fields.CurrentVersion
 	r.Type = fields.NodeTypeReply
-	r.Created = fields.TimestampFrom(time.Now())
+	r.Created = n.createdTime()
+	if err := placeReply(r, parent); err != nil {
+		return nil, err
+	}
+	if err := n.checkDepth(r.Depth); err != nil {
+		return nil, err
+	}
+	if err := n.fillReply(r, content, metadata); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// placeReply sets the fields of r that describe its position in the tree so
+// that it is a child of parent.
+func placeReply(r *Reply, parent interface{}) error {
 	switch concreteParent := parent.(type) {
 	case *Community:
 		r.CommunityID = *concreteParent.ID()
@@ -328,39 +796,217 @@ func (n *Builder) NewReplyQualified(parent interface{}, content, metadata *field
 		r.Parent = *concreteParent.ID()
 		r.Depth = concreteParent.Depth + 1
 	default:
-		return nil, fmt.Errorf("parent must be either a community or reply node")
+		return fmt.Errorf("parent must be either a community or reply node")
 
 	}
+	return nil
+}
+
+// NewReplies creates a run of sibling replies to the given community or reply,
+// one for each element of contents, all sharing the same metadata. Each reply
+// is created at least one millisecond after the previous one, so the replies
+// have distinct IDs even when their contents are identical and they sort in
+// the order given.
+//
+// The replies are signed concurrently, so the Builder's Signer must be safe
+// for concurrent use. All of the Signers in this package are.
+func (n *Builder) NewReplies(parent Node, contents []string, metadata []byte) ([]*Reply, error) {
+	qmeta, err := newMetadata("reply metadata", metadata)
+	if err != nil {
+		return nil, err
+	}
+	created := n.createdTime()
+	replies := make([]*Reply, len(contents))
+	for i, content := range contents {
+		qcontent, err := newContent("reply content", fields.ContentTypeUTF8String, []byte(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed creating reply %d: %w", i, err)
+		}
+		r, err := n.prepareReply(parent, qcontent, qmeta)
+		if err != nil {
+			return nil, err
+		}
+		r.Created = created + fields.Timestamp(i)
+		replies[i] = r
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(replies) {
+		workers = len(replies)
+	}
+	errs := make([]error, len(replies))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = n.signReply(replies[i])
+			}
+		}()
+	}
+	for i := range replies {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed signing reply %d: %w", i, err)
+		}
+	}
+	return replies, nil
+}
+
+// NewReplyInConversation creates a reply node with the given community,
+// conversation, and parent IDs without needing the parent node itself.
+// parentDepth is the depth of the parent node, and the reply will be one level
+// deeper. The conversationID must be the null hash when replying directly to a
+// community and must be the parentID when replying to the root of a
+// conversation.
+func (n *Builder) NewReplyInConversation(communityID, conversationID, parentID *fields.QualifiedHash, parentDepth fields.TreeDepth, content string, metadata []byte) (*Reply, error) {
+	qcontent, err := newContent("reply content", fields.ContentTypeUTF8String, []byte(content))
+	if err != nil {
+		return nil, err
+	}
+	qmeta, err := newMetadata("reply metadata", metadata)
+	if err != nil {
+		return nil, err
+	}
+	depth := parentDepth + 1
+	if depth <= parentDepth {
+		return nil, fmt.Errorf("parent depth %d is too deep to reply to", parentDepth)
+	}
+	if err := n.checkDepth(depth); err != nil {
+		return nil, err
+	}
+	switch {
+	case parentDepth == 0:
+		if !parentID.Equals(communityID) {
+			return nil, fmt.Errorf("parent at depth 0 must be the community %s, got %s", communityID, parentID)
+		}
+		if !conversationID.Equals(fields.NullHash()) {
+			return nil, fmt.Errorf("replies to a community must have the null hash as their conversation, got %s", conversationID)
+		}
+	case parentDepth == 1:
+		if !conversationID.Equals(parentID) {
+			return nil, fmt.Errorf("replies to a conversation root must have the root %s as their conversation, got %s", parentID, conversationID)
+		}
+	default:
+		if conversationID.Equals(fields.NullHash()) {
+			return nil, fmt.Errorf("replies at depth %d must have a conversation", depth)
+		}
+	}
+	r := newReply()
+	r.Version = fields.CurrentVersion
+	r.Type = fields.NodeTypeReply
+	r.Created = n.createdTime()
+	r.CommunityID = *communityID
+	r.ConversationID = *conversationID
+	r.Parent = *parentID
+	r.Depth = depth
+	return n.finishReply(r, qcontent, qmeta)
+}
+
+// finishReply populates the remaining fields of a reply whose position in the
+// tree has already been set, then signs it and computes its ID.
+func (n *Builder) finishReply(r *Reply, content, metadata *fields.QualifiedContent) (*Reply, error) {
+	if err := n.fillReply(r, content, metadata); err != nil {
+		return nil, err
+	}
+	if err := n.signReply(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// fillReply populates the fields of a reply whose position in the tree has
+// already been set, other than its signature and ID.
+func (n *Builder) fillReply(r *Reply, content, metadata *fields.QualifiedContent) error {
 	r.Content = *content
-	r.Metadata = *metadata
+	r.Metadata = *metadataOrEmpty(metadata)
 	r.Author = *n.User.ID()
 	idDesc, err := fields.NewHashDescriptor(fields.HashTypeSHA512, int(fields.HashDigestLengthSHA512_256))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	r.IDDesc = *idDesc
+	return nil
+}
 
-	// we've defined all pre-signature fields, it's time to sign the data
-	signedDataBytes, err := r.MarshalSignedData()
+// DraftReply creates a reply to the given community or reply exactly as
+// NewReply would, except that it is not signed. The draft carries the null
+// signature and a provisional ID computed over it, so it can be previewed
+// like any other node, but ValidateShallow rejects it with ErrUnsigned and it
+// cannot be read back from its binary form. Use SignDraft to finish it.
+//
+// The ID of a draft changes when it is signed, so it must not be used to
+// refer to the eventual reply.
+func (n *Builder) DraftReply(parent Node, content string, metadata []byte) (*Reply, error) {
+	qcontent, qmeta, err := newReplyContent(content, metadata)
 	if err != nil {
 		return nil, err
 	}
-	signature, err := n.Sign(signedDataBytes)
+	r, err := n.prepareReply(parent, qcontent, qmeta)
 	if err != nil {
 		return nil, err
 	}
-	qs, err := fields.NewQualifiedSignature(fields.SignatureTypeOpenPGPRSA, signature)
+	qs, err := fields.NewQualifiedSignature(fields.SignatureTypeNull, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	r.Trailer.Signature = *qs
+	id, err := computeID(r)
 	if err != nil {
 		return nil, err
 	}
+	r.id = fields.Blob(id)
+	return r, nil
+}
+
+// SignDraft signs a draft created by DraftReply and replaces its provisional
+// ID with its final one. The draft must have been created on behalf of the
+// Builder's user. It is modified in place, so any copies of its old ID no
+// longer refer to it.
+func (n *Builder) SignDraft(draft Node) error {
+	r, ok := draft.(*Reply)
+	if !ok {
+		return fmt.Errorf("only replies can be drafts, got %T", draft)
+	}
+	if !r.IsUnsigned() {
+		return fmt.Errorf("reply %v is already signed", r.ID())
+	}
+	if !r.Author.Equals(n.User.ID()) {
+		return fmt.Errorf("draft authored by %v cannot be signed by %v", &r.Author, n.User.ID())
+	}
+	return n.signReply(r)
+}
+
+// signReply signs a reply whose other fields have all been populated and
+// computes its ID.
+func (n *Builder) signReply(r *Reply) error {
+	// we've defined all pre-signature fields, it's time to sign the data
+	signedDataBytes, err := r.MarshalSignedData()
+	if err != nil {
+		return err
+	}
+	signature, err := n.Sign(signedDataBytes)
+	if err != nil {
+		return err
+	}
+	qs, err := fields.NewQualifiedSignature(n.Signer.SignatureType(), signature)
+	if err != nil {
+		return err
+	}
 	r.Trailer.Signature = *qs
 
 	// determine the node's final hash ID
 	id, err := computeID(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	r.id = fields.Blob(id)
 
-	return r, nil
+	return nil
 }
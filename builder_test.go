@@ -0,0 +1,320 @@
+package forest_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+// fixedSigner wraps a Signer so that it always produces the same signature
+// for the same input, regardless of when the signature is made.
+type fixedSigner struct {
+	forest.Signer
+	signatures map[string][]byte
+}
+
+func (f *fixedSigner) Sign(data []byte) ([]byte, error) {
+	if sig, ok := f.signatures[string(data)]; ok {
+		return sig, nil
+	}
+	sig, err := f.Signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	f.signatures[string(data)] = sig
+	return sig, nil
+}
+
+func TestBuilderWithCreated(t *testing.T) {
+	identity, signer, community := testutil.MakeCommunityOrSkip(t)
+	created := fields.TimestampFrom(time.Date(2019, 6, 27, 0, 0, 0, 0, time.UTC))
+	builder := forest.As(identity, &fixedSigner{Signer: signer, signatures: make(map[string][]byte)})
+	timed := builder.WithCreated(created)
+	if timed == builder {
+		t.Fatalf("WithCreated should return a copy of the builder")
+	}
+
+	reply1, err := timed.NewReply(community, "same content", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create reply with valid parameters: %v", err)
+	}
+	reply2, err := timed.NewReply(community, "same content", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create reply with valid parameters: %v", err)
+	}
+	if reply1.Created != created {
+		t.Errorf("Expected reply created at %d, got %d", created, reply1.Created)
+	}
+	if !reply1.ID().Equals(reply2.ID()) {
+		t.Errorf("Expected identical inputs and created time to produce identical IDs, got %v and %v", reply1.ID(), reply2.ID())
+	}
+
+	community2, err := timed.NewCommunity("test community", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create community with valid parameters: %v", err)
+	} else if community2.Created != created {
+		t.Errorf("Expected community created at %d, got %d", created, community2.Created)
+	}
+	identity2, err := timed.NewIdentity("test-username", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create identity with valid parameters: %v", err)
+	} else if identity2.Created != created {
+		t.Errorf("Expected identity created at %d, got %d", created, identity2.Created)
+	}
+}
+
+func TestBuilderNewReplyInConversation(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	builder := forest.As(identity, signer)
+
+	explicit, err := builder.NewReplyInConversation(&reply.CommunityID, reply.ID(), reply.ID(), reply.Depth, "explicit", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create reply with valid parameters: %v", err)
+	}
+	inferred, err := builder.NewReply(reply, "inferred", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create reply with valid parameters: %v", err)
+	}
+	if !explicit.CommunityID.Equals(&inferred.CommunityID) ||
+		!explicit.ConversationID.Equals(&inferred.ConversationID) ||
+		!explicit.Parent.Equals(&inferred.Parent) ||
+		explicit.Depth != inferred.Depth {
+		t.Errorf("Expected explicit reply to have the same position as inferred reply")
+	}
+	if err := explicit.ValidateShallow(); err != nil {
+		t.Errorf("Expected explicit reply to be valid: %v", err)
+	}
+
+	if _, err := builder.NewReplyInConversation(community.ID(), fields.NullHash(), community.ID(), 1, "wrong depth", []byte{}); err == nil {
+		t.Errorf("Expected error when replying to a community with a nonzero parent depth")
+	}
+	if _, err := builder.NewReplyInConversation(community.ID(), fields.NullHash(), reply.ID(), 3, "no conversation", []byte{}); err == nil {
+		t.Errorf("Expected error when replying deep in a tree without a conversation")
+	}
+}
+
+func TestBuilderContentTooLong(t *testing.T) {
+	identity, signer, community := testutil.MakeCommunityOrSkip(t)
+	builder := forest.As(identity, signer)
+	long := strings.Repeat("a", 70000)
+
+	reply, err := builder.NewReply(community, long, []byte{})
+	if err == nil {
+		t.Fatalf("Expected error building reply with %d bytes of content, got node with content length %d", len(long), reply.Content.Descriptor.Length)
+	} else if !strings.Contains(err.Error(), "70000") || !strings.Contains(err.Error(), "65535") {
+		t.Errorf("Expected error to describe the content length and maximum, got: %v", err)
+	}
+	if _, err := builder.NewReply(community, "short", []byte(long)); err == nil {
+		t.Errorf("Expected error building reply with %d bytes of metadata", len(long))
+	}
+	if _, err := builder.NewCommunity(long, []byte{}); err == nil {
+		t.Errorf("Expected error building community with %d bytes of name", len(long))
+	}
+	if _, err := builder.NewIdentity(long, []byte{}); err == nil {
+		t.Errorf("Expected error building identity with %d bytes of name", len(long))
+	}
+}
+
+func TestBuilderMaxDepth(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	limited := forest.As(identity, signer).WithMaxDepth(3)
+
+	// build a chain down to the limit
+	var parent forest.Node = reply
+	for depth := reply.Depth + 1; depth <= 3; depth++ {
+		next, err := limited.NewReply(parent, "deeper", []byte{})
+		if err != nil {
+			t.Fatalf("Failed to create reply at depth %d within limit: %v", depth, err)
+		}
+		parent = next
+	}
+	if _, err := limited.NewReply(parent, "too deep", []byte{}); !errors.Is(err, forest.ErrTooDeep) {
+		t.Errorf("Expected ErrTooDeep building reply beyond builder limit, got %v", err)
+	}
+	if _, err := limited.NewReplies(parent, []string{"too deep"}, []byte{}); !errors.Is(err, forest.ErrTooDeep) {
+		t.Errorf("Expected ErrTooDeep building replies beyond builder limit, got %v", err)
+	}
+	if _, err := limited.NewReply(community, "shallow", []byte{}); err != nil {
+		t.Errorf("Expected limited builder to build shallow replies: %v", err)
+	}
+
+	// an artificially deep reply at the global limit is valid, but nothing
+	// can be built beneath it
+	builder := forest.As(identity, signer)
+	deepest, err := builder.NewReplyInConversation(&reply.CommunityID, reply.ID(), reply.ID(), forest.MaxTreeDepth-1, "deepest", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create reply at MaxTreeDepth: %v", err)
+	}
+	if err := deepest.ValidateShallow(); err != nil {
+		t.Errorf("Expected reply at MaxTreeDepth to be valid: %v", err)
+	}
+	if _, err := builder.NewReply(deepest, "too deep", []byte{}); !errors.Is(err, forest.ErrTooDeep) {
+		t.Errorf("Expected ErrTooDeep building reply beyond MaxTreeDepth, got %v", err)
+	}
+	if _, err := builder.NewReplyInConversation(&reply.CommunityID, reply.ID(), deepest.ID(), deepest.Depth, "too deep", []byte{}); !errors.Is(err, forest.ErrTooDeep) {
+		t.Errorf("Expected ErrTooDeep building reply in conversation beyond MaxTreeDepth, got %v", err)
+	}
+	deepest.Depth++
+	if err := deepest.ValidateShallow(); !errors.Is(err, forest.ErrTooDeep) {
+		t.Errorf("Expected ErrTooDeep validating reply beyond MaxTreeDepth, got %v", err)
+	}
+}
+
+func TestBuilderDraftReply(t *testing.T) {
+	identity, signer, community := testutil.MakeCommunityOrSkip(t)
+	builder := forest.As(identity, signer)
+	draft, err := builder.DraftReply(community, "draft", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create draft reply: %v", err)
+	}
+	if !draft.IsUnsigned() {
+		t.Errorf("Expected draft to be unsigned")
+	}
+	if err := draft.ValidateShallow(); !errors.Is(err, forest.ErrUnsigned) {
+		t.Errorf("Expected ErrUnsigned validating draft, got %v", err)
+	}
+	if valid, err := forest.ValidateID(draft, *draft.ID()); err != nil || !valid {
+		t.Errorf("Expected draft to have a provisional ID matching its content: %v", err)
+	}
+
+	provisional := *draft.ID()
+	if err := builder.SignDraft(draft); err != nil {
+		t.Fatalf("Failed to sign draft: %v", err)
+	}
+	if draft.IsUnsigned() {
+		t.Errorf("Expected signed draft not to be unsigned")
+	}
+	if draft.ID().Equals(&provisional) {
+		t.Errorf("Expected signing to change the draft's ID")
+	}
+	if err := draft.ValidateShallow(); err != nil {
+		t.Errorf("Expected signed draft to be valid: %v", err)
+	}
+	if valid, err := forest.ValidateSignature(draft, identity); err != nil || !valid {
+		t.Errorf("Expected signed draft to have a valid signature: %v", err)
+	}
+	if valid, err := forest.ValidateID(draft, *draft.ID()); err != nil || !valid {
+		t.Errorf("Expected signed draft to have a valid ID: %v", err)
+	}
+	if err := builder.SignDraft(draft); err == nil {
+		t.Errorf("Expected error signing a reply that is already signed")
+	}
+
+	otherIdentity, otherSigner := testutil.MakeIdentityFromKeyOrSkip(t, testkeys.PrivKey2, "")
+	draft, err = builder.DraftReply(community, "draft", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create draft reply: %v", err)
+	}
+	if err := forest.As(otherIdentity, otherSigner).SignDraft(draft); err == nil {
+		t.Errorf("Expected error signing a draft on behalf of another identity")
+	}
+}
+
+// TestBuilderConcurrentUse is most useful when run with the race detector.
+func TestBuilderConcurrentUse(t *testing.T) {
+	identity, signer, community := testutil.MakeCommunityOrSkip(t)
+	builder := forest.As(identity, signer)
+	const workers, perWorker = 8, 4
+	replies := make(chan *forest.Reply, workers*perWorker)
+	errs := make(chan error, workers*perWorker)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			// alternate between the shared builder and copies of it
+			b := builder
+			if w%2 == 1 {
+				b = builder.WithMaxDepth(forest.MaxTreeDepth)
+			}
+			for i := 0; i < perWorker; i++ {
+				reply, err := b.NewReply(community, fmt.Sprintf("worker %d reply %d", w, i), []byte{})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				replies <- reply
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(replies)
+	close(errs)
+	for err := range errs {
+		t.Errorf("Failed building reply concurrently: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community} {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding node: %v", err)
+		}
+	}
+	count := 0
+	for reply := range replies {
+		count++
+		if err := forest.ValidateDeepCached(reply, s, nil); err != nil {
+			t.Errorf("Expected concurrently built reply to be valid: %v", err)
+		}
+	}
+	if count != workers*perWorker {
+		t.Errorf("Expected %d replies, got %d", workers*perWorker, count)
+	}
+}
+
+func TestBuilderEmptyMetadata(t *testing.T) {
+	identity, signer, community := testutil.MakeCommunityOrSkip(t)
+	created := fields.TimestampFrom(time.Date(2019, 6, 27, 0, 0, 0, 0, time.UTC))
+	builder := forest.As(identity, &fixedSigner{Signer: signer, signatures: make(map[string][]byte)}).WithCreated(created)
+	content := testutil.QualifiedContentOrSkip(t, fields.ContentTypeUTF8String, []byte("content"))
+
+	build := map[string]func(metadata []byte) (forest.Node, error){
+		"identity": func(metadata []byte) (forest.Node, error) {
+			return builder.NewIdentity("test-username", metadata)
+		},
+		"community": func(metadata []byte) (forest.Node, error) {
+			return builder.NewCommunity("test community", metadata)
+		},
+		"reply": func(metadata []byte) (forest.Node, error) {
+			return builder.NewReply(community, "content", metadata)
+		},
+		"qualified reply": func(metadata []byte) (forest.Node, error) {
+			if metadata == nil {
+				return builder.NewReplyQualified(community, content, nil)
+			}
+			return builder.NewReplyQualified(community, content, testutil.QualifiedContentOrSkip(t, fields.ContentTypeTwig, metadata))
+		},
+	}
+	for name, build := range build {
+		withNil, err := build(nil)
+		if err != nil {
+			t.Fatalf("Failed to build %s with nil metadata: %v", name, err)
+		}
+		withEmpty, err := build([]byte{})
+		if err != nil {
+			t.Fatalf("Failed to build %s with empty metadata: %v", name, err)
+		}
+		if !withNil.ID().Equals(withEmpty.ID()) {
+			t.Errorf("Expected %s built with nil and empty metadata to have identical IDs, got %v and %v", name, withNil.ID(), withEmpty.ID())
+		}
+		for _, node := range []forest.Node{withNil, withEmpty} {
+			metadata, err := node.TwigMetadata()
+			if err != nil {
+				t.Errorf("Failed reading metadata of %s: %v", name, err)
+			} else if len(metadata.Values) != 0 {
+				t.Errorf("Expected %s to have no metadata, got %v", name, metadata.Values)
+			}
+		}
+	}
+	if empty := fields.EmptyContent(); empty.Blob == nil || empty.Descriptor.Length != 0 || empty.Descriptor.Type != fields.ContentTypeTwig {
+		t.Errorf("Expected EmptyContent to be zero-length twig content with a non-nil blob, got %v", empty)
+	}
+}
@@ -3,21 +3,30 @@ package main
 import (
 	"encoding"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/grove"
+	"git.sr.ht/~whereswaldon/forest-go/prompt"
+	"git.sr.ht/~whereswaldon/forest-go/store"
 	"git.sr.ht/~whereswaldon/forest-go/twig"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 const (
-	usageError = 1
+	usageError   = 1
+	failureError = 2
 
 	commandIdentity  = "identity"
 	commandCommunity = "community"
@@ -25,18 +34,24 @@ const (
 
 	commandShow   = "show"
 	commandCreate = "create"
+	commandVerify = "verify"
+	commandExport = "export"
+	commandImport = "import"
 )
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintln(os.Stderr, `forest
+		fmt.Fprint(os.Stderr, `forest
 
 A CLI for manipulating nodes in the arbor forest.
 
 Subcommands:
 
 `+commandCreate+" ("+commandIdentity+"|"+commandCommunity+"|"+commandReply+`)
-show <node-id>
+show [-store <grove-dir>] [-format json|jsonl|text] (<node-file>|<node-id>|<short-id>)...
+verify [-store <grove-dir>] <node-id>...
+export -store <grove-dir> [-o <bundle-file>] (<root-node-id>|<short-id>)
+import -store <grove-dir> <bundle-file>
 `)
 		flag.PrintDefaults()
 		os.Exit(usageError)
@@ -51,18 +66,40 @@ show <node-id>
 		cmdHandler = create
 	case commandShow:
 		cmdHandler = show
+	case commandVerify:
+		cmdHandler = verify
+	case commandExport:
+		cmdHandler = export
+	case commandImport:
+		cmdHandler = importBundle
 	default:
 		flag.Usage()
 	}
 	if err := cmdHandler(os.Args[2:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		os.Exit(failureError)
 	}
 }
 
 type handler func(args []string) error
 
+// output formats accepted by the show subcommand
+const (
+	formatJSON      = "json"
+	formatJSONLines = "jsonl"
+	formatText      = "text"
+)
+
 func show(args []string) error {
+	return showTo(os.Stdout, args)
+}
+
+// showTo implements the show subcommand, writing the nodes to w.
+func showTo(w io.Writer, args []string) error {
+	var storeDir, format string
 	flags := flag.NewFlagSet(commandShow, flag.ExitOnError)
+	flags.StringVar(&storeDir, "store", "", "grove directory used to look up the node if given a node id instead of a filename")
+	flags.StringVar(&format, "format", formatJSON, "output format: "+formatJSON+" (indented), "+formatJSONLines+" (one node per line), or "+formatText+" (human-readable summary)")
 	usage := func() {
 		flags.PrintDefaults()
 		os.Exit(usageError)
@@ -74,7 +111,356 @@ func show(args []string) error {
 	if len(flags.Args()) < 1 {
 		usage()
 	}
-	return showNode(args, commandShow, forest.UnmarshalBinaryNode)
+	switch format {
+	case formatJSON, formatJSONLines, formatText:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", format)
+		usage()
+	}
+	for _, arg := range flags.Args() {
+		node, err := loadNode(arg, storeDir)
+		if err != nil {
+			return err
+		}
+		if err := showNode(w, node, format); err != nil {
+			return fmt.Errorf("Error showing %s: %v", arg, err)
+		}
+	}
+	return nil
+}
+
+// loadNode reads the node identified by arg. If storeDir is set and arg is a
+// valid node id, the node is looked up in the grove at storeDir. Otherwise arg
+// is treated as the name of a file containing the node, or (if storeDir is set
+// and there is no such file) as a short node id to resolve within the grove.
+func loadNode(arg, storeDir string) (forest.Node, error) {
+	if storeDir != "" {
+		id, err := fields.ParseQualifiedHash(arg)
+		if err != nil {
+			if _, statErr := os.Stat(arg); statErr == nil {
+				return loadNodeFile(arg)
+			}
+		}
+		g, err := grove.New(storeDir)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening grove: %v", err)
+		}
+		if id == nil {
+			if id, err = store.ResolveShort(g, arg); err != nil {
+				return nil, fmt.Errorf("Error resolving %s: %v", arg, err)
+			}
+		}
+		node, has, err := g.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("Error looking up node %s: %v", id, err)
+		} else if !has {
+			return nil, fmt.Errorf("node %s not found in grove %s", id, storeDir)
+		}
+		return node, nil
+	}
+	return loadNodeFile(arg)
+}
+
+// loadNodeFile reads the node stored in the given file.
+func loadNodeFile(filename string) (forest.Node, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	node, err := forest.UnmarshalBinaryNode(b)
+	if errors.Is(err, forest.ErrEmptyInput) {
+		return nil, fmt.Errorf("%s is empty or not a node", filename)
+	}
+	return node, err
+}
+
+func verify(args []string) error {
+	var storeDir string
+	flags := flag.NewFlagSet(commandVerify, flag.ExitOnError)
+	flags.StringVar(&storeDir, "store", "", "grove directory used to resolve referenced nodes for deep validation")
+	usage := func() {
+		flags.PrintDefaults()
+		os.Exit(usageError)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(flags.Args()) < 1 {
+		usage()
+	}
+	var s forest.Store
+	if storeDir != "" {
+		g, err := grove.New(storeDir)
+		if err != nil {
+			return fmt.Errorf("Error opening grove: %v", err)
+		}
+		s = g
+	}
+	failed := 0
+	for _, filename := range flags.Args() {
+		if err := verifyNode(filename, s); err != nil {
+			fmt.Printf("%s: FAIL: %v\n", filename, err)
+			failed++
+		} else {
+			fmt.Printf("%s: OK\n", filename)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d nodes failed verification", failed, len(flags.Args()))
+	}
+	return nil
+}
+
+// verifyNode checks the validity of the node stored in filename. If s is non-nil,
+// it also checks that all referenced nodes exist in s and that the node is signed
+// by its author within s.
+func verifyNode(filename string, s forest.Store) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	node, err := forest.UnmarshalBinaryNode(b)
+	if errors.Is(err, forest.ErrEmptyInput) {
+		return fmt.Errorf("file is empty or not a node")
+	} else if err != nil {
+		return fmt.Errorf("failed parsing node: %v", err)
+	}
+	if err := node.ValidateShallow(); err != nil {
+		return fmt.Errorf("invalid node: %v", err)
+	}
+	if hashable, ok := node.(forest.Hashable); !ok {
+		return fmt.Errorf("node of type %T cannot be hashed", node)
+	} else if valid, err := forest.ValidateID(hashable, *node.ID()); err != nil {
+		return fmt.Errorf("failed validating id: %v", err)
+	} else if !valid {
+		return fmt.Errorf("id does not match node content")
+	}
+	var author *forest.Identity
+	if identity, ok := node.(*forest.Identity); ok {
+		author = identity
+	} else if s != nil {
+		if err := node.ValidateDeep(s); err != nil {
+			return fmt.Errorf("invalid references: %v", err)
+		}
+		authorNode, has, err := s.GetIdentity(node.AuthorID())
+		if err != nil {
+			return fmt.Errorf("failed looking up author: %v", err)
+		} else if !has {
+			return fmt.Errorf("author %s not found in store", node.AuthorID())
+		}
+		if author, ok = authorNode.(*forest.Identity); !ok {
+			return fmt.Errorf("author %s is not an identity", node.AuthorID())
+		}
+	}
+	if author != nil {
+		if valid, err := forest.ValidateSignature(node.(forest.SignatureValidator), author); err != nil {
+			return fmt.Errorf("invalid signature: %v", err)
+		} else if !valid {
+			return fmt.Errorf("invalid signature")
+		}
+	}
+	return nil
+}
+
+func export(args []string) error {
+	var storeDir, outfile string
+	flags := flag.NewFlagSet(commandExport, flag.ExitOnError)
+	flags.StringVar(&storeDir, "store", "", "[required] grove directory containing the subtree to export")
+	flags.StringVar(&outfile, "o", "bundle.forest", "file to write the node bundle to")
+	usage := func() {
+		flags.PrintDefaults()
+		os.Exit(usageError)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if storeDir == "" || len(flags.Args()) != 1 {
+		usage()
+	}
+	g, err := grove.New(storeDir)
+	if err != nil {
+		return fmt.Errorf("Error opening grove: %v", err)
+	}
+	root, err := fields.ParseQualifiedHash(flags.Arg(0))
+	if err != nil {
+		if root, err = store.ResolveShort(g, flags.Arg(0)); err != nil {
+			return fmt.Errorf("Error resolving root node id: %v", err)
+		}
+	}
+	nodes, err := collectBundle(g, root)
+	if err != nil {
+		return fmt.Errorf("Error collecting nodes: %v", err)
+	}
+	out, err := os.Create(outfile)
+	if err != nil {
+		return fmt.Errorf("Error creating bundle: %v", err)
+	}
+	defer out.Close()
+	if err := forest.WriteNodes(out, nodes); err != nil {
+		return fmt.Errorf("Error writing bundle: %v", err)
+	}
+	fmt.Printf("exported %d nodes to %s\n", len(nodes), outfile)
+	return nil
+}
+
+// collectBundle returns every node in the subtree rooted at root along with
+// every other node needed to validate them: the ancestors of root, the
+// communities of the replies, and the identities that authored any of these.
+// Identities come first, then communities, then the ancestors of root from
+// the top down, then the subtree in breadth-first order, so that every node
+// appears after the nodes needed to validate it.
+func collectBundle(s forest.Store, root *fields.QualifiedHash) ([]forest.Node, error) {
+	var (
+		subtree, ancestors, authors, communities []forest.Node
+		seen                                     = make(map[string]struct{})
+	)
+	// include adds the node with the given id to the list if it has not
+	// already been added. It ignores the null hash used by root nodes.
+	include := func(id *fields.QualifiedHash, list *[]forest.Node) error {
+		if id.Equals(fields.NullHash()) {
+			return nil
+		}
+		if _, ok := seen[id.String()]; ok {
+			return nil
+		}
+		node, has, err := s.Get(id)
+		if err != nil {
+			return fmt.Errorf("failed looking up %s: %w", id, err)
+		} else if !has {
+			return fmt.Errorf("node %s not found in store", id)
+		}
+		seen[id.String()] = struct{}{}
+		*list = append(*list, node)
+		return nil
+	}
+	// includeReferences adds the author and community of node.
+	includeReferences := func(node forest.Node) error {
+		if err := include(node.AuthorID(), &authors); err != nil {
+			return err
+		}
+		if reply, ok := node.(*forest.Reply); ok {
+			if err := include(&reply.CommunityID, &communities); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := store.Walk(s, root, func(id *fields.QualifiedHash) error {
+		if err := include(id, &subtree); err != nil {
+			return err
+		}
+		return includeReferences(subtree[len(subtree)-1])
+	}); err != nil {
+		return nil, err
+	}
+	if reply, ok := subtree[0].(*forest.Reply); ok {
+		// walk up from root to its community, collecting its ancestors from
+		// the bottom up
+		for parentID := reply.ParentID(); !parentID.Equals(&reply.CommunityID) && !parentID.Equals(fields.NullHash()); {
+			if err := include(parentID, &ancestors); err != nil {
+				return nil, err
+			}
+			parent := ancestors[len(ancestors)-1]
+			if err := includeReferences(parent); err != nil {
+				return nil, err
+			}
+			parentID = parent.ParentID()
+		}
+	}
+	for _, community := range communities {
+		if err := include(community.AuthorID(), &authors); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+		ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+	}
+	bundle := append(authors, communities...)
+	bundle = append(bundle, ancestors...)
+	return append(bundle, subtree...), nil
+}
+
+func importBundle(args []string) error {
+	var storeDir string
+	flags := flag.NewFlagSet(commandImport, flag.ExitOnError)
+	flags.StringVar(&storeDir, "store", "", "[required] grove directory to import the nodes into")
+	usage := func() {
+		flags.PrintDefaults()
+		os.Exit(usageError)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if storeDir == "" || len(flags.Args()) != 1 {
+		usage()
+	}
+	g, err := grove.New(storeDir)
+	if err != nil {
+		return fmt.Errorf("Error opening grove: %v", err)
+	}
+	in, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("Error opening bundle: %v", err)
+	}
+	defer in.Close()
+	nodes, err := forest.ReadNodes(in)
+	if err != nil {
+		return fmt.Errorf("Error reading bundle: %v", err)
+	}
+	// identities must be inserted first so that the signatures of the other
+	// nodes can be checked against the store
+	sort.SliceStable(nodes, func(i, j int) bool {
+		_, iIsIdentity := nodes[i].(*forest.Identity)
+		_, jIsIdentity := nodes[j].(*forest.Identity)
+		return iIsIdentity && !jIsIdentity
+	})
+	for _, node := range nodes {
+		if err := validateForImport(node, g); err != nil {
+			return fmt.Errorf("Error validating node %s: %v", node.ID(), err)
+		}
+		if err := g.Add(node); err != nil {
+			return fmt.Errorf("Error adding node %s: %v", node.ID(), err)
+		}
+	}
+	fmt.Printf("imported %d nodes into %s\n", len(nodes), storeDir)
+	return nil
+}
+
+// validateForImport checks the node's fields, its ID, its signature by its
+// author, and its references to other nodes, all of which must already be
+// present in s.
+func validateForImport(node forest.Node, s forest.Store) error {
+	if err := node.ValidateShallow(); err != nil {
+		return fmt.Errorf("invalid node: %v", err)
+	}
+	if hashable, ok := node.(forest.Hashable); !ok {
+		return fmt.Errorf("node of type %T cannot be hashed", node)
+	} else if valid, err := forest.ValidateID(hashable, *node.ID()); err != nil {
+		return fmt.Errorf("failed validating id: %v", err)
+	} else if !valid {
+		return fmt.Errorf("id does not match node content")
+	}
+	author, ok := node.(*forest.Identity)
+	if !ok {
+		authorNode, has, err := s.GetIdentity(node.AuthorID())
+		if err != nil {
+			return fmt.Errorf("failed looking up author: %v", err)
+		} else if !has {
+			return fmt.Errorf("author %s not found", node.AuthorID())
+		}
+		if author, ok = authorNode.(*forest.Identity); !ok {
+			return fmt.Errorf("author %s is not an identity", node.AuthorID())
+		}
+	}
+	if valid, err := forest.ValidateSignature(node.(forest.SignatureValidator), author); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	} else if !valid {
+		return fmt.Errorf("invalid signature")
+	}
+	if err := node.ValidateDeep(s); err != nil {
+		return fmt.Errorf("invalid references: %v", err)
+	}
+	return nil
 }
 
 func create(args []string) error {
@@ -141,13 +527,16 @@ func decodeMetadata(input string) ([]byte, error) {
 
 func createIdentity(args []string) error {
 	var (
-		name, keyfile, gpguser, metadata string
+		name, keyfile, gpguser, gpgBinary, metadata string
+		dryRun                                      bool
 	)
 	flags := flag.NewFlagSet(commandCreate+" "+commandIdentity, flag.ExitOnError)
 	flags.StringVar(&name, "name", "forest", "username for the identity node")
-	flags.StringVar(&keyfile, "key", "arbor.privkey", "the openpgp private key for the identity node")
+	flags.StringVar(&keyfile, "key", "arbor.privkey", "the openpgp private key for the identity node (prompts for a passphrase if encrypted)")
 	flags.StringVar(&gpguser, "gpguser", "", "gpg2 user whose private key should be used to create this node. Supercedes -key.")
+	flags.StringVar(&gpgBinary, "gpg-binary", "", "gpg executable to run for -gpguser (found automatically if empty)")
 	flags.StringVar(&metadata, "metadata", "{}", "Twig metadata fields for the node: {\"<key>/<version>\": \"data\",...}")
+	flags.BoolVar(&dryRun, "dry-run", false, "validate the node and print it as JSON instead of saving it")
 
 	usage := func() {
 		flags.PrintDefaults()
@@ -156,7 +545,7 @@ func createIdentity(args []string) error {
 		usage()
 		return fmt.Errorf("Error parsing arguments: %v", err)
 	}
-	signer, err := getSigner(gpguser, keyfile)
+	signer, err := getSigner(gpguser, gpgBinary, keyfile)
 	if err != nil {
 		return fmt.Errorf("Error getting signer: %v", err)
 	}
@@ -171,7 +560,11 @@ func createIdentity(args []string) error {
 		return fmt.Errorf("Error creating identity: %v", err)
 	}
 
-	fname, err := identity.ID().MarshalString()
+	if dryRun {
+		return printValidated(identity, identity)
+	}
+
+	fname, err := forest.NodeFilename(identity)
 	if err != nil {
 		return fmt.Errorf("Error marshalling identity: %v", err)
 	}
@@ -187,14 +580,17 @@ func createIdentity(args []string) error {
 
 func createCommunity(args []string) error {
 	var (
-		name, keyfile, identity, gpguser, metadata string
+		name, keyfile, identity, gpguser, gpgBinary, metadata string
+		dryRun                                                bool
 	)
 	flags := flag.NewFlagSet(commandCreate+" "+commandCommunity, flag.ExitOnError)
 	flags.StringVar(&name, "name", "forest", "username for the community node")
-	flags.StringVar(&keyfile, "key", "arbor.privkey", "the openpgp private key for the signing identity node")
+	flags.StringVar(&keyfile, "key", "arbor.privkey", "the openpgp private key for the signing identity node (prompts for a passphrase if encrypted)")
 	flags.StringVar(&identity, "as", "", "[required] the id of the signing identity node")
 	flags.StringVar(&gpguser, "gpguser", "", "gpg2 user whose private key should be used to create this node. Supercedes -key.")
+	flags.StringVar(&gpgBinary, "gpg-binary", "", "gpg executable to run for -gpguser (found automatically if empty)")
 	flags.StringVar(&metadata, "metadata", "{}", "Twig metadata fields for the node: {\"<key>/<version>\": \"data\",...}")
+	flags.BoolVar(&dryRun, "dry-run", false, "validate the node and print it as JSON instead of saving it")
 	usage := func() {
 		flags.PrintDefaults()
 	}
@@ -202,7 +598,7 @@ func createCommunity(args []string) error {
 		usage()
 		return fmt.Errorf("Error parsing arguments: %v", err)
 	}
-	signer, err := getSigner(gpguser, keyfile)
+	signer, err := getSigner(gpguser, gpgBinary, keyfile)
 	if err != nil {
 		return fmt.Errorf("Error getting signer: %v", err)
 	}
@@ -220,7 +616,11 @@ func createCommunity(args []string) error {
 		return fmt.Errorf("Error creating community: %v", err)
 	}
 
-	fname, err := community.ID().MarshalString()
+	if dryRun {
+		return printValidated(community, idNode)
+	}
+
+	fname, err := forest.NodeFilename(community)
 	if err != nil {
 		return fmt.Errorf("Error marshalling community: %v", err)
 	}
@@ -236,15 +636,18 @@ func createCommunity(args []string) error {
 
 func createReply(args []string) error {
 	var (
-		content, parent, keyfile, identity, gpguser, metadata string
+		content, parent, keyfile, identity, gpguser, gpgBinary, metadata string
+		dryRun                                                           bool
 	)
 	flags := flag.NewFlagSet(commandCreate+" "+commandReply, flag.ExitOnError)
-	flags.StringVar(&keyfile, "key", "arbor.privkey", "the openpgp private key for the signing identity node")
+	flags.StringVar(&keyfile, "key", "arbor.privkey", "the openpgp private key for the signing identity node (prompts for a passphrase if encrypted)")
 	flags.StringVar(&gpguser, "gpguser", "", "gpg2 user whose private key should be used to create this node. Supercedes -key.")
+	flags.StringVar(&gpgBinary, "gpg-binary", "", "gpg executable to run for -gpguser (found automatically if empty)")
 	flags.StringVar(&identity, "as", "", "[required] the id of the signing identity node")
 	flags.StringVar(&parent, "to", "", "[required] the id of the parent reply or community node")
 	flags.StringVar(&content, "content", "", "[required] content of the reply node")
 	flags.StringVar(&metadata, "metadata", "{}", "Twig metadata fields for the node: {\"<key>/<version>\": \"data\",...}")
+	flags.BoolVar(&dryRun, "dry-run", false, "validate the node and print it as JSON instead of saving it")
 
 	usage := func() {
 		flags.PrintDefaults()
@@ -254,7 +657,7 @@ func createReply(args []string) error {
 		return err
 	}
 
-	signer, err := getSigner(gpguser, keyfile)
+	signer, err := getSigner(gpguser, gpgBinary, keyfile)
 	if err != nil {
 		return fmt.Errorf("Error getting signer: %v", err)
 	}
@@ -278,7 +681,11 @@ func createReply(args []string) error {
 		return fmt.Errorf("Error during creating new reply: %v", err)
 	}
 
-	fname, err := reply.ID().MarshalString()
+	if dryRun {
+		return printValidated(reply, idNode)
+	}
+
+	fname, err := forest.NodeFilename(reply)
 	if err != nil {
 		return fmt.Errorf("Error marshalling reply.ID: %v", err)
 	}
@@ -292,36 +699,99 @@ func createReply(args []string) error {
 	return nil
 }
 
-func showNode(args []string, commandName string, fromBytes func([]byte) (forest.Node, error)) error {
-	flags := flag.NewFlagSet(commandName+" "+commandShow, flag.ExitOnError)
-	usage := func() {
-		flags.PrintDefaults()
-	}
-	if err := flags.Parse(args); err != nil {
-		usage()
+// showNode validates the node's fields and writes it to w in the given format.
+// Every format ends each node with a newline, so that the output of several
+// nodes can be concatenated.
+func showNode(w io.Writer, node forest.Node, format string) error {
+	if err := node.ValidateShallow(); err != nil {
 		return err
 	}
-	if len(flags.Args()) < 1 {
-		return fmt.Errorf("missing required argument [node id]")
-	}
-	b, err := ioutil.ReadFile(args[0])
-	if err != nil && err != io.EOF {
-		return err
+	var (
+		text []byte
+		err  error
+	)
+	switch format {
+	case formatJSON:
+		text, err = json.MarshalIndent(node, "", "  ")
+	case formatJSONLines:
+		text, err = json.Marshal(node)
+	case formatText:
+		return summarizeNode(w, node)
+	default:
+		return fmt.Errorf("unknown format %q", format)
 	}
-	c, err := fromBytes(b)
 	if err != nil {
 		return err
 	}
-	if err := c.ValidateShallow(); err != nil {
-		return err
+	_, err = fmt.Fprintln(w, string(text))
+	return err
+}
+
+// summarizeNode writes a short human-readable description of the node to w,
+// followed by a blank line.
+func summarizeNode(w io.Writer, node forest.Node) error {
+	var (
+		nodeType fields.NodeType
+		label    string
+		value    fields.QualifiedContent
+	)
+	switch n := node.(type) {
+	case *forest.Identity:
+		nodeType, label, value = n.Type, "name", n.Name
+	case *forest.Community:
+		nodeType, label, value = n.Type, "name", n.Name
+	case *forest.Reply:
+		nodeType, label, value = n.Type, "content", n.Content
+	default:
+		return fmt.Errorf("cannot summarize node of type %T", node)
+	}
+	field := func(name, value string) string {
+		return fmt.Sprintf("  %-9s %s", name+":", value)
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s", fields.NodeTypeNames[nodeType], node.ID()),
+		field("created", node.CreatedAt().UTC().Format(time.RFC3339)),
+		field("author", node.AuthorID().String()),
+		field("parent", fmt.Sprintf("%s (depth %d)", node.ParentID(), node.TreeDepth())),
+		field(label, fmt.Sprintf("%q", value.Blob)),
+	}
+	if metadata, err := node.TwigMetadata(); err == nil && len(metadata.Values) > 0 {
+		keys := make([]string, 0, len(metadata.Values))
+		for key := range metadata.Values {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		lines = append(lines, field("metadata", strings.Join(keys, ", ")))
 	}
-	text, err := json.Marshal(c)
-	if err != nil {
-		return err
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
 	}
-	if _, err := os.Stdout.Write(text); err != nil {
-		return err
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// printValidated validates the node's fields and its signature by author, then
+// writes it to stdout as JSON.
+func printValidated(node forest.Node, author *forest.Identity) error {
+	if err := node.ValidateShallow(); err != nil {
+		return fmt.Errorf("Error validating node: %v", err)
+	}
+	validator, ok := node.(forest.SignatureValidator)
+	if !ok {
+		return fmt.Errorf("Error validating node: %T has no signature", node)
+	}
+	if valid, err := forest.ValidateSignature(validator, author); err != nil {
+		return fmt.Errorf("Error validating signature: %v", err)
+	} else if !valid {
+		return fmt.Errorf("Error validating signature: signature is invalid")
+	}
+	text, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("Error marshalling node: %v", err)
 	}
+	fmt.Println(string(text))
 	return nil
 }
 
@@ -416,15 +886,17 @@ type PGPKeyConfig struct {
 }
 
 // getSigner returns a Signer. If the gpguser parameter is not the empty string, it
-// uses a GPGSigner with that username. Otherwise, it uses a NativeSigner with the
-// given privkeyFile as the source of the private key.
-func getSigner(gpguser, privkeyFile string) (forest.Signer, error) {
+// uses a GPGSigner with that username, running gpgBinary if it is not empty.
+// Otherwise, it uses a NativeSigner with the
+// given privkeyFile as the source of the private key, prompting for a passphrase
+// if the key is encrypted.
+func getSigner(gpguser, gpgBinary, privkeyFile string) (forest.Signer, error) {
 	var (
 		signer forest.Signer
 		err    error
 	)
 	if gpguser != "" {
-		signer, err = forest.NewGPGSigner(gpguser)
+		signer, err = forest.NewGPGSignerWithOptions(gpguser, forest.GPGSignerOptions{GPGBinary: gpgBinary})
 	} else {
 		privkey, err := getPrivateKey(privkeyFile, &PGPKeyConfig{
 			Name:    "Arbor identity key",
@@ -434,11 +906,50 @@ func getSigner(gpguser, privkeyFile string) (forest.Signer, error) {
 		if err != nil {
 			return nil, err
 		}
+		if privkey.PrivateKey != nil && privkey.PrivateKey.Encrypted {
+			return getEncryptedSigner(privkeyFile)
+		}
 		return forest.NewNativeSigner(privkey)
 	}
 	return signer, err
 }
 
+// getEncryptedSigner returns a NativeSigner using the passphrase-protected
+// private key in privkeyFile, prompting for the passphrase. The key is only
+// decrypted in memory; the file is left as it is.
+func getEncryptedSigner(privkeyFile string) (forest.Signer, error) {
+	if privkeyFile == "-" {
+		return nil, fmt.Errorf("Cannot read an encrypted key from stdin, since the passphrase is read from stdin")
+	}
+	keyFile, err := os.Open(privkeyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer keyFile.Close()
+	passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for %s: ", privkeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading passphrase: %v", err)
+	}
+	return forest.NewNativeSignerFromEncryptedKey(keyFile, passphrase)
+}
+
+// readPassphrase prompts for a passphrase and reads it from stdin. If stdin is
+// a terminal, the passphrase is not echoed. Otherwise it is read as a line of
+// input, so that it can be piped in.
+func readPassphrase(promptText string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		// prompt on stderr so that it doesn't mix with the node ID printed to stdout
+		passphrase, err := prompt.New(os.Stdin, os.Stderr).PromptLine(promptText)
+		return []byte(passphrase), err
+	}
+	fmt.Fprint(os.Stderr, promptText)
+	passphrase, err := terminal.ReadPassword(fd)
+	// the newline typed after the passphrase was not echoed either
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
 // getPrivateKey gets a private key for creating the identity based on the value
 // of filename. If filename is:
 // "-" => read a private key from stdin, do not write private key to a file
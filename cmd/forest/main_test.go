@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/grove"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	// the community and the replies have different authors, so the bundle
+	// must carry both
+	owner, _, community := testutil.MakeCommunityOrSkip(t)
+	author, authorSigner := testutil.MakeIdentityFromKeyOrSkip(t, testkeys.PrivKey2, "")
+	builder := forest.As(author, authorSigner)
+	conversation, err := builder.NewReply(community, "conversation", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	root, err := builder.NewReply(conversation, "root", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	child, err := builder.NewReply(root, "child", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	sibling, err := builder.NewReply(conversation, "sibling", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+
+	sourceDir, destDir := t.TempDir(), t.TempDir()
+	source, err := grove.New(sourceDir)
+	if err != nil {
+		t.Fatalf("Failed opening grove: %v", err)
+	}
+	for _, node := range []forest.Node{owner, author, community, conversation, root, child, sibling} {
+		if err := source.Add(node); err != nil {
+			t.Fatalf("Failed adding %v to grove: %v", node.ID(), err)
+		}
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.forest")
+	if err := export([]string{"-store", sourceDir, "-o", bundle, root.ID().String()}); err != nil {
+		t.Fatalf("Failed exporting depth-%d reply: %v", root.Depth, err)
+	}
+	if err := importBundle([]string{"-store", destDir, bundle}); err != nil {
+		t.Fatalf("Failed importing bundle: %v", err)
+	}
+
+	dest, err := grove.New(destDir)
+	if err != nil {
+		t.Fatalf("Failed opening grove: %v", err)
+	}
+	for _, node := range []forest.Node{owner, author, community, conversation, root, child} {
+		if has, err := dest.Has(node.ID()); err != nil || !has {
+			t.Errorf("Expected imported grove to contain %v, got %v %v", node.ID(), has, err)
+		}
+	}
+	if has, err := dest.Has(sibling.ID()); err != nil || has {
+		t.Errorf("Expected imported grove not to contain %v outside the subtree, got %v %v", sibling.ID(), has, err)
+	}
+}
+
+func TestShowFormats(t *testing.T) {
+	_, _, community, reply := testutil.MakeReplyOrSkip(t)
+	dir := t.TempDir()
+	communityFile, replyFile := filepath.Join(dir, "community"), filepath.Join(dir, "reply")
+	for name, node := range map[string]forest.Node{communityFile: community, replyFile: reply} {
+		if err := saveAs(name, node); err != nil {
+			t.Skipf("Failed saving node to %s: %v", name, err)
+		}
+	}
+	nodes := []forest.Node{community, reply}
+
+	t.Run(formatJSON, func(t *testing.T) {
+		var out bytes.Buffer
+		if err := showTo(&out, []string{communityFile, replyFile}); err != nil {
+			t.Fatalf("Failed showing nodes: %v", err)
+		}
+		var expected bytes.Buffer
+		for _, node := range nodes {
+			text, err := json.MarshalIndent(node, "", "  ")
+			if err != nil {
+				t.Skipf("Failed marshalling %v: %v", node.ID(), err)
+			}
+			expected.Write(text)
+			expected.WriteString("\n")
+		}
+		if out.String() != expected.String() {
+			t.Errorf("Expected indented JSON ending in a newline for each node, got:\n%s", out.String())
+		}
+	})
+
+	t.Run(formatJSONLines, func(t *testing.T) {
+		var out bytes.Buffer
+		if err := showTo(&out, []string{"-format", formatJSONLines, communityFile, replyFile}); err != nil {
+			t.Fatalf("Failed showing nodes: %v", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != len(nodes) {
+			t.Fatalf("Expected %d lines, got %d:\n%s", len(nodes), len(lines), out.String())
+		}
+		for i, line := range lines {
+			node, err := forest.UnmarshalJSONNode([]byte(line))
+			if err != nil {
+				t.Errorf("Failed parsing line %d as a node: %v", i, err)
+			} else if !node.Equals(nodes[i]) {
+				t.Errorf("Expected line %d to hold %v, got %v", i, nodes[i].ID(), node.ID())
+			}
+		}
+	})
+
+	t.Run(formatText, func(t *testing.T) {
+		var out bytes.Buffer
+		if err := showTo(&out, []string{"-format", formatText, communityFile, replyFile}); err != nil {
+			t.Fatalf("Failed showing nodes: %v", err)
+		}
+		summaries := strings.Split(strings.TrimSuffix(out.String(), "\n\n"), "\n\n")
+		if len(summaries) != len(nodes) {
+			t.Fatalf("Expected %d summaries separated by blank lines, got %d:\n%s", len(nodes), len(summaries), out.String())
+		}
+		for i, expected := range []string{
+			"community " + community.ID().String(),
+			"reply " + reply.ID().String(),
+		} {
+			if !strings.HasPrefix(summaries[i], expected+"\n") {
+				t.Errorf("Expected summary %d to begin with %q, got:\n%s", i, expected, summaries[i])
+			}
+		}
+		for _, expected := range []string{
+			"  author:   " + reply.AuthorID().String(),
+			"  parent:   " + reply.ParentID().String() + " (depth 1)",
+			"  content:  " + fmt.Sprintf("%q", reply.Content.Blob),
+		} {
+			if !strings.Contains(summaries[1]+"\n", expected+"\n") {
+				t.Errorf("Expected reply summary to contain %q, got:\n%s", expected, summaries[1])
+			}
+		}
+	})
+
+	t.Run("store", func(t *testing.T) {
+		storeDir := t.TempDir()
+		g, err := grove.New(storeDir)
+		if err != nil {
+			t.Fatalf("Failed opening grove: %v", err)
+		}
+		if err := g.Add(reply); err != nil {
+			t.Fatalf("Failed adding %v to grove: %v", reply.ID(), err)
+		}
+		var out bytes.Buffer
+		if err := showTo(&out, []string{"-store", storeDir, "-format", formatJSONLines, communityFile, reply.ID().String()}); err != nil {
+			t.Fatalf("Failed showing nodes: %v", err)
+		}
+		if lines := strings.Count(out.String(), "\n"); lines != len(nodes) {
+			t.Errorf("Expected a file and an ID to produce %d lines, got %d:\n%s", len(nodes), lines, out.String())
+		}
+	})
+}
@@ -20,3 +20,14 @@ reply3=$("$forest_cmd" create reply --as "$identity" --to "$reply1" --content "t
 "$forest_cmd" show "$reply1"
 "$forest_cmd" show "$reply2"
 "$forest_cmd" show "$reply3"
+"$forest_cmd" show -format jsonl "$identity" "$community" "$reply1"
+"$forest_cmd" show -format text "$reply3"
+
+"$forest_cmd" verify "$identity" "$community" "$reply1" "$reply2" "$reply3"
+"$forest_cmd" verify -store . "$identity" "$community" "$reply1" "$reply2" "$reply3"
+
+"$forest_cmd" export -store . -o bundle.forest "$reply1"
+mkdir imported
+"$forest_cmd" import -store imported bundle.forest
+"$forest_cmd" verify -store imported imported/*
+"$forest_cmd" show -store imported "$reply1"
@@ -0,0 +1,190 @@
+package forest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/serialize"
+	"git.sr.ht/~whereswaldon/forest-go/twig"
+)
+
+/*
+Countersignatures
+
+A node can be countersigned by identities other than its author, for instance
+when several moderators want to endorse a community. The countersignature
+cannot live in the twig metadata of the countersigned node itself, because the
+metadata is covered by the node's signature and ID: adding to it would produce
+a different node. Instead, each countersignature is a reply to the
+countersigned node, authored by the countersigner, whose twig metadata holds
+two values:
+
+    countersig/1    base64 of a signature by the countersigner's key over the
+                    ID of the countersigned node
+    countersigner/1 the text form of the countersigner's ID
+
+The countersigned node is unchanged, so stores that know nothing about
+countersignatures still accept it (and the countersignatures, which are
+ordinary replies). Because the ID of a node is a hash that covers its
+signature, a countersignature over the ID endorses the node exactly as its
+author signed it. VerifyCountersignatures finds and checks them.
+*/
+
+const (
+	// CountersigKeyName and CountersigKeyVersion identify the twig metadata
+	// key holding a countersignature.
+	CountersigKeyName    = "countersig"
+	CountersigKeyVersion = 1
+
+	// CountersignerKeyName and CountersignerKeyVersion identify the twig
+	// metadata key holding the ID of the identity that made a
+	// countersignature.
+	CountersignerKeyName    = "countersigner"
+	CountersignerKeyVersion = 1
+)
+
+// countersignPrefix distinguishes the data signed to countersign a node from
+// the signed data of any node or key rotation, so that none of them can be
+// replayed as another.
+const countersignPrefix = "arbor countersignature\x00"
+
+// countersignContent is the content of every countersignature reply. Clients
+// should recognize countersignatures by their metadata, not their content.
+const countersignContent = "countersigned"
+
+// countersignSignedData returns the data that a countersigner signs in order
+// to countersign the node with the given ID.
+func countersignSignedData(target *fields.QualifiedHash) ([]byte, error) {
+	b, err := serialize.ArborSerialize(reflect.ValueOf(target))
+	if err != nil {
+		return nil, fmt.Errorf("failed serializing countersigned id: %w", err)
+	}
+	return append([]byte(countersignPrefix), b...), nil
+}
+
+// AddCountersignature creates a reply to target, authored and signed by the
+// Builder's user, that countersigns target. The target must be a community or
+// a reply. The target itself is not modified; add the returned reply to a
+// Store alongside it so that VerifyCountersignatures can find it.
+func (n *Builder) AddCountersignature(target Node) (*Reply, error) {
+	signedData, err := countersignSignedData(target.ID())
+	if err != nil {
+		return nil, err
+	}
+	countersig, err := n.Signer.Sign(signedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed countersigning %s: %w", target.ID(), err)
+	}
+	metadata := twig.New()
+	if _, err := metadata.Set(CountersigKeyName, CountersigKeyVersion, []byte(base64.StdEncoding.EncodeToString(countersig))); err != nil {
+		return nil, fmt.Errorf("failed setting %s metadata: %w", CountersigKeyName, err)
+	}
+	if _, err := metadata.Set(CountersignerKeyName, CountersignerKeyVersion, []byte(n.User.ID().String())); err != nil {
+		return nil, fmt.Errorf("failed setting %s metadata: %w", CountersignerKeyName, err)
+	}
+	metadataBytes, err := metadata.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling metadata: %w", err)
+	}
+	return n.NewReply(target, countersignContent, metadataBytes)
+}
+
+// VerifyCountersignatures returns the IDs of the identities that have validly
+// countersigned n, in sorted order and without duplicates. It considers every
+// child of n in s that carries countersignature metadata. A countersignature
+// is valid if its reply was authored and signed by the identity that it
+// names as the countersigner, and that identity's key signed n's ID.
+// Countersignatures that are invalid or whose countersigner is not in s are
+// ignored; an error is only returned if s cannot be read.
+func VerifyCountersignatures(n Node, s Store) ([]*fields.QualifiedHash, error) {
+	signedData, err := countersignSignedData(n.ID())
+	if err != nil {
+		return nil, err
+	}
+	childIDs, err := s.Children(n.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed listing children of %s: %w", n.ID(), err)
+	}
+	seen := make(map[string]*fields.QualifiedHash)
+	for _, childID := range childIDs {
+		child, has, err := s.Get(childID)
+		if err != nil {
+			return nil, fmt.Errorf("failed getting child %s: %w", childID, err)
+		} else if !has {
+			continue
+		}
+		reply, ok := child.(*Reply)
+		if !ok || !reply.Parent.Equals(n.ID()) {
+			continue
+		}
+		countersigner, err := verifyCountersignature(reply, signedData, s)
+		if err != nil {
+			return nil, err
+		} else if countersigner != nil {
+			seen[countersigner.String()] = countersigner
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	countersigners := make([]*fields.QualifiedHash, 0, len(keys))
+	for _, key := range keys {
+		countersigners = append(countersigners, seen[key])
+	}
+	return countersigners, nil
+}
+
+// verifyCountersignature returns the ID of the identity that countersigned
+// signedData in reply, or nil if reply is not a valid countersignature. An
+// error is only returned if the countersigner cannot be looked up in s.
+func verifyCountersignature(reply *Reply, signedData []byte, s Store) (*fields.QualifiedHash, error) {
+	metadata, err := reply.TwigMetadata()
+	if err != nil {
+		return nil, nil
+	}
+	encodedCountersig, hasCountersig := metadata.Get(CountersigKeyName, CountersigKeyVersion)
+	countersignerText, hasCountersigner := metadata.Get(CountersignerKeyName, CountersignerKeyVersion)
+	if !hasCountersig || !hasCountersigner {
+		return nil, nil
+	}
+	countersigner := &fields.QualifiedHash{}
+	if err := countersigner.UnmarshalText(countersignerText); err != nil {
+		return nil, nil
+	}
+	// a countersignature must be made by the reply's own author, otherwise
+	// anyone could copy it into a reply of their own
+	if !countersigner.Equals(reply.AuthorID()) {
+		return nil, nil
+	}
+	node, has, err := s.GetIdentity(countersigner)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting countersigner %s: %w", countersigner, err)
+	} else if !has {
+		return nil, nil
+	}
+	identity, ok := node.(*Identity)
+	if !ok {
+		return nil, nil
+	}
+	if valid, err := ValidateSignature(reply, identity); err != nil || !valid {
+		return nil, nil
+	}
+	countersigBytes, err := base64.StdEncoding.DecodeString(string(encodedCountersig))
+	if err != nil {
+		return nil, nil
+	}
+	// the countersignature was made by the same key that signed the identity
+	countersig, err := fields.NewQualifiedSignature(identity.Signature.Descriptor.Type, countersigBytes)
+	if err != nil {
+		return nil, nil
+	}
+	if valid, err := countersig.Verify(signedData, &identity.PublicKey); err != nil || !valid {
+		return nil, nil
+	}
+	return countersigner, nil
+}
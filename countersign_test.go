@@ -0,0 +1,80 @@
+package forest_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestCountersignature(t *testing.T) {
+	author, _, community := testutil.MakeCommunityOrSkip(t)
+	moderator, moderatorSigner := testutil.MakeIdentityFromKeyOrSkip(t, testkeys.PrivKey2, "")
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{author, moderator, community} {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding %s to store: %v", node.ID(), err)
+		}
+	}
+	if countersigners, err := forest.VerifyCountersignatures(community, s); err != nil {
+		t.Fatalf("Failed verifying countersignatures: %v", err)
+	} else if len(countersigners) != 0 {
+		t.Errorf("Expected no countersigners before countersigning, got %v", countersigners)
+	}
+
+	id := community.ID().String()
+	countersignature, err := forest.As(moderator, moderatorSigner).AddCountersignature(community)
+	if err != nil {
+		t.Fatalf("Failed countersigning community: %v", err)
+	}
+	if community.ID().String() != id {
+		t.Errorf("Expected countersigning not to change the community's ID")
+	}
+	if err := countersignature.ValidateShallow(); err != nil {
+		t.Errorf("Expected countersignature to be a valid reply: %v", err)
+	}
+	if err := s.Add(countersignature); err != nil {
+		t.Fatalf("Failed adding countersignature to store: %v", err)
+	}
+	countersigners, err := forest.VerifyCountersignatures(community, s)
+	if err != nil {
+		t.Fatalf("Failed verifying countersignatures: %v", err)
+	}
+	if len(countersigners) != 1 || !countersigners[0].Equals(moderator.ID()) {
+		t.Errorf("Expected only %s to have countersigned, got %v", moderator.ID(), countersigners)
+	}
+}
+
+func TestCountersignatureForged(t *testing.T) {
+	author, authorSigner, community := testutil.MakeCommunityOrSkip(t)
+	moderator, _ := testutil.MakeIdentityFromKeyOrSkip(t, testkeys.PrivKey2, "")
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{author, moderator, community} {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding %s to store: %v", node.ID(), err)
+		}
+	}
+	// claim to be the moderator without holding their key
+	forged, err := forest.As(moderator, authorSigner).AddCountersignature(community)
+	if err != nil {
+		t.Fatalf("Failed creating forged countersignature: %v", err)
+	}
+	if err := s.Add(forged); err != nil {
+		t.Fatalf("Failed adding forged countersignature to store: %v", err)
+	}
+	// an ordinary reply is not a countersignature
+	reply, err := forest.As(author, authorSigner).NewReply(community, "not a countersignature", []byte{})
+	if err != nil {
+		t.Fatalf("Failed creating reply: %v", err)
+	}
+	if err := s.Add(reply); err != nil {
+		t.Fatalf("Failed adding reply to store: %v", err)
+	}
+	if countersigners, err := forest.VerifyCountersignatures(community, s); err != nil {
+		t.Fatalf("Failed verifying countersignatures: %v", err)
+	} else if len(countersigners) != 0 {
+		t.Errorf("Expected forged countersignature to be ignored, got %v", countersigners)
+	}
+}
@@ -0,0 +1,124 @@
+package forest
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"reflect"
+
+	"git.sr.ht/~whereswaldon/forest-go/serialize"
+)
+
+// FieldDiff describes a field that differs between two nodes.
+type FieldDiff struct {
+	// Field is the name of the field within the node struct, such as
+	// "Parent" or "Content". The computed node ID is reported as "ID".
+	Field string
+	// A and B point to the values of the field within each node. One of
+	// them is nil if only one of the nodes has the field, which happens
+	// when the nodes are of different types.
+	A, B interface{}
+}
+
+// namedField is a field of a node along with its name.
+type namedField struct {
+	name  string
+	value interface{}
+}
+
+// nodeFields returns pointers to every field of the given node in the order
+// that they are serialized, preceded by the node's ID.
+func nodeFields(n Node) ([]namedField, error) {
+	var common *CommonNode
+	var specific []namedField
+	var trailer *Trailer
+	switch n := n.(type) {
+	case *Identity:
+		common, trailer = &n.CommonNode, &n.Trailer
+		specific = []namedField{
+			{"Name", &n.Name},
+			{"PublicKey", &n.PublicKey},
+		}
+	case *Community:
+		common, trailer = &n.CommonNode, &n.Trailer
+		specific = []namedField{
+			{"Name", &n.Name},
+		}
+	case *Reply:
+		common, trailer = &n.CommonNode, &n.Trailer
+		specific = []namedField{
+			{"CommunityID", &n.CommunityID},
+			{"ConversationID", &n.ConversationID},
+			{"Content", &n.Content},
+		}
+	default:
+		return nil, fmt.Errorf("unknown node type %T", n)
+	}
+	all := []namedField{
+		{"ID", common.ID()},
+		{"Version", &common.Version},
+		{"Type", &common.Type},
+		{"Parent", &common.Parent},
+		{"IDDesc", &common.IDDesc},
+		{"Depth", &common.Depth},
+		{"Created", &common.Created},
+		{"Metadata", &common.Metadata},
+		{"Author", &common.Author},
+	}
+	all = append(all, specific...)
+	return append(all, namedField{"Signature", &trailer.Signature}), nil
+}
+
+// fieldBytes returns the binary serialization of a node field.
+func fieldBytes(value interface{}) ([]byte, error) {
+	if marshaler, ok := value.(encoding.BinaryMarshaler); ok {
+		return marshaler.MarshalBinary()
+	}
+	return serialize.ArborSerialize(reflect.ValueOf(value))
+}
+
+// DiffNodes compares the fields of two nodes and returns the fields that
+// differ, in the order that they are serialized. Fields are compared by their
+// binary serialization, so the result is empty exactly when the two nodes
+// serialize identically. If the nodes are of different types, fields that only
+// one of them has are also reported, with a nil value for the node lacking them.
+func DiffNodes(a, b Node) ([]FieldDiff, error) {
+	aFields, err := nodeFields(a)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing fields of first node: %w", err)
+	}
+	bFields, err := nodeFields(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing fields of second node: %w", err)
+	}
+	bValues := make(map[string]interface{}, len(bFields))
+	for _, field := range bFields {
+		bValues[field.name] = field.value
+	}
+	var diffs []FieldDiff
+	for _, field := range aFields {
+		bValue, ok := bValues[field.name]
+		if !ok {
+			diffs = append(diffs, FieldDiff{Field: field.name, A: field.value})
+			continue
+		}
+		delete(bValues, field.name)
+		aBytes, err := fieldBytes(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed serializing %s of first node: %w", field.name, err)
+		}
+		bBytes, err := fieldBytes(bValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed serializing %s of second node: %w", field.name, err)
+		}
+		if !bytes.Equal(aBytes, bBytes) {
+			diffs = append(diffs, FieldDiff{Field: field.name, A: field.value, B: bValue})
+		}
+	}
+	for _, field := range bFields {
+		if _, ok := bValues[field.name]; ok {
+			diffs = append(diffs, FieldDiff{Field: field.name, B: field.value})
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,71 @@
+package forest_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func diffFieldNames(diffs []forest.FieldDiff) map[string]forest.FieldDiff {
+	names := make(map[string]forest.FieldDiff, len(diffs))
+	for _, diff := range diffs {
+		names[diff.Field] = diff
+	}
+	return names
+}
+
+func TestDiffNodes(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	b, err := reply.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling reply: %v", err)
+	}
+	roundTripped, err := forest.UnmarshalBinaryNode(b)
+	if err != nil {
+		t.Skipf("Failed unmarshalling reply: %v", err)
+	}
+	if diffs, err := forest.DiffNodes(reply, roundTripped); err != nil {
+		t.Errorf("Failed diffing identical nodes: %v", err)
+	} else if len(diffs) != 0 {
+		t.Errorf("Expected no differences between identical nodes, got %+v", diffs)
+	}
+
+	created := fields.TimestampFrom(reply.CreatedAt())
+	other, err := forest.As(identity, signer).WithCreated(created).NewReply(community, "other content", reply.Metadata.Blob)
+	if err != nil {
+		t.Skipf("Failed generating test reply: %v", err)
+	}
+	diffs, err := forest.DiffNodes(reply, other)
+	if err != nil {
+		t.Fatalf("Failed diffing replies: %v", err)
+	}
+	names := diffFieldNames(diffs)
+	for _, field := range []string{"ID", "Content", "Signature"} {
+		if _, ok := names[field]; !ok {
+			t.Errorf("Expected %s to differ, got %+v", field, diffs)
+		}
+	}
+	if len(names) != 3 {
+		t.Errorf("Expected only 3 differing fields, got %+v", diffs)
+	}
+	if diff := names["Content"]; diff.A != &reply.Content || diff.B != &other.Content {
+		t.Errorf("Expected Content diff to point to each node's content, got %+v", diff)
+	}
+
+	diffs, err = forest.DiffNodes(reply, community)
+	if err != nil {
+		t.Fatalf("Failed diffing nodes of different types: %v", err)
+	}
+	names = diffFieldNames(diffs)
+	if _, ok := names["Type"]; !ok {
+		t.Errorf("Expected Type to differ, got %+v", diffs)
+	}
+	if diff, ok := names["Content"]; !ok || diff.A == nil || diff.B != nil {
+		t.Errorf("Expected Content to be present only in the reply, got %+v", diff)
+	}
+	if diff, ok := names["Name"]; !ok || diff.A != nil || diff.B == nil {
+		t.Errorf("Expected Name to be present only in the community, got %+v", diff)
+	}
+}
@@ -74,18 +74,17 @@ func (d *HashDescriptor) UnmarshalText(b []byte) error {
 	return unmarshalTextDelimited(b, descriptorTextSeparator, &d.Type, &d.Length)
 }
 func (d *HashDescriptor) Validate() error {
-	validLengths, validType := ValidHashTypes[d.Type]
+	if d.Type == HashTypeNullHash {
+		if d.Length != 0 {
+			return fmt.Errorf("%d is not a valid hash length for hash type %d", d.Length, d.Type)
+		}
+		return nil
+	}
+	hashFunc, validType := LookupHashFunc(d.Type)
 	if !validType {
 		return fmt.Errorf("%d is not a valid hash type", d.Type)
 	}
-	found := false
-	for _, length := range validLengths {
-		if length == d.Length {
-			found = true
-			break
-		}
-	}
-	if !found {
+	if d.Length != hashFunc.Length {
 		return fmt.Errorf("%d is not a valid hash length for hash type %d", d.Length, d.Type)
 	}
 	return nil
@@ -0,0 +1,8 @@
+package fields
+
+// UnregisterHashType and UnregisterSignatureType expose the corresponding
+// unexported functions to the tests of this package.
+var (
+	UnregisterHashType      = unregisterHashType
+	UnregisterSignatureType = unregisterSignatureType
+)
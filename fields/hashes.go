@@ -0,0 +1,74 @@
+package fields
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// HashFunc describes the hash algorithm used to compute node IDs of a given
+// HashType.
+type HashFunc struct {
+	// New creates an instance of the hash algorithm.
+	New func() hash.Hash
+	// Length is the length in bytes of the digests produced by New.
+	Length ContentLength
+}
+
+// ErrHashTypeRegistered is returned (wrapped) by RegisterHashType when the
+// given HashType or name is already in use.
+var ErrHashTypeRegistered = errors.New("hash type already registered")
+
+// hashFuncs maps each registered HashType to its algorithm.
+var hashFuncs = map[HashType]HashFunc{
+	HashTypeSHA512: {New: sha512.New512_256, Length: HashDigestLengthSHA512_256},
+	HashTypeSHA3:   {New: sha3.New256, Length: HashDigestLengthSHA3_256},
+}
+
+// RegisterHashType makes a new hash algorithm available for computing and
+// validating node IDs under the given HashType. The name is used for the text
+// form of the HashType (as in HashNames). Neither the HashType nor the name may
+// already be registered, and HashTypeNullHash cannot be registered.
+//
+// RegisterHashType is not safe for concurrent use with any other function in
+// this package, so it should be called from an init function.
+func RegisterHashType(t HashType, name string, f HashFunc) error {
+	if f.New == nil {
+		return fmt.Errorf("hash type %d has no hash function", t)
+	} else if f.Length < 1 {
+		return fmt.Errorf("hash type %d has invalid digest length %d", t, f.Length)
+	} else if name == "" {
+		return fmt.Errorf("hash type %d has no name", t)
+	}
+	if _, registered := ValidHashTypes[t]; registered {
+		return fmt.Errorf("hash type %d: %w", t, ErrHashTypeRegistered)
+	}
+	for _, existing := range HashNames {
+		if existing == name {
+			return fmt.Errorf("hash type name %s: %w", name, ErrHashTypeRegistered)
+		}
+	}
+	hashFuncs[t] = f
+	ValidHashTypes[t] = []ContentLength{f.Length}
+	HashNames[t] = name
+	return nil
+}
+
+// unregisterHashType undoes a successful call to RegisterHashType for the
+// given HashType so that tests can leave the registry as they found it.
+func unregisterHashType(t HashType) {
+	delete(hashFuncs, t)
+	delete(ValidHashTypes, t)
+	delete(HashNames, t)
+}
+
+// LookupHashFunc returns the hash algorithm registered for the given
+// HashType. It returns false for unregistered types and for
+// HashTypeNullHash, which has no algorithm.
+func LookupHashFunc(t HashType) (HashFunc, bool) {
+	f, found := hashFuncs[t]
+	return f, found
+}
@@ -16,6 +16,9 @@ const (
 
 	// HashDigestLengthSHA512_256 is the length of the digest produced by the SHA512/256 hash algorithm
 	HashDigestLengthSHA512_256 ContentLength = 32
+
+	// HashDigestLengthSHA3_256 is the length of the digest produced by the SHA3-256 hash algorithm
+	HashDigestLengthSHA3_256 ContentLength = 32
 )
 
 // multiByteSerializationOrder defines the order in which multi-byte
@@ -221,14 +224,21 @@ const sizeofTimestamp = 8
 
 const nanosPerMilli = 1000000
 
+// timestampTextFormat is RFC3339 with exactly millisecond precision, which is
+// the precision of a Timestamp.
+const timestampTextFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// TimestampFrom converts t into a Timestamp, discarding any precision finer
+// than a millisecond.
 func TimestampFrom(t time.Time) Timestamp {
 	return Timestamp(t.UnixNano() / nanosPerMilli)
 }
 
+// Time converts the Timestamp into a time.Time in the local time zone.
 func (t Timestamp) Time() time.Time {
-	sec := (uint(t) / 1000)
-	nsec := (uint(t) % 1000) * nanosPerMilli
-	return time.Unix(int64(sec), int64(nsec))
+	sec := int64(t / 1000)
+	nsec := int64(t%1000) * nanosPerMilli
+	return time.Unix(sec, nsec)
 }
 
 // MarshalBinary converts the Timestamp into its binary representation
@@ -238,8 +248,21 @@ func (v Timestamp) MarshalBinary() ([]byte, error) {
 	return b.Bytes(), err
 }
 
+// MarshalText formats the Timestamp as an RFC3339 time in UTC with
+// millisecond precision, such as "2020-01-02T15:04:05.000Z".
 func (v Timestamp) MarshalText() ([]byte, error) {
-	return []byte(fmt.Sprintf("V%d", v)), nil
+	return []byte(v.Time().UTC().Format(timestampTextFormat)), nil
+}
+
+// UnmarshalText parses an RFC3339 time, as produced by MarshalText, into the
+// Timestamp. Precision finer than a millisecond is discarded.
+func (v *Timestamp) UnmarshalText(b []byte) error {
+	t, err := time.Parse(time.RFC3339, string(b))
+	if err != nil {
+		return fmt.Errorf("failed parsing timestamp: %w", err)
+	}
+	*v = TimestampFrom(t)
+	return nil
 }
 
 // UnmarshalBinary converts from the binary representation of a Timestamp
@@ -311,19 +334,23 @@ type HashType genericType
 const (
 	HashTypeNullHash HashType = iota
 	HashTypeSHA512
+	HashTypeSHA3
 
 	sizeofHashType = sizeofgenericType
 )
 
-// map to valid lengths
+// ValidHashTypes maps each known HashType to its valid lengths. It is extended
+// by RegisterHashType.
 var ValidHashTypes = map[HashType][]ContentLength{
 	HashTypeNullHash: []ContentLength{0},
 	HashTypeSHA512:   []ContentLength{HashDigestLengthSHA512_256},
+	HashTypeSHA3:     []ContentLength{HashDigestLengthSHA3_256},
 }
 
 var HashNames = map[HashType]string{
 	HashTypeNullHash: "NullHash",
 	HashTypeSHA512:   "SHA512",
+	HashTypeSHA3:     "SHA3",
 }
 
 func (t HashType) MarshalBinary() ([]byte, error) {
@@ -368,16 +395,21 @@ const (
 	sizeofContentType                 = sizeofgenericType
 	ContentTypeUTF8String ContentType = 1
 	ContentTypeTwig       ContentType = 2
+	// ContentTypeGzipUTF8 is UTF-8 text compressed with gzip. See
+	// NewCompressedContent.
+	ContentTypeGzipUTF8 ContentType = 3
 )
 
 var ValidContentTypes = map[ContentType]struct{}{
 	ContentTypeUTF8String: struct{}{},
 	ContentTypeTwig:       struct{}{},
+	ContentTypeGzipUTF8:   struct{}{},
 }
 
 var ContentNames = map[ContentType]string{
 	ContentTypeUTF8String: "UTF-8",
 	ContentTypeTwig:       "Twig",
+	ContentTypeGzipUTF8:   "Gzip-UTF-8",
 }
 
 func (t ContentType) MarshalBinary() ([]byte, error) {
@@ -412,16 +444,20 @@ const (
 	sizeofKeyType             = sizeofgenericType
 	KeyTypeNoKey      KeyType = 0
 	KeyTypeOpenPGPRSA KeyType = 1
+	// KeyTypeSSH is an SSH public key in the SSH wire format
+	KeyTypeSSH KeyType = 2
 )
 
 var ValidKeyTypes = map[KeyType]struct{}{
 	KeyTypeNoKey:      struct{}{},
 	KeyTypeOpenPGPRSA: struct{}{},
+	KeyTypeSSH:        struct{}{},
 }
 
 var KeyNames = map[KeyType]string{
 	KeyTypeNoKey:      "None",
 	KeyTypeOpenPGPRSA: "OpenPGP-RSA",
+	KeyTypeSSH:        "SSH",
 }
 
 func (t KeyType) MarshalBinary() ([]byte, error) {
@@ -453,16 +489,25 @@ func (t *KeyType) Equals(t2 *KeyType) bool {
 type SignatureType genericType
 
 const (
-	sizeofSignatureType                   = sizeofgenericType
+	sizeofSignatureType = sizeofgenericType
+	// SignatureTypeNull marks a node that has not been signed yet. It is
+	// never valid in a node read from binary data.
+	SignatureTypeNull       SignatureType = 0
 	SignatureTypeOpenPGPRSA SignatureType = 1
+	// SignatureTypeSSH is an SSH signature in the SSH wire format, as
+	// produced by an ssh-agent. It must be verified with a KeyTypeSSH key.
+	SignatureTypeSSH SignatureType = 2
 )
 
 var ValidSignatureTypes = map[SignatureType]struct{}{
 	SignatureTypeOpenPGPRSA: struct{}{},
+	SignatureTypeSSH:        struct{}{},
 }
 
 var SignatureNames = map[SignatureType]string{
+	SignatureTypeNull:       "Null",
 	SignatureTypeOpenPGPRSA: "OpenPGP-RSA",
+	SignatureTypeSSH:        "SSH",
 }
 
 func (t SignatureType) MarshalBinary() ([]byte, error) {
@@ -52,3 +52,24 @@ func TestBlobContains(t *testing.T) {
         t.Fatal("ContainsString() found nonexistent string in Blob.")
      }
 }
+
+func TestTimestampText(t *testing.T) {
+	created := time.Date(2020, time.March, 4, 5, 6, 7, 890123456, time.FixedZone("test", 3600))
+	timestamp := fields.TimestampFrom(created)
+	text, err := timestamp.MarshalText()
+	if err != nil {
+		t.Fatalf("Failed marshalling timestamp: %v", err)
+	}
+	if expected := "2020-03-04T04:06:07.890Z"; string(text) != expected {
+		t.Errorf("Expected timestamp text %s, got %s", expected, text)
+	}
+	var parsed fields.Timestamp
+	if err := parsed.UnmarshalText(text); err != nil {
+		t.Fatalf("Failed parsing %s: %v", text, err)
+	} else if parsed != timestamp {
+		t.Errorf("Expected %s to parse as %d, got %d", text, timestamp, parsed)
+	}
+	if err := parsed.UnmarshalText([]byte("yesterday")); err == nil {
+		t.Errorf("Expected error parsing invalid timestamp")
+	}
+}
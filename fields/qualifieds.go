@@ -2,8 +2,13 @@ package fields
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
 	"encoding"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"unicode/utf8"
 
@@ -11,6 +16,7 @@ import (
 	"git.sr.ht/~whereswaldon/forest-go/twig"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
 )
 
 const minSizeofQualified = sizeofDescriptor
@@ -66,7 +72,7 @@ func (q *QualifiedHash) UnmarshalBinary(b []byte) error {
 		return err
 	}
 	if int(q.Descriptor.Length) > len(unused) {
-		return fmt.Errorf("malformed qualified hash, length %d is longer than remaining bytes (%d)", q.Descriptor.Length, len(unused))
+		return fmt.Errorf("malformed qualified hash, length %d is longer than remaining bytes (%d): %w", q.Descriptor.Length, len(unused), io.ErrUnexpectedEOF)
 	}
 	return q.Blob.UnmarshalBinary(unused[:q.Descriptor.Length])
 }
@@ -75,10 +81,23 @@ func (q *QualifiedHash) BytesConsumed() int {
 	return sizeofHashDescriptor + q.Blob.BytesConsumed()
 }
 
+// Equals returns whether the two hashes have identical descriptors and digest
+// bytes. This is deliberately strict so that it agrees with comparisons of the
+// text form of the hashes (which include the descriptor length). For valid
+// hashes, the descriptor length is always the length of the digest, so a hash
+// parsed from bytes and one constructed with NewQualifiedHash will be equal.
+// Use EqualsDigest to compare hashes whose descriptor length may not have
+// been populated consistently.
 func (q *QualifiedHash) Equals(other *QualifiedHash) bool {
 	return q.Descriptor.Equals(&other.Descriptor) && q.Blob.Equals(&other.Blob)
 }
 
+// EqualsDigest returns whether the two hashes have the same hash type and
+// digest bytes, ignoring the length stored in their descriptors.
+func (q *QualifiedHash) EqualsDigest(other *QualifiedHash) bool {
+	return q.Descriptor.Type.Equals(&other.Descriptor.Type) && q.Blob.Equals(&other.Blob)
+}
+
 func (q *QualifiedHash) MarshalText() ([]byte, error) {
 	return marshalTextQualified(&q.Descriptor, q.Blob)
 }
@@ -87,6 +106,22 @@ func (q *QualifiedHash) UnmarshalText(b []byte) error {
 	return unmarshalTextDelimited(b, qualifiedTextSeparator, &q.Descriptor, &q.Blob)
 }
 
+// ParseQualifiedHash parses the text form of a QualifiedHash (as produced by
+// MarshalString) and ensures that the result is valid.
+func ParseQualifiedHash(s string) (*QualifiedHash, error) {
+	if s == "" {
+		return nil, fmt.Errorf("cannot parse empty string as qualified hash")
+	}
+	q := &QualifiedHash{}
+	if err := q.UnmarshalText([]byte(s)); err != nil {
+		return nil, fmt.Errorf("malformed qualified hash %q: %w", s, err)
+	}
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid qualified hash %q: %w", s, err)
+	}
+	return q, nil
+}
+
 func (q *QualifiedHash) MarshalString() (string, error) {
 	s, e := q.MarshalText()
 	return string(s), e
@@ -98,6 +133,22 @@ func (q *QualifiedHash) String() string {
 	return s
 }
 
+// ShortLength is the number of hexadecimal digits in the output of
+// QualifiedHash.Short.
+const ShortLength = 8
+
+// Short returns an abbreviated form of the hash suitable for display: the
+// first ShortLength hexadecimal digits of its digest. It is stable, but it is
+// not unique, so it cannot be parsed back into a QualifiedHash without a
+// collection of candidates to search (see store.ResolveShort).
+func (q *QualifiedHash) Short() string {
+	digits := hex.EncodeToString(q.Blob)
+	if len(digits) > ShortLength {
+		digits = digits[:ShortLength]
+	}
+	return digits
+}
+
 func (q *QualifiedHash) Validate() error {
 	if err := q.Descriptor.Validate(); err != nil {
 		return err
@@ -115,8 +166,12 @@ type QualifiedContent struct {
 
 const minSizeofQualifiedContent = sizeofContentDescriptor
 
-// NewQualifiedContent returns a valid QualifiedContent from the given data
+// NewQualifiedContent returns a valid QualifiedContent from the given data. A
+// nil content is treated as empty, so the resulting Blob is never nil.
 func NewQualifiedContent(t ContentType, content []byte) (*QualifiedContent, error) {
+	if content == nil {
+		content = []byte{}
+	}
 	cd, err := NewContentDescriptor(t, len(content))
 	if err != nil {
 		return nil, err
@@ -124,6 +179,76 @@ func NewQualifiedContent(t ContentType, content []byte) (*QualifiedContent, erro
 	return &QualifiedContent{*cd, Blob(content)}, nil
 }
 
+// EmptyContent returns the canonical QualifiedContent for a node that has no
+// metadata: empty Twig data with a non-nil Blob.
+func EmptyContent() *QualifiedContent {
+	return &QualifiedContent{
+		Descriptor: ContentDescriptor{
+			Type:   ContentTypeTwig,
+			Length: 0,
+		},
+		Blob: []byte{},
+	}
+}
+
+// compressedContentTypes maps each content type that can be compressed to
+// the content type of its compressed form.
+var compressedContentTypes = map[ContentType]ContentType{
+	ContentTypeUTF8String: ContentTypeGzipUTF8,
+}
+
+// maxDecompressedLength is the largest number of bytes that compressed content
+// may expand to. It guards against maliciously crafted content.
+const maxDecompressedLength = 1 << 20
+
+// NewCompressedContent compresses raw and returns a valid QualifiedContent
+// holding the compressed bytes. contentType is the type of the uncompressed
+// data, and must be ContentTypeUTF8String.
+//
+// Nodes are content-addressed over the compressed bytes, not the raw ones.
+// Compressing the same data twice is not guaranteed to yield the same bytes
+// (for instance, across versions of the compression library), but the ID of
+// a node never changes once it has been created because it is computed from
+// the stored bytes.
+func NewCompressedContent(contentType ContentType, raw []byte) (*QualifiedContent, error) {
+	compressedType, ok := compressedContentTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("content of type %d cannot be compressed", contentType)
+	}
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	if _, err := writer.Write(raw); err != nil {
+		return nil, fmt.Errorf("failed compressing content: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed compressing content: %w", err)
+	}
+	return NewQualifiedContent(compressedType, buf.Bytes())
+}
+
+// Decompressed returns the content in its uncompressed form. If the content
+// is not of a compressed type, its bytes are returned unchanged.
+func (q *QualifiedContent) Decompressed() ([]byte, error) {
+	switch q.Descriptor.Type {
+	case ContentTypeGzipUTF8:
+		reader, err := gzip.NewReader(bytes.NewReader(q.Blob))
+		if err != nil {
+			return nil, fmt.Errorf("failed reading compressed content: %w", err)
+		}
+		defer reader.Close()
+		raw, err := ioutil.ReadAll(io.LimitReader(reader, maxDecompressedLength+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing content: %w", err)
+		}
+		if len(raw) > maxDecompressedLength {
+			return nil, fmt.Errorf("decompressed content exceeds %d bytes", maxDecompressedLength)
+		}
+		return raw, nil
+	default:
+		return q.Blob, nil
+	}
+}
+
 func (q *QualifiedContent) Equals(other *QualifiedContent) bool {
 	return q.Descriptor.Equals(&other.Descriptor) && q.Blob.Equals(&other.Blob)
 }
@@ -134,7 +259,7 @@ func (q *QualifiedContent) UnmarshalBinary(b []byte) error {
 		return err
 	}
 	if int(q.Descriptor.Length) > len(unused) {
-		return fmt.Errorf("malformed qualified content, length %d is longer than remaining bytes (%d)", q.Descriptor.Length, len(unused))
+		return fmt.Errorf("malformed qualified content, length %d is longer than remaining bytes (%d): %w", q.Descriptor.Length, len(unused), io.ErrUnexpectedEOF)
 	}
 	return q.Blob.UnmarshalBinary(unused[:q.Descriptor.Length])
 }
@@ -176,9 +301,17 @@ func (q *QualifiedContent) Validate() error {
 			fmt.Errorf("invalid utf8 data in qualified content of type utf8")
 		}
 	case ContentTypeTwig:
-		if err := twig.New().UnmarshalBinary(q.Blob); err != nil {
+		if err := twig.ValidateWithLimits(q.Blob, twig.CurrentLimits()); err != nil {
 			return fmt.Errorf("invalid twig data in qualified content of type twig: %w", err)
 		}
+	case ContentTypeGzipUTF8:
+		raw, err := q.Decompressed()
+		if err != nil {
+			return fmt.Errorf("invalid compressed data in qualified content of type gzip utf8: %w", err)
+		}
+		if !utf8.Valid(raw) {
+			return fmt.Errorf("invalid utf8 data in qualified content of type gzip utf8")
+		}
 	}
 	return nil
 }
@@ -209,7 +342,7 @@ func (q *QualifiedKey) UnmarshalBinary(b []byte) error {
 		return err
 	}
 	if int(q.Descriptor.Length) > len(unused) {
-		return fmt.Errorf("malformed qualified key, length %d is longer than remaining bytes (%d)", q.Descriptor.Length, len(unused))
+		return fmt.Errorf("malformed qualified key, length %d is longer than remaining bytes (%d): %w", q.Descriptor.Length, len(unused), io.ErrUnexpectedEOF)
 	}
 	return q.Blob.UnmarshalBinary(unused[:q.Descriptor.Length])
 }
@@ -240,10 +373,23 @@ func (q *QualifiedKey) Validate() error {
 		if entity.PrimaryKey.PubKeyAlgo != packet.PubKeyAlgoRSA {
 			return fmt.Errorf("expected RSA key, but was %v", entity.PrimaryKey.PubKeyAlgo)
 		}
+	case KeyTypeSSH:
+		if _, err := q.AsSSHPublicKey(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// AsSSHPublicKey parses the key as an SSH public key in the SSH wire format.
+func (q *QualifiedKey) AsSSHPublicKey() (ssh.PublicKey, error) {
+	key, err := ssh.ParsePublicKey(q.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading ssh public key: %v", err)
+	}
+	return key, nil
+}
+
 func (q *QualifiedKey) AsEntity() (*openpgp.Entity, error) {
 	buf := bytes.NewBuffer(q.Blob)
 	entity, err := openpgp.ReadEntity(packet.NewReader(buf))
@@ -253,6 +399,32 @@ func (q *QualifiedKey) AsEntity() (*openpgp.Entity, error) {
 	return entity, nil
 }
 
+// Fingerprint returns a short string identifying the key, suitable for
+// showing to users and for comparing keys. OpenPGP keys are identified by
+// the uppercase hex of their primary key fingerprint and SSH keys by their
+// SHA256 fingerprint (as displayed by ssh-keygen -l), so that they match what
+// other tools display. Keys of other types are identified by the hex of a
+// SHA512/256 digest of their bytes. An error is returned if an OpenPGP or
+// SSH key cannot be parsed.
+func (q *QualifiedKey) Fingerprint() (string, error) {
+	switch q.Descriptor.Type {
+	case KeyTypeOpenPGPRSA:
+		entity, err := q.AsEntity()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), nil
+	case KeyTypeSSH:
+		key, err := q.AsSSHPublicKey()
+		if err != nil {
+			return "", err
+		}
+		return ssh.FingerprintSHA256(key), nil
+	}
+	digest := sha512.Sum512_256(q.Blob)
+	return hex.EncodeToString(digest[:]), nil
+}
+
 type QualifiedSignature struct {
 	Descriptor SignatureDescriptor `arbor:"order=0,recurse=serialize"`
 	Blob       `arbor:"order=1"`
@@ -279,7 +451,7 @@ func (q *QualifiedSignature) UnmarshalBinary(b []byte) error {
 		return err
 	}
 	if int(q.Descriptor.Length) > len(unused) {
-		return fmt.Errorf("malformed qualified signature, length %d is longer than remaining bytes (%d)", q.Descriptor.Length, len(unused))
+		return fmt.Errorf("malformed qualified signature, length %d is longer than remaining bytes (%d): %w", q.Descriptor.Length, len(unused), io.ErrUnexpectedEOF)
 	}
 	return q.Blob.UnmarshalBinary(unused[:q.Descriptor.Length])
 }
@@ -316,9 +488,33 @@ func (q *QualifiedSignature) Validate() error {
 		if algorithm != packet.PubKeyAlgoRSA {
 			return fmt.Errorf("RSA-type signature made with non-RSA algorithm: %v", algorithm)
 		}
-
-	default:
-		return fmt.Errorf("unknown signature type %d", q.Descriptor.Type)
+	case SignatureTypeSSH:
+		if _, err := q.AsSSHSignature(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// AsSSHSignature parses the signature as an SSH signature in the SSH wire format.
+func (q *QualifiedSignature) AsSSHSignature() (*ssh.Signature, error) {
+	sig := new(ssh.Signature)
+	if err := ssh.Unmarshal(q.Blob, sig); err != nil {
+		return nil, fmt.Errorf("failed reading signature data as ssh signature: %w", err)
+	}
+	return sig, nil
+}
+
+// Verify reports whether the signature is a valid signature over data made by
+// the private half of key. OpenPGP signatures require an OpenPGP key and SSH
+// signatures (of any algorithm supported by SSH, including Ed25519) require an
+// SSH key. An invalid signature produces a non-nil error describing the
+// failure as well as false. The check is performed by the SignatureVerifier
+// registered for the signature's type with RegisterSignatureType.
+func (q *QualifiedSignature) Verify(data []byte, key *QualifiedKey) (bool, error) {
+	verify, found := LookupSignatureVerifier(q.Descriptor.Type)
+	if !found {
+		return false, fmt.Errorf("Unknown signature type %d", q.Descriptor.Type)
+	}
+	return verify(data, q.Blob, key)
+}
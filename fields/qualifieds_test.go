@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
 	"reflect"
 	"testing"
 	"time"
@@ -17,6 +22,7 @@ import (
 	"git.sr.ht/~whereswaldon/forest-go/twig"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestQualifiedContent(t *testing.T) {
@@ -256,6 +262,138 @@ func TestQualifiedBounds(t *testing.T) {
 	}
 }
 
+func TestCompressedContent(t *testing.T) {
+	raw := bytes.Repeat([]byte("# heading\n\nsome markdown that repeats\n"), 100)
+	content, err := fields.NewCompressedContent(fields.ContentTypeUTF8String, raw)
+	if err != nil {
+		t.Fatalf("failed compressing content: %v", err)
+	}
+	if content.Descriptor.Type != fields.ContentTypeGzipUTF8 {
+		t.Errorf("expected compressed content type, got %d", content.Descriptor.Type)
+	}
+	if len(content.Blob) >= len(raw) {
+		t.Errorf("expected compressed content (%d bytes) to be smaller than raw content (%d bytes)", len(content.Blob), len(raw))
+	}
+	if err := content.Validate(); err != nil {
+		t.Errorf("expected compressed content to be valid: %v", err)
+	}
+	decompressed, err := content.Decompressed()
+	if err != nil {
+		t.Fatalf("failed decompressing content: %v", err)
+	}
+	if !bytes.Equal(decompressed, raw) {
+		t.Errorf("expected decompressed content to match raw content")
+	}
+
+	// round trip through the binary form
+	b, err := serialize.ArborSerialize(reflect.ValueOf(content))
+	if err != nil {
+		t.Fatalf("failed marshalling compressed content: %v", err)
+	}
+	parsed := &fields.QualifiedContent{}
+	if err := parsed.UnmarshalBinary(b); err != nil {
+		t.Fatalf("failed unmarshalling compressed content: %v", err)
+	}
+	if !parsed.Equals(content) {
+		t.Errorf("expected compressed content to survive binary round trip")
+	}
+
+	if _, err := fields.NewCompressedContent(fields.ContentTypeTwig, raw); err == nil {
+		t.Errorf("expected compressing twig content to fail")
+	}
+
+	invalidUTF8, err := fields.NewCompressedContent(fields.ContentTypeUTF8String, []byte{0xff, 0xfe})
+	if err != nil {
+		t.Fatalf("failed compressing content: %v", err)
+	}
+	if err := invalidUTF8.Validate(); err == nil {
+		t.Errorf("expected compressed invalid utf8 to fail validation")
+	}
+
+	notGzip, err := fields.NewQualifiedContent(fields.ContentTypeGzipUTF8, []byte("not gzip"))
+	if err != nil {
+		t.Fatalf("failed constructing content: %v", err)
+	}
+	if err := notGzip.Validate(); err == nil {
+		t.Errorf("expected content that is not gzipped to fail validation")
+	}
+}
+
+func TestQualifiedHashEquals(t *testing.T) {
+	digest := sha256.Sum256([]byte("some content"))
+	constructed, err := fields.NewQualifiedHash(fields.HashTypeSHA512, digest[:])
+	if err != nil {
+		t.Fatalf("failed constructing qualified hash: %v", err)
+	}
+	b, err := serialize.ArborSerialize(reflect.ValueOf(constructed))
+	if err != nil {
+		t.Fatalf("failed marshalling qualified hash: %v", err)
+	}
+	parsed := &fields.QualifiedHash{}
+	if err := parsed.UnmarshalBinary(b); err != nil {
+		t.Fatalf("failed unmarshalling qualified hash: %v", err)
+	}
+	if !constructed.Equals(parsed) || !parsed.Equals(constructed) {
+		t.Errorf("expected hash parsed from bytes to equal hash constructed by NewQualifiedHash")
+	}
+	if !constructed.EqualsDigest(parsed) {
+		t.Errorf("expected hash parsed from bytes to have the same digest as hash constructed by NewQualifiedHash")
+	}
+
+	// a hash whose descriptor length was never populated
+	unpopulated := &fields.QualifiedHash{
+		Descriptor: fields.HashDescriptor{Type: fields.HashTypeSHA512},
+		Blob:       digest[:],
+	}
+	if constructed.Equals(unpopulated) {
+		t.Errorf("expected hashes with different descriptor lengths to be strictly unequal")
+	}
+	if !constructed.EqualsDigest(unpopulated) || !unpopulated.EqualsDigest(constructed) {
+		t.Errorf("expected hashes with identical type and digest to have equal digests")
+	}
+
+	otherType, err := fields.NewQualifiedHash(fields.HashTypeNullHash, digest[:])
+	if err != nil {
+		t.Fatalf("failed constructing qualified hash: %v", err)
+	}
+	if constructed.EqualsDigest(otherType) {
+		t.Errorf("expected hashes with different types to have unequal digests")
+	}
+}
+
+func TestParseQualifiedHash(t *testing.T) {
+	digest := sha256.Sum256([]byte("some content"))
+	original, err := fields.NewQualifiedHash(fields.HashTypeSHA512, digest[:])
+	if err != nil {
+		t.Fatalf("failed constructing qualified hash: %v", err)
+	}
+	parsed, err := fields.ParseQualifiedHash(original.String())
+	if err != nil {
+		t.Fatalf("failed parsing text form of valid hash: %v", err)
+	} else if !parsed.Equals(original) {
+		t.Errorf("expected parsed hash %v to equal original %v", parsed, original)
+	}
+	if parsed, err := fields.ParseQualifiedHash(fields.NullHash().String()); err != nil {
+		t.Errorf("failed parsing text form of null hash: %v", err)
+	} else if !parsed.Equals(fields.NullHash()) {
+		t.Errorf("expected parsed null hash to equal null hash, got %v", parsed)
+	}
+
+	text := original.String()
+	for _, malformed := range []string{
+		"",
+		"garbage",
+		text[:len(text)/2],
+		text + "!",
+		// claims to be a null hash but has a digest
+		fields.NullHash().String() + "AAAA",
+	} {
+		if _, err := fields.ParseQualifiedHash(malformed); err == nil {
+			t.Errorf("expected error parsing malformed hash %q", malformed)
+		}
+	}
+}
+
 func TestQualifiedSignature(t *testing.T) {
 	signingData := "I should be signed"
 	// make an RSA signature to test with
@@ -328,3 +466,275 @@ func TestQualifiedSignature(t *testing.T) {
 		})
 	}
 }
+
+func TestQualifiedSignatureVerify(t *testing.T) {
+	data := []byte("I should be signed")
+
+	// OpenPGP
+	entity, err := openpgp.NewEntity("testkey", "", "", nil)
+	if err != nil {
+		t.Skipf("Failed generating OpenPGP key: %v", err)
+	}
+	pgpKeyBuf := new(bytes.Buffer)
+	if err := entity.Serialize(pgpKeyBuf); err != nil {
+		t.Skipf("Failed serializing OpenPGP key: %v", err)
+	}
+	pgpSigBuf := new(bytes.Buffer)
+	if err := openpgp.DetachSign(pgpSigBuf, entity, bytes.NewBuffer(data), nil); err != nil {
+		t.Skipf("Failed signing with OpenPGP key: %v", err)
+	}
+	pgpKey, err := fields.NewQualifiedKey(fields.KeyTypeOpenPGPRSA, pgpKeyBuf.Bytes())
+	if err != nil {
+		t.Skipf("Failed building OpenPGP key: %v", err)
+	}
+	pgpSig, err := fields.NewQualifiedSignature(fields.SignatureTypeOpenPGPRSA, pgpSigBuf.Bytes())
+	if err != nil {
+		t.Skipf("Failed building OpenPGP signature: %v", err)
+	}
+
+	// Ed25519 via SSH
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Skipf("Failed generating Ed25519 key: %v", err)
+	}
+	sshSigner, err := ssh.NewSignerFromKey(edPriv)
+	if err != nil {
+		t.Skipf("Failed building SSH signer: %v", err)
+	}
+	sshSignature, err := sshSigner.Sign(rand.Reader, data)
+	if err != nil {
+		t.Skipf("Failed signing with SSH key: %v", err)
+	}
+	sshKey, err := fields.NewQualifiedKey(fields.KeyTypeSSH, sshSigner.PublicKey().Marshal())
+	if err != nil {
+		t.Skipf("Failed building SSH key: %v", err)
+	}
+	sshSig, err := fields.NewQualifiedSignature(fields.SignatureTypeSSH, ssh.Marshal(sshSignature))
+	if err != nil {
+		t.Skipf("Failed building SSH signature: %v", err)
+	}
+
+	for _, row := range []struct {
+		name  string
+		sig   *fields.QualifiedSignature
+		key   *fields.QualifiedKey
+		data  []byte
+		valid bool
+	}{
+		{"openpgp valid", pgpSig, pgpKey, data, true},
+		{"openpgp wrong data", pgpSig, pgpKey, []byte("something else"), false},
+		{"openpgp with ssh key", pgpSig, sshKey, data, false},
+		{"ssh valid", sshSig, sshKey, data, true},
+		{"ssh wrong data", sshSig, sshKey, []byte("something else"), false},
+		{"ssh with openpgp key", sshSig, pgpKey, data, false},
+	} {
+		t.Run(row.name, func(t *testing.T) {
+			valid, err := row.sig.Verify(row.data, row.key)
+			if valid != row.valid {
+				t.Errorf("Expected Verify to return %v, got %v (err: %v)", row.valid, valid, err)
+			} else if row.valid && err != nil {
+				t.Errorf("Expected no error for valid signature, got %v", err)
+			} else if !row.valid && err == nil {
+				t.Errorf("Expected an error describing the invalid signature")
+			}
+		})
+	}
+}
+
+func TestQualifiedKeyFingerprint(t *testing.T) {
+	entity, err := openpgp.NewEntity("testkey", "", "", nil)
+	if err != nil {
+		t.Skipf("Failed generating OpenPGP key: %v", err)
+	}
+	pgpKeyBuf := new(bytes.Buffer)
+	if err := entity.Serialize(pgpKeyBuf); err != nil {
+		t.Skipf("Failed serializing OpenPGP key: %v", err)
+	}
+	pgpKey, err := fields.NewQualifiedKey(fields.KeyTypeOpenPGPRSA, pgpKeyBuf.Bytes())
+	if err != nil {
+		t.Skipf("Failed building OpenPGP key: %v", err)
+	}
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Skipf("Failed generating Ed25519 key: %v", err)
+	}
+	sshSigner, err := ssh.NewSignerFromKey(edPriv)
+	if err != nil {
+		t.Skipf("Failed building SSH signer: %v", err)
+	}
+	sshKey, err := fields.NewQualifiedKey(fields.KeyTypeSSH, sshSigner.PublicKey().Marshal())
+	if err != nil {
+		t.Skipf("Failed building SSH key: %v", err)
+	}
+	noKey, err := fields.NewQualifiedKey(fields.KeyTypeNoKey, []byte("key"))
+	if err != nil {
+		t.Skipf("Failed building key: %v", err)
+	}
+	otherNoKey, err := fields.NewQualifiedKey(fields.KeyTypeNoKey, []byte("other key"))
+	if err != nil {
+		t.Skipf("Failed building key: %v", err)
+	}
+
+	for _, row := range []struct {
+		name     string
+		key      *fields.QualifiedKey
+		expected string
+	}{
+		{"openpgp", pgpKey, fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)},
+		{"ssh", sshKey, ssh.FingerprintSHA256(sshSigner.PublicKey())},
+	} {
+		t.Run(row.name, func(t *testing.T) {
+			fingerprint, err := row.key.Fingerprint()
+			if err != nil {
+				t.Fatalf("Failed computing fingerprint: %v", err)
+			} else if fingerprint != row.expected {
+				t.Errorf("Expected fingerprint %s, got %s", row.expected, fingerprint)
+			}
+		})
+	}
+
+	first, err := noKey.Fingerprint()
+	if err != nil {
+		t.Fatalf("Failed computing fingerprint: %v", err)
+	}
+	again, err := noKey.Fingerprint()
+	if err != nil {
+		t.Fatalf("Failed computing fingerprint: %v", err)
+	} else if first != again {
+		t.Errorf("Expected a stable fingerprint, got %s and %s", first, again)
+	}
+	if other, err := otherNoKey.Fingerprint(); err != nil {
+		t.Fatalf("Failed computing fingerprint: %v", err)
+	} else if other == first {
+		t.Errorf("Expected different keys to have different fingerprints")
+	}
+
+	malformed, err := fields.NewQualifiedKey(fields.KeyTypeOpenPGPRSA, []byte("not a key"))
+	if err != nil {
+		t.Skipf("Failed building key: %v", err)
+	}
+	if _, err := malformed.Fingerprint(); err == nil {
+		t.Errorf("Expected an error fingerprinting a malformed OpenPGP key")
+	}
+}
+
+func TestQualifiedContentTwigLimits(t *testing.T) {
+	defer func(entries int) { twig.MaxEntries = entries }(twig.MaxEntries)
+	data := twig.New()
+	for i := 0; i < 3; i++ {
+		data.Values[twig.Key{Name: "key", Version: uint(i)}] = []byte("value")
+	}
+	b, _ := data.MarshalBinary()
+	content, err := fields.NewQualifiedContent(fields.ContentTypeTwig, b)
+	if err != nil {
+		t.Fatalf("Failed creating twig content: %v", err)
+	}
+	if err := content.Validate(); err != nil {
+		t.Errorf("Expected twig content within limits to be valid: %v", err)
+	}
+	twig.MaxEntries = 2
+	if err := content.Validate(); !errors.Is(err, twig.ErrLimitExceeded) {
+		t.Errorf("Expected twig content with too many entries to fail with ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestRegisterHashType(t *testing.T) {
+	const fakeType fields.HashType = 200
+	if err := fields.RegisterHashType(fakeType, "FNV64", fields.HashFunc{New: func() hash.Hash { return fnv.New64() }, Length: 8}); err != nil {
+		t.Fatalf("Failed registering hash type: %v", err)
+	}
+	t.Cleanup(func() { fields.UnregisterHashType(fakeType) })
+	if err := fields.RegisterHashType(fakeType, "other", fields.HashFunc{New: sha256.New, Length: 32}); !errors.Is(err, fields.ErrHashTypeRegistered) {
+		t.Errorf("Expected ErrHashTypeRegistered registering a type twice, got %v", err)
+	}
+	if err := fields.RegisterHashType(fakeType+1, "SHA512", fields.HashFunc{New: sha256.New, Length: 32}); !errors.Is(err, fields.ErrHashTypeRegistered) {
+		t.Errorf("Expected ErrHashTypeRegistered registering a name twice, got %v", err)
+	}
+	if err := fields.RegisterHashType(fields.HashTypeNullHash, "null", fields.HashFunc{New: sha256.New, Length: 32}); err == nil {
+		t.Errorf("Expected error registering the null hash type")
+	}
+
+	hashFunc, found := fields.LookupHashFunc(fakeType)
+	if !found || hashFunc.Length != 8 {
+		t.Fatalf("Expected to find registered hash type with length 8, got %v %v", hashFunc, found)
+	}
+	digest := hashFunc.New().Sum(nil)
+	q, err := fields.NewQualifiedHash(fakeType, digest)
+	if err != nil {
+		t.Fatalf("Failed creating qualified hash of registered type: %v", err)
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected qualified hash of registered type to be valid: %v", err)
+	}
+	if q, err := fields.NewQualifiedHash(fakeType, append(digest, 0)); err == nil {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Expected qualified hash with wrong length for registered type to be invalid")
+		}
+	}
+	text, err := q.MarshalText()
+	if err != nil {
+		t.Fatalf("Failed marshalling qualified hash of registered type: %v", err)
+	}
+	var parsed fields.QualifiedHash
+	if err := parsed.UnmarshalText(text); err != nil {
+		t.Errorf("Failed parsing text %s of qualified hash of registered type: %v", text, err)
+	} else if !parsed.Equals(q) {
+		t.Errorf("Expected %s to parse as %v, got %v", text, q, &parsed)
+	}
+
+	if _, found := fields.LookupHashFunc(fields.HashTypeNullHash); found {
+		t.Errorf("Expected the null hash type to have no hash function")
+	}
+	sha3, found := fields.LookupHashFunc(fields.HashTypeSHA3)
+	if !found || sha3.Length != fields.HashDigestLengthSHA3_256 || sha3.New().Size() != int(sha3.Length) {
+		t.Errorf("Expected SHA3-256 to be registered")
+	}
+}
+
+func TestRegisterSignatureType(t *testing.T) {
+	const fakeType fields.SignatureType = 200
+	// the fake scheme's signature is the key followed by the data
+	verify := func(data, sig []byte, key *fields.QualifiedKey) (bool, error) {
+		if !bytes.Equal(sig, append(append([]byte{}, key.Blob...), data...)) {
+			return false, fmt.Errorf("fake signature does not match")
+		}
+		return true, nil
+	}
+	if err := fields.RegisterSignatureType(fakeType, "fake", verify); err != nil {
+		t.Fatalf("Failed registering signature type: %v", err)
+	}
+	t.Cleanup(func() { fields.UnregisterSignatureType(fakeType) })
+	if err := fields.RegisterSignatureType(fakeType, "other", verify); !errors.Is(err, fields.ErrSignatureTypeRegistered) {
+		t.Errorf("Expected ErrSignatureTypeRegistered registering a type twice, got %v", err)
+	}
+	if err := fields.RegisterSignatureType(fakeType+1, "SSH", verify); !errors.Is(err, fields.ErrSignatureTypeRegistered) {
+		t.Errorf("Expected ErrSignatureTypeRegistered registering a name twice, got %v", err)
+	}
+	if err := fields.RegisterSignatureType(fields.SignatureTypeNull, "null", verify); err == nil {
+		t.Errorf("Expected error registering the null signature type")
+	}
+
+	data := []byte("I should be signed")
+	key, err := fields.NewQualifiedKey(fields.KeyTypeNoKey, []byte("key"))
+	if err != nil {
+		t.Fatalf("Failed creating key: %v", err)
+	}
+	sig, err := fields.NewQualifiedSignature(fakeType, append([]byte("key"), data...))
+	if err != nil {
+		t.Fatalf("Failed creating signature of registered type: %v", err)
+	}
+	if err := sig.Validate(); err != nil {
+		t.Errorf("Expected signature of registered type to be valid: %v", err)
+	}
+	if text, err := sig.MarshalText(); err != nil {
+		t.Errorf("Failed marshalling signature of registered type: %v", err)
+	} else if !bytes.HasPrefix(text, []byte("fake")) {
+		t.Errorf("Expected text form of signature to begin with its type name, got %s", text)
+	}
+	if valid, err := sig.Verify(data, key); err != nil || !valid {
+		t.Errorf("Expected signature of registered type to verify, got valid=%v err=%v", valid, err)
+	}
+	if valid, err := sig.Verify([]byte("something else"), key); err == nil || valid {
+		t.Errorf("Expected signature of registered type not to verify other data")
+	}
+}
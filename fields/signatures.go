@@ -0,0 +1,108 @@
+package fields
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SignatureVerifier reports whether sig is a valid signature over data made
+// by the private half of key. An invalid signature should produce a non-nil
+// error describing the failure as well as false.
+type SignatureVerifier func(data, sig []byte, key *QualifiedKey) (bool, error)
+
+// ErrSignatureTypeRegistered is returned (wrapped) by RegisterSignatureType
+// when the given SignatureType or name is already in use.
+var ErrSignatureTypeRegistered = errors.New("signature type already registered")
+
+// signatureVerifiers maps each registered SignatureType to its verifier.
+var signatureVerifiers = map[SignatureType]SignatureVerifier{
+	SignatureTypeOpenPGPRSA: verifyOpenPGP,
+	SignatureTypeSSH:        verifySSH,
+}
+
+// RegisterSignatureType makes a new signature scheme available for verifying
+// node signatures under the given SignatureType. The name is used for the
+// text form of the SignatureType (as in SignatureNames). Neither the
+// SignatureType nor the name may already be registered, and
+// SignatureTypeNull cannot be registered.
+//
+// RegisterSignatureType is not safe for concurrent use with any other function
+// in this package, so it should be called from an init function.
+func RegisterSignatureType(t SignatureType, name string, verify SignatureVerifier) error {
+	if verify == nil {
+		return fmt.Errorf("signature type %d has no verifier", t)
+	} else if name == "" {
+		return fmt.Errorf("signature type %d has no name", t)
+	}
+	if t == SignatureTypeNull {
+		return fmt.Errorf("the null signature type cannot be registered")
+	}
+	if _, registered := ValidSignatureTypes[t]; registered {
+		return fmt.Errorf("signature type %d: %w", t, ErrSignatureTypeRegistered)
+	}
+	for _, existing := range SignatureNames {
+		if existing == name {
+			return fmt.Errorf("signature type name %s: %w", name, ErrSignatureTypeRegistered)
+		}
+	}
+	signatureVerifiers[t] = verify
+	ValidSignatureTypes[t] = struct{}{}
+	SignatureNames[t] = name
+	return nil
+}
+
+// unregisterSignatureType undoes a successful call to RegisterSignatureType
+// for the given SignatureType so that tests can leave the registry as they
+// found it.
+func unregisterSignatureType(t SignatureType) {
+	delete(signatureVerifiers, t)
+	delete(ValidSignatureTypes, t)
+	delete(SignatureNames, t)
+}
+
+// LookupSignatureVerifier returns the verifier registered for the given
+// SignatureType.
+func LookupSignatureVerifier(t SignatureType) (SignatureVerifier, bool) {
+	verify, found := signatureVerifiers[t]
+	return verify, found
+}
+
+// verifyOpenPGP is the SignatureVerifier for OpenPGP signatures.
+func verifyOpenPGP(data, sig []byte, key *QualifiedKey) (bool, error) {
+	if key.Descriptor.Type != KeyTypeOpenPGPRSA {
+		return false, fmt.Errorf("OpenPGP signature cannot be validated with key of type %d", key.Descriptor.Type)
+	}
+	pubkeyEntity, err := key.AsEntity()
+	if err != nil {
+		return false, err
+	}
+	keyring := openpgp.EntityList([]*openpgp.Entity{pubkeyEntity})
+	_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewBuffer(data), bytes.NewBuffer(sig), nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verifySSH is the SignatureVerifier for SSH signatures.
+func verifySSH(data, sig []byte, key *QualifiedKey) (bool, error) {
+	if key.Descriptor.Type != KeyTypeSSH {
+		return false, fmt.Errorf("SSH signature cannot be validated with key of type %d", key.Descriptor.Type)
+	}
+	pubkey, err := key.AsSSHPublicKey()
+	if err != nil {
+		return false, err
+	}
+	sshSig := new(ssh.Signature)
+	if err := ssh.Unmarshal(sig, sshSig); err != nil {
+		return false, fmt.Errorf("failed reading signature data as ssh signature: %w", err)
+	}
+	if err := pubkey.Verify(data, sshSig); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,25 @@
+package forest
+
+import (
+	"fmt"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// NodeFilename returns the name of the file in which n is stored on disk,
+// such as within a grove. Use fields.ParseQualifiedHash to recover the node's
+// ID from the name.
+func NodeFilename(n Node) (string, error) {
+	return IDFilename(n.ID())
+}
+
+// IDFilename returns the name of the file in which the node with the given ID
+// is stored on disk. It is the same as NodeFilename, for callers that only
+// have the ID of a node.
+func IDFilename(id *fields.QualifiedHash) (string, error) {
+	name, err := id.MarshalString()
+	if err != nil {
+		return "", fmt.Errorf("failed converting %s to a filename: %w", id, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,200 @@
+package grove
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// childIndexFile is the name of the file in which a grove persists its
+// ChildCache between processes. Node files always begin with the name of a
+// hash type, so it is never mistaken for a node.
+const childIndexFile = ".child-index"
+
+// childIndexHeader is the first line of every child index file. It
+// identifies the format of the remaining lines.
+const childIndexHeader = "grove-child-index/1"
+
+// childIndexRecord formats the line of the child index describing a node.
+// Each line holds the ID of a node, the ID of its parent, and its creation
+// time in milliseconds since the epoch, separated by spaces.
+func childIndexRecord(id, parent string, created time.Time) string {
+	return fmt.Sprintf("%s %s %d\n", id, parent, fields.TimestampFrom(created))
+}
+
+// parseChildIndexRecord parses a line written by childIndexRecord.
+func parseChildIndexRecord(line string) (id, parent *fields.QualifiedHash, created time.Time, err error) {
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 {
+		return nil, nil, created, fmt.Errorf("expected 3 fields in %q, found %d", line, len(parts))
+	}
+	id, parent = &fields.QualifiedHash{}, &fields.QualifiedHash{}
+	if err := id.UnmarshalText([]byte(parts[0])); err != nil {
+		return nil, nil, created, fmt.Errorf("failed parsing node id %q: %w", parts[0], err)
+	}
+	if err := parent.UnmarshalText([]byte(parts[1])); err != nil {
+		return nil, nil, created, fmt.Errorf("failed parsing parent id %q: %w", parts[1], err)
+	}
+	millis, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return nil, nil, created, fmt.Errorf("failed parsing creation time %q: %w", parts[2], err)
+	}
+	return id, parent, fields.Timestamp(millis).Time(), nil
+}
+
+// loadChildIndex populates the ChildCache from the child index file. It fails
+// without modifying the ChildCache if the index is missing, cannot be parsed,
+// or does not describe exactly the node files present in the grove. The
+// caller must hold the mutex exclusively.
+func (g *Grove) loadChildIndex() error {
+	file, err := g.Open(childIndexFile)
+	if err != nil {
+		return fmt.Errorf("failed opening child index: %w", err)
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed reading child index: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || scanner.Text() != childIndexHeader {
+		return fmt.Errorf("child index does not begin with %q", childIndexHeader)
+	}
+	cache := NewChildCache()
+	created := make(map[string]time.Time)
+	for scanner.Scan() {
+		id, parent, t, err := parseChildIndexRecord(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("failed parsing child index: %w", err)
+		}
+		cache.Add(parent, id)
+		cache.Add(id)
+		created[id.String()] = t
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed scanning child index: %w", err)
+	}
+
+	// ensure that the index matches the node files that are actually present
+	nodeInfo, err := g.getAllNodeFileInfo()
+	if err != nil {
+		return fmt.Errorf("failed listing node file candidates: %w", err)
+	}
+	onDisk := make(map[string]struct{}, len(nodeInfo))
+	for _, info := range nodeInfo {
+		onDisk[info.Name()] = struct{}{}
+	}
+	if len(onDisk) != len(created) {
+		return fmt.Errorf("child index lists %d nodes, but grove contains %d", len(created), len(onDisk))
+	}
+	for id := range created {
+		if _, present := onDisk[id]; !present {
+			return fmt.Errorf("child index lists node %s, which is not in the grove", id)
+		}
+	}
+
+	for parent, children := range cache.Elements {
+		submap, inMap := g.ChildCache.Elements[parent]
+		if !inMap {
+			submap = make(map[string]*fields.QualifiedHash, len(children))
+			g.ChildCache.Elements[parent] = submap
+		}
+		for key, child := range children {
+			submap[key] = child
+		}
+	}
+	if g.created == nil {
+		g.created = make(map[string]time.Time)
+	}
+	for id, t := range created {
+		g.created[id] = t
+	}
+	return nil
+}
+
+// loadChildIndexOnce populates the ChildCache from the child index the first
+// time that it is called. If there is no usable index, but the ChildCache
+// already describes every node file in the grove (as it does for a new
+// grove), the ChildCache is considered complete anyway so that an index can
+// be written for it. The caller must hold the mutex exclusively.
+func (g *Grove) loadChildIndexOnce() {
+	if g.indexChecked {
+		return
+	}
+	g.indexChecked = true
+	if err := g.loadChildIndex(); err == nil || g.cacheCoversGrove() {
+		g.cacheComplete = true
+	}
+}
+
+// cacheCoversGrove returns whether every node file in the grove is already
+// described by the ChildCache. The caller must hold the mutex.
+func (g *Grove) cacheCoversGrove() bool {
+	nodeInfo, err := g.getAllNodeFileInfo()
+	if err != nil {
+		return false
+	}
+	for _, info := range nodeInfo {
+		if _, known := g.created[info.Name()]; !known {
+			return false
+		}
+	}
+	return true
+}
+
+// writeChildIndex replaces the child index file with the current contents of
+// the ChildCache. Children whose creation time is unknown are read from disk,
+// and those that cannot be read are left out (which will cause the next
+// attempt to load the index to fail). The caller must hold the mutex.
+func (g *Grove) writeChildIndex() error {
+	var index bytes.Buffer
+	index.WriteString(childIndexHeader + "\n")
+	for parent, children := range g.ChildCache.Elements {
+		for _, child := range children {
+			t, known := g.created[child.String()]
+			if !known {
+				header, err := g.header(nodeFilename(child))
+				if err != nil {
+					continue
+				}
+				t = header.Created
+			}
+			index.WriteString(childIndexRecord(child.String(), parent, t))
+		}
+	}
+	if renamer, ok := g.FS.(RenameFS); ok {
+		return writeAtomically(renamer, childIndexFile, index.Bytes())
+	}
+	file, err := g.Create(childIndexFile)
+	if err != nil {
+		return fmt.Errorf("failed to create child index: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(index.Bytes()); err != nil {
+		return fmt.Errorf("failed to write child index: %w", err)
+	}
+	return nil
+}
+
+// appendChildIndex records a newly-added node at the end of the child index.
+// It fails with an error matching os.ErrNotExist if there is no child index.
+// The caller must hold the mutex exclusively.
+func (g *Grove) appendChildIndex(node forest.Node) error {
+	file, err := g.OpenFile(childIndexFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed opening child index: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write([]byte(childIndexRecord(node.ID().String(), node.ParentID().String(), node.CreatedAt()))); err != nil {
+		return fmt.Errorf("failed appending to child index: %w", err)
+	}
+	return nil
+}
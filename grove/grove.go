@@ -17,6 +17,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
@@ -77,6 +79,39 @@ func (r RelativeFS) Remove(path string) error {
 	return os.Remove(r.resolve(path))
 }
 
+// Stat returns information about the given path relative to the root
+// of the RelativeFS.
+func (r RelativeFS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(r.resolve(path))
+}
+
+// StatFS is an FS that can provide information about a file without
+// opening it. Groves use it (when available) to check for the presence
+// of nodes cheaply.
+type StatFS interface {
+	FS
+	Stat(path string) (os.FileInfo, error)
+}
+
+// ensure RelativeFS satisfies the StatFS interface
+var _ StatFS = RelativeFS{}
+
+// Rename moves the file at oldpath to newpath (both relative to the root of
+// the RelativeFS), replacing any existing file at newpath.
+func (r RelativeFS) Rename(oldpath, newpath string) error {
+	return os.Rename(r.resolve(oldpath), r.resolve(newpath))
+}
+
+// RenameFS is an FS that can atomically replace one file with another. Groves
+// use it (when available) so that node files never appear partially written.
+type RenameFS interface {
+	FS
+	Rename(oldpath, newpath string) error
+}
+
+// ensure RelativeFS satisfies the RenameFS interface
+var _ RenameFS = RelativeFS{}
+
 // Grove is an on-disk store for arbor forest nodes. It maintains internal
 // in-memory caches in order to accelerate certain expensive operations.
 // Because of this, it must be notified when new content appears on disk.
@@ -85,12 +120,50 @@ func (r RelativeFS) Remove(path string) error {
 // (it is not an error to call Add() on a node already present in a store).
 // Another (potentially more expensive) way to ensure consistency in the
 // event of a disk modification is to call RebuildChildCache().
+//
+// The contents of the ChildCache are persisted in an index file within the
+// grove, so that Children can be answered without parsing every node file
+// after the grove is reopened. The index is kept up to date by Add and
+// RemoveSubtree and rewritten whenever the ChildCache is rebuilt. If the index
+// is missing or does not match the node files on disk, it is ignored and
+// rebuilt by scanning the grove.
+//
+// The methods of a Grove are safe for concurrent use by multiple goroutines.
+// This guarantee does not extend to the embedded FS, NodeCache, and
+// ChildCache, which must not be accessed directly while the Grove is in use.
 type Grove struct {
 	FS
 	NodeCache *store.MemoryStore
 	*ChildCache
+
+	// created records the creation time of each node in the ChildCache so
+	// that children can be sorted without reading them from disk
+	created map[string]time.Time
+
+	// indexChecked is set once the grove has attempted to populate the
+	// ChildCache from its child index file, and cacheComplete is set once
+	// the ChildCache is known to describe every node file in the grove
+	indexChecked, cacheComplete bool
+
+	// readOnly is set for groves that cannot be modified, such as those
+	// created by NewFromFSReadOnly
+	readOnly bool
+
+	// mutex guards the caches and the files of the grove. Methods that
+	// may modify either must hold it exclusively.
+	mutex sync.RWMutex
 }
 
+// ensure Grove supports paging through children
+var _ store.ChildrenPager = &Grove{}
+
+// ensure Grove can report statistics about its contents
+var _ store.StatsReporter = &Grove{}
+var _ store.RangeQuerier = &Grove{}
+var _ store.Iterable = &Grove{}
+var _ store.Reindexer = &Grove{}
+var _ store.BatchGetter = &Grove{}
+
 // New constructs a Grove that stores nodes in a hierarchy rooted at
 // the given path.
 func New(root string) (*Grove, error) {
@@ -98,8 +171,19 @@ func New(root string) (*Grove, error) {
 }
 
 // NewWithFS constructs a Grove using the given FS implementation to
-// access its nodes. This is primarily useful for testing.
+// access its nodes. This is primarily useful for testing. Temporary files left
+// behind by an Add that was interrupted by a crash are removed.
 func NewWithFS(fs FS) (*Grove, error) {
+	g, err := newGrove(fs)
+	if err != nil {
+		return nil, err
+	}
+	g.removeTempFiles()
+	return g, nil
+}
+
+// newGrove constructs a Grove using the given FS without touching its files.
+func newGrove(fs FS) (*Grove, error) {
 	if fs == nil {
 		return nil, fmt.Errorf("fs cannot be nil")
 	}
@@ -107,6 +191,7 @@ func NewWithFS(fs FS) (*Grove, error) {
 		FS:         fs,
 		NodeCache:  store.NewMemoryStore(),
 		ChildCache: NewChildCache(),
+		created:    make(map[string]time.Time),
 	}, nil
 }
 
@@ -117,11 +202,45 @@ func NewWithFS(fs FS) (*Grove, error) {
 // actual node struct. If the file holding a node exists on disk but was unable
 // to be opened, read, or parsed, `present` will still be false.
 func (g *Grove) Get(nodeID *fields.QualifiedHash) (node forest.Node, present bool, err error) {
+	// Get may populate the node cache, so it needs exclusive access
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.get(nodeID)
+}
+
+// GetMany returns the nodes with the given IDs that are present in the grove,
+// keyed by the string form of their IDs. The grove is locked once for the
+// whole batch, and nodes already in the NodeCache are returned without
+// touching the disk. The FS interface cannot open files relative to an open
+// directory, so each remaining node still costs one file open.
+func (g *Grove) GetMany(ids []*fields.QualifiedHash) (map[string]forest.Node, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	nodes, err := g.NodeCache.GetMany(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up cached nodes: %w", err)
+	}
+	for _, id := range ids {
+		if _, cached := nodes[id.String()]; cached {
+			continue
+		}
+		node, present, err := g.get(id)
+		if err != nil {
+			return nil, err
+		} else if present {
+			nodes[id.String()] = node
+		}
+	}
+	return nodes, nil
+}
+
+// get implements Get. The caller must hold the mutex exclusively.
+func (g *Grove) get(nodeID *fields.QualifiedHash) (node forest.Node, present bool, err error) {
 	node, inCache, _ := g.NodeCache.Get(nodeID)
 	if inCache {
 		return node, true, nil
 	}
-	filename := nodeID.String()
+	filename := nodeFilename(nodeID)
 	file, err := g.Open(filename)
 	// if the file doesn't exist, just return false with no error
 	if errors.Is(err, os.ErrNotExist) {
@@ -131,6 +250,7 @@ func (g *Grove) Get(nodeID *fields.QualifiedHash) (node forest.Node, present boo
 	if err != nil {
 		return nil, false, fmt.Errorf("failed opening node file \"%s\": %w", filename, err)
 	}
+	defer file.Close()
 	b, err := ioutil.ReadAll(file)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed reading bytes from \"%s\": %w", filename, err)
@@ -143,6 +263,77 @@ func (g *Grove) Get(nodeID *fields.QualifiedHash) (node forest.Node, present boo
 	return node, true, nil
 }
 
+// headerReadSize is the number of bytes of a node file first read by Header.
+// It is large enough to hold the header of a node with modest metadata.
+const headerReadSize = 512
+
+// Header returns the fields common to all nodes for the node with the given id,
+// reading only as much of its file as is needed to parse them. This is much
+// cheaper than Get for nodes with large content, and does not add the node to
+// the NodeCache. If there is no such node, the returned error matches
+// os.ErrNotExist with errors.Is.
+func (g *Grove) Header(nodeID *fields.QualifiedHash) (forest.NodeHeader, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.header(nodeFilename(nodeID))
+}
+
+// header implements Header for the node file with the given name. The caller
+// must hold the mutex.
+func (g *Grove) header(filename string) (forest.NodeHeader, error) {
+	file, err := g.Open(filename)
+	if err != nil {
+		return forest.NodeHeader{}, fmt.Errorf("failed opening node file \"%s\": %w", filename, err)
+	}
+	defer file.Close()
+	var b []byte
+	for size := headerReadSize; ; size *= 2 {
+		chunk := make([]byte, size)
+		n, readErr := io.ReadFull(file, chunk)
+		b = append(b, chunk[:n]...)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return forest.NodeHeader{}, fmt.Errorf("failed reading bytes from \"%s\": %w", filename, readErr)
+		}
+		header, err := forest.HeaderOf(b)
+		// if the header is truncated, try again with more of the file
+		// unless the whole file has already been read
+		if errors.Is(err, forest.ErrTruncated) && readErr == nil {
+			continue
+		} else if err != nil {
+			return forest.NodeHeader{}, fmt.Errorf("failed unmarshalling node header from \"%s\": %w", filename, err)
+		}
+		return header, nil
+	}
+}
+
+// Has reports whether a node with the given id is present in the grove. It checks
+// for the node's file without reading or parsing it, so it is much cheaper than Get.
+// If the grove's FS does not implement StatFS, the file is opened (but not read)
+// instead.
+func (g *Grove) Has(nodeID *fields.QualifiedHash) (bool, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if inCache, _ := g.NodeCache.Has(nodeID); inCache {
+		return true, nil
+	}
+	filename := nodeFilename(nodeID)
+	var err error
+	if statFS, ok := g.FS.(StatFS); ok {
+		_, err = statFS.Stat(filename)
+	} else {
+		var file File
+		if file, err = g.Open(filename); err == nil {
+			file.Close()
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed checking for node file \"%s\": %w", filename, err)
+	}
+	return true, nil
+}
+
 // getAllNodeFileInfo returns a slice of information about all node files
 // within the grove.
 func (g *Grove) getAllNodeFileInfo() ([]os.FileInfo, error) {
@@ -172,31 +363,70 @@ func (g *Grove) getAllNodeFileInfo() ([]os.FileInfo, error) {
 // nodeFromInfo converts the info about a file into a node extracted from
 // the contents of that file (it opens, reads, and parses the file).
 func (g *Grove) nodeFromInfo(info os.FileInfo) (forest.Node, error) {
-	nodeIDString := info.Name()
+	node, err := g.readNodeFile(info.Name())
+	if err != nil {
+		return nil, err
+	}
+	_ = g.NodeCache.Add(node)
+	return node, nil
+}
+
+// readNodeFile returns the node stored in the file with the given name,
+// taking it from the node cache if possible. Unlike nodeFromInfo, it does not
+// add the node to the cache, so the caller only needs to hold the mutex for
+// reading.
+func (g *Grove) readNodeFile(name string) (forest.Node, error) {
 	nodeID := &fields.QualifiedHash{}
-	if err := nodeID.UnmarshalText([]byte(nodeIDString)); err != nil {
-		return nil, fmt.Errorf("unable to parse %s as a node id: %w", nodeIDString, err)
+	if err := nodeID.UnmarshalText([]byte(name)); err != nil {
+		return nil, fmt.Errorf("unable to parse %s as a node id: %w", name, err)
 	}
 	if node, present, _ := g.NodeCache.Get(nodeID); present {
 		return node, nil
 	}
-	nodeFile, err := g.Open(info.Name())
+	nodeFile, err := g.Open(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed opening node file %s: %w", info.Name(), err)
+		return nil, fmt.Errorf("failed opening node file %s: %w", name, err)
 	}
 	defer nodeFile.Close()
 	nodeData, err := ioutil.ReadAll(nodeFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed reading node file %s: %w", info.Name(), err)
+		return nil, fmt.Errorf("failed reading node file %s: %w", name, err)
 	}
 	node, err := forest.UnmarshalBinaryNode(nodeData)
 	if err != nil {
-		return nil, fmt.Errorf("failed parsing node file %s: %w", info.Name(), err)
+		return nil, fmt.Errorf("failed parsing node file %s: %w", name, err)
 	}
-	_ = g.NodeCache.Add(node)
 	return node, nil
 }
 
+// ForEach invokes visitor on every node in the grove, reading and parsing
+// their files one at a time. Nodes read this way are not added to the node
+// cache. The visitor is called without holding the grove's mutex, so it may
+// use the grove, but nodes added during the iteration may not be visited.
+func (g *Grove) ForEach(visitor func(forest.Node) error) error {
+	g.mutex.RLock()
+	nodeInfo, err := g.getAllNodeFileInfo()
+	g.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed listing node file candidates: %w", err)
+	}
+	for _, info := range nodeInfo {
+		g.mutex.RLock()
+		node, err := g.readNodeFile(info.Name())
+		g.mutex.RUnlock()
+		if errors.Is(err, os.ErrNotExist) {
+			// removed since the grove was listed
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := visitor(node); err != nil {
+			return fmt.Errorf("visitor function errored on %s: %w", node.ID(), err)
+		}
+	}
+	return nil
+}
+
 // nodesFromInfo batch-converts a slice of file info into a slice of
 // forest nodes by calling nodeFromInfo on each.
 func (g *Grove) nodesFromInfo(info []os.FileInfo) ([]forest.Node, error) {
@@ -229,11 +459,28 @@ func (g *Grove) allNodes() ([]forest.Node, error) {
 // during the search for child nodes will cause the entire operation to
 // error.
 func (g *Grove) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	g.mutex.RLock()
+	if children, inCache := g.ChildCache.Get(id); inCache {
+		defer g.mutex.RUnlock()
+		return g.sortChildren(children)
+	}
+	g.mutex.RUnlock()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.children(id)
+}
+
+// children implements Children. The caller must hold the mutex exclusively.
+func (g *Grove) children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
 	children, inCache := g.ChildCache.Get(id)
 	if inCache {
-		return children, nil
+		return g.sortChildren(children)
+	}
+	g.loadChildIndexOnce()
+	if children, inCache = g.ChildCache.Get(id); inCache {
+		return g.sortChildren(children)
 	}
-	if err := g.RebuildChildCache(); err != nil {
+	if err := g.rebuildChildCache(); err != nil {
 		return nil, fmt.Errorf("failed rebuilding child cache: %w", err)
 	}
 	children, inCache = g.ChildCache.Get(id)
@@ -241,39 +488,88 @@ func (g *Grove) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, err
 		return []*fields.QualifiedHash{}, nil
 	}
 
+	return g.sortChildren(children)
+}
+
+// sortChildren sorts the given children in the order defined by
+// forest.ChildBefore and returns them. The creation time of any child added
+// to the ChildCache without using cacheChildInfo is read from the header of
+// its file. The caller must hold the mutex.
+func (g *Grove) sortChildren(children []*fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	created := make([]time.Time, len(children))
+	for i, child := range children {
+		t, known := g.created[child.String()]
+		if !known {
+			header, err := g.header(nodeFilename(child))
+			if err != nil {
+				return nil, fmt.Errorf("failed looking up creation time of child %s: %w", child, err)
+			}
+			t = header.Created
+		}
+		created[i] = t
+	}
+	sort.Sort(childSorter{children, created})
 	return children, nil
 }
 
+// childSorter sorts children and their creation times together.
+type childSorter struct {
+	children []*fields.QualifiedHash
+	created  []time.Time
+}
+
+func (c childSorter) Len() int {
+	return len(c.children)
+}
+
+func (c childSorter) Less(i, j int) bool {
+	return forest.ChildBefore(c.created[i], c.children[i], c.created[j], c.children[j])
+}
+
+func (c childSorter) Swap(i, j int) {
+	c.children[i], c.children[j] = c.children[j], c.children[i]
+	c.created[i], c.created[j] = c.created[j], c.created[i]
+}
+
+// ChildrenCount returns the number of known children of the specified ID.
+// Like Children, it may need to scan every node in the grove the first time
+// a given ID is queried.
+func (g *Grove) ChildrenCount(id *fields.QualifiedHash) (int, error) {
+	children, err := g.Children(id)
+	if err != nil {
+		return 0, err
+	}
+	return len(children), nil
+}
+
+// ChildrenPage returns at most limit children of the specified ID, skipping
+// the first offset children. Children are in the same order as returned by
+// Children.
+func (g *Grove) ChildrenPage(id *fields.QualifiedHash, offset, limit int) ([]*fields.QualifiedHash, error) {
+	children, err := g.Children(id)
+	if err != nil {
+		return nil, err
+	}
+	return store.Page(children, offset, limit)
+}
+
 // Recent returns a slice of the most recently-created nodes of the given type.
 // The slice is sorted so that the most-recently-created nodes are at the beginning.
 func (g *Grove) Recent(nodeType fields.NodeType, quantity int) ([]forest.Node, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 	nodes, err := g.allNodes()
 	if err != nil {
 		return nil, fmt.Errorf("failed getting all nodes from grove: %w", err)
 	}
-	// TODO: find a cleaner way to sort nodes by time
 	sort.Slice(nodes, func(i, j int) bool {
-		var a, b forest.CommonNode
-		switch n := nodes[i].(type) {
-		case *forest.Identity:
-			a = n.CommonNode
-		case *forest.Community:
-			a = n.CommonNode
-		case *forest.Reply:
-			a = n.CommonNode
-		}
-		switch n := nodes[j].(type) {
-		case *forest.Identity:
-			b = n.CommonNode
-		case *forest.Community:
-			b = n.CommonNode
-		case *forest.Reply:
-			b = n.CommonNode
-		}
-		return a.Created > b.Created
+		return nodes[i].CreatedAt().After(nodes[j].CreatedAt())
 	})
-	rightType := make([]forest.Node, 0, quantity)
+	rightType := []forest.Node{}
 	for _, node := range nodes {
+		if len(rightType) >= quantity {
+			break
+		}
 		switch node.(type) {
 		case *forest.Identity:
 			if nodeType == fields.NodeTypeIdentity {
@@ -289,28 +585,210 @@ func (g *Grove) Recent(nodeType fields.NodeType, quantity int) ([]forest.Node, e
 			}
 		}
 	}
-	if len(rightType) > quantity {
-		rightType = rightType[:quantity]
-	}
 	return rightType, nil
 }
 
+// Between returns the nodes of the given type created within the inclusive
+// range [start, end], sorted from oldest to newest. Only the headers of nodes
+// outside of the range are read.
+func (g *Grove) Between(nodeType fields.NodeType, start, end fields.Timestamp) ([]forest.Node, error) {
+	// reading nodes may populate the node cache, so this needs exclusive access
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	nodeInfo, err := g.getAllNodeFileInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed listing node file candidates: %w", err)
+	}
+	var nodes []forest.Node
+	for _, info := range nodeInfo {
+		header, err := g.header(info.Name())
+		if err != nil {
+			return nil, err
+		}
+		created := fields.TimestampFrom(header.Created)
+		if header.Type != nodeType || created < start || created > end {
+			continue
+		}
+		node, err := g.nodeFromInfo(info)
+		if err != nil {
+			return nil, fmt.Errorf("failed transforming fileInfo into Node: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return forest.ChildBefore(nodes[i].CreatedAt(), nodes[i].ID(), nodes[j].CreatedAt(), nodes[j].ID())
+	})
+	return nodes, nil
+}
+
+// Validate checks the signature of every node in the grove. The identity that
+// signed each node is looked up in the grove and then, if it is not there, in
+// resolve (which may be nil). It returns the IDs of nodes whose signatures are
+// invalid separately from the IDs of nodes whose signing identity could not be
+// found, and only returns an error if the nodes or identities could not be
+// read.
+func (g *Grove) Validate(resolve forest.Store) (invalid, unverifiable []*fields.QualifiedHash, err error) {
+	// reading nodes may populate the node cache, so this needs exclusive access
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	nodes, err := g.allNodes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting all nodes from grove: %w", err)
+	}
+	for _, node := range nodes {
+		signer, isIdentity := node.(*forest.Identity)
+		if !isIdentity {
+			signer, err = g.resolveIdentity(node.AuthorID(), resolve)
+			if err != nil {
+				return nil, nil, err
+			} else if signer == nil {
+				unverifiable = append(unverifiable, node.ID())
+				continue
+			}
+		}
+		if valid, err := forest.ValidateSignatureWithKey(node, &signer.PublicKey); err != nil || !valid {
+			invalid = append(invalid, node.ID())
+		}
+	}
+	return invalid, unverifiable, nil
+}
+
+// resolveIdentity looks up the identity with the given ID in the grove and
+// then in resolve (if it is not nil). It returns nil if the identity cannot be
+// found. The caller must hold the mutex exclusively.
+func (g *Grove) resolveIdentity(id *fields.QualifiedHash, resolve forest.Store) (*forest.Identity, error) {
+	node, present, err := g.get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up identity %s: %w", id, err)
+	}
+	if !present && resolve != nil {
+		node, present, err = resolve.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed resolving identity %s: %w", id, err)
+		}
+	}
+	if !present {
+		return nil, nil
+	}
+	identity, isIdentity := node.(*forest.Identity)
+	if !isIdentity {
+		return nil, nil
+	}
+	return identity, nil
+}
+
 // RebuildChildCache must be called each time a node is inserted into the
 // underlying storage without actually calling Add() on the grove. Without
 // this, calls to Children() will not always include new results.
 func (g *Grove) RebuildChildCache() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.rebuildChildCache()
+}
+
+// RebuildIndex discards the grove's caches and rebuilds them from the node
+// files on disk, as Compact does.
+func (g *Grove) RebuildIndex() error {
+	_, err := g.Compact()
+	return err
+}
+
+// rebuildChildCache implements RebuildChildCache. The caller must hold the
+// mutex exclusively.
+func (g *Grove) rebuildChildCache() error {
 	nodes, err := g.allNodes()
 	if err != nil {
 		return fmt.Errorf("failed getting all nodes from grove: %w", err)
 	}
 	for _, node := range nodes {
-		g.CacheChildInfo(node)
+		g.cacheChildInfo(node)
 	}
+	g.indexChecked, g.cacheComplete = true, true
+	// the index only accelerates future lookups, so failing to write it
+	// just means that it will be rebuilt again later
+	_ = g.writeChildIndex()
 	return nil
 }
 
+// Stats scans the grove's files and reports the number of nodes of each type
+// and the total size of the node files. Every node file is parsed, so this is
+// as expensive as loading the whole grove.
+func (g *Grove) Stats() (store.StoreStats, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var stats store.StoreStats
+	nodeInfo, err := g.getAllNodeFileInfo()
+	if err != nil {
+		return stats, fmt.Errorf("failed listing node file candidates: %w", err)
+	}
+	for _, info := range nodeInfo {
+		node, err := g.nodeFromInfo(info)
+		if err != nil {
+			return stats, fmt.Errorf("failed reading node file %s: %w", info.Name(), err)
+		}
+		stats.Count(node)
+		stats.Bytes += info.Size()
+	}
+	return stats, nil
+}
+
+// Compact rescans the grove's files and rebuilds its internal caches from
+// scratch, dropping any cached information about nodes whose files no longer
+// exist. Temporary files left behind by an interrupted Add are deleted. It
+// returns the number of stale cache entries that were removed. It is safe to
+// call concurrently with other methods on the grove.
+func (g *Grove) Compact() (removed int, err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if !g.readOnly {
+		g.removeTempFiles()
+	}
+	nodes, err := g.allNodes()
+	if err != nil {
+		return 0, fmt.Errorf("failed getting all nodes from grove: %w", err)
+	}
+	oldNodeCache, oldChildCache := g.NodeCache, g.ChildCache
+	g.NodeCache, g.ChildCache = store.NewMemoryStore(), NewChildCache()
+	g.created = make(map[string]time.Time)
+	for _, node := range nodes {
+		_ = g.NodeCache.Add(node)
+		g.cacheChildInfo(node)
+	}
+	for id := range oldNodeCache.Items {
+		if _, present := g.NodeCache.Items[id]; !present {
+			removed++
+		}
+	}
+	for parent, children := range oldChildCache.Elements {
+		newChildren, present := g.ChildCache.Elements[parent]
+		if !present {
+			removed++
+		}
+		for child := range children {
+			if _, present := newChildren[child]; !present {
+				removed++
+			}
+		}
+	}
+	g.indexChecked, g.cacheComplete = true, true
+	_ = g.writeChildIndex()
+	return removed, nil
+}
+
 // CacheChildInfo updates the child cache information for the given node.
 func (g *Grove) CacheChildInfo(node forest.Node) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.cacheChildInfo(node)
+}
+
+// cacheChildInfo implements CacheChildInfo. The caller must hold the mutex
+// exclusively.
+func (g *Grove) cacheChildInfo(node forest.Node) {
+	if g.created == nil {
+		g.created = make(map[string]time.Time)
+	}
+	g.created[node.ID().String()] = node.CreatedAt()
 	// ensure we cache this node's relationship to its parent
 	g.ChildCache.Add(node.ParentID(), node.ID())
 	// ensure we cache this node's existence (if it turns out that we
@@ -322,9 +800,22 @@ func (g *Grove) CacheChildInfo(node forest.Node) {
 // Add inserts the node into the grove. If the given node is already in the
 // grove, Add will do nothing. It is not an error to insert a node more than
 // once.
+//
+// If the grove's FS implements RenameFS, the node is written to a temporary
+// file that is renamed into place once complete, so a crash or failed write
+// never leaves a partial node file behind. A temporary file left by a crash is
+// removed when the grove is next opened or compacted. Otherwise the node file is written
+// in place, and a failure partway through may leave an unparseable file.
+//
+// Add fails with store.ErrReadOnly if the grove is read-only.
 func (g *Grove) Add(node forest.Node) error {
-	g.CacheChildInfo(node)
-	if _, alreadyPresent, err := g.Get(node.ID()); err != nil {
+	if g.readOnly {
+		return store.ErrReadOnly
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.cacheChildInfo(node)
+	if _, alreadyPresent, err := g.get(node.ID()); err != nil {
 		return fmt.Errorf("failed checking whether node already in grove: %w", err)
 	} else if alreadyPresent {
 		return nil
@@ -334,7 +825,37 @@ func (g *Grove) Add(node forest.Node) error {
 		return fmt.Errorf("failed to serialize node: %w", err)
 	}
 
-	id := node.ID().String()
+	// the index must be checked against the grove's files before the new
+	// node is among them
+	g.loadChildIndexOnce()
+	if err := g.writeNode(nodeFilename(node.ID()), data); err != nil {
+		return err
+	}
+	// a failure here leaves an index that doesn't match the grove's files,
+	// so it will be rebuilt the next time that it is loaded
+	if err := g.appendChildIndex(node); errors.Is(err, os.ErrNotExist) && g.cacheComplete {
+		_ = g.writeChildIndex()
+	}
+	return nil
+}
+
+// nodeFilename returns the name of the file holding the node with the given
+// ID. A QualifiedHash that cannot be marshalled could not have been parsed
+// from a node either, so the error from forest.IDFilename is ignored in the
+// same way that QualifiedHash.String ignores it.
+func nodeFilename(id *fields.QualifiedHash) string {
+	name, _ := forest.IDFilename(id)
+	return name
+}
+
+// writeNode stores the serialized node data in the file with the given name.
+func (g *Grove) writeNode(id string, data []byte) error {
+	if renamer, ok := g.FS.(RenameFS); ok {
+		return writeAtomically(renamer, id, data)
+	}
+	// Without the ability to rename, a failure partway through writing will
+	// leave a partial node file behind that cannot be parsed. It must be
+	// removed by hand before the node can be added again.
 	nodeFile, err := g.Create(id)
 	if err != nil {
 		return fmt.Errorf("failed to create file for node %s: %w", id, err)
@@ -348,6 +869,66 @@ func (g *Grove) Add(node forest.Node) error {
 	return nil
 }
 
+// tempFilePrefix begins the names of files that are being written. Node files
+// always begin with the name of a hash type, so these are never mistaken for
+// nodes.
+const tempFilePrefix = ".tmp-"
+
+// removeTempFiles deletes any temporary files in the grove, which can only be
+// left behind if the process crashed while writing a node. It must not be
+// called while another Add may be in progress, so the caller must hold the
+// mutex exclusively (or be constructing the grove). Failures are ignored,
+// since leftover temporary files are never mistaken for nodes.
+func (g *Grove) removeTempFiles() {
+	rootDir, err := g.Open("")
+	if err != nil {
+		return
+	}
+	info, err := rootDir.Readdir(-1)
+	rootDir.Close()
+	if err != nil {
+		return
+	}
+	for _, fileInfo := range info {
+		if strings.HasPrefix(fileInfo.Name(), tempFilePrefix) {
+			_ = g.Remove(fileInfo.Name())
+		}
+	}
+}
+
+// writeAtomically writes data into a temporary file and then renames it to
+// name, so that the file at name is either absent or complete. If anything
+// fails, the temporary file is removed.
+func writeAtomically(fs RenameFS, name string, data []byte) (err error) {
+	tempName := tempFilePrefix + name
+	tempFile, err := fs.Create(tempName)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for node %s: %w", name, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = fs.Remove(tempName)
+		}
+	}()
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write data to temporary file for node %s: %w", name, err)
+	}
+	if syncer, ok := tempFile.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			tempFile.Close()
+			return fmt.Errorf("failed to flush temporary file for node %s: %w", name, err)
+		}
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file for node %s: %w", name, err)
+	}
+	if err := fs.Rename(tempName, name); err != nil {
+		return fmt.Errorf("failed to move temporary file into place for node %s: %w", name, err)
+	}
+	return nil
+}
+
 // GetIdentity returns an Identity node with the given ID (if it is present
 // in the grove). This operation may be faster than using Get, as the grove
 // may be able to do less search work when it knows the type of node you're
@@ -413,38 +994,50 @@ func (g *Grove) GetReply(communityID, conversationID, replyID *fields.QualifiedH
 }
 
 // CopyInto copies all nodes from the store into the provided store.
-//
-// BUG(whereswaldon): this method is not yet implemented. It requires
-// more extensive file manipulation than other Grove methods (listing
-// directory contents) and has therefore been deprioritized in favor
-// of the functionality that can be implemented simply. However, it is
-// implementable, and should be done as soon as is feasible.
 func (g *Grove) CopyInto(other forest.Store) error {
-	return fmt.Errorf("method CopyInto() is not currently implemented on Grove")
+	return g.ForEach(other.Add)
 }
 
 // RemoveSubtree removes the subtree rooted at the node
-// with the provided ID from the grove.
+// with the provided ID from the grove. It fails with store.ErrReadOnly if the
+// grove is read-only.
 func (g *Grove) RemoveSubtree(id *fields.QualifiedHash) error {
-	children, err := g.Children(id)
+	if g.readOnly {
+		return store.ErrReadOnly
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if err := g.removeSubtree(id); err != nil {
+		return err
+	}
+	// a stale index will be detected and rebuilt the next time it is loaded
+	_ = g.writeChildIndex()
+	return nil
+}
+
+// removeSubtree implements RemoveSubtree. The caller must hold the mutex
+// exclusively.
+func (g *Grove) removeSubtree(id *fields.QualifiedHash) error {
+	children, err := g.children(id)
 	if err != nil {
 		return fmt.Errorf("failed looking up children of %s: %w", id, err)
 	}
 	for _, child := range children {
-		if err := g.RemoveSubtree(child); err != nil {
+		if err := g.removeSubtree(child); err != nil {
 			return fmt.Errorf("failed removing children of %s: %w", child, err)
 		}
 	}
-	child, _, err := g.Get(id)
+	child, _, err := g.get(id)
 	if err != nil {
 		return fmt.Errorf("failed looking up child %s during removal: %w", id, err)
 	}
 	g.ChildCache.RemoveChild(child.ParentID(), id)
 	g.ChildCache.RemoveParent(id)
+	delete(g.created, id.String())
 	if err := g.NodeCache.RemoveSubtree(id); err != nil {
 		return fmt.Errorf("failed removing node %s from internal cache: %w", id, err)
 	}
-	if err := g.Remove(id.String()); err != nil {
+	if err := g.Remove(nodeFilename(id)); err != nil {
 		return fmt.Errorf("failed removing node %s from filesystem: %w", id, err)
 	}
 	return nil
@@ -1,314 +1,29 @@
 package grove_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 	"git.sr.ht/~whereswaldon/forest-go/grove"
+	"git.sr.ht/~whereswaldon/forest-go/grove/grovetest"
+	"git.sr.ht/~whereswaldon/forest-go/store"
 	"git.sr.ht/~whereswaldon/forest-go/testkeys"
 	"git.sr.ht/~whereswaldon/forest-go/twig"
 )
 
-type truncatableFile interface {
-	grove.File
-	Truncate(size int64) error
-}
-
-// fakeFile implements the grove.File interface, but is entirely in-memory.
-// This helps speed testing.
-type fakeFile struct {
-	data []byte
-	*bytes.Buffer
-	name    string
-	mode    os.FileMode
-	modtime time.Time
-}
-
-var _ os.FileInfo = &fakeFile{}
-var _ truncatableFile = &fakeFile{}
-
-func newFakeFile(name string, content []byte) *fakeFile {
-	return &fakeFile{
-		name:    name,
-		mode:    os.FileMode(0660),
-		modtime: time.Now(),
-		data:    content,
-		Buffer:  bytes.NewBuffer(content),
-	}
-}
-
-func (f *fakeFile) Name() string {
-	return f.name
-}
-
-func (f *fakeFile) Size() int64 {
-	return int64(f.Buffer.Len())
-}
-
-func (f *fakeFile) Mode() os.FileMode {
-	return f.mode
-}
-
-func (f *fakeFile) ModTime() time.Time {
-	return f.modtime
-}
-
-func (f *fakeFile) IsDir() bool {
-	return false
-}
-
-func (f *fakeFile) Sys() interface{} {
-	return nil
-}
-
-// needed to implement Close so that fakeFile is a io.ReadWriteCloser
-func (f *fakeFile) Close() error {
-	return nil
-}
-
-func (f *fakeFile) Readdir(n int) ([]os.FileInfo, error) {
-	return []os.FileInfo{}, nil
-}
-
-// ResetBuffer creates a new Buffer with the file's data. This is useful
-// for ensuring that a given fakeFile can be read more than once. Calling
-// this method effectively resets the contents of the file to be correct
-// after the file has been read (reading the file will empty it).
-func (f *fakeFile) ResetBuffer() {
-	f.Buffer = bytes.NewBuffer(f.data)
-}
-
-// needed to implement truncatableFile
-func (f *fakeFile) Truncate(size int64) error {
-	f.Buffer.Truncate(int(size))
-	f.modtime = time.Now()
-	return nil
-}
-
-// errFile implements the grove.File interface and wraps another grove.File.
-// If the errFile's error field is set to nil, it is a transparent wrapper
-// for the underlying File. If the field is set to a non-nil error value,
-// this will be returned from all operations that can return an error.
-type errFile struct {
-	error
-	wrappedFile truncatableFile
-}
-
-var _ truncatableFile = &errFile{}
-var _ os.FileInfo = &errFile{}
-
-func NewErrFile(file truncatableFile) *errFile {
-	return &errFile{
-		wrappedFile: file,
-	}
-}
-
-func (e *errFile) Name() string {
-	return e.wrappedFile.Name()
-}
-
-func (e *errFile) Size() int64 {
-	if fake, implements := e.wrappedFile.(os.FileInfo); implements {
-		return fake.Size()
-	}
-	return 0
-}
-
-func (e *errFile) Mode() os.FileMode {
-	if fake, implements := e.wrappedFile.(os.FileInfo); implements {
-		return fake.Mode()
-	}
-	return os.FileMode(0660)
-}
-
-func (e *errFile) ModTime() time.Time {
-	if fake, implements := e.wrappedFile.(os.FileInfo); implements {
-		return fake.ModTime()
-	}
-	return time.Now()
-}
-
-func (e *errFile) IsDir() bool {
-	if fake, implements := e.wrappedFile.(os.FileInfo); implements {
-		return fake.IsDir()
-	}
-	return false
-}
-
-func (e *errFile) Sys() interface{} {
-	if fake, implements := e.wrappedFile.(os.FileInfo); implements {
-		return fake.Sys()
-	}
-	return nil
-}
-
-func (e *errFile) Read(b []byte) (int, error) {
-	if e.error != nil {
-		return 0, e.error
-	}
-	return e.wrappedFile.Read(b)
-}
-
-func (e *errFile) Write(b []byte) (int, error) {
-	if e.error != nil {
-		return 0, e.error
-	}
-	return e.wrappedFile.Write(b)
-}
-
-func (e *errFile) Close() error {
-	if e.error != nil {
-		return e.error
-	}
-	return e.wrappedFile.Close()
-}
-
-func (e *errFile) Readdir(n int) ([]os.FileInfo, error) {
-	if e.error != nil {
-		return nil, e.error
-	}
-	return e.wrappedFile.Readdir(n)
-}
-
-func (e *errFile) Truncate(size int64) error {
-	if e.error != nil {
-		return e.error
-	}
-	return e.wrappedFile.Truncate(size)
-}
-
-// fakeFS implements grove.FS, but is entirely in-memory.
-type fakeFS struct {
-	*bytes.Buffer
-	files map[string]truncatableFile
-}
-
-var _ grove.FS = fakeFS{}
-
-func newFakeFS() fakeFS {
-	return fakeFS{
-		files: make(map[string]truncatableFile),
-	}
-}
-
-func (r fakeFS) Name() string {
-	return ""
-}
-
-func (r fakeFS) Close() error {
-	return nil
-}
-
-func (r fakeFS) Readdir(n int) ([]os.FileInfo, error) {
-	count := n
-	if count <= 0 {
-		count = len(r.files)
-	}
-	info := make([]os.FileInfo, 0, count)
-	for _, file := range r.files {
-		info = append(info, file.(os.FileInfo))
-	}
-	return info, nil
-}
-
-// Open opens the given path as an absolute path relative to the root
-// of the fakeFS
-func (r fakeFS) Open(path string) (grove.File, error) {
-	if path == "" {
-		return r, nil
-	}
-	file, exists := r.files[path]
-	if !exists {
-		return nil, os.ErrNotExist
-	}
-	return file, nil
-}
-
-// Create makes the given path as an absolute path relative to the root
-// of the fakeFS
-func (r fakeFS) Create(path string) (grove.File, error) {
-	// mimic os.Create(), so creating a file that already exists truncates
-	// the current one
-	file, exists := r.files[path]
-	if exists {
-		file.Truncate(0)
-	} else {
-		file = newFakeFile(path, []byte{})
-		r.files[path] = file
-	}
-
-	return file, nil
-}
-
-// OpenFile opens the given path as an absolute path relative to the root
-// of the fakeFS
-func (r fakeFS) OpenFile(path string, flag int, perm os.FileMode) (grove.File, error) {
-	return r.Open(path)
-}
-
-func (r fakeFS) Remove(path string) error {
-	_, exists := r.files[path]
-	if !exists {
-		return fmt.Errorf("file doesn't exist")
-	}
-	delete(r.files, path)
-	return nil
-}
-
-// errFS is a testing type that wraps an ordinary FS with the ability to
-// return a specific error on any function call.
-type errFS struct {
-	fs grove.FS
-	error
-}
-
-var _ grove.FS = errFS{}
-
-func newErrFS(fs grove.FS) *errFS {
-	return &errFS{
-		fs: fs,
-	}
-}
-
-// Open opens the given path as an absolute path relative to the root
-// of the errFS
-func (r errFS) Open(path string) (grove.File, error) {
-	if r.error != nil {
-		return nil, r.error
-	}
-	return r.fs.Open(path)
-}
-
-// Create makes the given path as an absolute path relative to the root
-// of the errFS
-func (r errFS) Create(path string) (grove.File, error) {
-	if r.error != nil {
-		return nil, r.error
-	}
-	return r.fs.Create(path)
-}
-
-// OpenFile opens the given path as an absolute path relative to the root
-// of the errFS
-func (r errFS) OpenFile(path string, flag int, perm os.FileMode) (grove.File, error) {
-	if r.error != nil {
-		return nil, r.error
-	}
-	return r.fs.OpenFile(path, flag, perm)
-}
-
-func (r errFS) Remove(path string) error {
-	if r.error != nil {
-		return r.error
-	}
-	return r.fs.Remove(path)
-}
-
 type testNodeBuilder struct {
 	*testing.T
 	*forest.Builder
@@ -335,10 +50,10 @@ func NewNodeBuilder(t *testing.T) *testNodeBuilder {
 	}
 }
 
-// newReplyFile creates a fakeFile that contains the binary data for a reply
+// newReplyFile creates a MemFile that contains the binary data for a reply
 // node that is a direct child of the given community and constructed by the
 // given builder. It returns the reply node as a convenience for testing.
-func (tnb *testNodeBuilder) newReplyFile(content string) (*forest.Reply, *fakeFile) {
+func (tnb *testNodeBuilder) newReplyFile(content string) (*forest.Reply, *grovetest.MemFile) {
 	reply, err := tnb.NewReply(tnb.Community, content, []byte{})
 	if err != nil {
 		tnb.T.Errorf("Failed generating test reply node: %v", err)
@@ -347,11 +62,11 @@ func (tnb *testNodeBuilder) newReplyFile(content string) (*forest.Reply, *fakeFi
 	if err != nil {
 		tnb.T.Errorf("Failed marshalling test reply node: %v", err)
 	}
-	return reply, newFakeFile(reply.ID().String(), b)
+	return reply, grovetest.NewMemFile(reply.ID().String(), b)
 }
 
 func TestCreateEmptyGrove(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	grove, err := grove.NewWithFS(fs)
 	if err != nil {
 		t.Fatalf("Failed to create grove with fake fs: %v", err)
@@ -369,7 +84,7 @@ func TestCreateGroveFromNil(t *testing.T) {
 }
 
 func TestGroveGet(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
 	g, err := grove.NewWithFS(fs)
@@ -387,7 +102,7 @@ func TestGroveGet(t *testing.T) {
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[replyFile.Name()] = replyFile
+	fs.Files[replyFile.Name()] = replyFile
 
 	// no nodes in fs, make sure we get nothing
 	if node, present, err := g.Get(reply.ID()); err != nil {
@@ -399,19 +114,174 @@ func TestGroveGet(t *testing.T) {
 	}
 }
 
+func TestGroveGetMany(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	fakeNodeBuilder := NewNodeBuilder(t)
+	onDisk, onDiskFile := fakeNodeBuilder.newReplyFile("on disk")
+	cached, cachedFile := fakeNodeBuilder.newReplyFile("cached")
+	missing, _ := fakeNodeBuilder.newReplyFile("missing")
+	g, err := grove.NewWithFS(fs)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fs.Files[onDiskFile.Name()] = onDiskFile
+	fs.Files[cachedFile.Name()] = cachedFile
+	if _, present, err := g.Get(cached.ID()); err != nil || !present {
+		t.Fatalf("Failed loading %v into cache: present=%v err=%v", cached.ID(), present, err)
+	}
+
+	nodes, err := g.GetMany([]*fields.QualifiedHash{onDisk.ID(), cached.ID(), missing.ID()})
+	if err != nil {
+		t.Fatalf("Failed looking up batch of nodes: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("Expected 2 nodes, got %d", len(nodes))
+	}
+	for _, id := range []*fields.QualifiedHash{onDisk.ID(), cached.ID()} {
+		if node, present := nodes[id.String()]; !present {
+			t.Errorf("Expected batch to include %v", id)
+		} else if !node.ID().Equals(id) {
+			t.Errorf("Expected batch entry for %v to hold that node, got %v", id, node.ID())
+		}
+	}
+	if _, present := nodes[missing.ID().String()]; present {
+		t.Errorf("Expected batch to omit missing node %v", missing.ID())
+	}
+}
+
+func TestGroveHas(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
+	g, err := grove.NewWithFS(fs)
+	if err != nil {
+		t.Errorf("Failed constructing grove: %v", err)
+	}
+
+	if present, err := g.Has(reply.ID()); err != nil {
+		t.Errorf("Failed checking for %v (not present): %v", reply.ID(), err)
+	} else if present {
+		t.Errorf("Grove indicated that a node was present when it was not added")
+	}
+
+	// Has should not parse the file, so even garbage contents count as present
+	replyFile.Reset()
+	if _, err := replyFile.Write([]byte("this is not an arbor node")); err != nil {
+		t.Skipf("Unable to write test data into node file: %v", err)
+	}
+	fs.Files[replyFile.Name()] = replyFile
+
+	if present, err := g.Has(reply.ID()); err != nil {
+		t.Errorf("Failed checking for %v (present): %v", reply.ID(), err)
+	} else if !present {
+		t.Errorf("Grove indicated that a node was not present when it should have been")
+	}
+}
+
+func TestGroveHeader(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, replyFile := fakeNodeBuilder.newReplyFile(strings.Repeat("large content ", 1000))
+	g, err := grove.NewWithFS(fs)
+	if err != nil {
+		t.Errorf("Failed constructing grove: %v", err)
+	}
+
+	if _, err := g.Header(reply.ID()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected missing node header to fail with os.ErrNotExist, got %v", err)
+	}
+
+	fs.Files[replyFile.Name()] = replyFile
+	header, err := g.Header(reply.ID())
+	if err != nil {
+		t.Fatalf("Failed reading header of %v: %v", reply.ID(), err)
+	}
+	if header.Type != fields.NodeTypeReply {
+		t.Errorf("Expected header type %v, got %v", fields.NodeTypeReply, header.Type)
+	}
+	if header.Version != reply.Version {
+		t.Errorf("Expected header version %v, got %v", reply.Version, header.Version)
+	}
+	if !header.Parent.Equals(reply.ParentID()) {
+		t.Errorf("Expected header parent %v, got %v", reply.ParentID(), &header.Parent)
+	}
+	if header.Depth != reply.TreeDepth() {
+		t.Errorf("Expected header depth %v, got %v", reply.TreeDepth(), header.Depth)
+	}
+	if !header.Author.Equals(reply.AuthorID()) {
+		t.Errorf("Expected header author %v, got %v", reply.AuthorID(), &header.Author)
+	}
+	if !header.Created.Equal(reply.CreatedAt()) {
+		t.Errorf("Expected header creation time %v, got %v", reply.CreatedAt(), header.Created)
+	}
+	if replyFile.Len() == 0 {
+		t.Errorf("Expected Header to leave the reply content unread")
+	}
+}
+
+func TestGroveConcurrentAddAndChildren(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-concurrency")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	replies := make([]*forest.Reply, 32)
+	for i := range replies {
+		replies[i], _ = fakeNodeBuilder.newReplyFile(fmt.Sprintf("reply %d", i))
+	}
+	communityID := fakeNodeBuilder.Community.ID()
+
+	var wg sync.WaitGroup
+	for _, reply := range replies {
+		wg.Add(2)
+		go func(reply *forest.Reply) {
+			defer wg.Done()
+			if err := g.Add(reply); err != nil {
+				t.Errorf("Failed adding %v: %v", reply.ID(), err)
+			}
+		}(reply)
+		go func(reply *forest.Reply) {
+			defer wg.Done()
+			if _, err := g.Children(communityID); err != nil {
+				t.Errorf("Failed listing children of %v: %v", communityID, err)
+			}
+			if _, err := g.Children(reply.ID()); err != nil {
+				t.Errorf("Failed listing children of %v: %v", reply.ID(), err)
+			}
+			if _, _, err := g.Get(reply.ID()); err != nil {
+				t.Errorf("Failed getting %v: %v", reply.ID(), err)
+			}
+		}(reply)
+	}
+	wg.Wait()
+
+	children, err := g.Children(communityID)
+	if err != nil {
+		t.Fatalf("Failed listing children of %v: %v", communityID, err)
+	}
+	if len(children) != len(replies) {
+		t.Errorf("Expected %d children after concurrent adds, got %d", len(replies), len(children))
+	}
+}
+
 func TestGroveGetErrorReadingFile(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
-	errReplyFile := NewErrFile(replyFile)
+	errReplyFile := grovetest.NewErrFile(replyFile)
 	g, err := grove.NewWithFS(fs)
 	if err != nil {
 		t.Errorf("Failed constructing grove: %v", err)
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[errReplyFile.Name()] = errReplyFile
-	errReplyFile.error = os.ErrClosed
+	fs.Files[errReplyFile.Name()] = errReplyFile
+	errReplyFile.Err = os.ErrClosed
 
 	// no nodes in fs, make sure we get nothing
 	if node, present, err := g.Get(reply.ID()); err == nil {
@@ -424,7 +294,7 @@ func TestGroveGetErrorReadingFile(t *testing.T) {
 }
 
 func TestGroveGetErrorUnmarshallingFile(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
 	replyFile.Reset()
@@ -438,7 +308,7 @@ func TestGroveGetErrorUnmarshallingFile(t *testing.T) {
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[replyFile.Name()] = replyFile
+	fs.Files[replyFile.Name()] = replyFile
 
 	// no nodes in fs, make sure we get nothing
 	if node, present, err := g.Get(reply.ID()); err == nil {
@@ -451,15 +321,15 @@ func TestGroveGetErrorUnmarshallingFile(t *testing.T) {
 }
 
 func TestGroveGetErrorOpeningFile(t *testing.T) {
-	fs := newFakeFS()
-	eFS := newErrFS(fs)
+	fs := grovetest.NewMemFS()
+	eFS := grovetest.NewErrFS(fs)
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, _ := fakeNodeBuilder.newReplyFile("test content")
 	g, err := grove.NewWithFS(eFS)
 	if err != nil {
 		t.Errorf("Failed constructing grove: %v", err)
 	}
-	eFS.error = os.ErrPermission
+	eFS.Err = os.ErrPermission
 
 	// no nodes in fs, make sure we get nothing
 	if node, present, err := g.Get(reply.ID()); err == nil {
@@ -472,7 +342,7 @@ func TestGroveGetErrorOpeningFile(t *testing.T) {
 }
 
 func TestGroveAdd(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, _ := fakeNodeBuilder.newReplyFile("test content")
 
@@ -487,29 +357,141 @@ func TestGroveAdd(t *testing.T) {
 }
 
 func TestGroveAddFailToWrite(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
-	eFile := NewErrFile(replyFile)
+	eFile := grovetest.NewErrFile(replyFile)
 
 	g, err := grove.NewWithFS(fs)
 	if err != nil {
 		t.Errorf("Failed constructing grove: %v", err)
 	}
 
-	fs.files[eFile.Name()] = eFile
-	eFile.error = os.ErrClosed
+	fs.Files[eFile.Name()] = eFile
+	eFile.Err = os.ErrClosed
 
 	if err := g.Add(reply); err == nil {
 		t.Errorf("Expected Add() to fail when writing to file fails")
 	}
 }
 
+// partialWriteFS is a MemFS whose files accept only half of the data in each
+// write before failing.
+type partialWriteFS struct {
+	*grovetest.MemFS
+}
+
+func (p partialWriteFS) Create(path string) (grove.File, error) {
+	file, err := p.MemFS.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return partialWriteFile{file}, nil
+}
+
+type partialWriteFile struct {
+	grove.File
+}
+
+func (p partialWriteFile) Write(b []byte) (int, error) {
+	n, _ := p.File.Write(b[:len(b)/2])
+	return n, io.ErrShortWrite
+}
+
+func TestGroveAddPartialWrite(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+
+	g, err := grove.NewWithFS(partialWriteFS{fs})
+	if err != nil {
+		t.Errorf("Failed constructing grove: %v", err)
+	}
+
+	if err := g.Add(reply); err == nil {
+		t.Errorf("Expected Add() to fail when writing to file fails partway")
+	}
+	if _, exists := fs.Files[reply.ID().String()]; exists {
+		t.Errorf("Expected failed Add() not to leave a partial node file")
+	}
+	if len(fs.Files) != 0 {
+		t.Errorf("Expected failed Add() to clean up temporary files, found %d files", len(fs.Files))
+	}
+}
+
+func TestGroveAddWritesAtomically(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+
+	g, err := grove.NewWithFS(fs)
+	if err != nil {
+		t.Errorf("Failed constructing grove: %v", err)
+	}
+
+	if err := g.Add(reply); err != nil {
+		t.Fatalf("Expected Add() to succeed: %v", err)
+	}
+	for name := range fs.Files {
+		if strings.HasPrefix(name, ".tmp-") {
+			t.Errorf("Expected no temporary files to remain after Add(), found %s", name)
+		}
+	}
+	file, exists := fs.Files[reply.ID().String()]
+	if !exists {
+		t.Fatalf("Expected node file to be renamed into place")
+	}
+	if file.Name() != reply.ID().String() {
+		t.Errorf("Expected renamed node file to be named %s, got %s", reply.ID(), file.Name())
+	}
+	expected, err := reply.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling reply: %v", err)
+	}
+	if contents, err := ioutil.ReadAll(file); err != nil {
+		t.Errorf("Failed reading node file: %v", err)
+	} else if !bytes.Equal(contents, expected) {
+		t.Errorf("Expected node file to contain the complete node")
+	}
+}
+
+func TestGroveRemovesStaleTempFiles(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
+	fs.Files[replyFile.Name()] = replyFile
+	staleName := ".tmp-" + reply.ID().String()
+	fs.Files[staleName] = grovetest.NewMemFile(staleName, []byte("partial"))
+
+	g, err := grove.NewWithFS(fs)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	if _, exists := fs.Files[staleName]; exists {
+		t.Errorf("Expected opening the grove to remove stale temporary file %s", staleName)
+	}
+	if _, exists := fs.Files[replyFile.Name()]; !exists {
+		t.Errorf("Expected opening the grove to keep node file %s", replyFile.Name())
+	}
+
+	// a crash while the grove is open leaves a file for Compact to remove
+	fs.Files[staleName] = grovetest.NewMemFile(staleName, []byte("partial"))
+	if _, err := g.Compact(); err != nil {
+		t.Fatalf("Failed compacting grove: %v", err)
+	}
+	if _, exists := fs.Files[staleName]; exists {
+		t.Errorf("Expected Compact to remove stale temporary file %s", staleName)
+	}
+	if _, has, err := g.Get(reply.ID()); err != nil || !has {
+		t.Errorf("Expected grove to still contain %v (err: %v)", reply.ID(), err)
+	}
+}
+
 func TestGroveAddShouldntTruncateExisting(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
-	fs.files[replyFile.Name()] = replyFile
+	fs.Files[replyFile.Name()] = replyFile
 	originalModTime := replyFile.ModTime()
 
 	g, err := grove.NewWithFS(fs)
@@ -527,9 +509,9 @@ func TestGroveAddShouldntTruncateExisting(t *testing.T) {
 }
 
 func TestGroveAddFailToCreate(t *testing.T) {
-	fs := newFakeFS()
-	efs := newErrFS(fs)
-	efs.error = os.ErrPermission
+	fs := grovetest.NewMemFS()
+	efs := grovetest.NewErrFS(fs)
+	efs.Err = os.ErrPermission
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, _ := fakeNodeBuilder.newReplyFile("test content")
 	g, err := grove.NewWithFS(efs)
@@ -593,9 +575,9 @@ func (e errNode) AuthorID() *fields.QualifiedHash {
 }
 
 func TestGroveAddFailToSerialize(t *testing.T) {
-	fs := newFakeFS()
-	efs := newErrFS(fs)
-	efs.error = os.ErrPermission
+	fs := grovetest.NewMemFS()
+	efs := grovetest.NewErrFS(fs)
+	efs.Err = os.ErrPermission
 	eNode := errNode{
 		fmt.Errorf("I can't be serialized"),
 	}
@@ -610,7 +592,7 @@ func TestGroveAddFailToSerialize(t *testing.T) {
 }
 
 func TestGroveChildren(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
 	reply1, replyFile1 := fakeNodeBuilder.newReplyFile("test content")
@@ -618,11 +600,11 @@ func TestGroveChildren(t *testing.T) {
 	identity := fakeNodeBuilder.Builder.User
 	identityData, err := identity.MarshalBinary()
 	idFileName, _ := identity.ID().MarshalString()
-	idFile := newFakeFile(idFileName, identityData)
+	idFile := grovetest.NewMemFile(idFileName, identityData)
 	community := fakeNodeBuilder.Community
 	communityData, err := community.MarshalBinary()
 	communityFileName, _ := community.ID().MarshalString()
-	communityFile := newFakeFile(communityFileName, communityData)
+	communityFile := grovetest.NewMemFile(communityFileName, communityData)
 
 	resetAll := func() {
 		replyFile.ResetBuffer()
@@ -637,11 +619,11 @@ func TestGroveChildren(t *testing.T) {
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[replyFile.Name()] = replyFile
+	fs.Files[replyFile.Name()] = replyFile
 
-	fs.files[idFile.Name()] = idFile
+	fs.Files[idFile.Name()] = idFile
 
-	fs.files[communityFile.Name()] = communityFile
+	fs.Files[communityFile.Name()] = communityFile
 
 	if children, err := g.Children(identity.ID()); err != nil {
 		t.Errorf("Expected looking for identity children to succeed: %v", err)
@@ -663,8 +645,8 @@ func TestGroveChildren(t *testing.T) {
 	// reset fakeFiles so they can be read again
 	resetAll()
 
-	fs.files[replyFile1.Name()] = replyFile1
-	fs.files[replyFile2.Name()] = replyFile2
+	fs.Files[replyFile1.Name()] = replyFile1
+	fs.Files[replyFile2.Name()] = replyFile2
 	_ = g.Add(reply1)
 	_ = g.Add(reply2)
 
@@ -676,9 +658,9 @@ func TestGroveChildren(t *testing.T) {
 }
 
 func TestGroveChildrenOpenRootFails(t *testing.T) {
-	fs := newFakeFS()
-	efs := newErrFS(fs)
-	efs.error = os.ErrPermission
+	fs := grovetest.NewMemFS()
+	efs := grovetest.NewErrFS(fs)
+	efs.Err = os.ErrPermission
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, _ := fakeNodeBuilder.newReplyFile("test content")
 	g, err := grove.NewWithFS(efs)
@@ -694,18 +676,18 @@ func TestGroveChildrenOpenRootFails(t *testing.T) {
 }
 
 func TestGroveChildrenOpenNodeFails(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
-	eReplyFile := NewErrFile(replyFile)
-	eReplyFile.error = os.ErrPermission
+	eReplyFile := grovetest.NewErrFile(replyFile)
+	eReplyFile.Err = os.ErrPermission
 	g, err := grove.NewWithFS(fs)
 	if err != nil {
 		t.Errorf("Failed constructing grove: %v", err)
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[eReplyFile.Name()] = eReplyFile
+	fs.Files[eReplyFile.Name()] = eReplyFile
 
 	if children, err := g.Children(reply.ID()); err == nil {
 		t.Errorf("Expected permission error when reading node file to be propagated upward, but Children() did not error")
@@ -715,7 +697,7 @@ func TestGroveChildrenOpenNodeFails(t *testing.T) {
 }
 
 func TestGroveChildrenParseNodeFails(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
 	replyFile.Buffer.Truncate(1)
@@ -725,17 +707,19 @@ func TestGroveChildrenParseNodeFails(t *testing.T) {
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[replyFile.Name()] = replyFile
+	fs.Files[replyFile.Name()] = replyFile
 
 	if children, err := g.Children(reply.ID()); err == nil {
 		t.Errorf("Expected error when parsing node file to be propagated upward, but Children() did not error")
+	} else if !errors.Is(err, forest.ErrTruncated) {
+		t.Errorf("Expected error parsing truncated node file to match %q, got: %v", forest.ErrTruncated, err)
 	} else if len(children) > 0 {
 		t.Errorf("Expected no child nodes for when parsing a node failed, found %d", len(children))
 	}
 }
 
 func TestGroveRecent(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
 	reply1, replyFile1 := fakeNodeBuilder.newReplyFile("test content")
@@ -746,21 +730,21 @@ func TestGroveRecent(t *testing.T) {
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[replyFile.Name()] = replyFile
-	fs.files[replyFile1.Name()] = replyFile1
-	fs.files[replyFile2.Name()] = replyFile2
+	fs.Files[replyFile.Name()] = replyFile
+	fs.Files[replyFile1.Name()] = replyFile1
+	fs.Files[replyFile2.Name()] = replyFile2
 
 	identity := fakeNodeBuilder.Builder.User
 	identityData, err := identity.MarshalBinary()
 	idFileName, _ := identity.ID().MarshalString()
-	idFile := newFakeFile(idFileName, identityData)
-	fs.files[idFile.Name()] = idFile
+	idFile := grovetest.NewMemFile(idFileName, identityData)
+	fs.Files[idFile.Name()] = idFile
 
 	community := fakeNodeBuilder.Community
 	communityData, err := community.MarshalBinary()
 	communityFileName, _ := community.ID().MarshalString()
-	communityFile := newFakeFile(communityFileName, communityData)
-	fs.files[communityFile.Name()] = communityFile
+	communityFile := grovetest.NewMemFile(communityFileName, communityData)
+	fs.Files[communityFile.Name()] = communityFile
 
 	if replies, err := g.Recent(fields.NodeTypeReply, 5); err != nil {
 		t.Errorf("Expected recent replies to succeed: %v", err)
@@ -848,18 +832,18 @@ func TestGroveRecent(t *testing.T) {
 }
 
 func TestGroveRecentOpenNodeFails(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	_, replyFile := fakeNodeBuilder.newReplyFile("test content")
-	eReplyFile := NewErrFile(replyFile)
-	eReplyFile.error = os.ErrPermission
+	eReplyFile := grovetest.NewErrFile(replyFile)
+	eReplyFile.Err = os.ErrPermission
 	g, err := grove.NewWithFS(fs)
 	if err != nil {
 		t.Errorf("Failed constructing grove: %v", err)
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[eReplyFile.Name()] = eReplyFile
+	fs.Files[eReplyFile.Name()] = eReplyFile
 
 	if replies, err := g.Recent(fields.NodeTypeReply, 5); err == nil {
 		t.Errorf("Expected permission error when reading node file to be propagated upward, but Recent() did not error")
@@ -868,19 +852,109 @@ func TestGroveRecentOpenNodeFails(t *testing.T) {
 	}
 }
 
+func TestGroveChildrenPage(t *testing.T) {
+	fs := grovetest.NewMemFS()
+	g, err := grove.NewWithFS(fs)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	community := fakeNodeBuilder.Community
+	for i := 0; i < 3; i++ {
+		reply, _ := fakeNodeBuilder.newReplyFile(fmt.Sprintf("reply %d", i))
+		if err := g.Add(reply); err != nil {
+			t.Fatalf("Failed adding %v: %v", reply.ID(), err)
+		}
+	}
+	if count, err := g.ChildrenCount(community.ID()); err != nil {
+		t.Errorf("ChildrenCount failed on valid input: %v", err)
+	} else if count != 3 {
+		t.Errorf("Expected 3 children, got %d", count)
+	}
+	first, err := g.ChildrenPage(community.ID(), 0, 2)
+	if err != nil {
+		t.Fatalf("ChildrenPage failed on valid input: %v", err)
+	}
+	second, err := g.ChildrenPage(community.ID(), 2, 2)
+	if err != nil {
+		t.Fatalf("ChildrenPage failed on valid input: %v", err)
+	}
+	if len(first) != 2 || len(second) != 1 {
+		t.Fatalf("Expected pages of 2 and 1 children, got %d and %d", len(first), len(second))
+	}
+	seen := map[string]struct{}{}
+	for _, child := range append(first, second...) {
+		seen[child.String()] = struct{}{}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected pages to contain 3 distinct children, got %d", len(seen))
+	}
+}
+
+func TestGroveCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-compact")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+	reply1, _ := fakeNodeBuilder.newReplyFile("test content")
+	community := fakeNodeBuilder.Community
+	for _, node := range []forest.Node{fakeNodeBuilder.Builder.User, community, reply, reply1} {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+		if _, _, err := g.Get(node.ID()); err != nil {
+			t.Fatalf("Failed getting %v: %v", node.ID(), err)
+		}
+	}
+
+	// remove a node behind the grove's back
+	if err := os.Remove(filepath.Join(dir, reply1.ID().String())); err != nil {
+		t.Skipf("Failed removing node file: %v", err)
+	}
+	// the node cache entry, the child cache entry, and the link to the
+	// parent should all be stale
+	if removed, err := g.Compact(); err != nil {
+		t.Errorf("Failed compacting grove: %v", err)
+	} else if removed != 3 {
+		t.Errorf("Expected compaction to remove 3 stale entries, removed %d", removed)
+	}
+	if children, err := g.Children(community.ID()); err != nil {
+		t.Errorf("Expected looking for community children to succeed: %v", err)
+	} else if len(children) != 1 || !children[0].Equals(reply.ID()) {
+		t.Errorf("Expected only %v as a child of community, got %v", reply.ID(), children)
+	}
+	if _, has, err := g.Get(reply1.ID()); err != nil {
+		t.Errorf("should not error when looking up nonexistent node: %v", err)
+	} else if has {
+		t.Errorf("should not have node after its file was removed")
+	}
+	if removed, err := g.Compact(); err != nil {
+		t.Errorf("Failed compacting grove: %v", err)
+	} else if removed != 0 {
+		t.Errorf("Expected compacting a consistent grove to remove nothing, removed %d", removed)
+	}
+}
+
 func TestGroveRemoveSubtree(t *testing.T) {
-	fs := newFakeFS()
+	fs := grovetest.NewMemFS()
 	fakeNodeBuilder := NewNodeBuilder(t)
 	reply, replyFile := fakeNodeBuilder.newReplyFile("test content")
 	reply1, replyFile1 := fakeNodeBuilder.newReplyFile("test content")
 	identity := fakeNodeBuilder.Builder.User
 	identityData, err := identity.MarshalBinary()
 	idFileName, _ := identity.ID().MarshalString()
-	idFile := newFakeFile(idFileName, identityData)
+	idFile := grovetest.NewMemFile(idFileName, identityData)
 	community := fakeNodeBuilder.Community
 	communityData, err := community.MarshalBinary()
 	communityFileName, _ := community.ID().MarshalString()
-	communityFile := newFakeFile(communityFileName, communityData)
+	communityFile := grovetest.NewMemFile(communityFileName, communityData)
 
 	resetAll := func() {
 		replyFile.ResetBuffer()
@@ -894,10 +968,10 @@ func TestGroveRemoveSubtree(t *testing.T) {
 	}
 
 	// add node to fs, now should be discoverable
-	fs.files[replyFile.Name()] = replyFile
-	fs.files[idFile.Name()] = idFile
-	fs.files[communityFile.Name()] = communityFile
-	fs.files[replyFile1.Name()] = replyFile1
+	fs.Files[replyFile.Name()] = replyFile
+	fs.Files[idFile.Name()] = idFile
+	fs.Files[communityFile.Name()] = communityFile
+	fs.Files[replyFile1.Name()] = replyFile1
 
 	if err := g.RemoveSubtree(reply1.ID()); err != nil {
 		t.Errorf("should not have failed to remove node: %v", err)
@@ -923,3 +997,568 @@ func TestGroveRemoveSubtree(t *testing.T) {
 		t.Errorf("should no longer have reply after removing community above it.")
 	}
 }
+
+func TestGroveStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-stats")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+	reply1, _ := fakeNodeBuilder.newReplyFile("other content")
+	var expectedBytes int64
+	for _, node := range []forest.Node{fakeNodeBuilder.Builder.User, fakeNodeBuilder.Community, reply, reply1} {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+		b, err := node.MarshalBinary()
+		if err != nil {
+			t.Skipf("Failed marshalling %v: %v", node.ID(), err)
+		}
+		expectedBytes += int64(len(b))
+	}
+	stats, err := g.Stats()
+	if err != nil {
+		t.Fatalf("Failed getting grove stats: %v", err)
+	}
+	expected := store.StoreStats{Identities: 1, Communities: 1, Replies: 2, Total: 4, Bytes: expectedBytes}
+	if stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, stats)
+	}
+}
+
+func TestGroveBetween(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-between")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	if err := g.Add(fakeNodeBuilder.Community); err != nil {
+		t.Fatalf("Failed adding %v: %v", fakeNodeBuilder.Community.ID(), err)
+	}
+	base := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	replies := make([]forest.Node, 5)
+	for i := range replies {
+		created := fields.TimestampFrom(base.Add(time.Duration(i) * time.Second))
+		reply, err := fakeNodeBuilder.WithCreated(created).NewReply(fakeNodeBuilder.Community, fmt.Sprintf("reply %d", i), []byte{})
+		if err != nil {
+			t.Skipf("Failed generating test reply node: %v", err)
+		}
+		replies[i] = reply
+	}
+	// add the replies out of order to ensure that the results are sorted
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		if err := g.Add(replies[i]); err != nil {
+			t.Fatalf("Failed adding %v: %v", replies[i].ID(), err)
+		}
+	}
+
+	start := fields.TimestampFrom(replies[1].CreatedAt())
+	end := fields.TimestampFrom(replies[3].CreatedAt())
+	nodes, err := g.Between(fields.NodeTypeReply, start, end)
+	if err != nil {
+		t.Fatalf("Failed querying grove: %v", err)
+	}
+	expected := replies[1:4]
+	if len(nodes) != len(expected) {
+		t.Fatalf("Expected %d nodes, got %d", len(expected), len(nodes))
+	}
+	for i := range expected {
+		if !nodes[i].Equals(expected[i]) {
+			t.Errorf("Expected node %d to be %v, got %v", i, expected[i].ID(), nodes[i].ID())
+		}
+	}
+	if nodes, err := g.Between(fields.NodeTypeCommunity, start, end); err != nil {
+		t.Errorf("Failed querying grove for communities: %v", err)
+	} else if len(nodes) != 0 {
+		t.Errorf("Expected no communities in range, got %d", len(nodes))
+	}
+
+	// nodes created at the same time are ordered as by forest.ChildBefore
+	tied, err := fakeNodeBuilder.WithCreated(start).NewReply(fakeNodeBuilder.Community, "tied", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test reply node: %v", err)
+	}
+	if err := g.Add(tied); err != nil {
+		t.Fatalf("Failed adding %v: %v", tied.ID(), err)
+	}
+	nodes, err = g.Between(fields.NodeTypeReply, start, start)
+	if err != nil {
+		t.Fatalf("Failed querying grove: %v", err)
+	} else if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes created at %v, got %d", start, len(nodes))
+	}
+	if !forest.ChildBefore(nodes[0].CreatedAt(), nodes[0].ID(), nodes[1].CreatedAt(), nodes[1].ID()) {
+		t.Errorf("Expected nodes created at the same time to be ordered by forest.ChildBefore")
+	}
+}
+
+func TestGroveLoadDir(t *testing.T) {
+	src, err := ioutil.TempDir("", "grove-load-src")
+	if err != nil {
+		t.Skipf("Failed creating temporary source directory: %v", err)
+	}
+	defer os.RemoveAll(src)
+	dir, err := ioutil.TempDir("", "grove-load")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+	nodes := []forest.Node{fakeNodeBuilder.Builder.User, fakeNodeBuilder.Community, reply}
+	for _, node := range nodes {
+		b, err := node.MarshalBinary()
+		if err != nil {
+			t.Skipf("Failed marshalling %v: %v", node.ID(), err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(src, node.ID().String()), b, 0600); err != nil {
+			t.Skipf("Failed writing node file: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "README"), []byte("not a node"), 0600); err != nil {
+		t.Skipf("Failed writing non-node file: %v", err)
+	}
+	huge := make([]byte, forest.MaxNodeSize+1)
+	if err := ioutil.WriteFile(filepath.Join(src, "huge"), huge, 0600); err != nil {
+		t.Skipf("Failed writing oversized file: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "subdir"), 0700); err != nil {
+		t.Skipf("Failed creating subdirectory: %v", err)
+	}
+
+	var last grove.LoadProgress
+	calls := 0
+	if err := g.LoadDir(src, func(progress grove.LoadProgress) {
+		calls++
+		last = progress
+	}); err != nil {
+		t.Fatalf("Failed loading directory: %v", err)
+	}
+	expected := grove.LoadProgress{Path: last.Path, Loaded: 3, Skipped: 2, Total: 5}
+	if last != expected || calls != expected.Total {
+		t.Errorf("Expected %d progress reports ending with %+v, got %d ending with %+v", expected.Total, expected, calls, last)
+	}
+	for _, node := range nodes {
+		if has, err := g.Has(node.ID()); err != nil || !has {
+			t.Errorf("Expected grove to contain %v after loading (err: %v)", node.ID(), err)
+		}
+	}
+	if _, err := g.AddFromReader(bytes.NewReader(huge)); !errors.Is(err, forest.ErrNodeTooLarge) {
+		t.Errorf("Expected ErrNodeTooLarge adding oversized node data, got %v", err)
+	}
+}
+
+func TestGroveValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-validate")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("valid content")
+	tampered, _ := fakeNodeBuilder.newReplyFile("tampered content")
+	tampered.Content.Blob[0] ^= 1
+	tamperedData, err := tampered.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling tampered node: %v", err)
+	}
+	// the ID of the tampered node is computed from its new content when it
+	// is read back out of the grove
+	tamperedNode, err := forest.UnmarshalBinaryNode(tamperedData)
+	if err != nil {
+		t.Skipf("Failed unmarshalling tampered node: %v", err)
+	}
+
+	// the outsider's identity is only available from the resolving store,
+	// and the stranger's is not available at all
+	resolve := store.NewMemoryStore()
+	outsiderSigner := testkeys.Signer(t, testkeys.PrivKey2)
+	outsider, err := forest.NewIdentity(outsiderSigner, "outsider", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating identity: %v", err)
+	}
+	if err := resolve.Add(outsider); err != nil {
+		t.Skipf("Failed adding %v to store: %v", outsider.ID(), err)
+	}
+	outsiderReply, err := forest.As(outsider, outsiderSigner).NewReply(fakeNodeBuilder.Community, "from outside", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test reply node: %v", err)
+	}
+	stranger, err := forest.NewIdentity(outsiderSigner, "stranger", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating identity: %v", err)
+	}
+	strangerReply, err := forest.As(stranger, outsiderSigner).NewReply(fakeNodeBuilder.Community, "from nowhere", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test reply node: %v", err)
+	}
+
+	for _, node := range []forest.Node{fakeNodeBuilder.Builder.User, fakeNodeBuilder.Community, reply, tampered, outsiderReply, strangerReply} {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+	}
+	invalid, unverifiable, err := g.Validate(resolve)
+	if err != nil {
+		t.Fatalf("Failed validating grove: %v", err)
+	}
+	if len(invalid) != 1 || !invalid[0].Equals(tamperedNode.ID()) {
+		t.Errorf("Expected only %v to be invalid, got %v", tamperedNode.ID(), invalid)
+	}
+	if len(unverifiable) != 1 || !unverifiable[0].Equals(strangerReply.ID()) {
+		t.Errorf("Expected only %v to be unverifiable, got %v", strangerReply.ID(), unverifiable)
+	}
+
+	// without the resolving store, the outsider's reply can't be verified either
+	if _, unverifiable, err := g.Validate(nil); err != nil {
+		t.Fatalf("Failed validating grove: %v", err)
+	} else if len(unverifiable) != 2 {
+		t.Errorf("Expected 2 unverifiable nodes without a resolving store, got %v", unverifiable)
+	}
+}
+
+func TestGroveExportImportTar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-export")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+	nodes := []forest.Node{fakeNodeBuilder.Builder.User, fakeNodeBuilder.Community, reply}
+	for _, node := range nodes {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+	}
+	// a file named like a node that doesn't contain one should be left out
+	junkName := fields.HashNames[fields.HashTypeSHA512] + "_B32__junk"
+	if err := ioutil.WriteFile(filepath.Join(dir, junkName), []byte("junk"), 0600); err != nil {
+		t.Skipf("Failed writing junk file: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := g.ExportTar(&archive); err != nil {
+		t.Fatalf("Failed exporting grove: %v", err)
+	}
+	exported := archive.Bytes()
+	reader := tar.NewReader(bytes.NewReader(exported))
+	entries := 0
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Failed reading exported tar: %v", err)
+		}
+		if header.Name == junkName {
+			t.Errorf("Expected junk file to be left out of export")
+		}
+		entries++
+	}
+	if entries != len(nodes) {
+		t.Errorf("Expected %d tar entries, got %d", len(nodes), entries)
+	}
+
+	importDir, err := ioutil.TempDir("", "grove-import")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(importDir)
+	imported, err := grove.New(importDir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	if err := imported.ImportTar(bytes.NewReader(exported)); err != nil {
+		t.Fatalf("Failed importing tar: %v", err)
+	}
+	for _, node := range nodes {
+		if got, has, err := imported.Get(node.ID()); err != nil || !has || !got.Equals(node) {
+			t.Errorf("Expected imported grove to contain %v (err: %v)", node.ID(), err)
+		}
+	}
+
+	// entries that aren't nodes must be rejected
+	var bad bytes.Buffer
+	writer := tar.NewWriter(&bad)
+	if err := writer.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: junkName, Mode: 0644, Size: 4}); err != nil {
+		t.Skipf("Failed writing tar header: %v", err)
+	}
+	if _, err := writer.Write([]byte("junk")); err != nil {
+		t.Skipf("Failed writing tar entry: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Skipf("Failed finishing tar: %v", err)
+	}
+	if err := imported.ImportTar(&bad); err == nil {
+		t.Errorf("Expected importing a non-node entry to fail")
+	}
+	if _, err := os.Stat(filepath.Join(importDir, junkName)); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written for the invalid entry (stat error: %v)", err)
+	}
+
+	// entries longer than any node must be rejected without reading them
+	var huge bytes.Buffer
+	writer = tar.NewWriter(&huge)
+	hugeData := make([]byte, forest.MaxNodeSize+1)
+	if err := writer.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: junkName, Mode: 0644, Size: int64(len(hugeData))}); err != nil {
+		t.Skipf("Failed writing tar header: %v", err)
+	}
+	if _, err := writer.Write(hugeData); err != nil {
+		t.Skipf("Failed writing tar entry: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Skipf("Failed finishing tar: %v", err)
+	}
+	if err := imported.ImportTar(&huge); !errors.Is(err, forest.ErrNodeTooLarge) {
+		t.Errorf("Expected ErrNodeTooLarge importing an oversized entry, got %v", err)
+	}
+}
+
+func TestChildrenOrderAcrossStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-child-order")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	community := fakeNodeBuilder.Community
+	base := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// several replies share a creation time so that ties must be broken by ID
+	offsets := []int{2, 0, 1, 1, 1, 3}
+	replies := make([]forest.Node, len(offsets))
+	for i, offset := range offsets {
+		created := fields.TimestampFrom(base.Add(time.Duration(offset) * time.Second))
+		reply, err := fakeNodeBuilder.WithCreated(created).NewReply(community, fmt.Sprintf("reply %d", i), []byte{})
+		if err != nil {
+			t.Skipf("Failed generating test reply node: %v", err)
+		}
+		replies[i] = reply
+	}
+	expected := make([]forest.Node, len(replies))
+	copy(expected, replies)
+	sort.Slice(expected, func(i, j int) bool {
+		return forest.ChildBefore(expected[i].CreatedAt(), expected[i].ID(), expected[j].CreatedAt(), expected[j].ID())
+	})
+
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	cache, err := store.NewCacheStore(store.NewMemoryStore(), store.NewMemoryStore())
+	if err != nil {
+		t.Skipf("Failed constructing CacheStore: %v", err)
+	}
+	stores := []struct {
+		name  string
+		store forest.Store
+	}{
+		{"MemoryStore", store.NewMemoryStore()},
+		{"Grove", g},
+		{"Archive", archive},
+		{"CacheStore", cache},
+	}
+	for _, row := range stores {
+		for _, node := range append([]forest.Node{community}, replies...) {
+			if err := row.store.Add(node); err != nil {
+				t.Fatalf("Failed adding %v to %s: %v", node.ID(), row.name, err)
+			}
+		}
+	}
+	// a second grove over the same directory must discover the children on disk
+	reopened, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	stores = append(stores, struct {
+		name  string
+		store forest.Store
+	}{"reopened Grove", reopened})
+
+	for _, row := range stores {
+		children, err := row.store.Children(community.ID())
+		if err != nil {
+			t.Errorf("Failed listing children in %s: %v", row.name, err)
+			continue
+		}
+		if len(children) != len(expected) {
+			t.Errorf("Expected %s to list %d children, got %d", row.name, len(expected), len(children))
+			continue
+		}
+		for i := range expected {
+			if !children[i].Equals(expected[i].ID()) {
+				t.Errorf("Expected %s to list %v at index %d, got %v", row.name, expected[i].ID(), i, children[i])
+			}
+		}
+	}
+}
+
+func TestGroveChildIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-child-index")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	community := fakeNodeBuilder.Community
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+	reply1, _ := fakeNodeBuilder.newReplyFile("test content")
+	reply2, _ := fakeNodeBuilder.newReplyFile("test content")
+	for _, node := range []forest.Node{fakeNodeBuilder.Builder.User, community, reply, reply1} {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+	}
+
+	// reopen the grove and check its children, reporting whether any node
+	// files had to be parsed to find them
+	checkChildren := func(expected ...*fields.QualifiedHash) (scanned bool) {
+		t.Helper()
+		g, err := grove.New(dir)
+		if err != nil {
+			t.Fatalf("Failed constructing grove: %v", err)
+		}
+		children, err := g.Children(community.ID())
+		if err != nil {
+			t.Fatalf("Expected looking for community children to succeed: %v", err)
+		}
+		if len(children) != len(expected) {
+			t.Fatalf("Expected %d children, got %d", len(expected), len(children))
+		}
+		for _, child := range expected {
+			if !containsID(children, child) {
+				t.Errorf("Expected %v among children, got %v", child, children)
+			}
+		}
+		return len(g.NodeCache.Items) > 0
+	}
+	if checkChildren(reply.ID(), reply1.ID()) {
+		t.Errorf("Expected children of a reopened grove to be found without scanning")
+	}
+
+	if err := g.RemoveSubtree(reply1.ID()); err != nil {
+		t.Fatalf("Failed removing subtree: %v", err)
+	}
+	if checkChildren(reply.ID()) {
+		t.Errorf("Expected index to be updated by RemoveSubtree")
+	}
+
+	// add a node behind the grove's back so that the index is inconsistent
+	data, err := reply2.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling reply: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, reply2.ID().String()), data, 0644); err != nil {
+		t.Skipf("Failed writing node file: %v", err)
+	}
+	if !checkChildren(reply.ID(), reply2.ID()) {
+		t.Errorf("Expected inconsistent index to be rebuilt by scanning")
+	}
+	if checkChildren(reply.ID(), reply2.ID()) {
+		t.Errorf("Expected rebuilt index to be used without scanning")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, ".child-index"), []byte("garbage"), 0644); err != nil {
+		t.Skipf("Failed corrupting child index: %v", err)
+	}
+	if !checkChildren(reply.ID(), reply2.ID()) {
+		t.Errorf("Expected corrupt index to be rebuilt by scanning")
+	}
+}
+
+func containsID(ids []*fields.QualifiedHash, id *fields.QualifiedHash) bool {
+	for _, candidate := range ids {
+		if candidate.Equals(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGroveForEach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-for-each")
+	if err != nil {
+		t.Skipf("Failed creating temporary grove directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	fakeNodeBuilder := NewNodeBuilder(t)
+	reply, _ := fakeNodeBuilder.newReplyFile("test content")
+	nodes := []forest.Node{fakeNodeBuilder.Builder.User, fakeNodeBuilder.Community, reply}
+	for _, node := range nodes {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+	}
+
+	// reopen the grove so that nothing is cached
+	g, err = grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed constructing grove: %v", err)
+	}
+	visited := 0
+	if err := g.ForEach(func(node forest.Node) error {
+		visited++
+		return nil
+	}); err != nil {
+		t.Errorf("Expected iteration to succeed: %v", err)
+	} else if visited != len(nodes) {
+		t.Errorf("Expected %d nodes to be visited, got %d", len(nodes), visited)
+	}
+	if len(g.NodeCache.Items) != 0 {
+		t.Errorf("Expected iteration not to populate the node cache")
+	}
+
+	errStop := errors.New("stop")
+	visited = 0
+	if err := g.ForEach(func(node forest.Node) error {
+		visited++
+		return errStop
+	}); !errors.Is(err, errStop) {
+		t.Errorf("Expected visitor error to be returned, got %v", err)
+	} else if visited != 1 {
+		t.Errorf("Expected iteration to stop after the first error, visited %d nodes", visited)
+	}
+
+	copied := store.NewMemoryStore()
+	if err := g.CopyInto(copied); err != nil {
+		t.Errorf("Expected CopyInto to succeed: %v", err)
+	}
+	for _, node := range nodes {
+		if has, _ := copied.Has(node.ID()); !has {
+			t.Errorf("Expected copy to contain %v", node.ID())
+		}
+	}
+}
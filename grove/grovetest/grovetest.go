@@ -0,0 +1,361 @@
+/*
+Package grovetest provides in-memory implementations of the grove.FS and
+grove.File interfaces for use in tests. MemFS allows constructing a grove
+without touching the disk, and ErrFS and ErrFile wrap other implementations
+so that failures can be injected on demand.
+*/
+package grovetest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"git.sr.ht/~whereswaldon/forest-go/grove"
+)
+
+// TruncatableFile is a grove.File that can be truncated. All files stored
+// in a MemFS must implement it so that MemFS.Create can mimic os.Create.
+type TruncatableFile interface {
+	grove.File
+	Truncate(size int64) error
+}
+
+// MemFile implements the grove.File interface, but is entirely in-memory.
+// It also implements os.FileInfo so that it can describe itself in directory
+// listings.
+type MemFile struct {
+	data []byte
+	*bytes.Buffer
+	name    string
+	mode    os.FileMode
+	modtime time.Time
+}
+
+var _ os.FileInfo = &MemFile{}
+var _ TruncatableFile = &MemFile{}
+
+// NewMemFile creates a MemFile with the given name and contents.
+func NewMemFile(name string, content []byte) *MemFile {
+	return &MemFile{
+		name:    name,
+		mode:    os.FileMode(0660),
+		modtime: time.Now(),
+		data:    content,
+		Buffer:  bytes.NewBuffer(content),
+	}
+}
+
+func (f *MemFile) Name() string {
+	return f.name
+}
+
+func (f *MemFile) Size() int64 {
+	return int64(f.Buffer.Len())
+}
+
+func (f *MemFile) Mode() os.FileMode {
+	return f.mode
+}
+
+func (f *MemFile) ModTime() time.Time {
+	return f.modtime
+}
+
+func (f *MemFile) IsDir() bool {
+	return false
+}
+
+func (f *MemFile) Sys() interface{} {
+	return nil
+}
+
+// Close does nothing, as there are no resources to release.
+func (f *MemFile) Close() error {
+	return nil
+}
+
+// Readdir always returns an empty listing, as a MemFile is never a directory.
+func (f *MemFile) Readdir(n int) ([]os.FileInfo, error) {
+	return []os.FileInfo{}, nil
+}
+
+// ResetBuffer creates a new Buffer with the file's original data. Reading a
+// MemFile consumes its contents, so this must be called before a MemFile can
+// be read again.
+func (f *MemFile) ResetBuffer() {
+	f.Buffer = bytes.NewBuffer(f.data)
+}
+
+// Truncate discards all but the first size bytes of the unread contents
+// of the file.
+func (f *MemFile) Truncate(size int64) error {
+	f.Buffer.Truncate(int(size))
+	f.modtime = time.Now()
+	return nil
+}
+
+// ErrFile implements the grove.File interface and wraps another file.
+// If Err is nil, it is a transparent wrapper for the underlying file. If
+// Err is non-nil, it will be returned from all operations that can return
+// an error.
+type ErrFile struct {
+	Err         error
+	wrappedFile TruncatableFile
+}
+
+var _ TruncatableFile = &ErrFile{}
+var _ os.FileInfo = &ErrFile{}
+
+// NewErrFile wraps the given file. The returned ErrFile will not return any
+// errors until its Err field is set.
+func NewErrFile(file TruncatableFile) *ErrFile {
+	return &ErrFile{
+		wrappedFile: file,
+	}
+}
+
+func (e *ErrFile) Name() string {
+	return e.wrappedFile.Name()
+}
+
+func (e *ErrFile) Size() int64 {
+	if info, implements := e.wrappedFile.(os.FileInfo); implements {
+		return info.Size()
+	}
+	return 0
+}
+
+func (e *ErrFile) Mode() os.FileMode {
+	if info, implements := e.wrappedFile.(os.FileInfo); implements {
+		return info.Mode()
+	}
+	return os.FileMode(0660)
+}
+
+func (e *ErrFile) ModTime() time.Time {
+	if info, implements := e.wrappedFile.(os.FileInfo); implements {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+func (e *ErrFile) IsDir() bool {
+	if info, implements := e.wrappedFile.(os.FileInfo); implements {
+		return info.IsDir()
+	}
+	return false
+}
+
+func (e *ErrFile) Sys() interface{} {
+	if info, implements := e.wrappedFile.(os.FileInfo); implements {
+		return info.Sys()
+	}
+	return nil
+}
+
+func (e *ErrFile) Read(b []byte) (int, error) {
+	if e.Err != nil {
+		return 0, e.Err
+	}
+	return e.wrappedFile.Read(b)
+}
+
+func (e *ErrFile) Write(b []byte) (int, error) {
+	if e.Err != nil {
+		return 0, e.Err
+	}
+	return e.wrappedFile.Write(b)
+}
+
+func (e *ErrFile) Close() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return e.wrappedFile.Close()
+}
+
+func (e *ErrFile) Readdir(n int) ([]os.FileInfo, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	return e.wrappedFile.Readdir(n)
+}
+
+func (e *ErrFile) Truncate(size int64) error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return e.wrappedFile.Truncate(size)
+}
+
+// MemFS implements grove.FS, but is entirely in-memory. It has a single
+// flat directory of files, which is the only layout a grove requires.
+type MemFS struct {
+	// Files maps file names to their contents. Tests may insert files
+	// directly to simulate content appearing on disk without the grove's
+	// knowledge.
+	Files map[string]TruncatableFile
+}
+
+var _ grove.RenameFS = &MemFS{}
+
+// NewMemFS creates an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		Files: make(map[string]TruncatableFile),
+	}
+}
+
+// memDir is the root directory of a MemFS.
+type memDir struct {
+	fs *MemFS
+}
+
+func (d memDir) Name() string {
+	return ""
+}
+
+func (d memDir) Read(b []byte) (int, error) {
+	return 0, fmt.Errorf("cannot read from a directory")
+}
+
+func (d memDir) Write(b []byte) (int, error) {
+	return 0, fmt.Errorf("cannot write to a directory")
+}
+
+func (d memDir) Close() error {
+	return nil
+}
+
+// Readdir lists every file in the MemFS. It requires all files to implement
+// os.FileInfo, as MemFile and ErrFile do.
+func (d memDir) Readdir(n int) ([]os.FileInfo, error) {
+	count := n
+	if count <= 0 {
+		count = len(d.fs.Files)
+	}
+	info := make([]os.FileInfo, 0, count)
+	for _, file := range d.fs.Files {
+		info = append(info, file.(os.FileInfo))
+	}
+	return info, nil
+}
+
+// Open returns the file with the given name, or the root directory of
+// the MemFS if path is empty.
+func (r *MemFS) Open(path string) (grove.File, error) {
+	if path == "" {
+		return memDir{r}, nil
+	}
+	file, exists := r.Files[path]
+	if !exists {
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
+
+// Create makes a new empty file with the given name. Like os.Create,
+// creating a file that already exists truncates it.
+func (r *MemFS) Create(path string) (grove.File, error) {
+	file, exists := r.Files[path]
+	if exists {
+		file.Truncate(0)
+	} else {
+		file = NewMemFile(path, []byte{})
+		r.Files[path] = file
+	}
+
+	return file, nil
+}
+
+// OpenFile opens the file with the given name. The flag and perm arguments
+// are ignored.
+func (r *MemFS) OpenFile(path string, flag int, perm os.FileMode) (grove.File, error) {
+	return r.Open(path)
+}
+
+// Remove deletes the file with the given name.
+func (r *MemFS) Remove(path string) error {
+	_, exists := r.Files[path]
+	if !exists {
+		return fmt.Errorf("file doesn't exist")
+	}
+	delete(r.Files, path)
+	return nil
+}
+
+// Rename moves the file at oldpath to newpath, replacing any file already
+// there. Only MemFiles (and ErrFiles wrapping them) can be renamed.
+func (r *MemFS) Rename(oldpath, newpath string) error {
+	file, exists := r.Files[oldpath]
+	if !exists {
+		return os.ErrNotExist
+	}
+	if err := setName(file, newpath); err != nil {
+		return err
+	}
+	delete(r.Files, oldpath)
+	r.Files[newpath] = file
+	return nil
+}
+
+// setName changes the name that the file reports for itself.
+func setName(file TruncatableFile, name string) error {
+	switch f := file.(type) {
+	case *MemFile:
+		f.name = name
+		return nil
+	case *ErrFile:
+		return setName(f.wrappedFile, name)
+	default:
+		return fmt.Errorf("cannot rename file of type %T", file)
+	}
+}
+
+// ErrFS wraps another grove.FS with the ability to return a specific error
+// from any method. If Err is nil, it is a transparent wrapper for the
+// underlying FS.
+type ErrFS struct {
+	fs  grove.FS
+	Err error
+}
+
+var _ grove.FS = &ErrFS{}
+
+// NewErrFS wraps the given FS. The returned ErrFS will not return any errors
+// until its Err field is set.
+func NewErrFS(fs grove.FS) *ErrFS {
+	return &ErrFS{
+		fs: fs,
+	}
+}
+
+func (r *ErrFS) Open(path string) (grove.File, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.fs.Open(path)
+}
+
+func (r *ErrFS) Create(path string) (grove.File, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.fs.Create(path)
+}
+
+func (r *ErrFS) OpenFile(path string, flag int, perm os.FileMode) (grove.File, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.fs.OpenFile(path, flag, perm)
+}
+
+func (r *ErrFS) Remove(path string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return r.fs.Remove(path)
+}
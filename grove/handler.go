@@ -0,0 +1,149 @@
+package grove
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// defaultRecentQuantity is the number of nodes listed by the recent endpoint
+// of a Handler when the request does not specify one.
+const defaultRecentQuantity = 10
+
+// maxRecentQuantity is the largest number of nodes that a request to the
+// recent endpoint of a Handler may ask for.
+const maxRecentQuantity = 1000
+
+// Handler returns an http.Handler that serves the contents of g. It answers
+// GET requests on the following paths:
+//
+//	/node/{id}      the binary form of the node with the given ID
+//	/children/{id}  a JSON array of the IDs of the node's children
+//	/recent/{type}  a JSON array of the IDs of the most recent nodes of the
+//	                given type ("identity", "community", or "reply"),
+//	                newest first. The query parameter n sets how many
+//	                (default 10, at most 1000).
+//
+// Requests for nodes that are not in the grove receive a 404 response.
+func Handler(g *Grove) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/node/", g.serveNode)
+	mux.HandleFunc("/children/", g.serveChildren)
+	mux.HandleFunc("/recent/", g.serveRecent)
+	return mux
+}
+
+// requestedID parses the node ID at the end of the request's path, which must
+// begin with prefix. It writes an error response and returns nil if the
+// request is malformed.
+func requestedID(w http.ResponseWriter, r *http.Request, prefix string) *fields.QualifiedHash {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	id, err := fields.ParseQualifiedHash(strings.TrimPrefix(r.URL.Path, prefix))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid node id: %v", err), http.StatusBadRequest)
+		return nil
+	}
+	return id
+}
+
+// serveNode responds with the binary form of the requested node.
+func (g *Grove) serveNode(w http.ResponseWriter, r *http.Request) {
+	id := requestedID(w, r, "/node/")
+	if id == nil {
+		return
+	}
+	node, present, err := g.Get(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed getting node: %v", err), http.StatusInternalServerError)
+		return
+	} else if !present {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := node.MarshalBinary()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed marshalling node: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(data)
+}
+
+// serveChildren responds with the IDs of the requested node's children.
+func (g *Grove) serveChildren(w http.ResponseWriter, r *http.Request) {
+	id := requestedID(w, r, "/children/")
+	if id == nil {
+		return
+	}
+	if present, err := g.Has(id); err != nil {
+		http.Error(w, fmt.Sprintf("failed checking for node: %v", err), http.StatusInternalServerError)
+		return
+	} else if !present {
+		http.NotFound(w, r)
+		return
+	}
+	children, err := g.Children(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed listing children: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, children)
+}
+
+// serveRecent responds with the IDs of the most recent nodes of the requested
+// type.
+func (g *Grove) serveRecent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	typeName := strings.TrimPrefix(r.URL.Path, "/recent/")
+	nodeType, known := fields.NodeType(0), false
+	for t, name := range fields.NodeTypeNames {
+		if name == typeName {
+			nodeType, known = t, true
+		}
+	}
+	if !known {
+		http.Error(w, fmt.Sprintf("unknown node type %q", typeName), http.StatusBadRequest)
+		return
+	}
+	quantity := defaultRecentQuantity
+	if n := r.URL.Query().Get("n"); n != "" {
+		var err error
+		if quantity, err = strconv.Atoi(n); err != nil || quantity < 0 || quantity > maxRecentQuantity {
+			http.Error(w, fmt.Sprintf("invalid quantity %q", n), http.StatusBadRequest)
+			return
+		}
+	}
+	nodes, err := g.Recent(nodeType, quantity)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed listing recent nodes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	ids := make([]*fields.QualifiedHash, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID())
+	}
+	writeJSON(w, ids)
+}
+
+// writeJSON responds with the JSON encoding of value.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed encoding response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,97 @@
+package grove_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/grove"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestGroveHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grove-handler")
+	if err != nil {
+		t.Skipf("Failed creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	g, err := grove.New(dir)
+	if err != nil {
+		t.Fatalf("Failed creating grove: %v", err)
+	}
+	tnb := NewNodeBuilder(t)
+	if tnb == nil {
+		t.Skip("Failed creating test nodes")
+	}
+	reply, err := tnb.NewReply(tnb.Community, "served", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	for _, node := range []forest.Node{tnb.User, tnb.Community, reply} {
+		if err := g.Add(node); err != nil {
+			t.Fatalf("Failed adding %s: %v", node.ID(), err)
+		}
+	}
+	server := httptest.NewServer(grove.Handler(g))
+	defer server.Close()
+
+	get := func(path string, expectedStatus int, expectedType string) []byte {
+		t.Helper()
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Failed requesting %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatalf("Failed reading response to %s: %v", path, err)
+		}
+		if response.StatusCode != expectedStatus {
+			t.Errorf("Expected status %d for %s, got %d: %s", expectedStatus, path, response.StatusCode, body)
+		}
+		if contentType := response.Header.Get("Content-Type"); expectedType != "" && contentType != expectedType {
+			t.Errorf("Expected content type %s for %s, got %s", expectedType, path, contentType)
+		}
+		return body
+	}
+	decodeIDs := func(body []byte) []*fields.QualifiedHash {
+		t.Helper()
+		var ids []*fields.QualifiedHash
+		if err := json.Unmarshal(body, &ids); err != nil {
+			t.Fatalf("Failed decoding IDs from %s: %v", body, err)
+		}
+		return ids
+	}
+
+	body := get("/node/"+reply.ID().String(), http.StatusOK, "application/octet-stream")
+	if node, err := forest.UnmarshalBinaryNode(body); err != nil {
+		t.Errorf("Failed parsing served node: %v", err)
+	} else if !node.Equals(reply) {
+		t.Errorf("Expected served node to be %s, got %s", reply.ID(), node.ID())
+	}
+
+	children := decodeIDs(get("/children/"+tnb.Community.ID().String(), http.StatusOK, "application/json"))
+	if len(children) != 1 || !children[0].Equals(reply.ID()) {
+		t.Errorf("Expected community to have only child %s, got %v", reply.ID(), children)
+	}
+
+	recent := decodeIDs(get("/recent/community?n=5", http.StatusOK, "application/json"))
+	if len(recent) != 1 || !recent[0].Equals(tnb.Community.ID()) {
+		t.Errorf("Expected only %s among recent communities, got %v", tnb.Community.ID(), recent)
+	}
+
+	missing := testutil.RandomQualifiedHash().String()
+	get("/node/"+missing, http.StatusNotFound, "")
+	get("/children/"+missing, http.StatusNotFound, "")
+	get("/node/not-an-id", http.StatusBadRequest, "")
+	get("/recent/conversation", http.StatusBadRequest, "")
+	get("/recent/reply?n=-1", http.StatusBadRequest, "")
+	get("/recent/reply?n=1001", http.StatusBadRequest, "")
+	get("/recent/reply?n=2000000000", http.StatusBadRequest, "")
+	get("/node/SHA512_B32__AAAA", http.StatusBadRequest, "")
+}
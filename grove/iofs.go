@@ -0,0 +1,115 @@
+//go:build go1.16
+// +build go1.16
+
+package grove
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+
+	"git.sr.ht/~whereswaldon/forest-go/store"
+)
+
+// ioFS adapts a standard library fs.FS into a read-only FS. Every method that
+// would modify the file system fails with an error matching store.ErrReadOnly.
+type ioFS struct {
+	fsys fs.FS
+}
+
+// ensure ioFS satisfies the StatFS interface
+var _ StatFS = ioFS{}
+
+// resolve converts a path within a grove into an fs.FS path. The root of a
+// grove is the empty path, but fs.FS calls it ".".
+func (i ioFS) resolve(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// Open opens the given path within the fs.FS.
+func (i ioFS) Open(path string) (File, error) {
+	file, err := i.fsys.Open(i.resolve(path))
+	if err != nil {
+		return nil, err
+	}
+	return ioFile{File: file, name: path}, nil
+}
+
+// Create always fails, because an fs.FS cannot be written.
+func (i ioFS) Create(path string) (File, error) {
+	return nil, fmt.Errorf("cannot create %s: %w", path, store.ErrReadOnly)
+}
+
+// OpenFile opens the given path within the fs.FS if flag requests read-only
+// access, and fails otherwise.
+func (i ioFS) OpenFile(path string, flag int, perm os.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, fmt.Errorf("cannot open %s for writing: %w", path, store.ErrReadOnly)
+	}
+	return i.Open(path)
+}
+
+// Remove always fails, because an fs.FS cannot be written.
+func (i ioFS) Remove(path string) error {
+	return fmt.Errorf("cannot remove %s: %w", path, store.ErrReadOnly)
+}
+
+// Stat returns information about the given path within the fs.FS.
+func (i ioFS) Stat(path string) (os.FileInfo, error) {
+	return fs.Stat(i.fsys, i.resolve(path))
+}
+
+// ioFile adapts an fs.File into a File.
+type ioFile struct {
+	fs.File
+	name string
+}
+
+// Name returns the path at which the file was opened.
+func (f ioFile) Name() string {
+	return f.name
+}
+
+// Write always fails, because an fs.FS cannot be written.
+func (f ioFile) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("cannot write %s: %w", f.name, store.ErrReadOnly)
+}
+
+// Readdir lists the contents of the file, which must be a directory.
+func (f ioFile) Readdir(n int) ([]os.FileInfo, error) {
+	dir, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a directory", f.name)
+	}
+	entries, err := dir.ReadDir(n)
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return infos, fmt.Errorf("failed getting info for %s: %w", entry.Name(), infoErr)
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+// NewFromFSReadOnly constructs a Grove that reads its nodes from the root of
+// fsys, such as an embed.FS or the result of os.DirFS. Since an fs.FS cannot
+// be written, Add and RemoveSubtree on the returned Grove fail with an error
+// matching store.ErrReadOnly. If fsys holds a child index, it is used to answer
+// Children without parsing every node; otherwise the ChildCache is built by
+// reading the nodes on first use.
+func NewFromFSReadOnly(fsys fs.FS) (*Grove, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("fsys cannot be nil")
+	}
+	g, err := newGrove(ioFS{fsys: fsys})
+	if err != nil {
+		return nil, err
+	}
+	g.readOnly = true
+	return g, nil
+}
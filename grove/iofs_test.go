@@ -0,0 +1,71 @@
+//go:build go1.16
+// +build go1.16
+
+package grove_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/grove"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+)
+
+func TestGroveFromFSReadOnly(t *testing.T) {
+	tnb := NewNodeBuilder(t)
+	if tnb == nil {
+		t.Skip("Failed creating test nodes")
+	}
+	reply, err := tnb.NewReply(tnb.Community, "embedded", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	fsys := fstest.MapFS{}
+	for _, node := range []forest.Node{tnb.User, tnb.Community, reply} {
+		data, err := node.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed marshalling %s: %v", node.ID(), err)
+		}
+		fsys[node.ID().String()] = &fstest.MapFile{Data: data}
+	}
+	g, err := grove.NewFromFSReadOnly(fsys)
+	if err != nil {
+		t.Fatalf("Failed creating grove from fs.FS: %v", err)
+	}
+
+	if node, has, err := g.Get(reply.ID()); err != nil || !has {
+		t.Errorf("Expected reply to be in grove, has=%v err=%v", has, err)
+	} else if !node.Equals(reply) {
+		t.Errorf("Expected grove to return %s, got %s", reply.ID(), node.ID())
+	}
+	if has, err := g.Has(tnb.Community.ID()); err != nil || !has {
+		t.Errorf("Expected community to be in grove, has=%v err=%v", has, err)
+	}
+	if children, err := g.Children(tnb.Community.ID()); err != nil {
+		t.Errorf("Failed listing children: %v", err)
+	} else if len(children) != 1 || !children[0].Equals(reply.ID()) {
+		t.Errorf("Expected community to have only child %s, got %v", reply.ID(), children)
+	}
+	if recent, err := g.Recent(fields.NodeTypeReply, 5); err != nil {
+		t.Errorf("Failed listing recent replies: %v", err)
+	} else if len(recent) != 1 || !recent[0].Equals(reply) {
+		t.Errorf("Expected only %s among recent replies, got %v", reply.ID(), recent)
+	}
+
+	other, err := tnb.NewReply(tnb.Community, "not embedded", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	if err := g.Add(other); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Expected adding to a read-only grove to fail with ErrReadOnly, got %v", err)
+	}
+	if err := g.RemoveSubtree(reply.ID()); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Expected removing from a read-only grove to fail with ErrReadOnly, got %v", err)
+	}
+	if children, err := g.Children(tnb.Community.ID()); err != nil || len(children) != 1 {
+		t.Errorf("Expected failed writes to leave children unchanged, got %v (err %v)", children, err)
+	}
+}
@@ -0,0 +1,112 @@
+package grove
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+)
+
+// AddFromReader reads a single binary-marshaled node from r and adds it to the
+// grove. It returns the node that was read. Failures to parse the node data
+// match the sentinel errors of forest.UnmarshalBinaryNode with errors.Is. At
+// most forest.MaxNodeSize bytes are accepted; longer data is rejected with an
+// error wrapping forest.ErrNodeTooLarge without being read in full.
+func (g *Grove) AddFromReader(r io.Reader) (forest.Node, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(bufio.NewReader(r), forest.MaxNodeSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed reading node data: %w", err)
+	} else if len(data) > forest.MaxNodeSize {
+		return nil, fmt.Errorf("node data exceeds limit of %d bytes: %w", forest.MaxNodeSize, forest.ErrNodeTooLarge)
+	}
+	node, err := forest.UnmarshalBinaryNode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshalling node: %w", err)
+	}
+	if err := g.Add(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// LoadProgress describes how far a call to LoadDir has gotten.
+type LoadProgress struct {
+	// Path is the file that was most recently processed
+	Path string
+	// Loaded and Skipped count the files that have been added to the grove
+	// and that were skipped because they did not contain a node
+	Loaded, Skipped int
+	// Total is the number of files that will be processed
+	Total int
+}
+
+// LoadDir adds the node in each file of the given directory to the grove. The
+// files are read one at a time, so memory use does not grow with the size of
+// the directory. Files that do not contain a valid node are skipped with a
+// logged warning. If progress is not nil, it is invoked after each file is
+// processed. Subdirectories are ignored.
+func (g *Grove) LoadDir(dir string, progress func(LoadProgress)) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed listing files in %s: %w", dir, err)
+	}
+	state := LoadProgress{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			state.Total++
+		}
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		state.Path = filepath.Join(dir, info.Name())
+		if err := g.loadFile(state.Path); isNodeDataError(err) {
+			log.Printf("skipping %s: %v", state.Path, err)
+			state.Skipped++
+		} else if err != nil {
+			return err
+		} else {
+			state.Loaded++
+		}
+		if progress != nil {
+			progress(state)
+		}
+	}
+	return nil
+}
+
+// loadFile adds the node in the file at path to the grove.
+func (g *Grove) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed opening %s: %w", path, err)
+	}
+	defer file.Close()
+	if _, err := g.AddFromReader(file); err != nil {
+		return fmt.Errorf("failed loading node from %s: %w", path, err)
+	}
+	return nil
+}
+
+// isNodeDataError reports whether err indicates data that is not a node.
+func isNodeDataError(err error) bool {
+	for _, target := range []error{
+		forest.ErrTruncated,
+		forest.ErrUnknownNodeType,
+		forest.ErrBadDescriptor,
+		forest.ErrUnsupportedSchemaVersion,
+		forest.ErrNodeTooLarge,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package grove
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+)
+
+// ExportTar writes every node in the grove to w as a tar archive. Each node is
+// stored in its binary form in a regular file named by its ID, so the archive
+// can be extracted directly into a grove directory or read with ImportTar.
+// Files in the grove that do not contain valid nodes are left out. Entries are
+// sorted by name and timestamped with the creation time of their node, so
+// exporting the same nodes always produces the same archive.
+func (g *Grove) ExportTar(w io.Writer) error {
+	// reading nodes may populate the node cache, so this needs exclusive access
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	nodeInfo, err := g.getAllNodeFileInfo()
+	if err != nil {
+		return fmt.Errorf("failed listing node file candidates: %w", err)
+	}
+	sort.Slice(nodeInfo, func(i, j int) bool {
+		return nodeInfo[i].Name() < nodeInfo[j].Name()
+	})
+	archive := tar.NewWriter(w)
+	for _, info := range nodeInfo {
+		node, err := g.nodeFromInfo(info)
+		if isNodeDataError(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		data, err := node.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("failed to serialize node %s: %w", node.ID(), err)
+		}
+		name, err := forest.NodeFilename(node)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  node.CreatedAt(),
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed writing tar header for %s: %w", header.Name, err)
+		}
+		if _, err := archive.Write(data); err != nil {
+			return fmt.Errorf("failed writing %s to tar: %w", header.Name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed finishing tar: %w", err)
+	}
+	return nil
+}
+
+// ImportTar adds the nodes in a tar archive written by ExportTar to the grove.
+// Each entry must be a regular file containing a valid node and named by that
+// node's ID. Directory entries are ignored. An entry longer than
+// forest.MaxNodeSize is rejected with an error wrapping forest.ErrNodeTooLarge
+// without being read in full. Any other entry stops the import
+// with an error before anything is written for it, though nodes from earlier
+// entries will already have been added.
+func (g *Grove) ImportTar(r io.Reader) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed reading tar: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return fmt.Errorf("tar entry %s is not a regular file", header.Name)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(archive, forest.MaxNodeSize+1))
+		if err != nil {
+			return fmt.Errorf("failed reading tar entry %s: %w", header.Name, err)
+		} else if len(data) > forest.MaxNodeSize {
+			return fmt.Errorf("tar entry %s exceeds limit of %d bytes: %w", header.Name, forest.MaxNodeSize, forest.ErrNodeTooLarge)
+		}
+		node, err := forest.UnmarshalBinaryNode(data)
+		if err != nil {
+			return fmt.Errorf("failed unmarshalling node from tar entry %s: %w", header.Name, err)
+		}
+		if name, err := forest.NodeFilename(node); err != nil {
+			return err
+		} else if name != header.Name {
+			return fmt.Errorf("tar entry %s contains node %s", header.Name, node.ID())
+		}
+		if err := g.Add(node); err != nil {
+			return err
+		}
+	}
+}
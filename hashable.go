@@ -1,10 +1,8 @@
 package forest
 
 import (
-	"crypto/sha512"
 	"encoding"
 	"fmt"
-	"hash"
 
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 )
@@ -14,15 +12,10 @@ type Hashable interface {
 	encoding.BinaryMarshaler
 }
 
-// computeID determines the correct value of the ID of any hashable entity
+// computeID determines the correct value of the ID of any hashable entity,
+// using the hash algorithm registered for its HashType with
+// fields.RegisterHashType.
 func computeID(h Hashable) ([]byte, error) {
-	// map from HashType and Length to the function that creates an instance of that hash
-	// algorithm
-	hashType2Func := map[fields.HashType]map[fields.ContentLength]func() hash.Hash{
-		fields.HashTypeSHA512: map[fields.ContentLength]func() hash.Hash{
-			fields.HashDigestLengthSHA512_256: sha512.New512_256,
-		},
-	}
 	hd := h.HashDescriptor()
 	if hd.Type == fields.HashTypeNullHash {
 		return []byte{}, nil
@@ -31,15 +24,14 @@ func computeID(h Hashable) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	hashCategory, found := hashType2Func[fields.HashType(hd.Type)]
+	hashFunc, found := fields.LookupHashFunc(hd.Type)
 	if !found {
 		return nil, fmt.Errorf("Unknown HashType %d", hd.Type)
 	}
-	hashFunc, found := hashCategory[hd.Length]
-	if !found {
+	if hd.Length != hashFunc.Length {
 		return nil, fmt.Errorf("Invalid hash length %d for hash type %d", hd.Length, hd.Type)
 	}
-	hasher := hashFunc()
+	hasher := hashFunc.New()
 	_, _ = hasher.Write(binaryContent) // never errors
 	return hasher.Sum(nil), nil
 }
@@ -0,0 +1,77 @@
+package forest_test
+
+import (
+	"hash"
+	"hash/crc32"
+	"sync"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"golang.org/x/crypto/sha3"
+)
+
+// rawHashable hashes a fixed byte string with a configurable hash type.
+type rawHashable struct {
+	desc fields.HashDescriptor
+	data []byte
+}
+
+func (r rawHashable) HashDescriptor() *fields.HashDescriptor {
+	return &r.desc
+}
+
+func (r rawHashable) MarshalBinary() ([]byte, error) {
+	return r.data, nil
+}
+
+// crcType is a hash type registered by registerCRCType for the tests in this
+// file.
+const crcType fields.HashType = 201
+
+var (
+	registerCRCOnce sync.Once
+	registerCRCErr  error
+)
+
+// registerCRCType registers crcType the first time that it is called. The
+// hash registry is global and has no exported way to remove a type, so
+// registering on every call would fail when the tests are run more than once.
+func registerCRCType() error {
+	registerCRCOnce.Do(func() {
+		registerCRCErr = fields.RegisterHashType(crcType, "CRC32", fields.HashFunc{New: func() hash.Hash { return crc32.NewIEEE() }, Length: 4})
+	})
+	return registerCRCErr
+}
+
+func TestValidateIDRegisteredHash(t *testing.T) {
+	if err := registerCRCType(); err != nil {
+		t.Fatalf("Failed registering hash type: %v", err)
+	}
+	data := []byte("hash me")
+	sha3Digest := sha3.Sum256(data)
+	crcHash := crc32.NewIEEE()
+	_, _ = crcHash.Write(data)
+	for _, run := range []struct {
+		hashType fields.HashType
+		digest   []byte
+	}{
+		{fields.HashTypeSHA3, sha3Digest[:]},
+		{crcType, crcHash.Sum(nil)},
+	} {
+		expected, err := fields.NewQualifiedHash(run.hashType, run.digest)
+		if err != nil {
+			t.Fatalf("Failed creating expected ID: %v", err)
+		}
+		h := rawHashable{desc: expected.Descriptor, data: data}
+		if valid, err := forest.ValidateID(h, *expected); err != nil {
+			t.Errorf("Failed validating ID of hash type %d: %v", run.hashType, err)
+		} else if !valid {
+			t.Errorf("Expected ID of hash type %d to match its content", run.hashType)
+		}
+		h.data = []byte("something else")
+		if valid, err := forest.ValidateID(h, *expected); err != nil || valid {
+			t.Errorf("Expected ID of hash type %d not to match other content, got valid=%v err=%v", run.hashType, valid, err)
+		}
+	}
+}
@@ -52,3 +52,15 @@ func TestIdentitySerialize(t *testing.T) {
 		t.Errorf("Deserialized identity should be the same as what went in, expected %v, got %v", identity, id2)
 	}
 }
+
+func TestAuthorID(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	if !identity.Author.Equals(fields.NullHash()) {
+		t.Errorf("Expected identity's Author field to hold the null hash")
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if !node.AuthorID().Equals(identity.ID()) {
+			t.Errorf("Expected %T to be authored by %v, got %v", node, identity.ID(), node.AuthorID())
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package forest
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+/*
+JSON schema
+
+The binary form of a node is its canonical representation. The JSON form exists
+so that tooling that cannot easily handle the binary format can exchange nodes.
+Every node is encoded as a single JSON object with the following keys:
+
+    version        number  schema version of the node
+    type           number  fields.NodeType of the node
+    id             hash    the ID of the node (ignored if absent when parsing)
+    parent         hash
+    idDescriptor   object  {"type": number, "length": number}
+    depth          number
+    created        number  milliseconds since the UNIX epoch
+    metadata       content
+    author         hash
+    signature      content
+
+Identity nodes additionally have "name" (content) and "publicKey" (content)
+keys. Community nodes additionally have a "name" (content) key. Reply nodes
+additionally have "communityID" (hash), "conversationID" (hash), and "content"
+(content) keys.
+
+A "hash" is an object of the form {"type": number, "length": number, "value": string}
+where value is the hex encoding of the hash bytes. A "content" is an object of
+the same form where value is the standard base64 encoding of the bytes. The
+type of a content is the corresponding ContentType, KeyType, or SignatureType.
+*/
+
+type jsonDescriptor struct {
+	Type   uint8 `json:"type"`
+	Length int   `json:"length"`
+}
+
+type jsonQualified struct {
+	jsonDescriptor
+	Value string `json:"value"`
+}
+
+type jsonNode struct {
+	Version        uint16         `json:"version"`
+	Type           uint8          `json:"type"`
+	ID             *jsonQualified `json:"id,omitempty"`
+	Parent         jsonQualified  `json:"parent"`
+	IDDescriptor   jsonDescriptor `json:"idDescriptor"`
+	Depth          uint32         `json:"depth"`
+	Created        uint64         `json:"created"`
+	Metadata       jsonQualified  `json:"metadata"`
+	Author         jsonQualified  `json:"author"`
+	Name           *jsonQualified `json:"name,omitempty"`
+	PublicKey      *jsonQualified `json:"publicKey,omitempty"`
+	CommunityID    *jsonQualified `json:"communityID,omitempty"`
+	ConversationID *jsonQualified `json:"conversationID,omitempty"`
+	Content        *jsonQualified `json:"content,omitempty"`
+	Signature      jsonQualified  `json:"signature"`
+}
+
+func hashToJSON(q *fields.QualifiedHash) *jsonQualified {
+	return &jsonQualified{
+		jsonDescriptor: jsonDescriptor{Type: uint8(q.Descriptor.Type), Length: int(q.Descriptor.Length)},
+		Value:          hex.EncodeToString(q.Blob),
+	}
+}
+
+func hashFromJSON(j *jsonQualified, name string) (*fields.QualifiedHash, error) {
+	if j == nil {
+		return nil, fmt.Errorf("missing required field %s", name)
+	}
+	b, err := hex.DecodeString(j.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding %s: %w", name, err)
+	}
+	if j.Length != len(b) {
+		return nil, fmt.Errorf("%s length %d does not match value length %d", name, j.Length, len(b))
+	}
+	return fields.NewQualifiedHash(fields.HashType(j.Type), b)
+}
+
+func blobToJSON(t uint8, b fields.Blob) *jsonQualified {
+	return &jsonQualified{
+		jsonDescriptor: jsonDescriptor{Type: t, Length: len(b)},
+		Value:          base64.StdEncoding.EncodeToString(b),
+	}
+}
+
+func blobFromJSON(j *jsonQualified, name string) ([]byte, error) {
+	if j == nil {
+		return nil, fmt.Errorf("missing required field %s", name)
+	}
+	b, err := base64.StdEncoding.DecodeString(j.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding %s: %w", name, err)
+	}
+	if j.Length != len(b) {
+		return nil, fmt.Errorf("%s length %d does not match value length %d", name, j.Length, len(b))
+	}
+	return b, nil
+}
+
+func contentFromJSON(j *jsonQualified, name string) (*fields.QualifiedContent, error) {
+	b, err := blobFromJSON(j, name)
+	if err != nil {
+		return nil, err
+	}
+	return fields.NewQualifiedContent(fields.ContentType(j.Type), b)
+}
+
+// toJSON populates the fields of the jsonNode that are common to all nodes.
+func (n *CommonNode) toJSON(t *Trailer) *jsonNode {
+	return &jsonNode{
+		Version: uint16(n.Version),
+		Type:    uint8(n.Type),
+		ID:      hashToJSON(n.ID()),
+		Parent:  *hashToJSON(&n.Parent),
+		IDDescriptor: jsonDescriptor{
+			Type:   uint8(n.IDDesc.Type),
+			Length: int(n.IDDesc.Length),
+		},
+		Depth:     uint32(n.Depth),
+		Created:   uint64(n.Created),
+		Metadata:  *blobToJSON(uint8(n.Metadata.Descriptor.Type), n.Metadata.Blob),
+		Author:    *hashToJSON(&n.Author),
+		Signature: *blobToJSON(uint8(t.Signature.Descriptor.Type), t.Signature.Blob),
+	}
+}
+
+// fromJSON populates the CommonNode and Trailer from the jsonNode.
+func (n *CommonNode) fromJSON(t *Trailer, j *jsonNode) error {
+	n.Version = fields.Version(j.Version)
+	n.Type = fields.NodeType(j.Type)
+	parent, err := hashFromJSON(&j.Parent, "parent")
+	if err != nil {
+		return err
+	}
+	n.Parent = *parent
+	idDesc, err := fields.NewHashDescriptor(fields.HashType(j.IDDescriptor.Type), j.IDDescriptor.Length)
+	if err != nil {
+		return fmt.Errorf("failed decoding idDescriptor: %w", err)
+	}
+	n.IDDesc = *idDesc
+	n.Depth = fields.TreeDepth(j.Depth)
+	n.Created = fields.Timestamp(j.Created)
+	metadata, err := contentFromJSON(&j.Metadata, "metadata")
+	if err != nil {
+		return err
+	}
+	n.Metadata = *metadata
+	author, err := hashFromJSON(&j.Author, "author")
+	if err != nil {
+		return err
+	}
+	n.Author = *author
+	sig, err := blobFromJSON(&j.Signature, "signature")
+	if err != nil {
+		return err
+	}
+	qs, err := fields.NewQualifiedSignature(fields.SignatureType(j.Signature.Type), sig)
+	if err != nil {
+		return err
+	}
+	t.Signature = *qs
+	return nil
+}
+
+// MarshalJSON encodes the Identity using the JSON schema documented in this package.
+func (i *Identity) MarshalJSON() ([]byte, error) {
+	j := i.CommonNode.toJSON(&i.Trailer)
+	j.Name = blobToJSON(uint8(i.Name.Descriptor.Type), i.Name.Blob)
+	j.PublicKey = blobToJSON(uint8(i.PublicKey.Descriptor.Type), i.PublicKey.Blob)
+	return json.Marshal(j)
+}
+
+// MarshalJSON encodes the Community using the JSON schema documented in this package.
+func (c *Community) MarshalJSON() ([]byte, error) {
+	j := c.CommonNode.toJSON(&c.Trailer)
+	j.Name = blobToJSON(uint8(c.Name.Descriptor.Type), c.Name.Blob)
+	return json.Marshal(j)
+}
+
+// MarshalJSON encodes the Reply using the JSON schema documented in this package.
+func (r *Reply) MarshalJSON() ([]byte, error) {
+	j := r.CommonNode.toJSON(&r.Trailer)
+	j.CommunityID = hashToJSON(&r.CommunityID)
+	j.ConversationID = hashToJSON(&r.ConversationID)
+	j.Content = blobToJSON(uint8(r.Content.Descriptor.Type), r.Content.Blob)
+	return json.Marshal(j)
+}
+
+// UnmarshalJSONNode parses a node of any type from the JSON schema documented
+// in this package. If the JSON includes an ID, it must match the ID computed
+// from the rest of the node.
+func UnmarshalJSONNode(b []byte) (Node, error) {
+	var j jsonNode
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, fmt.Errorf("failed parsing node json: %w", err)
+	}
+	if err := checkSchemaVersion(fields.Version(j.Version)); err != nil {
+		return nil, err
+	}
+	var (
+		node   Hashable
+		common *CommonNode
+		err    error
+	)
+	nodeType := fields.NodeType(j.Type)
+	switch nodeType {
+	case fields.NodeTypeIdentity:
+		i := newIdentity()
+		if err = i.CommonNode.fromJSON(&i.Trailer, &j); err != nil {
+			break
+		}
+		var name *fields.QualifiedContent
+		if name, err = contentFromJSON(j.Name, "name"); err != nil {
+			break
+		}
+		i.Name = *name
+		var key []byte
+		if key, err = blobFromJSON(j.PublicKey, "publicKey"); err != nil {
+			break
+		}
+		var qkey *fields.QualifiedKey
+		if qkey, err = fields.NewQualifiedKey(fields.KeyType(j.PublicKey.Type), key); err != nil {
+			break
+		}
+		i.PublicKey = *qkey
+		node, common = i, &i.CommonNode
+	case fields.NodeTypeCommunity:
+		c := newCommunity()
+		if err = c.CommonNode.fromJSON(&c.Trailer, &j); err != nil {
+			break
+		}
+		var name *fields.QualifiedContent
+		if name, err = contentFromJSON(j.Name, "name"); err != nil {
+			break
+		}
+		c.Name = *name
+		node, common = c, &c.CommonNode
+	case fields.NodeTypeReply:
+		r := newReply()
+		if err = r.CommonNode.fromJSON(&r.Trailer, &j); err != nil {
+			break
+		}
+		var communityID, conversationID *fields.QualifiedHash
+		if communityID, err = hashFromJSON(j.CommunityID, "communityID"); err != nil {
+			break
+		}
+		r.CommunityID = *communityID
+		if conversationID, err = hashFromJSON(j.ConversationID, "conversationID"); err != nil {
+			break
+		}
+		r.ConversationID = *conversationID
+		var content *fields.QualifiedContent
+		if content, err = contentFromJSON(j.Content, "content"); err != nil {
+			break
+		}
+		r.Content = *content
+		node, common = r, &r.CommonNode
+	default:
+		return nil, fmt.Errorf("Unable to unmarshal node of type %d, unknown type", j.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding %s node json: %w", fields.NodeTypeNames[nodeType], err)
+	}
+	if common.id, err = computeID(node); err != nil {
+		return nil, fmt.Errorf("failed computing node id: %w", err)
+	}
+	if j.ID != nil {
+		expected, err := hashFromJSON(j.ID, "id")
+		if err != nil {
+			return nil, err
+		}
+		if !expected.Equals(common.ID()) {
+			return nil, fmt.Errorf("node id %s does not match computed id %s", expected, common.ID())
+		}
+	}
+	return node.(Node), nil
+}
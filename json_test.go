@@ -0,0 +1,60 @@
+package forest_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	for _, node := range []forest.Node{identity, community, reply} {
+		b, err := json.Marshal(node)
+		if err != nil {
+			t.Fatalf("Failed marshalling %T to json: %v", node, err)
+		}
+		parsed, err := forest.UnmarshalJSONNode(b)
+		if err != nil {
+			t.Fatalf("Failed unmarshalling %T from json: %v", node, err)
+		}
+		if !parsed.ID().Equals(node.ID()) {
+			t.Errorf("Expected json round trip to preserve id %v, got %v", node.ID(), parsed.ID())
+		}
+		if !parsed.Equals(node) {
+			t.Errorf("Expected json round trip to produce an equal %T", node)
+		}
+		original, err := node.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed marshalling %T to binary: %v", node, err)
+		}
+		roundTripped, err := parsed.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed marshalling parsed %T to binary: %v", node, err)
+		}
+		if string(original) != string(roundTripped) {
+			t.Errorf("Expected binary->json->binary to preserve binary form of %T", node)
+		}
+	}
+}
+
+func TestJSONMismatchedID(t *testing.T) {
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	b, err := json.Marshal(community)
+	if err != nil {
+		t.Fatalf("Failed marshalling community to json: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatalf("Failed parsing generated json: %v", err)
+	}
+	raw["created"] = raw["created"].(float64) + 1
+	b, err = json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("Failed re-encoding json: %v", err)
+	}
+	if _, err := forest.UnmarshalJSONNode(b); err == nil {
+		t.Errorf("Expected error unmarshalling json with id that doesn't match its content")
+	}
+}
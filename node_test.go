@@ -1,9 +1,12 @@
 package forest_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
 	"git.sr.ht/~whereswaldon/forest-go/testutil"
 )
 
@@ -23,3 +26,146 @@ func TestUnmarshalNode(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalNodeErrors(t *testing.T) {
+	_, _, _, reply := testutil.MakeReplyOrSkip(t)
+	bin, err := reply.MarshalBinary()
+	if err != nil {
+		t.Skip("Failed to marshal node into binary", err)
+	}
+	corrupt := func(index int, value byte) []byte {
+		b := append([]byte{}, bin...)
+		b[index] = value
+		return b
+	}
+	for _, row := range []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", []byte{}, forest.ErrTruncated},
+		{"empty input", []byte{}, forest.ErrEmptyInput},
+		{"nil input", nil, forest.ErrEmptyInput},
+		{"truncated schema", bin[:1], forest.ErrTruncated},
+		{"truncated body", bin[:len(bin)/2], forest.ErrTruncated},
+		{"missing last byte", bin[:len(bin)-1], forest.ErrTruncated},
+		// the node type immediately follows the two-byte version
+		{"unknown node type", corrupt(2, 200), forest.ErrUnknownNodeType},
+		// the parent's hash type immediately follows the node type
+		{"bad hash type", corrupt(3, 200), forest.ErrBadDescriptor},
+	} {
+		t.Run(row.name, func(t *testing.T) {
+			_, err := forest.UnmarshalBinaryNode(row.data)
+			if !errors.Is(err, row.expected) {
+				t.Errorf("Expected error matching %q, got: %v", row.expected, err)
+			}
+		})
+	}
+	if _, err := forest.UnmarshalBinaryNode(bin[:1]); errors.Is(err, forest.ErrEmptyInput) {
+		t.Errorf("Expected truncated but nonempty input not to match %q", forest.ErrEmptyInput)
+	}
+	if _, err := forest.NodeTypeOf(corrupt(2, 200)); !errors.Is(err, forest.ErrUnknownNodeType) {
+		t.Errorf("Expected NodeTypeOf error matching %q, got: %v", forest.ErrUnknownNodeType, err)
+	}
+}
+
+func TestSchemaHeader(t *testing.T) {
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	bin, err := community.MarshalBinary()
+	if err != nil {
+		t.Skip("Failed to marshal node into binary", err)
+	}
+	// the header is the two-byte version followed by the one-byte type, and
+	// nothing after it should be consulted
+	header := bin[:3]
+	if nodeType, err := forest.NodeTypeOf(header); err != nil {
+		t.Errorf("Failed reading node type from header: %v", err)
+	} else if nodeType != fields.NodeTypeCommunity {
+		t.Errorf("Expected node type %d, got %d", fields.NodeTypeCommunity, nodeType)
+	}
+	if version, err := forest.SchemaVersionOf(header[:2]); err != nil {
+		t.Errorf("Failed reading schema version from header: %v", err)
+	} else if version != community.Version {
+		t.Errorf("Expected schema version %d, got %d", community.Version, version)
+	}
+	for length := 0; length < 2; length++ {
+		if _, err := forest.SchemaVersionOf(header[:length]); !errors.Is(err, forest.ErrTruncated) {
+			t.Errorf("Expected reading version from %d bytes to fail with %q, got: %v", length, forest.ErrTruncated, err)
+		}
+	}
+	for length := 0; length < 3; length++ {
+		if _, err := forest.NodeTypeOf(header[:length]); !errors.Is(err, forest.ErrTruncated) {
+			t.Errorf("Expected reading type from %d bytes to fail with %q, got: %v", length, forest.ErrTruncated, err)
+		}
+	}
+}
+
+func TestUnmarshalNodeSchemaVersion(t *testing.T) {
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	bin, err := community.MarshalBinary()
+	if err != nil {
+		t.Skip("Failed to marshal node into binary", err)
+	}
+	if !forest.IsSupportedSchemaVersion(fields.CurrentVersion) {
+		t.Errorf("Expected the current schema version to be supported")
+	} else if forest.IsSupportedSchemaVersion(258) {
+		t.Errorf("Expected schema version 258 not to be supported")
+	}
+	future := append([]byte{}, bin...)
+	// the version is a two-byte big-endian integer at the start of the node
+	future[0], future[1] = 0x01, 0x02
+	if _, err := forest.UnmarshalBinaryNode(future); !errors.Is(err, forest.ErrUnsupportedSchemaVersion) {
+		t.Errorf("Expected error matching %q, got: %v", forest.ErrUnsupportedSchemaVersion, err)
+	} else if !strings.Contains(err.Error(), "258") {
+		t.Errorf("Expected error to include the offending version 258, got: %v", err)
+	}
+
+	var consulted fields.Version
+	forest.SetSchemaVersionHandler(func(version fields.Version) bool {
+		consulted = version
+		return true
+	})
+	defer forest.SetSchemaVersionHandler(nil)
+	node, err := forest.UnmarshalBinaryNode(future)
+	if err != nil {
+		t.Fatalf("Expected handler to allow parsing newer version, got: %v", err)
+	}
+	if consulted != 258 {
+		t.Errorf("Expected handler to be consulted about version 258, got %d", consulted)
+	}
+	if parsed, ok := node.(*forest.Community); !ok {
+		t.Errorf("Expected best-effort parse to produce a community, got %T", node)
+	} else if !parsed.Name.Equals(&community.Name) {
+		t.Errorf("Expected best-effort parse to preserve community name")
+	}
+	consulted = 0
+	if _, err := forest.UnmarshalBinaryNode(bin); err != nil {
+		t.Errorf("Failed to unmarshal supported version: %v", err)
+	} else if consulted != 0 {
+		t.Errorf("Handler should not be consulted for supported versions")
+	}
+
+	forest.SetSchemaVersionHandler(nil)
+	if _, err := forest.UnmarshalBinaryNode(future); !errors.Is(err, forest.ErrUnsupportedSchemaVersion) {
+		t.Errorf("Expected clearing the handler to reject newer versions, got: %v", err)
+	}
+}
+
+func TestNodeFilename(t *testing.T) {
+	id, _, community, reply := testutil.MakeReplyOrSkip(t)
+	for _, node := range []forest.Node{id, community, reply} {
+		name, err := forest.NodeFilename(node)
+		if err != nil {
+			t.Fatalf("Failed computing filename of %s: %v", node.ID(), err)
+		}
+		if strings.ContainsAny(name, "/\\") {
+			t.Errorf("Expected filename %q not to contain path separators", name)
+		}
+		parsed, err := fields.ParseQualifiedHash(name)
+		if err != nil {
+			t.Errorf("Failed parsing filename %q: %v", name, err)
+		} else if !parsed.Equals(node.ID()) {
+			t.Errorf("Expected filename %q to round-trip to %s, got %s", name, node.ID(), parsed)
+		}
+	}
+}
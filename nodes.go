@@ -2,7 +2,9 @@ package forest
 
 import (
 	"encoding"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"time"
 
@@ -18,7 +20,13 @@ type Validator interface {
 }
 
 type Node interface {
+	// AuthorID returns the ID of the Identity that signed the node. Identities
+	// are self-authored, so the AuthorID of an Identity is its own ID even
+	// though its Author field holds the null hash.
 	AuthorID() *fields.QualifiedHash
+	// CreatedAt returns the time at which the node was created, as recorded
+	// in its Created field. Code that handles nodes generically should use it
+	// rather than switching on the concrete node type to reach that field.
 	CreatedAt() time.Time
 	Equals(interface{}) bool
 	ID() *fields.QualifiedHash
@@ -31,41 +39,181 @@ type Node interface {
 	encoding.BinaryUnmarshaler
 }
 
+var (
+	// ErrTruncated indicates that binary node data ended before the node
+	// was complete.
+	ErrTruncated = errors.New("node data truncated")
+	// ErrUnknownNodeType indicates that binary node data does not describe
+	// a known type of node.
+	ErrUnknownNodeType = errors.New("unknown node type")
+	// ErrBadDescriptor indicates that a descriptor within binary node data
+	// specifies an invalid type.
+	ErrBadDescriptor = errors.New("invalid descriptor in node data")
+	// ErrEmptyInput indicates that there was no node data at all. Errors
+	// matching it also match ErrTruncated.
+	ErrEmptyInput = errors.New("empty input")
+	// ErrTooDeep indicates that a reply is deeper in its tree than
+	// MaxTreeDepth (or a lower limit configured on a Builder).
+	ErrTooDeep = errors.New("reply too deep")
+	// ErrUnsigned indicates that a node is a draft that has not been signed
+	// yet (see Builder.DraftReply).
+	ErrUnsigned = errors.New("node is an unsigned draft")
+	// ErrNodeTooLarge indicates that node data is longer than MaxNodeSize.
+	ErrNodeTooLarge = errors.New("node data too large")
+)
+
+// MaxNodeSize is the greatest length in bytes of the binary form of a node
+// that will be read from a stream or file. Every variable-length field of a
+// node is limited to fields.MaxContentLength bytes, so valid nodes are far
+// smaller than this.
+const MaxNodeSize = 1 << 20
+
+// MaxTreeDepth is the greatest depth that a valid reply may have. Builders
+// refuse to create deeper replies and ValidateShallow rejects them, which
+// bounds the work needed to walk from any reply to its community.
+const MaxTreeDepth fields.TreeDepth = 1 << 16
+
+// unmarshalError pairs one of the sentinel unmarshalling errors above with
+// the underlying error that caused it, so that errors.Is matches both.
+type unmarshalError struct {
+	kind error
+	err  error
+}
+
+func (u *unmarshalError) Error() string {
+	return fmt.Sprintf("%v: %v", u.kind, u.err)
+}
+
+func (u *unmarshalError) Unwrap() error {
+	return u.err
+}
+
+func (u *unmarshalError) Is(target error) bool {
+	return target == u.kind
+}
+
+// isTruncation reports whether err was caused by running out of input.
+func isTruncation(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 // NodeTypeOf returns the NodeType of the provided binary-marshaled node.
+// Only the schema header at the start of the data is read, so the rest of the
+// node is neither parsed nor validated.
 // If the provided bytes are not a forest node or the type cannot be determined,
 // an error will be returned and the first return value must be ignored.
+// The error will match ErrTruncated or ErrUnknownNodeType with errors.Is.
 func NodeTypeOf(b []byte) (fields.NodeType, error) {
 	_, t, err := VersionAndNodeTypeOf(b)
 	return t, err
 }
 
+// SchemaVersionOf returns the schema version of the provided binary-marshaled
+// node. Only the version at the start of the data is read, so the rest of the
+// node is not validated. Data too short to contain a version produces an error
+// that matches ErrTruncated with errors.Is.
+func SchemaVersionOf(b []byte) (fields.Version, error) {
+	var version fields.Version
+	if err := version.UnmarshalBinary(b); err != nil {
+		return version, &unmarshalError{kind: ErrTruncated, err: fmt.Errorf("%d bytes is too short to contain a schema version: %w", len(b), err)}
+	}
+	return version, nil
+}
+
+// VersionAndNodeTypeOf returns the schema version and NodeType of the provided
+// binary-marshaled node. Errors match ErrTruncated or ErrUnknownNodeType with
+// errors.Is.
 func VersionAndNodeTypeOf(b []byte) (fields.Version, fields.NodeType, error) {
 	var schema SchemaInfo
 	_, err := serialize.ArborDeserialize(reflect.ValueOf(&schema), b)
-	return schema.Version, schema.Type, err
+	if err != nil {
+		// any version number is acceptable, so if the data wasn't
+		// truncated the failure must have been an invalid node type
+		if isTruncation(err) {
+			return schema.Version, schema.Type, &unmarshalError{kind: ErrTruncated, err: err}
+		}
+		return schema.Version, schema.Type, &unmarshalError{kind: ErrUnknownNodeType, err: err}
+	}
+	return schema.Version, schema.Type, nil
+}
+
+// NodeHeader holds the fields shared by every type of node, which are stored at
+// the beginning of its binary representation.
+type NodeHeader struct {
+	Version fields.Version
+	Type    fields.NodeType
+	Parent  fields.QualifiedHash
+	Depth   fields.TreeDepth
+	Created time.Time
+	Author  fields.QualifiedHash
+}
+
+// HeaderOf returns the NodeHeader of the provided binary-marshaled node. Only
+// the fields common to all nodes are read, so the provided bytes may omit the
+// type-specific remainder of the node (such as the content of a reply), and
+// that remainder is neither parsed nor validated. Errors match ErrTruncated,
+// ErrUnknownNodeType, or ErrBadDescriptor with errors.Is.
+func HeaderOf(b []byte) (NodeHeader, error) {
+	if _, _, err := VersionAndNodeTypeOf(b); err != nil {
+		return NodeHeader{}, err
+	}
+	var common CommonNode
+	if _, err := serialize.ArborDeserialize(reflect.ValueOf(&common), b); err != nil {
+		if isTruncation(err) {
+			return NodeHeader{}, &unmarshalError{kind: ErrTruncated, err: err}
+		}
+		return NodeHeader{}, &unmarshalError{kind: ErrBadDescriptor, err: err}
+	}
+	return NodeHeader{
+		Version: common.Version,
+		Type:    common.Type,
+		Parent:  common.Parent,
+		Depth:   common.Depth,
+		Created: common.CreatedAt(),
+		Author:  common.Author,
+	}, nil
 }
 
 // UnmarshalBinaryNode unmarshals a node of any type. If it does not return an
 // error, the concrete type of the first return parameter will be one of the
 // node structs declared in this package (e.g. Identity, Community, etc...)
+// Malformed data produces errors that match ErrTruncated, ErrUnknownNodeType,
+// or ErrBadDescriptor with errors.Is, and nodes with a schema version that is
+// not supported (see IsSupportedSchemaVersion) produce errors matching
+// ErrUnsupportedSchemaVersion.
+// Empty (or nil) data produces an error matching both ErrEmptyInput and
+// ErrTruncated.
 func UnmarshalBinaryNode(b []byte) (Node, error) {
+	if len(b) == 0 {
+		return nil, &unmarshalError{kind: ErrEmptyInput, err: ErrTruncated}
+	}
 	v, t, err := VersionAndNodeTypeOf(b)
 	if err != nil {
 		return nil, err
 	}
-	if v > fields.CurrentVersion {
-		return nil, fmt.Errorf("Unable to unmarshal node of version %d, only supports <= %d", v, fields.CurrentVersion)
+	if err := checkSchemaVersion(v); err != nil {
+		return nil, err
 	}
+	var node Node
 	switch t {
 	case fields.NodeTypeIdentity:
-		return UnmarshalIdentity(b)
+		node, err = UnmarshalIdentity(b)
 	case fields.NodeTypeCommunity:
-		return UnmarshalCommunity(b)
+		node, err = UnmarshalCommunity(b)
 	case fields.NodeTypeReply:
-		return UnmarshalReply(b)
+		node, err = UnmarshalReply(b)
 	default:
-		return nil, fmt.Errorf("Unable to unmarshal node of type %d, unknown type", t)
+		return nil, &unmarshalError{kind: ErrUnknownNodeType, err: fmt.Errorf("Unable to unmarshal node of type %d", t)}
+	}
+	if err != nil {
+		// the node type is known, so the data was either too short or
+		// contained a descriptor with an invalid type
+		if isTruncation(err) {
+			return nil, &unmarshalError{kind: ErrTruncated, err: err}
+		}
+		return nil, &unmarshalError{kind: ErrBadDescriptor, err: err}
 	}
+	return node, nil
 }
 
 type SchemaInfo struct {
@@ -172,7 +320,7 @@ func (n *CommonNode) ValidateShallow() error {
 func (n *CommonNode) ValidateDeep(store Store) error {
 	// ensure known parent
 	if !n.Parent.Equals(fields.NullHash()) {
-		if _, has, err := store.Get(&n.Parent); !has {
+		if has, err := store.Has(&n.Parent); !has {
 			return fmt.Errorf("Unknown parent %v", n.Parent)
 		} else if err != nil {
 			return err
@@ -180,7 +328,7 @@ func (n *CommonNode) ValidateDeep(store Store) error {
 	}
 	// ensure known author
 	if !n.Author.Equals(fields.NullHash()) {
-		if _, has, err := store.Get(&n.Author); !has {
+		if has, err := store.Has(&n.Author); !has {
 			return fmt.Errorf("Unknown Author %v", n.Author)
 		} else if err != nil {
 			return err
@@ -212,6 +360,12 @@ func (t *Trailer) GetSignature() *fields.QualifiedSignature {
 	return &t.Signature
 }
 
+// IsUnsigned returns whether the node is a draft that carries the null
+// signature instead of a real one.
+func (t *Trailer) IsUnsigned() bool {
+	return t.Signature.Descriptor.Type == fields.SignatureTypeNull
+}
+
 func (t *Trailer) Equals(t2 *Trailer) bool {
 	return t.Signature.Equals(&t2.Signature)
 }
@@ -227,6 +381,12 @@ type Identity struct {
 	Trailer    `arbor:"order=3,recurse=always"`
 }
 
+// AuthorID returns the ID of the identity itself, as identities sign
+// themselves.
+func (i *Identity) AuthorID() *fields.QualifiedHash {
+	return i.ID()
+}
+
 func newIdentity() *Identity {
 	i := new(Identity)
 	// define how to serialize this node type's fields
@@ -383,7 +543,7 @@ func (c *Community) ValidateShallow() error {
 
 // ValidateDeep checks all referenced nodes for existence within the store.
 func (c *Community) ValidateDeep(store Store) error {
-	if _, has, err := store.Get(&c.Author); !has {
+	if has, err := store.Has(&c.Author); !has {
 		return fmt.Errorf("Missing author node %v", c.Author)
 	} else if err != nil {
 		return err
@@ -445,6 +605,9 @@ func (r *Reply) Equals(other interface{}) bool {
 // ValidateShallow checks all fields for internal validity. It does not check
 // the existence or validity of nodes referenced from this node.
 func (r *Reply) ValidateShallow() error {
+	if r.IsUnsigned() {
+		return fmt.Errorf("Reply %v has no signature: %w", r.ID(), ErrUnsigned)
+	}
 	if err := r.CommonNode.ValidateShallow(); err != nil {
 		return err
 	}
@@ -460,6 +623,8 @@ func (r *Reply) ValidateShallow() error {
 		return fmt.Errorf("Reply conversation id at depth 1 must be null hash")
 	} else if r.Depth > fields.TreeDepth(1) && r.ConversationID.Equals(fields.NullHash()) {
 		return fmt.Errorf("Reply conversation id at depth > 1 must be null hash, got %v", r.ConversationID)
+	} else if r.Depth > MaxTreeDepth {
+		return fmt.Errorf("Reply depth %d exceeds limit of %d: %w", r.Depth, MaxTreeDepth, ErrTooDeep)
 	}
 	if r.Parent.Equals(fields.NullHash()) {
 		return fmt.Errorf("Reply parent must not be null hash")
@@ -480,11 +645,52 @@ func (r *Reply) ValidateDeep(store Store) error {
 		needed = append(needed, &r.ConversationID)
 	}
 	for _, neededNode := range needed {
-		if _, has, err := store.Get(neededNode); !has {
+		if has, err := store.Has(neededNode); !has {
 			return fmt.Errorf("Missing required node %v", neededNode)
 		} else if err != nil {
 			return err
 		}
 	}
+	parent, _, err := store.Get(&r.Parent)
+	if err != nil {
+		return fmt.Errorf("failed looking up parent %v: %w", &r.Parent, err)
+	} else if parent == nil {
+		return fmt.Errorf("Missing required node %v", &r.Parent)
+	}
+	if r.Depth != parent.TreeDepth()+1 {
+		return fmt.Errorf("reply depth %d is inconsistent with parent %v at depth %d", r.Depth, &r.Parent, parent.TreeDepth())
+	}
+	root, err := r.rootCommunity(store)
+	if err != nil {
+		return err
+	}
+	if !root.Equals(&r.CommunityID) {
+		return fmt.Errorf("reply claims community %v, but its ancestry is rooted at %v", &r.CommunityID, root)
+	}
 	return nil
 }
+
+// rootCommunity walks up the reply's ancestry in the store and returns the ID
+// of the community at its root.
+func (r *Reply) rootCommunity(store Store) (*fields.QualifiedHash, error) {
+	current := &r.Parent
+	// each step must move closer to the root, so a valid ancestry
+	// never needs more steps than the reply's depth
+	for steps := fields.TreeDepth(0); steps < r.Depth; steps++ {
+		ancestor, has, err := store.Get(current)
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up ancestor %v: %w", current, err)
+		} else if !has {
+			return nil, fmt.Errorf("Missing required ancestor %v", current)
+		}
+		switch ancestor := ancestor.(type) {
+		case *Community:
+			return ancestor.ID(), nil
+		case *Reply:
+			current = &ancestor.Parent
+		default:
+			return nil, fmt.Errorf("ancestor %v is a %T, not a community or reply", current, ancestor)
+		}
+	}
+	return nil, fmt.Errorf("no community found within %d ancestors of reply", r.Depth)
+}
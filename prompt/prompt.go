@@ -0,0 +1,139 @@
+/*
+Package prompt implements simple interactive prompts for command line tools.
+
+A Prompter reads answers from an input stream and writes its questions to an
+output stream. Answers that cannot be understood are retried, up to the
+Prompter's MaxAttempts, before an error is returned.
+*/
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxAttempts is the number of times a question is asked before giving
+// up, unless a Prompter is configured otherwise.
+const DefaultMaxAttempts = 3
+
+// ErrTooManyAttempts is returned when no valid answer was given within the
+// maximum number of attempts.
+var ErrTooManyAttempts = errors.New("too many invalid responses")
+
+// Prompter asks questions on Out and reads the answers from In.
+type Prompter struct {
+	In  *bufio.Reader
+	Out io.Writer
+	// MaxAttempts is the number of times a question will be asked before
+	// ErrTooManyAttempts is returned. Values less than one are treated as
+	// DefaultMaxAttempts.
+	MaxAttempts int
+}
+
+// New creates a Prompter that reads from in and writes to out.
+func New(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{
+		In:          bufio.NewReader(in),
+		Out:         out,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// Stdio creates a Prompter that uses the process's standard input and output.
+func Stdio() *Prompter {
+	return New(os.Stdin, os.Stdout)
+}
+
+func (p *Prompter) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return DefaultMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+// readLine reads a single line of input without its line ending. A final line
+// without a line ending is returned without error.
+func (p *Prompter) readLine() (string, error) {
+	line, err := p.In.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ask repeatedly displays the question and reads a line of input until parse
+// accepts it or the maximum number of attempts is exhausted.
+func (p *Prompter) ask(question string, parse func(string) error) error {
+	for i := 0; i < p.maxAttempts(); i++ {
+		fmt.Fprint(p.Out, question)
+		line, err := p.readLine()
+		if err != nil {
+			return fmt.Errorf("failed reading response: %w", err)
+		}
+		if err := parse(line); err != nil {
+			fmt.Fprintln(p.Out, err)
+			continue
+		}
+		return nil
+	}
+	return ErrTooManyAttempts
+}
+
+// PromptLine displays the prompt and returns the line of text entered in
+// response.
+func (p *Prompter) PromptLine(prompt string) (string, error) {
+	fmt.Fprint(p.Out, prompt)
+	line, err := p.readLine()
+	if err != nil {
+		return "", fmt.Errorf("failed reading response: %w", err)
+	}
+	return line, nil
+}
+
+// Choose displays the prompt followed by a numbered list of the choices and
+// returns the index of the choice that was selected.
+func (p *Prompter) Choose(prompt string, choices []string) (int, error) {
+	if len(choices) == 0 {
+		return 0, fmt.Errorf("no choices given")
+	}
+	var question strings.Builder
+	question.WriteString(prompt + "\n")
+	for i, choice := range choices {
+		fmt.Fprintf(&question, "%d) %s\n", i, choice)
+	}
+	fmt.Fprintf(&question, "Choice [0-%d]: ", len(choices)-1)
+	var chosen int
+	err := p.ask(question.String(), func(line string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || n < 0 || n >= len(choices) {
+			return fmt.Errorf("%q is not a number between 0 and %d", line, len(choices)-1)
+		}
+		chosen = n
+		return nil
+	})
+	return chosen, err
+}
+
+// Confirm displays the prompt and returns whether it was answered with yes or
+// no. Answers are case-insensitive and may be abbreviated to their first
+// letter.
+func (p *Prompter) Confirm(prompt string) (bool, error) {
+	var confirmed bool
+	err := p.ask(prompt+" [y/n]: ", func(line string) error {
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			confirmed = true
+		case "n", "no":
+			confirmed = false
+		default:
+			return fmt.Errorf("please answer yes or no")
+		}
+		return nil
+	})
+	return confirmed, err
+}
@@ -0,0 +1,90 @@
+package prompt_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~whereswaldon/forest-go/prompt"
+)
+
+func TestChoose(t *testing.T) {
+	choices := []string{"a", "b", "c"}
+	for _, row := range []struct {
+		name        string
+		input       string
+		maxAttempts int
+		expected    int
+		expectedErr error
+	}{
+		{"valid", "1\n", 0, 1, nil},
+		{"invalid then valid", "x\n7\n2\n", 0, 2, nil},
+		{"no trailing newline", "0", 0, 0, nil},
+		{"too many attempts", "x\n7\n2\n", 2, 0, prompt.ErrTooManyAttempts},
+	} {
+		t.Run(row.name, func(t *testing.T) {
+			p := prompt.New(strings.NewReader(row.input), ioutil.Discard)
+			if row.maxAttempts > 0 {
+				p.MaxAttempts = row.maxAttempts
+			}
+			chosen, err := p.Choose("Pick one", choices)
+			if row.expectedErr != nil {
+				if !errors.Is(err, row.expectedErr) {
+					t.Errorf("Expected error %v, got %v", row.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if chosen != row.expected {
+				t.Errorf("Expected choice %d, got %d", row.expected, chosen)
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for _, row := range []struct {
+		input    string
+		expected bool
+		fails    bool
+	}{
+		{"y\n", true, false},
+		{"NO\n", false, false},
+		{"maybe\nYes\n", true, false},
+		{"maybe\nperhaps\nsure\n", false, true},
+		{"", false, true},
+	} {
+		p := prompt.New(strings.NewReader(row.input), ioutil.Discard)
+		confirmed, err := p.Confirm("Continue?")
+		if row.fails {
+			if err == nil {
+				t.Errorf("Expected input %q to fail", row.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for input %q: %v", row.input, err)
+		} else if confirmed != row.expected {
+			t.Errorf("Expected %v for input %q, got %v", row.expected, row.input, confirmed)
+		}
+	}
+}
+
+func TestPromptLine(t *testing.T) {
+	p := prompt.New(strings.NewReader("first line\r\nsecond line\n"), ioutil.Discard)
+	for _, expected := range []string{"first line", "second line"} {
+		line, err := p.PromptLine("> ")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if line != expected {
+			t.Errorf("Expected %q, got %q", expected, line)
+		}
+	}
+	if _, err := p.PromptLine("> "); err == nil {
+		t.Errorf("Expected error reading past end of input")
+	}
+}
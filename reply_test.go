@@ -111,3 +111,87 @@ func TestReplyToReplySerializes(t *testing.T) {
 	_, _, _, r2, _ := getReplyToReplyOrFail(t)
 	ensureSerializes(t, r2)
 }
+
+func TestReplyValidatesWithKey(t *testing.T) {
+	identity, _, _, reply := testutil.MakeReplyOrSkip(t)
+	if correct, err := forest.ValidateSignatureWithKey(reply, &identity.PublicKey); err != nil || !correct {
+		t.Error("Signature validation with key failed on unmodified node", err)
+	}
+	reply.Content.Blob = fields.Blob([]byte("whatever"))
+	if correct, err := forest.ValidateSignatureWithKey(reply, &identity.PublicKey); err == nil && correct {
+		t.Error("Signature validation with key succeeded on modified node", err)
+	}
+}
+
+func TestNewReplies(t *testing.T) {
+	identity, privkey, community := testutil.MakeCommunityOrSkip(t)
+	contents := []string{"first", "same", "same", "last"}
+	replies, err := forest.As(identity, privkey).NewReplies(community, contents, []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create replies with valid parameters: %v", err)
+	}
+	if len(replies) != len(contents) {
+		t.Fatalf("Expected %d replies, got %d", len(contents), len(replies))
+	}
+	seen := make(map[string]bool)
+	for i, reply := range replies {
+		validateReply(t, identity, reply)
+		if string(reply.Content.Blob) != contents[i] {
+			t.Errorf("Expected reply %d to have content %q, got %q", i, contents[i], reply.Content.Blob)
+		}
+		if !reply.Parent.Equals(community.ID()) || !reply.CommunityID.Equals(community.ID()) {
+			t.Errorf("Reply %d is not a child of the community", i)
+		}
+		if i > 0 && reply.Created <= replies[i-1].Created {
+			t.Errorf("Expected reply %d to be created after reply %d", i, i-1)
+		}
+		if seen[reply.ID().String()] {
+			t.Errorf("Reply %d has a duplicate ID", i)
+		}
+		seen[reply.ID().String()] = true
+	}
+	if _, err := forest.As(identity, privkey).NewReplies(identity, contents, []byte{}); err == nil {
+		t.Errorf("Expected replying to an identity to fail")
+	}
+}
+
+func benchmarkReplies(b *testing.B, build func(*forest.Builder, *forest.Community, []string) error) {
+	signer := testkeys.Signer(b, testkeys.PrivKey1)
+	identity, err := forest.NewIdentity(signer, "benchmark", []byte{})
+	if err != nil {
+		b.Fatalf("Failed creating identity: %v", err)
+	}
+	builder := forest.As(identity, signer)
+	community, err := builder.NewCommunity("benchmark", []byte{})
+	if err != nil {
+		b.Fatalf("Failed creating community: %v", err)
+	}
+	contents := make([]string, 32)
+	for i := range contents {
+		contents[i] = testutil.RandomString(64)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := build(builder, community, contents); err != nil {
+			b.Fatalf("Failed creating replies: %v", err)
+		}
+	}
+}
+
+func BenchmarkNewReplyLoop(b *testing.B) {
+	benchmarkReplies(b, func(builder *forest.Builder, community *forest.Community, contents []string) error {
+		for _, content := range contents {
+			if _, err := builder.NewReply(community, content, []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkNewReplies(b *testing.B) {
+	benchmarkReplies(b, func(builder *forest.Builder, community *forest.Community, contents []string) error {
+		_, err := builder.NewReplies(community, contents, []byte{})
+		return err
+	})
+}
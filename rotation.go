@@ -0,0 +1,152 @@
+package forest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/serialize"
+	"git.sr.ht/~whereswaldon/forest-go/twig"
+)
+
+/*
+Key rotation
+
+An identity cannot change its key, because the key is part of the content that
+determines the identity's ID. Instead, a user whose key is compromised (or
+simply old) can create a new identity that supersedes the old one. The new
+identity is an ordinary, self-signed identity node whose twig metadata holds
+two extra values:
+
+    supersedes/1           the text form of the old identity's ID
+    supersedes-signature/1 base64 of a signature by the old key over the
+                           old identity's ID and the new identity's key
+
+The second value is what makes the rotation trustworthy: only the holder of
+the old key could have produced it, so the new key is endorsed by the old one.
+VerifyRotation checks both values.
+
+Rotation does not revoke anything. Nodes signed by the old identity remain
+valid, and an attacker holding a compromised old key can endorse keys of their
+own, so clients that see several rotations away from the same identity cannot
+tell which is legitimate and should treat them all with suspicion. Clients
+decide for themselves whether to display nodes from a rotated identity as
+belonging to the same user.
+*/
+
+const (
+	// SupersedesKeyName and SupersedesKeyVersion identify the twig metadata
+	// key holding the ID of the identity superseded by an identity.
+	SupersedesKeyName    = "supersedes"
+	SupersedesKeyVersion = 1
+
+	// SupersedesSignatureKeyName and SupersedesSignatureKeyVersion identify
+	// the twig metadata key holding the old key's endorsement of the new key.
+	SupersedesSignatureKeyName    = "supersedes-signature"
+	SupersedesSignatureKeyVersion = 1
+)
+
+// rotationPrefix distinguishes the data signed to endorse a key rotation
+// from the signed data of any node, so that neither can be replayed as the
+// other.
+const rotationPrefix = "arbor identity rotation\x00"
+
+// rotationSignedData returns the data that the old key signs in order to
+// endorse newKey as the successor to the identity with the given ID.
+func rotationSignedData(oldID *fields.QualifiedHash, newKey *fields.QualifiedKey) ([]byte, error) {
+	buf := bytes.NewBufferString(rotationPrefix)
+	for _, value := range []interface{}{oldID, newKey} {
+		b, err := serialize.ArborSerialize(reflect.ValueOf(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed serializing rotation data: %w", err)
+		}
+		_, _ = buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// RotateIdentity creates a new Identity, signed by newSigner, that supersedes
+// old. The Builder must be acting as old, as its Signer is used to endorse the
+// new key. The new identity has the same name as old. See VerifyRotation for
+// how to check the result.
+func (n *Builder) RotateIdentity(old *Identity, newSigner Signer) (*Identity, error) {
+	if !old.ID().Equals(n.User.ID()) {
+		return nil, fmt.Errorf("Builder acting as %s cannot rotate identity %s", n.User.ID(), old.ID())
+	}
+	newKeyBytes, err := newSigner.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting new public key: %w", err)
+	}
+	newKey, err := fields.NewQualifiedKey(newSigner.KeyType(), newKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed qualifying new public key: %w", err)
+	}
+	signedData, err := rotationSignedData(old.ID(), newKey)
+	if err != nil {
+		return nil, err
+	}
+	endorsement, err := n.Signer.Sign(signedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed endorsing new key: %w", err)
+	}
+	metadata := twig.New()
+	if _, err := metadata.Set(SupersedesKeyName, SupersedesKeyVersion, []byte(old.ID().String())); err != nil {
+		return nil, fmt.Errorf("failed setting %s metadata: %w", SupersedesKeyName, err)
+	}
+	if _, err := metadata.Set(SupersedesSignatureKeyName, SupersedesSignatureKeyVersion, []byte(base64.StdEncoding.EncodeToString(endorsement))); err != nil {
+		return nil, fmt.Errorf("failed setting %s metadata: %w", SupersedesSignatureKeyName, err)
+	}
+	metadataBytes, err := metadata.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling metadata: %w", err)
+	}
+	qmeta, err := fields.NewQualifiedContent(fields.ContentTypeTwig, metadataBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create qualified content of type %d from %s", fields.ContentTypeTwig, metadataBytes)
+	}
+	return newIdentityQualified(newSigner, &old.Name, qmeta, n.createdTime())
+}
+
+// VerifyRotation returns whether newIdentity is a valid successor to old. This
+// requires that newIdentity is validly self-signed, that its metadata claims to
+// supersede old, and that its key was endorsed by old's key. See the key
+// rotation documentation in this package for what a valid rotation does and
+// does not imply.
+func VerifyRotation(old, newIdentity *Identity) (bool, error) {
+	if valid, err := ValidateSignature(newIdentity, newIdentity); err != nil {
+		return false, fmt.Errorf("failed validating new identity signature: %w", err)
+	} else if !valid {
+		return false, nil
+	}
+	metadata, err := newIdentity.TwigMetadata()
+	if err != nil {
+		return false, fmt.Errorf("failed reading new identity metadata: %w", err)
+	}
+	supersedes, has := metadata.Get(SupersedesKeyName, SupersedesKeyVersion)
+	if !has {
+		return false, fmt.Errorf("new identity does not supersede any identity")
+	}
+	if string(supersedes) != old.ID().String() {
+		return false, fmt.Errorf("new identity supersedes %s, not %s", supersedes, old.ID())
+	}
+	encodedEndorsement, has := metadata.Get(SupersedesSignatureKeyName, SupersedesSignatureKeyVersion)
+	if !has {
+		return false, fmt.Errorf("new identity is missing the endorsement of the old key")
+	}
+	endorsement, err := base64.StdEncoding.DecodeString(string(encodedEndorsement))
+	if err != nil {
+		return false, fmt.Errorf("failed decoding endorsement: %w", err)
+	}
+	// the endorsement was made by the same key that signed the old identity
+	signature, err := fields.NewQualifiedSignature(old.Signature.Descriptor.Type, endorsement)
+	if err != nil {
+		return false, fmt.Errorf("failed qualifying endorsement: %w", err)
+	}
+	signedData, err := rotationSignedData(old.ID(), &newIdentity.PublicKey)
+	if err != nil {
+		return false, err
+	}
+	return signature.Verify(signedData, &old.PublicKey)
+}
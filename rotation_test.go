@@ -0,0 +1,47 @@
+package forest_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestRotateIdentity(t *testing.T) {
+	old, oldSigner := testutil.MakeIdentityOrSkip(t)
+	newSigner := testkeys.Signer(t, testkeys.PrivKey2)
+	rotated, err := forest.As(old, oldSigner).RotateIdentity(old, newSigner)
+	if err != nil {
+		t.Fatalf("Failed rotating identity: %v", err)
+	}
+	if valid, err := forest.VerifyRotation(old, rotated); err != nil || !valid {
+		t.Errorf("Expected rotation to verify: %v", err)
+	}
+	if !rotated.Name.Equals(&old.Name) {
+		t.Errorf("Expected rotated identity to keep name %s, got %s", old.Name.Blob, rotated.Name.Blob)
+	}
+
+	// a rotation does not verify against an unrelated identity
+	other, _ := testutil.MakeIdentityOrSkip(t)
+	if valid, err := forest.VerifyRotation(other, rotated); err == nil && valid {
+		t.Errorf("Expected rotation to fail verification against a different identity")
+	}
+}
+
+func TestRotateIdentityWithoutOldKey(t *testing.T) {
+	old, _ := testutil.MakeIdentityOrSkip(t)
+	newSigner := testkeys.Signer(t, testkeys.PrivKey2)
+	// claim to act as old without holding its key
+	forged, err := forest.As(old, newSigner).RotateIdentity(old, newSigner)
+	if err != nil {
+		t.Fatalf("Failed creating forged rotation: %v", err)
+	}
+	if valid, err := forest.VerifyRotation(old, forged); err == nil && valid {
+		t.Errorf("Expected rotation endorsed by the wrong key to fail verification")
+	}
+
+	if _, err := forest.As(old, newSigner).RotateIdentity(forged, newSigner); err == nil {
+		t.Errorf("Expected rotating an identity other than the Builder's user to fail")
+	}
+}
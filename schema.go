@@ -0,0 +1,64 @@
+package forest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// supportedSchemaVersions is the set of schema versions that this library
+// knows how to parse. It is never modified, so it may be read without locking.
+var supportedSchemaVersions = map[fields.Version]struct{}{
+	fields.CurrentVersion: {},
+}
+
+// IsSupportedSchemaVersion reports whether this library knows how to parse
+// nodes with the given schema version. Nodes with other versions are rejected
+// by UnmarshalBinaryNode and UnmarshalJSONNode unless the handler installed
+// with SetSchemaVersionHandler accepts them.
+func IsSupportedSchemaVersion(version fields.Version) bool {
+	_, supported := supportedSchemaVersions[version]
+	return supported
+}
+
+// ErrUnsupportedSchemaVersion indicates that a node was created with a
+// schema version for which IsSupportedSchemaVersion reports false.
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
+// SchemaVersionHandler decides whether a node with an unsupported schema
+// version should be parsed anyway. If it returns true, the node is parsed
+// as though it had the current schema version. This is a best-effort
+// approach: fields added by newer versions will be lost or misinterpreted,
+// and the resulting node may fail validation.
+type SchemaVersionHandler func(version fields.Version) bool
+
+var (
+	schemaVersionHandler      SchemaVersionHandler
+	schemaVersionHandlerMutex sync.RWMutex
+)
+
+// SetSchemaVersionHandler installs a handler that is consulted whenever a node
+// with an unsupported schema version is unmarshalled. Passing nil restores the
+// default behavior of rejecting all such nodes.
+func SetSchemaVersionHandler(handler SchemaVersionHandler) {
+	schemaVersionHandlerMutex.Lock()
+	defer schemaVersionHandlerMutex.Unlock()
+	schemaVersionHandler = handler
+}
+
+// checkSchemaVersion returns an error matching ErrUnsupportedSchemaVersion if
+// the version is neither supported nor accepted by the installed handler.
+func checkSchemaVersion(version fields.Version) error {
+	if IsSupportedSchemaVersion(version) {
+		return nil
+	}
+	schemaVersionHandlerMutex.RLock()
+	handler := schemaVersionHandler
+	schemaVersionHandlerMutex.RUnlock()
+	if handler != nil && handler(version) {
+		return nil
+	}
+	return &unmarshalError{kind: ErrUnsupportedSchemaVersion, err: fmt.Errorf("Unable to unmarshal node of version %d", version)}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
@@ -253,7 +254,7 @@ func ArborDeserialize(value reflect.Value, data []byte) (unused []byte, err erro
 		}
 		bytesConsumed := unmarshaler.BytesConsumed()
 		if bytesConsumed > len(data) {
-			return nil, fmt.Errorf("field %v.BytesConsumed() returned %d, but only %d bytes in slice", field.value, bytesConsumed, len(data))
+			return nil, fmt.Errorf("field %v.BytesConsumed() returned %d, but only %d bytes in slice: %w", field.value, bytesConsumed, len(data), io.ErrUnexpectedEOF)
 		}
 		data = data[bytesConsumed:]
 	}
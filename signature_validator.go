@@ -1,12 +1,8 @@
 package forest
 
 import (
-	"bytes"
 	"fmt"
 
-	"golang.org/x/crypto/openpgp"
-	"golang.org/x/crypto/openpgp/packet"
-
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 )
 
@@ -30,24 +26,25 @@ func ValidateSignature(v SignatureValidator, identity *Identity) (bool, error) {
 	} else if !sigIdHash.Equals(identity.ID()) {
 		return false, fmt.Errorf("This node was signed by a different identity")
 	}
-	// get the key used to sign this node
-	pubkeyBuf := bytes.NewBuffer([]byte(identity.PublicKey.Blob))
-	pubkeyEntity, err := openpgp.ReadEntity(packet.NewReader(pubkeyBuf))
-	if err != nil {
-		return false, err
-	}
+	return validateSignatureWithKey(v, &identity.PublicKey)
+}
 
-	signedContent, err := v.MarshalSignedData()
-	if err != nil {
-		return false, err
+// ValidateSignatureWithKey returns whether the signature on the node n is a valid
+// signature over its signed data for the provided key. Unlike ValidateSignature,
+// it does not check which Identity claims to have signed the node, so callers can
+// validate signatures before resolving the signing Identity.
+func ValidateSignatureWithKey(n Node, key *fields.QualifiedKey) (bool, error) {
+	v, ok := n.(SignatureValidator)
+	if !ok {
+		return false, fmt.Errorf("node of type %T does not carry a signature", n)
 	}
-	signedContentBuf := bytes.NewBuffer(signedContent)
+	return validateSignatureWithKey(v, key)
+}
 
-	signatureBuf := bytes.NewBuffer([]byte(v.GetSignature().Blob))
-	keyring := openpgp.EntityList([]*openpgp.Entity{pubkeyEntity})
-	_, err = openpgp.CheckDetachedSignature(keyring, signedContentBuf, signatureBuf, nil)
+func validateSignatureWithKey(v SignatureValidator, key *fields.QualifiedKey) (bool, error) {
+	signedContent, err := v.MarshalSignedData()
 	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return v.GetSignature().Verify(signedContent, key)
 }
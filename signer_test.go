@@ -1,13 +1,25 @@
 package forest_test
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
 	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // ensureGPGInstalled will cause the calling test to be skipped if GPG
@@ -41,7 +53,9 @@ func TestGPGSigner(t *testing.T) {
 	}
 }
 
-func getGPGSignerOrFail(t *testing.T) (forest.Signer, func()) {
+// importGPGKeyOrFail imports testkeys.PrivKey1 into a new temporary GNUPG
+// home and returns its path along with a function that removes it.
+func importGPGKeyOrFail(t *testing.T) (string, func()) {
 	gpgExec := ensureGPGInstalled(t)
 
 	// generate PGP key to use
@@ -65,6 +79,11 @@ func getGPGSignerOrFail(t *testing.T) (forest.Signer, func()) {
 		t.Errorf("Error generating key: %v", err)
 		cleanup()
 	}
+	return tempdir, cleanup
+}
+
+func getGPGSignerOrFail(t *testing.T) (forest.Signer, func()) {
+	tempdir, cleanup := importGPGKeyOrFail(t)
 	// build signer
 	signer, err := forest.NewGPGSigner(testUsername)
 	if err != nil {
@@ -97,3 +116,313 @@ func TestGPGSignerAsIdentity(t *testing.T) {
 		t.Error("Signature validation failed on unmodified node", err)
 	}
 }
+
+func TestGPGSignerByFingerprint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping expensive GPG test in short mode")
+	}
+	tempdir, cleanup := importGPGKeyOrFail(t)
+	defer cleanup()
+	// the fingerprint is checked before a Rewriter can be installed, so
+	// point gpg at the temporary home through the environment
+	oldHome, hadHome := os.LookupEnv("GNUPGHOME")
+	os.Setenv("GNUPGHOME", tempdir)
+	defer func() {
+		if hadHome {
+			os.Setenv("GNUPGHOME", oldHome)
+		} else {
+			os.Unsetenv("GNUPGHOME")
+		}
+	}()
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(testkeys.PrivKey1))
+	if err != nil || len(entities) < 1 {
+		t.Skipf("Failed reading test key: %v", err)
+	}
+	fpr := fmt.Sprintf("%X", entities[0].PrimaryKey.Fingerprint)
+
+	signer, err := forest.NewGPGSignerByFingerprint(strings.ToLower(fpr))
+	if err != nil {
+		t.Fatalf("Failed to construct signer with fingerprint of present key: %v", err)
+	}
+	if signer.GPGUserName != fpr+"!" {
+		t.Errorf("Expected signer to select exactly %s!, got %s", fpr, signer.GPGUserName)
+	}
+	if _, err := forest.NewGPGSignerByFingerprint(strings.Repeat("0", len(fpr))); err == nil {
+		t.Errorf("Expected error constructing signer with absent fingerprint")
+	}
+	if _, err := forest.NewGPGSignerByFingerprint("not a fingerprint"); err == nil {
+		t.Errorf("Expected error constructing signer with malformed fingerprint")
+	}
+	if _, err := forest.NewGPGSignerByFingerprint(""); err == nil {
+		t.Errorf("Expected error constructing signer with empty fingerprint")
+	}
+}
+
+// fakeGPGRunner simulates gpg by failing with each of the given stderr
+// messages in turn, and then writing a fixed signature.
+type fakeGPGRunner struct {
+	failures []string
+	calls    int
+}
+
+func (f *fakeGPGRunner) run(cmd *exec.Cmd) error {
+	f.calls++
+	if f.calls <= len(f.failures) {
+		fmt.Fprintln(cmd.Stderr, f.failures[f.calls-1])
+		return fmt.Errorf("exit status 2")
+	}
+	_, err := cmd.Stdout.Write([]byte("signature"))
+	return err
+}
+
+func TestGPGSignerRetry(t *testing.T) {
+	transient := "gpg: can't connect to the agent: IPC connect call failed"
+	permanent := "gpg: signing failed: Bad passphrase"
+	for _, row := range []struct {
+		name          string
+		attempts      int
+		failures      []string
+		expectedCalls int
+		shouldError   bool
+	}{
+		{"transient then success", 3, []string{transient, transient}, 3, false},
+		{"too many transient failures", 2, []string{transient, transient}, 2, true},
+		{"permanent failure", 3, []string{permanent}, 1, true},
+		{"no retry by default", 0, []string{transient}, 1, true},
+	} {
+		t.Run(row.name, func(t *testing.T) {
+			runner := &fakeGPGRunner{failures: row.failures}
+			signer, err := forest.NewGPGSignerWithOptions("nobody", forest.GPGSignerOptions{
+				GPGBinary: "fake-gpg",
+				Retry: forest.GPGRetryPolicy{
+					Attempts: row.attempts,
+					Backoff:  time.Millisecond,
+				},
+				Runner: runner.run,
+			})
+			if err != nil {
+				t.Skipf("Failed constructing GPG signer: %v", err)
+			}
+			signature, err := signer.Sign([]byte("data"))
+			if row.shouldError && err == nil {
+				t.Errorf("Expected signing to fail")
+			} else if !row.shouldError && (err != nil || string(signature) != "signature") {
+				t.Errorf("Expected signing to succeed, got %q, %v", signature, err)
+			}
+			if runner.calls != row.expectedCalls {
+				t.Errorf("Expected gpg to be run %d times, got %d", row.expectedCalls, runner.calls)
+			}
+		})
+	}
+}
+
+func TestGPGSignerBinary(t *testing.T) {
+	var commands [][]string
+	signer, err := forest.NewGPGSignerWithOptions("nobody", forest.GPGSignerOptions{
+		GPGBinary: "/opt/gpg/bin/gpg",
+		Runner: func(cmd *exec.Cmd) error {
+			commands = append(commands, cmd.Args)
+			_, err := cmd.Stdout.Write([]byte("output"))
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed constructing GPG signer: %v", err)
+	}
+	if out, err := signer.Sign([]byte("data")); err != nil || string(out) != "output" {
+		t.Errorf("Expected fake signature, got %q, %v", out, err)
+	}
+	if out, err := signer.PublicKey(); err != nil || string(out) != "output" {
+		t.Errorf("Expected fake public key, got %q, %v", out, err)
+	}
+	if len(commands) != 2 {
+		t.Fatalf("Expected 2 gpg invocations, got %d", len(commands))
+	}
+	for _, args := range commands {
+		if args[0] != "/opt/gpg/bin/gpg" {
+			t.Errorf("Expected configured gpg binary to be run, got %q", args[0])
+		}
+	}
+}
+
+// getSSHAgentSignerOrSkip starts an in-memory ssh-agent holding a fresh ed25519
+// key and returns a signer backed by it.
+func getSSHAgentSignerOrSkip(t *testing.T) *forest.SSHAgentSigner {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Skipf("Failed generating ed25519 key: %v", err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Skipf("Failed adding key to ssh-agent: %v", err)
+	}
+	client, server := net.Pipe()
+	go agent.ServeAgent(keyring, server)
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Skipf("Failed converting public key: %v", err)
+	}
+	signer, err := forest.NewSSHAgentSigner(client, sshPub)
+	if err != nil {
+		t.Fatalf("Failed creating ssh-agent signer: %v", err)
+	}
+	return signer
+}
+
+func TestSSHAgentSignerAsIdentity(t *testing.T) {
+	signer := getSSHAgentSignerOrSkip(t)
+	identity, err := forest.NewIdentity(signer, "test-username", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create Identity with valid parameters: %v", err)
+	}
+	if err := identity.ValidateShallow(); err != nil {
+		t.Errorf("Identity signed by ssh-agent failed shallow validation: %v", err)
+	}
+	if valid, err := forest.ValidateSignature(identity, identity); err != nil || !valid {
+		t.Errorf("Identity signed by ssh-agent failed signature validation: %v", err)
+	}
+	community, err := forest.As(identity, signer).NewCommunity("test-community", []byte{})
+	if err != nil {
+		t.Fatalf("Failed to create Community with valid parameters: %v", err)
+	}
+	if valid, err := forest.ValidateSignature(community, identity); err != nil || !valid {
+		t.Errorf("Community signed by ssh-agent failed signature validation: %v", err)
+	}
+	community.Name.Blob = []byte("tampered")
+	if valid, err := forest.ValidateSignature(community, identity); err == nil && valid {
+		t.Errorf("Tampered community signed by ssh-agent passed signature validation")
+	}
+}
+
+func TestSSHAgentSignerMissingKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Skipf("Failed generating ed25519 key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Skipf("Failed converting public key: %v", err)
+	}
+	client, server := net.Pipe()
+	go agent.ServeAgent(agent.NewKeyring(), server)
+	if _, err := forest.NewSSHAgentSigner(client, sshPub); err == nil {
+		t.Errorf("Expected error creating signer for key not held by ssh-agent")
+	}
+}
+
+func TestCachingSigner(t *testing.T) {
+	signer := forest.NewCachingSigner(testkeys.Signer(t, testkeys.PrivKey1))
+	first, err := signer.Sign([]byte(testData))
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+	second, err := signer.Sign([]byte(testData))
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("Expected identical data to produce the cached signature")
+	}
+	other, err := signer.Sign([]byte(testData + "other"))
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+	if bytes.Equal(first, other) {
+		t.Errorf("Expected different data to produce a different signature")
+	}
+}
+
+func BenchmarkNativeSigner(b *testing.B) {
+	benchmarkSigner(b, func(s forest.Signer) forest.Signer { return s })
+}
+
+func BenchmarkCachingSigner(b *testing.B) {
+	benchmarkSigner(b, func(s forest.Signer) forest.Signer { return forest.NewCachingSigner(s) })
+}
+
+func benchmarkSigner(b *testing.B, wrap func(forest.Signer) forest.Signer) {
+	signer := wrap(testkeys.Signer(b, testkeys.PrivKey1))
+	data := []byte(testData)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := signer.Sign(data); err != nil {
+			b.Fatalf("Failed to sign data: %v", err)
+		}
+	}
+}
+
+func TestSignerTypesPopulateIdentity(t *testing.T) {
+	native := testkeys.Signer(t, testkeys.PrivKey1)
+	if native.KeyType() != fields.KeyTypeOpenPGPRSA || native.SignatureType() != fields.SignatureTypeOpenPGPRSA {
+		t.Errorf("Expected native signer to report OpenPGP RSA types, got %d and %d", native.KeyType(), native.SignatureType())
+	}
+	for _, signer := range []forest.Signer{native, forest.NewCachingSigner(native), getSSHAgentSignerOrSkip(t)} {
+		identity, err := forest.NewIdentity(signer, "typed", []byte{})
+		if err != nil {
+			t.Fatalf("Failed to create Identity with %T: %v", signer, err)
+		}
+		if identity.PublicKey.Descriptor.Type != signer.KeyType() {
+			t.Errorf("Expected identity from %T to have key type %d, got %d", signer, signer.KeyType(), identity.PublicKey.Descriptor.Type)
+		}
+		if identity.Trailer.Signature.Descriptor.Type != signer.SignatureType() {
+			t.Errorf("Expected identity from %T to have signature type %d, got %d", signer, signer.SignatureType(), identity.Trailer.Signature.Descriptor.Type)
+		}
+	}
+}
+
+func TestNativeSignerFromEncryptedKey(t *testing.T) {
+	block, err := armor.Decode(strings.NewReader(testkeys.PrivKey1))
+	if err != nil {
+		t.Skipf("Failed dearmoring test key: %v", err)
+	}
+	binaryKey, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		t.Skipf("Failed reading test key: %v", err)
+	}
+	for name, key := range map[string][]byte{
+		"armored": []byte(testkeys.PrivKey1),
+		"binary":  binaryKey,
+	} {
+		signer, err := forest.NewNativeSignerFromEncryptedKey(bytes.NewReader(key), []byte(testkeys.TestKeyPassphrase))
+		if err != nil {
+			t.Errorf("%s: failed creating signer from encrypted key: %v", name, err)
+			continue
+		}
+		identity, err := forest.NewIdentity(signer, "encrypted", []byte{})
+		if err != nil {
+			t.Skipf("%s: failed creating identity: %v", name, err)
+		}
+		if valid, err := forest.ValidateSignature(identity, identity); err != nil || !valid {
+			t.Errorf("%s: expected identity signed with decrypted key to be valid: %v", name, err)
+		}
+
+		if _, err := forest.NewNativeSignerFromEncryptedKey(bytes.NewReader(key), []byte("wrong")); err == nil {
+			t.Errorf("%s: expected decrypting with the wrong passphrase to fail", name)
+		}
+	}
+	if _, err := forest.NewNativeSignerFromEncryptedKey(strings.NewReader("not a key"), nil); err == nil {
+		t.Errorf("Expected reading a malformed key to fail")
+	}
+}
+
+func TestGPGSignerByFingerprintRunner(t *testing.T) {
+	const fpr = "0123456789ABCDEF0123456789ABCDEF01234567"
+	var commands [][]string
+	signer, err := forest.NewGPGSignerByFingerprintWithOptions("89abcdef01234567", forest.GPGSignerOptions{
+		GPGBinary: "/opt/gpg/bin/gpg",
+		Runner: func(cmd *exec.Cmd) error {
+			commands = append(commands, cmd.Args)
+			_, err := fmt.Fprintf(cmd.Stdout, "sec:u:2048:1:89ABCDEF01234567:::::::::\nfpr:::::::::%s:\n", fpr)
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed constructing GPG signer: %v", err)
+	}
+	if signer.GPGUserName != fpr+"!" {
+		t.Errorf("Expected signer to use exactly %s, got %s", fpr, signer.GPGUserName)
+	}
+	if len(commands) != 1 || commands[0][0] != "/opt/gpg/bin/gpg" {
+		t.Errorf("Expected the configured gpg binary to list the secret keys, got %q", commands)
+	}
+}
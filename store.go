@@ -1,16 +1,26 @@
 package forest
 
 import (
+	"time"
+
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 )
 
 type Store interface {
 	CopyInto(Store) error
 	Get(*fields.QualifiedHash) (Node, bool, error)
+	// Has reports whether a node with the given ID is present in the store.
+	// Implementations should avoid fully loading the node when possible.
+	Has(*fields.QualifiedHash) (bool, error)
 	GetIdentity(*fields.QualifiedHash) (Node, bool, error)
 	GetCommunity(*fields.QualifiedHash) (Node, bool, error)
 	GetConversation(communityID, conversationID *fields.QualifiedHash) (Node, bool, error)
 	GetReply(communityID, conversationID, replyID *fields.QualifiedHash) (Node, bool, error)
+	// Children returns the IDs of the known children of the node with the
+	// given ID. Children must be listed in ascending order of creation time,
+	// with children created at the same time ordered by the text form of
+	// their IDs (see ChildBefore), so that every implementation lists the
+	// same children in the same order.
 	Children(*fields.QualifiedHash) ([]*fields.QualifiedHash, error)
 	Recent(nodeType fields.NodeType, quantity int) ([]Node, error)
 	// Add inserts a node into the store. It is *not* an error to insert a node which is already
@@ -19,3 +29,13 @@ type Store interface {
 
 	RemoveSubtree(*fields.QualifiedHash) error
 }
+
+// ChildBefore reports whether a node created at aCreated with ID aID precedes
+// a node created at bCreated with ID bID in the order that Store.Children
+// lists children in.
+func ChildBefore(aCreated time.Time, aID *fields.QualifiedHash, bCreated time.Time, bID *fields.QualifiedHash) bool {
+	if !aCreated.Equal(bCreated) {
+		return aCreated.Before(bCreated)
+	}
+	return aID.String() < bID.String()
+}
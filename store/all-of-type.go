@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// AllCommunities returns every community in s, sorted from oldest to newest.
+// If s implements RangeQuerier (as stores that index their nodes by type
+// do), its Between method is used. Otherwise every node in s is visited.
+func AllCommunities(s forest.Store) ([]*forest.Community, error) {
+	nodes, err := allOfType(s, fields.NodeTypeCommunity)
+	if err != nil {
+		return nil, err
+	}
+	communities := make([]*forest.Community, 0, len(nodes))
+	for _, node := range nodes {
+		if community, ok := node.(*forest.Community); ok {
+			communities = append(communities, community)
+		}
+	}
+	return communities, nil
+}
+
+// AllIdentities returns every identity in s, sorted from oldest to newest,
+// in the same way as AllCommunities.
+func AllIdentities(s forest.Store) ([]*forest.Identity, error) {
+	nodes, err := allOfType(s, fields.NodeTypeIdentity)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]*forest.Identity, 0, len(nodes))
+	for _, node := range nodes {
+		if identity, ok := node.(*forest.Identity); ok {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+// allOfType returns every node of the given type in s, sorted from oldest to
+// newest.
+func allOfType(s forest.Store, nodeType fields.NodeType) ([]forest.Node, error) {
+	if s == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+	if querier, ok := s.(RangeQuerier); ok {
+		nodes, err := querier.Between(nodeType, 0, math.MaxUint64)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing nodes of type %d: %w", nodeType, err)
+		}
+		return nodes, nil
+	}
+	nodes := []forest.Node{}
+	if err := ForEach(s, func(node forest.Node) error {
+		if t, ok := nodeTypeOf(node); ok && t == nodeType {
+			nodes = append(nodes, node)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return forest.ChildBefore(nodes[i].CreatedAt(), nodes[i].ID(), nodes[j].CreatedAt(), nodes[j].ID())
+	})
+	return nodes, nil
+}
@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestAllCommunitiesAndIdentities(t *testing.T) {
+	memory := store.NewMemoryStore()
+	nodes := testutil.MakeTree(t, memory, "one -> a; two -> b -> c; three")
+	other := testutil.MakeTree(t, memory, "four")
+	// a CacheStore does not implement RangeQuerier, so this exercises the
+	// fallback
+	cache, err := store.NewCacheStore(store.NewMemoryStore(), memory)
+	if err != nil {
+		t.Fatalf("Failed creating CacheStore: %v", err)
+	}
+	for name, s := range map[string]forest.Store{"indexed": memory, "fallback": cache} {
+		communities, err := store.AllCommunities(s)
+		if err != nil {
+			t.Fatalf("%s: failed listing communities: %v", name, err)
+		}
+		if len(communities) != 4 {
+			t.Errorf("%s: expected 4 communities, got %d", name, len(communities))
+		}
+		for _, label := range []string{"one", "two", "three"} {
+			found := false
+			for _, community := range communities {
+				found = found || community.ID().Equals(nodes[label].ID())
+			}
+			if !found {
+				t.Errorf("%s: expected communities to include %s", name, label)
+			}
+		}
+		for i := 1; i < len(communities); i++ {
+			if communities[i].CreatedAt().Before(communities[i-1].CreatedAt()) {
+				t.Errorf("%s: expected communities from oldest to newest", name)
+			}
+		}
+
+		identities, err := store.AllIdentities(s)
+		if err != nil {
+			t.Fatalf("%s: failed listing identities: %v", name, err)
+		}
+		if len(identities) != 2 {
+			t.Errorf("%s: expected 2 identities, got %d", name, len(identities))
+		}
+		for _, author := range []forest.Node{nodes["one"], other["four"]} {
+			found := false
+			for _, identity := range identities {
+				found = found || identity.ID().Equals(author.AuthorID())
+			}
+			if !found {
+				t.Errorf("%s: expected identities to include %s", name, author.AuthorID())
+			}
+		}
+	}
+}
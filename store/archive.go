@@ -22,10 +22,29 @@ type Archive struct {
 	store                                 forest.Store
 	requests                              chan func()
 	nextSubscriberKey                     Subscription
-	postAddSubscribers, preAddSubscribers map[Subscription]func(forest.Node)
+	postAddSubscribers, preAddSubscribers map[Subscription]subscriber
+}
+
+// subscriber is a handler function paired with a predicate that determines
+// which nodes the handler should be invoked on.
+type subscriber struct {
+	handler func(forest.Node)
+	filter  func(forest.Node) bool
+	// unsubscribe, if set, is invoked on the worker goroutine when the
+	// subscription is removed
+	unsubscribe func()
+}
+
+// acceptAll is a subscriber filter that matches every node.
+func acceptAll(forest.Node) bool {
+	return true
 }
 
 var _ ExtendedStore = &Archive{}
+var _ ReadWriteStore = &Archive{}
+var _ StatsReporter = &Archive{}
+var _ Reindexer = &Archive{}
+var _ BatchGetter = &Archive{}
 
 // NewArchive creates a thread-safe storage structure for
 // forest nodes by wrapping an existing store implementation
@@ -34,13 +53,14 @@ func NewArchive(store forest.Store) *Archive {
 		store:              store,
 		requests:           make(chan func()),
 		nextSubscriberKey:  firstSubscription,
-		postAddSubscribers: make(map[Subscription]func(forest.Node)),
-		preAddSubscribers:  make(map[Subscription]func(forest.Node)),
+		postAddSubscribers: make(map[Subscription]subscriber),
+		preAddSubscribers:  make(map[Subscription]subscriber),
 	}
 	go func() {
 		for function := range m.requests {
 			function()
 		}
+		m.unsubscribeAll()
 	}()
 	return m
 }
@@ -54,7 +74,87 @@ func NewArchive(store forest.Store) *Archive {
 // Add() or AddAs(), and should not block. If long-running code is needed in a
 // handler, launch a new goroutine.
 func (m *Archive) SubscribeToNewMessages(handler func(n forest.Node)) (subscriptionID Subscription) {
-	return m.subscribeInMap(m.postAddSubscribers, handler)
+	return m.subscribeInMap(m.postAddSubscribers, handler, acceptAll)
+}
+
+// SubscribeToNewMessagesOfType works like SubscribeToNewMessages, but the
+// handler will only be invoked on nodes of the given nodeType.
+func (m *Archive) SubscribeToNewMessagesOfType(nodeType fields.NodeType, handler func(n forest.Node)) (subscriptionID Subscription) {
+	return m.subscribeInMap(m.postAddSubscribers, handler, func(n forest.Node) bool {
+		switch n.(type) {
+		case *forest.Identity:
+			return nodeType == fields.NodeTypeIdentity
+		case *forest.Community:
+			return nodeType == fields.NodeTypeCommunity
+		case *forest.Reply:
+			return nodeType == fields.NodeTypeReply
+		}
+		return false
+	})
+}
+
+// SubscribeToNewChildrenOf works like SubscribeToNewMessages, but the
+// handler will only be invoked on nodes whose parent is the given node.
+func (m *Archive) SubscribeToNewChildrenOf(parent *fields.QualifiedHash, handler func(n forest.Node)) (subscriptionID Subscription) {
+	return m.subscribeInMap(m.postAddSubscribers, handler, func(n forest.Node) bool {
+		return n.ParentID().Equals(parent)
+	})
+}
+
+// DefaultChannelBufferSize is the capacity of the channels returned by
+// SubscribeChannel.
+const DefaultChannelBufferSize = 64
+
+// ChannelOptions configures the channel returned by
+// SubscribeChannelWithOptions.
+type ChannelOptions struct {
+	// BufferSize is the capacity of the channel. Values less than one are
+	// treated as DefaultChannelBufferSize.
+	BufferSize int
+	// Block makes delivery wait for room in a full channel instead of
+	// dropping the node. While it waits, every other operation on the
+	// archive waits too, so a consumer that uses the archive itself can
+	// deadlock if it falls behind.
+	Block bool
+}
+
+// SubscribeChannel works like SubscribeToNewMessages, but delivers new nodes
+// on a buffered channel of DefaultChannelBufferSize nodes instead of invoking
+// a handler, so that they can be processed on the consumer's own goroutine.
+// If the channel is full when a node is added, that node is dropped for this
+// subscriber (other subscribers and the store itself are unaffected), so a
+// slow consumer misses nodes rather than stalling the archive. Consumers that
+// must see every node should use SubscribeChannelWithOptions to block
+// instead. The channel is closed when the subscription is removed with
+// UnsubscribeToNewMessages or the archive is shut down with Destroy.
+func (m *Archive) SubscribeChannel() (<-chan forest.Node, Subscription) {
+	return m.SubscribeChannelWithOptions(ChannelOptions{})
+}
+
+// SubscribeChannelWithOptions works like SubscribeChannel, with the given
+// buffer size and policy for full channels.
+func (m *Archive) SubscribeChannelWithOptions(options ChannelOptions) (<-chan forest.Node, Subscription) {
+	size := options.BufferSize
+	if size < 1 {
+		size = DefaultChannelBufferSize
+	}
+	nodes := make(chan forest.Node, size)
+	deliver := func(n forest.Node) {
+		select {
+		case nodes <- n:
+		default:
+		}
+	}
+	if options.Block {
+		deliver = func(n forest.Node) {
+			nodes <- n
+		}
+	}
+	return nodes, m.subscribe(m.postAddSubscribers, subscriber{
+		handler:     deliver,
+		filter:      acceptAll,
+		unsubscribe: func() { close(nodes) },
+	})
 }
 
 // PresubscribeToNewMessages establishes the given function as a handler to be
@@ -67,10 +167,18 @@ func (m *Archive) SubscribeToNewMessages(handler func(n forest.Node)) (subscript
 // Add() or AddAs(), and should not block. If long-running code is needed in a
 // handler, launch a new goroutine.
 func (m *Archive) PresubscribeToNewMessages(handler func(n forest.Node)) (subscriptionID Subscription) {
-	return m.subscribeInMap(m.preAddSubscribers, handler)
+	return m.subscribeInMap(m.preAddSubscribers, handler, acceptAll)
 }
 
-func (m *Archive) subscribeInMap(targetMap map[Subscription]func(forest.Node), handler func(n forest.Node)) (subscriptionID Subscription) {
+func (m *Archive) subscribeInMap(targetMap map[Subscription]subscriber, handler func(n forest.Node), filter func(n forest.Node) bool) (subscriptionID Subscription) {
+	return m.subscribe(targetMap, subscriber{
+		handler: handler,
+		filter:  filter,
+	})
+}
+
+// subscribe adds sub to targetMap under a new subscription ID.
+func (m *Archive) subscribe(targetMap map[Subscription]subscriber, sub subscriber) (subscriptionID Subscription) {
 	done := make(chan struct{})
 	m.requests <- func() {
 		defer close(done)
@@ -81,7 +189,7 @@ func (m *Archive) subscribeInMap(targetMap map[Subscription]func(forest.Node), h
 		if m.nextSubscriberKey == neverAssigned {
 			m.nextSubscriberKey = firstSubscription
 		}
-		targetMap[subscriptionID] = handler
+		targetMap[subscriptionID] = sub
 	}
 	<-done
 	return
@@ -110,14 +218,30 @@ func (m *Archive) executeAsync(f func()) {
 	<-done
 }
 
-func (m *Archive) unsubscribeInMap(targetMap map[Subscription]func(forest.Node), subscriptionID Subscription) {
+func (m *Archive) unsubscribeInMap(targetMap map[Subscription]subscriber, subscriptionID Subscription) {
 	m.executeAsync(func() {
-		if _, subscribed := targetMap[subscriptionID]; subscribed {
+		if sub, subscribed := targetMap[subscriptionID]; subscribed {
 			delete(targetMap, subscriptionID)
+			if sub.unsubscribe != nil {
+				sub.unsubscribe()
+			}
 		}
 	})
 }
 
+// unsubscribeAll removes every subscription, as though each had been
+// unsubscribed. It must be invoked on the worker goroutine.
+func (m *Archive) unsubscribeAll() {
+	for _, targetMap := range []map[Subscription]subscriber{m.preAddSubscribers, m.postAddSubscribers} {
+		for subscriptionID, sub := range targetMap {
+			delete(targetMap, subscriptionID)
+			if sub.unsubscribe != nil {
+				sub.unsubscribe()
+			}
+		}
+	}
+}
+
 func (m *Archive) CopyInto(s forest.Store) (err error) {
 	m.executeAsync(func() {
 		err = m.store.CopyInto(s)
@@ -125,6 +249,15 @@ func (m *Archive) CopyInto(s forest.Store) (err error) {
 	return
 }
 
+// CopyMissingInto copies the nodes in the archive that are not present in s
+// into s.
+func (m *Archive) CopyMissingInto(s forest.Store) (err error) {
+	m.executeAsync(func() {
+		err = copyMissingInto(m.store, s)
+	})
+	return
+}
+
 func (m *Archive) Get(id *fields.QualifiedHash) (node forest.Node, present bool, err error) {
 	m.executeAsync(func() {
 		node, present, err = m.store.Get(id)
@@ -132,6 +265,30 @@ func (m *Archive) Get(id *fields.QualifiedHash) (node forest.Node, present bool,
 	return
 }
 
+// GetMany looks up the nodes with the given IDs in the wrapped store as a
+// single request.
+func (m *Archive) GetMany(ids []*fields.QualifiedHash) (nodes map[string]forest.Node, err error) {
+	m.executeAsync(func() {
+		nodes, err = GetMany(m.store, ids)
+	})
+	return
+}
+
+func (m *Archive) Has(id *fields.QualifiedHash) (present bool, err error) {
+	m.executeAsync(func() {
+		present, err = m.store.Has(id)
+	})
+	return
+}
+
+// Stats describes the contents of the wrapped store.
+func (m *Archive) Stats() (stats StoreStats, err error) {
+	m.executeAsync(func() {
+		stats, err = Stats(m.store)
+	})
+	return
+}
+
 func (m *Archive) GetIdentity(id *fields.QualifiedHash) (node forest.Node, present bool, err error) {
 	m.executeAsync(func() {
 		node, present, err = m.store.GetIdentity(id)
@@ -189,30 +346,57 @@ func (m *Archive) Add(node forest.Node) (err error) {
 //
 // Subscribers will only be notified if the node is not already present in the archive.
 func (m *Archive) AddAs(node forest.Node, addedByID Subscription) (err error) {
-	if _, has, _ := m.Get(node.ID()); has {
-		return
-	}
 	m.executeAsync(func() {
-		m.notifySubscribed(m.preAddSubscribers, node, addedByID)
-		if err = m.store.Add(node); err == nil {
-			m.notifySubscribed(m.postAddSubscribers, node, addedByID)
+		if has, _ := m.store.Has(node.ID()); has {
+			return
 		}
+		err = m.addAs(node, addedByID)
 	})
 	return
 }
 
-// notifySubscribed runs all of the subscription handlers with
-// the provided node as input to each handler.
-func (m *Archive) notifySubscribed(targetMap map[Subscription]func(forest.Node), node forest.Node, ignore Subscription) {
-	for subscriptionID, handler := range targetMap {
-		if subscriptionID != ignore {
-			handler(node)
+// GetOrAdd atomically returns the node already stored with the same ID as node,
+// or adds node to the archive if there is no such node. The added return value
+// is true only if node was added. Subscribers are notified (as with Add) only
+// if node was added.
+func (m *Archive) GetOrAdd(node forest.Node) (stored forest.Node, added bool, err error) {
+	m.executeAsync(func() {
+		var has bool
+		if stored, has, err = m.store.Get(node.ID()); err != nil || has {
+			return
+		}
+		if err = m.addAs(node, neverAssigned); err == nil {
+			stored, added = node, true
+		}
+	})
+	return
+}
+
+// addAs inserts the node into the underlying store and notifies subscribers.
+// It must only be invoked on the archive's worker goroutine.
+func (m *Archive) addAs(node forest.Node, addedByID Subscription) error {
+	m.notifySubscribed(m.preAddSubscribers, node, addedByID)
+	if err := m.store.Add(node); err != nil {
+		return err
+	}
+	m.notifySubscribed(m.postAddSubscribers, node, addedByID)
+	return nil
+}
+
+// notifySubscribed runs all of the subscription handlers whose filters
+// match the provided node with the node as input to each handler.
+func (m *Archive) notifySubscribed(targetMap map[Subscription]subscriber, node forest.Node, ignore Subscription) {
+	for subscriptionID, sub := range targetMap {
+		if subscriptionID != ignore && sub.filter(node) {
+			sub.handler(node)
 		}
 	}
 }
 
 // Shut down the worker gorountine that powers this store. Subsequent
-// calls to methods on this MessageStore have undefined behavior
+// calls to methods on this MessageStore have undefined behavior. Every
+// subscription is removed, so the channels returned by SubscribeChannel are
+// closed once the worker has finished.
 func (m *Archive) Destroy() {
 	close(m.requests)
 }
@@ -256,6 +440,26 @@ func (a *Archive) DescendantsOf(id *fields.QualifiedHash) ([]*fields.QualifiedHa
 	return descendants, nil
 }
 
+// CountDescendants returns the number of known descendants of the node with the
+// given `id`, not including the node itself. It does not allocate a list of the
+// descendants, so it is cheaper than DescendantsOf when only the count is needed.
+func (a *Archive) CountDescendants(id *fields.QualifiedHash) (count int, err error) {
+	a.executeAsync(func() {
+		err = Walk(a.store, id, func(*fields.QualifiedHash) error {
+			count++
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed traversing descendants: %w", err)
+	}
+	// Walk visits the node itself in addition to its descendants
+	if count > 0 {
+		count--
+	}
+	return count, nil
+}
+
 // LeavesOf returns the leaf nodes of the tree rooted at `id`. The order of the returned
 // leaves is undefined.
 func (a *Archive) LeavesOf(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
@@ -284,3 +488,56 @@ func (a *Archive) RemoveSubtree(id *fields.QualifiedHash) error {
 	})
 	return err
 }
+
+// Thread returns the full thread containing the node with the given `id`. The
+// returned slice begins with the root of the node's ancestry and proceeds down
+// to the node itself, followed by all of the node's descendants in depth-first
+// order. If any ancestor of the node is missing from the store, Thread returns
+// an error rather than a partial thread.
+func (a *Archive) Thread(id *fields.QualifiedHash) ([]forest.Node, error) {
+	target, present, err := a.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up %s: %w", id, err)
+	} else if !present {
+		return nil, fmt.Errorf("node %s is not in the store", id)
+	}
+	ancestors := make([]forest.Node, 0, target.TreeDepth())
+	next := target.ParentID()
+	for !next.Equals(fields.NullHash()) {
+		parent, present, err := a.Get(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up ancestor %s: %w", next, err)
+		} else if !present {
+			return nil, fmt.Errorf("ancestor %s of %s is not in the store", next, id)
+		}
+		ancestors = append(ancestors, parent)
+		next = parent.ParentID()
+	}
+	thread := make([]forest.Node, 0, len(ancestors)+1)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		thread = append(thread, ancestors[i])
+	}
+	return a.appendDescendants(thread, target)
+}
+
+// appendDescendants appends the given node and all of its descendants to
+// the provided slice in depth-first order.
+func (a *Archive) appendDescendants(nodes []forest.Node, node forest.Node) ([]forest.Node, error) {
+	nodes = append(nodes, node)
+	children, err := a.Children(node.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up children of %s: %w", node.ID(), err)
+	}
+	for _, childID := range children {
+		child, present, err := a.Get(childID)
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up child %s: %w", childID, err)
+		} else if !present {
+			return nil, fmt.Errorf("child %s of %s is not in the store", childID, node.ID())
+		}
+		if nodes, err = a.appendDescendants(nodes, child); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,207 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestArchiveThread(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	builder := forest.As(identity, signer)
+	child, err := builder.NewReply(reply, "child", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	grandchild, err := builder.NewReply(child, "grandchild", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	for _, node := range []forest.Node{identity, community, reply, child, grandchild} {
+		if err := archive.Add(node); err != nil {
+			t.Skipf("Failed adding %v to archive: %v", node.ID(), err)
+		}
+	}
+
+	thread, err := archive.Thread(child.ID())
+	if err != nil {
+		t.Fatalf("Thread failed on valid input: %v", err)
+	}
+	expected := []forest.Node{community, reply, child, grandchild}
+	if len(thread) != len(expected) {
+		t.Fatalf("Expected thread of length %d, got %d", len(expected), len(thread))
+	}
+	for i := range expected {
+		if !thread[i].Equals(expected[i]) {
+			t.Errorf("Expected %v at thread index %d, got %v", expected[i].ID(), i, thread[i].ID())
+		}
+	}
+}
+
+func TestArchiveThreadMissingAncestor(t *testing.T) {
+	identity, signer, _, reply := testutil.MakeReplyOrSkip(t)
+	child, err := forest.As(identity, signer).NewReply(reply, "child", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	for _, node := range []forest.Node{identity, reply, child} {
+		if err := archive.Add(node); err != nil {
+			t.Skipf("Failed adding %v to archive: %v", node.ID(), err)
+		}
+	}
+	if _, err := archive.Thread(child.ID()); err == nil {
+		t.Errorf("Thread should error when an ancestor is missing from the store")
+	}
+}
+
+func TestArchiveCountDescendants(t *testing.T) {
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	nodes := testutil.MakeTree(t, archive, "root -> a; root -> b; a -> c; a -> d; d -> e; b -> f")
+	for label, expected := range map[string]int{
+		"root": 6,
+		"a":    3,
+		"b":    1,
+		"d":    1,
+		"e":    0,
+	} {
+		if count, err := archive.CountDescendants(nodes[label].ID()); err != nil {
+			t.Errorf("Failed counting descendants of %s: %v", label, err)
+		} else if count != expected {
+			t.Errorf("Expected %s to have %d descendants, got %d", label, expected, count)
+		}
+	}
+}
+
+func TestArchiveFilteredSubscriptions(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+
+	var ofType, childrenOf, suppressed []forest.Node
+	archive.SubscribeToNewMessagesOfType(fields.NodeTypeCommunity, func(n forest.Node) {
+		ofType = append(ofType, n)
+	})
+	archive.SubscribeToNewChildrenOf(community.ID(), func(n forest.Node) {
+		childrenOf = append(childrenOf, n)
+	})
+	suppressedID := archive.SubscribeToNewMessagesOfType(fields.NodeTypeReply, func(n forest.Node) {
+		suppressed = append(suppressed, n)
+	})
+
+	if err := archive.Add(identity); err != nil {
+		t.Skipf("Failed adding %v to archive: %v", identity.ID(), err)
+	}
+	if err := archive.Add(community); err != nil {
+		t.Skipf("Failed adding %v to archive: %v", community.ID(), err)
+	}
+	if err := archive.AddAs(reply, suppressedID); err != nil {
+		t.Skipf("Failed adding %v to archive: %v", reply.ID(), err)
+	}
+
+	if len(ofType) != 1 || !ofType[0].Equals(community) {
+		t.Errorf("Expected type subscription to see only the community, got %v", ofType)
+	}
+	if len(childrenOf) != 1 || !childrenOf[0].Equals(reply) {
+		t.Errorf("Expected children subscription to see only the reply, got %v", childrenOf)
+	}
+	if len(suppressed) != 0 {
+		t.Errorf("Expected AddAs to suppress notification to filtered subscription, got %v", suppressed)
+	}
+}
+
+func TestArchiveGetOrAdd(t *testing.T) {
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	notifications := 0
+	archive.SubscribeToNewMessages(func(forest.Node) {
+		// handlers run on the archive's goroutine, so this is not racy
+		notifications++
+	})
+	testConcurrentGetOrAdd(t, archive, community)
+	if notifications != 1 {
+		t.Errorf("Expected subscribers to be notified once, got %d notifications", notifications)
+	}
+}
+
+func TestArchiveSubscribeChannel(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	child, err := forest.As(identity, signer).NewReply(reply, "child", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	nodes := []forest.Node{identity, community, reply, child}
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+
+	// a consumer that never reads misses the nodes that don't fit, but
+	// doesn't stall the archive
+	dropping, droppingID := archive.SubscribeChannelWithOptions(store.ChannelOptions{BufferSize: 2})
+	// a slow consumer that must see every node
+	blocking, blockingID := archive.SubscribeChannelWithOptions(store.ChannelOptions{BufferSize: 1, Block: true})
+	received := make(chan []forest.Node)
+	go func() {
+		var seen []forest.Node
+		for node := range blocking {
+			time.Sleep(10 * time.Millisecond)
+			seen = append(seen, node)
+		}
+		received <- seen
+	}()
+
+	for _, node := range nodes {
+		if err := archive.Add(node); err != nil {
+			t.Fatalf("Failed adding %v to archive: %v", node.ID(), err)
+		}
+	}
+	archive.UnsubscribeToNewMessages(blockingID)
+	if seen := <-received; len(seen) != len(nodes) {
+		t.Errorf("Expected blocking subscriber to see all %d nodes, got %d", len(nodes), len(seen))
+	}
+
+	archive.UnsubscribeToNewMessages(droppingID)
+	var kept []forest.Node
+	for node := range dropping {
+		kept = append(kept, node)
+	}
+	if len(kept) != 2 || !kept[0].Equals(identity) || !kept[1].Equals(community) {
+		t.Errorf("Expected dropping subscriber to keep only the first 2 nodes, got %v", kept)
+	}
+}
+
+func TestArchiveDestroyClosesChannels(t *testing.T) {
+	identity, _, community := testutil.MakeCommunityOrSkip(t)
+	archive := store.NewArchive(store.NewMemoryStore())
+	nodes, _ := archive.SubscribeChannel()
+	received := make(chan int)
+	go func() {
+		count := 0
+		for range nodes {
+			count++
+		}
+		received <- count
+	}()
+	for _, node := range []forest.Node{identity, community} {
+		if err := archive.Add(node); err != nil {
+			t.Fatalf("Failed adding %v to archive: %v", node.ID(), err)
+		}
+	}
+	archive.Destroy()
+	select {
+	case count := <-received:
+		if count != 2 {
+			t.Errorf("Expected 2 nodes before the channel closed, got %d", count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Destroy to close the subscription channel")
+	}
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"fmt"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+// unknownAuthorIDLength is the number of characters of an author's ID that are
+// used in the placeholder name for an author that cannot be found.
+const unknownAuthorIDLength = 8
+
+// AuthorName returns the name of the identity that signed node, looking it up
+// in s. Identities sign themselves, so the name of an identity is its own. If
+// the identity is not in s, AuthorName returns a placeholder derived from the
+// identity's ID (which is the same for every node by that identity) and no
+// error.
+func AuthorName(s forest.Store, node forest.Node) (string, error) {
+	if identity, isIdentity := node.(*forest.Identity); isIdentity {
+		return string(identity.Name.Blob), nil
+	}
+	authorID := node.AuthorID()
+	author, has, err := s.GetIdentity(authorID)
+	if err != nil {
+		return "", fmt.Errorf("failed looking up author %s: %w", authorID, err)
+	} else if !has {
+		id := authorID.String()
+		if len(id) > unknownAuthorIDLength {
+			id = id[len(id)-unknownAuthorIDLength:]
+		}
+		return "unknown-" + id, nil
+	}
+	identity, isIdentity := author.(*forest.Identity)
+	if !isIdentity {
+		return "", fmt.Errorf("author %s of %s is not an identity", authorID, node.ID())
+	}
+	return string(identity.Name.Blob), nil
+}
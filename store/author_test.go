@@ -0,0 +1,44 @@
+package store_test
+
+import (
+	"strings"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestAuthorName(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	expected := string(identity.Name.Blob)
+
+	// the identity is missing, so a placeholder is expected
+	placeholder, err := store.AuthorName(s, reply)
+	if err != nil {
+		t.Fatalf("Expected no error for missing author, got %v", err)
+	}
+	if placeholder == expected || !strings.HasPrefix(placeholder, "unknown-") {
+		t.Errorf("Expected a placeholder name for a missing author, got %q", placeholder)
+	}
+	if other, err := store.AuthorName(s, community); err != nil || other != placeholder {
+		t.Errorf("Expected the same placeholder for every node by the same author, got %q and %q (err: %v)", placeholder, other, err)
+	}
+
+	if err := s.Add(identity); err != nil {
+		t.Skipf("Failed adding %v to store: %v", identity.ID(), err)
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if name, err := store.AuthorName(s, node); err != nil {
+			t.Errorf("Failed resolving author of %v: %v", node.ID(), err)
+		} else if name != expected {
+			t.Errorf("Expected author of %v to be %q, got %q", node.ID(), expected, name)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// RangeQuerier is implemented by stores that can efficiently find the nodes
+// created within a range of time.
+type RangeQuerier interface {
+	// Between returns the nodes of the given type created within the
+	// inclusive range [start, end], sorted from oldest to newest.
+	Between(nodeType fields.NodeType, start, end fields.Timestamp) ([]forest.Node, error)
+}
+
+// Between returns the nodes of the given type in s that were created within
+// the inclusive range [start, end], sorted from oldest to newest. If s
+// implements RangeQuerier, its Between method is used. Otherwise every node in
+// s is copied into memory and filtered.
+func Between(s forest.Store, nodeType fields.NodeType, start, end fields.Timestamp) ([]forest.Node, error) {
+	if querier, ok := s.(RangeQuerier); ok {
+		return querier.Between(nodeType, start, end)
+	}
+	snapshot := NewMemoryStore()
+	if err := s.CopyInto(snapshot); err != nil {
+		return nil, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+	return snapshot.Between(nodeType, start, end)
+}
+
+// Between returns the nodes of the given type created within the inclusive
+// range [start, end], sorted from oldest to newest.
+func (m *MemoryStore) Between(nodeType fields.NodeType, start, end fields.Timestamp) ([]forest.Node, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	nodes := m.recent[nodeType]
+	first := sort.Search(len(nodes), func(i int) bool {
+		return fields.TimestampFrom(nodes[i].CreatedAt()) >= start
+	})
+	last := sort.Search(len(nodes), func(i int) bool {
+		return fields.TimestampFrom(nodes[i].CreatedAt()) > end
+	})
+	if first >= last {
+		return []forest.Node{}, nil
+	}
+	inRange := make([]forest.Node, last-first)
+	copy(inRange, nodes[first:last])
+	return inRange, nil
+}
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+)
+
+func TestBetween(t *testing.T) {
+	// replies are created at one second intervals, two per second
+	replies := makeTimedReplies(t, 10, 2)
+	mem := store.NewMemoryStore()
+	cache, err := store.NewCacheStore(store.NewMemoryStore(), store.NewMemoryStore())
+	if err != nil {
+		t.Skipf("Failed constructing CacheStore: %v", err)
+	}
+	for _, s := range []forest.Store{mem, cache} {
+		for _, node := range replies {
+			if err := s.Add(node); err != nil {
+				t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+			}
+		}
+	}
+	start := fields.TimestampFrom(replies[2].CreatedAt())
+	end := fields.TimestampFrom(replies[7].CreatedAt())
+	expected := replies[2:8]
+
+	for _, row := range []struct {
+		name  string
+		store forest.Store
+	}{
+		{"MemoryStore", mem},
+		{"CacheStore", cache},
+	} {
+		nodes, err := store.Between(row.store, fields.NodeTypeReply, start, end)
+		if err != nil {
+			t.Errorf("Failed querying %s: %v", row.name, err)
+			continue
+		}
+		if len(nodes) != len(expected) {
+			t.Errorf("Expected %s to return %d nodes, got %d", row.name, len(expected), len(nodes))
+		}
+		for _, node := range expected {
+			if !containsNode(nodes, node) {
+				t.Errorf("Expected %s to return %v created at %v", row.name, node.ID(), node.CreatedAt())
+			}
+		}
+		for i := 1; i < len(nodes); i++ {
+			if nodes[i].CreatedAt().Before(nodes[i-1].CreatedAt()) {
+				t.Errorf("Expected %s to return nodes in ascending order, got %v before %v", row.name, nodes[i-1].CreatedAt(), nodes[i].CreatedAt())
+			}
+		}
+		if nodes, err := store.Between(row.store, fields.NodeTypeCommunity, start, end); err != nil {
+			t.Errorf("Failed querying %s for communities: %v", row.name, err)
+		} else if len(nodes) != 0 {
+			t.Errorf("Expected %s to return no communities, got %d", row.name, len(nodes))
+		}
+		if nodes, err := store.Between(row.store, fields.NodeTypeReply, end, start); err != nil {
+			t.Errorf("Failed querying %s with an empty range: %v", row.name, err)
+		} else if len(nodes) != 0 {
+			t.Errorf("Expected %s to return no nodes for an empty range, got %d", row.name, len(nodes))
+		}
+	}
+}
+
+func containsNode(nodes []forest.Node, node forest.Node) bool {
+	for _, n := range nodes {
+		if n.Equals(node) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"log"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
@@ -14,9 +15,37 @@ import (
 // be directly modified.
 type CacheStore struct {
 	Cache, Back forest.Store
+	CacheStoreOptions
 }
 
 var _ forest.Store = &CacheStore{}
+var _ StatsReporter = &CacheStore{}
+var _ Reindexer = &CacheStore{}
+
+// CacheWritePolicy determines how a CacheStore handles failures to write a node
+// into its Cache after the node is already in its Back store.
+type CacheWritePolicy uint8
+
+const (
+	// CacheWriteFatal causes the failure to be returned to the caller. The
+	// node will remain in the Back store regardless.
+	CacheWriteFatal CacheWritePolicy = iota
+	// CacheWriteBestEffort reports the failure to OnCacheWriteError and
+	// otherwise ignores it. Since the Back store holds every node, the only
+	// consequence is that later lookups of the node may be slower.
+	CacheWriteBestEffort
+)
+
+// CacheStoreOptions configures the behavior of a CacheStore.
+type CacheStoreOptions struct {
+	// WritePolicy determines how failures to write nodes into the Cache
+	// (when adding them or when propagating them up from the Back store)
+	// are handled. The default is CacheWriteFatal.
+	WritePolicy CacheWritePolicy
+	// OnCacheWriteError is invoked with each failure ignored because of
+	// CacheWriteBestEffort. If it is nil, failures are logged.
+	OnCacheWriteError func(node forest.Node, err error)
+}
 
 // NewCacheStore creates a single logical store from the given two stores.
 // All items from `cache` are automatically copied into `base` during
@@ -26,10 +55,35 @@ var _ forest.Store = &CacheStore{}
 // fast in-memory implementations as the `cache` layer and disk or
 // network-based implementations as the `base` layer.
 func NewCacheStore(cache, back forest.Store) (*CacheStore, error) {
+	return NewCacheStoreWithOptions(cache, back, CacheStoreOptions{})
+}
+
+// NewCacheStoreWithOptions works like NewCacheStore, but configures the
+// resulting CacheStore with the given options.
+func NewCacheStoreWithOptions(cache, back forest.Store, options CacheStoreOptions) (*CacheStore, error) {
 	if err := cache.CopyInto(back); err != nil {
 		return nil, err
 	}
-	return &CacheStore{cache, back}, nil
+	return &CacheStore{
+		Cache:             cache,
+		Back:              back,
+		CacheStoreOptions: options,
+	}, nil
+}
+
+// addToCache adds the node to the Cache, handling failure according to the
+// WritePolicy.
+func (m *CacheStore) addToCache(node forest.Node) error {
+	err := m.Cache.Add(node)
+	if err == nil || m.WritePolicy != CacheWriteBestEffort {
+		return err
+	}
+	if m.OnCacheWriteError != nil {
+		m.OnCacheWriteError(node, err)
+	} else {
+		log.Printf("failed adding %s to cache: %v", node.ID(), err)
+	}
+	return nil
 }
 
 // Get returns the requested node if it is present in either the Cache or the Back Store.
@@ -39,16 +93,45 @@ func (m *CacheStore) Get(id *fields.QualifiedHash) (forest.Node, bool, error) {
 	return m.getUsingFuncs(id, m.Cache.Get, m.Back.Get)
 }
 
+// Has reports whether the node with the given ID is present in either the
+// Cache or the Back Store. Unlike Get, it does not add the node to the cache.
+func (m *CacheStore) Has(id *fields.QualifiedHash) (bool, error) {
+	if inCache, err := m.Cache.Has(id); err != nil {
+		return false, fmt.Errorf("failed checking cache for id: %w", err)
+	} else if inCache {
+		return true, nil
+	}
+	inBackingStore, err := m.Back.Has(id)
+	if err != nil {
+		return false, fmt.Errorf("failed checking backing store for id: %w", err)
+	}
+	return inBackingStore, nil
+}
+
 func (m *CacheStore) CopyInto(other forest.Store) error {
 	return m.Back.CopyInto(other)
 }
 
-// Add inserts the given node into both stores of the CacheStore
+// CopyMissingInto copies every node in the Back store that is not already
+// present in other into other.
+func (m *CacheStore) CopyMissingInto(other forest.Store) error {
+	return copyMissingInto(m.Back, other)
+}
+
+// Stats describes the contents of the Back store, which holds every node in
+// the CacheStore.
+func (m *CacheStore) Stats() (StoreStats, error) {
+	return Stats(m.Back)
+}
+
+// Add inserts the given node into both stores of the CacheStore. If adding to
+// the Cache fails, the node remains in the Back store and the failure is handled
+// according to the WritePolicy.
 func (m *CacheStore) Add(node forest.Node) error {
 	if err := m.Back.Add(node); err != nil {
 		return err
 	}
-	if err := m.Cache.Add(node); err != nil {
+	if err := m.addToCache(node); err != nil {
 		return err
 	}
 	return nil
@@ -67,7 +150,7 @@ func (m *CacheStore) getUsingFuncs(id *fields.QualifiedHash, getter1, getter2 fu
 		return nil, false, fmt.Errorf("failed fetching id from cache: %w", err)
 	}
 	if inBackingStore {
-		if err := m.Cache.Add(backNode); err != nil {
+		if err := m.addToCache(backNode); err != nil {
 			return nil, false, fmt.Errorf("failed to up-propagate node into cache: %w", err)
 		}
 	}
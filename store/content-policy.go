@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+const (
+	// AllowedContentKeyName and AllowedContentKeyVersion identify the twig
+	// metadata key on a community that restricts the content types of its
+	// replies. The value is a comma-separated list of the decimal numbers
+	// of the allowed content types (see fields.ContentType), such as "1" to
+	// allow only UTF-8 text or "1,3" to also allow gzipped UTF-8 text.
+	// Whitespace around each number is ignored. An empty value allows no
+	// content types, while a community without the key allows all of them.
+	//
+	// The community's metadata is covered by its signature, so the policy
+	// is fixed when the community is created. Enforcing it is up to
+	// clients and relays (see ContentAllowed); nodes that violate it are
+	// still valid.
+	AllowedContentKeyName    = "allowedcontent"
+	AllowedContentKeyVersion = 1
+)
+
+// AllowedContent returns the content types permitted in replies to the given
+// community. If the community has no content policy, the second return value
+// is false and all content types are permitted. A policy that cannot be
+// parsed produces an error.
+func AllowedContent(community *forest.Community) ([]fields.ContentType, bool, error) {
+	metadata, err := community.TwigMetadata()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed parsing metadata of community %s: %w", community.ID(), err)
+	}
+	value, has := metadata.Get(AllowedContentKeyName, AllowedContentKeyVersion)
+	if !has {
+		return nil, false, nil
+	}
+	allowed := []fields.ContentType{}
+	if strings.TrimSpace(string(value)) == "" {
+		return allowed, true, nil
+	}
+	for _, element := range strings.Split(string(value), ",") {
+		contentType, err := strconv.ParseUint(strings.TrimSpace(element), 10, 8)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid content type %q in policy of community %s: %w", element, community.ID(), err)
+		}
+		allowed = append(allowed, fields.ContentType(contentType))
+	}
+	return allowed, true, nil
+}
+
+// ContentAllowed reports whether the content type of reply is permitted by the
+// content policy of its community (see AllowedContentKeyName). The community
+// must be in s. Replies to communities without a policy are always allowed.
+func ContentAllowed(s forest.Store, reply *forest.Reply) (bool, error) {
+	node, has, err := s.GetCommunity(&reply.CommunityID)
+	if err != nil {
+		return false, fmt.Errorf("failed looking up community %s: %w", &reply.CommunityID, err)
+	} else if !has {
+		return false, fmt.Errorf("community %s of reply %s is not in store", &reply.CommunityID, reply.ID())
+	}
+	community, ok := node.(*forest.Community)
+	if !ok {
+		return false, fmt.Errorf("community %s of reply %s is not a community", &reply.CommunityID, reply.ID())
+	}
+	allowed, hasPolicy, err := AllowedContent(community)
+	if err != nil {
+		return false, err
+	} else if !hasPolicy {
+		return true, nil
+	}
+	for _, contentType := range allowed {
+		if contentType == reply.Content.Descriptor.Type {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+	"git.sr.ht/~whereswaldon/forest-go/twig"
+)
+
+func policyMetadata(t *testing.T, policy string) []byte {
+	data, err := twig.New().Set(store.AllowedContentKeyName, store.AllowedContentKeyVersion, []byte(policy))
+	if err != nil {
+		t.Skipf("Failed building twig metadata: %v", err)
+	}
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling twig metadata: %v", err)
+	}
+	return b
+}
+
+func TestContentAllowed(t *testing.T) {
+	identity, signer := testutil.MakeIdentityOrSkip(t)
+	builder := forest.As(identity, signer)
+	gzipped, err := fields.NewCompressedContent(fields.ContentTypeUTF8String, []byte("compressed"))
+	if err != nil {
+		t.Skipf("Failed compressing content: %v", err)
+	}
+	emptyMetadata, err := fields.NewQualifiedContent(fields.ContentTypeTwig, []byte{})
+	if err != nil {
+		t.Skipf("Failed creating metadata: %v", err)
+	}
+	for _, run := range []struct {
+		name                string
+		metadata            []byte
+		textAllowed, gzipOK bool
+	}{
+		{"no policy", []byte{}, true, true},
+		{"text only", policyMetadata(t, "1"), true, false},
+		{"gzip only", policyMetadata(t, "3"), false, true},
+		{"text and gzip", policyMetadata(t, " 1, 3 "), true, true},
+		{"nothing", policyMetadata(t, ""), false, false},
+	} {
+		community, err := builder.NewCommunity(run.name, run.metadata)
+		if err != nil {
+			t.Skipf("Failed creating community: %v", err)
+		}
+		text, err := builder.NewReply(community, "text", []byte{})
+		if err != nil {
+			t.Skipf("Failed creating reply: %v", err)
+		}
+		compressed, err := builder.NewReplyQualified(community, gzipped, emptyMetadata)
+		if err != nil {
+			t.Skipf("Failed creating reply: %v", err)
+		}
+		s := store.NewMemoryStore()
+		if err := s.Add(community); err != nil {
+			t.Fatalf("Failed adding community: %v", err)
+		}
+		for _, check := range []struct {
+			reply    *forest.Reply
+			expected bool
+		}{
+			{text, run.textAllowed},
+			{compressed, run.gzipOK},
+		} {
+			allowed, err := store.ContentAllowed(s, check.reply)
+			if err != nil {
+				t.Errorf("%s: failed checking content type %d: %v", run.name, check.reply.Content.Descriptor.Type, err)
+			} else if allowed != check.expected {
+				t.Errorf("%s: expected content type %d allowed=%v, got %v", run.name, check.reply.Content.Descriptor.Type, check.expected, allowed)
+			}
+		}
+	}
+
+	community, err := builder.NewCommunity("malformed", policyMetadata(t, "text"))
+	if err != nil {
+		t.Skipf("Failed creating community: %v", err)
+	}
+	reply, err := builder.NewReply(community, "text", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating reply: %v", err)
+	}
+	if _, err := store.ContentAllowed(store.NewMemoryStore(), reply); err == nil {
+		t.Errorf("Expected error checking reply whose community is not in store")
+	}
+	s := store.NewMemoryStore()
+	if err := s.Add(community); err != nil {
+		t.Fatalf("Failed adding community: %v", err)
+	}
+	if _, err := store.ContentAllowed(s, reply); err == nil {
+		t.Errorf("Expected error checking reply against malformed policy")
+	}
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"fmt"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+// IsCrossCommunity reports whether reply claims a different community than
+// its parent belongs to: the parent itself if it is a community, or the
+// community claimed by the parent if it is a reply. Such a reply violates the
+// protocol, but IsCrossCommunity only reports it, so that tools can flag
+// suspicious nodes without rejecting them. Only the immediate parent is
+// consulted; Reply.ValidateDeep checks the whole ancestry. If the parent is
+// not in s, the returned error wraps ErrMissingParent.
+func IsCrossCommunity(s forest.Store, reply *forest.Reply) (bool, error) {
+	parent, has, err := s.Get(&reply.Parent)
+	if err != nil {
+		return false, fmt.Errorf("failed looking up parent %s: %w", &reply.Parent, err)
+	} else if !has {
+		return false, fmt.Errorf("cannot check community of %s without parent %s: %w", reply.ID(), &reply.Parent, ErrMissingParent)
+	}
+	switch parent := parent.(type) {
+	case *forest.Community:
+		return !parent.ID().Equals(&reply.CommunityID), nil
+	case *forest.Reply:
+		return !parent.CommunityID.Equals(&reply.CommunityID), nil
+	default:
+		return false, fmt.Errorf("parent %s of %s is neither a community nor a reply", parent.ID(), reply.ID())
+	}
+}
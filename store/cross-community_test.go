@@ -0,0 +1,54 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestIsCrossCommunity(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	builder := forest.As(identity, signer)
+	other, err := builder.NewCommunity("other", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	child, err := builder.NewReply(reply, "child", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, other, reply, child} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	// copies of valid replies that claim the other community
+	crossReply, crossChild := *reply, *child
+	crossReply.CommunityID = *other.ID()
+	crossChild.CommunityID = *other.ID()
+
+	for _, row := range []struct {
+		name     string
+		reply    *forest.Reply
+		expected bool
+	}{
+		{"reply to community", reply, false},
+		{"reply to reply", child, false},
+		{"cross-community reply to community", &crossReply, true},
+		{"cross-community reply to reply", &crossChild, true},
+	} {
+		if cross, err := store.IsCrossCommunity(s, row.reply); err != nil {
+			t.Errorf("%s: failed checking community: %v", row.name, err)
+		} else if cross != row.expected {
+			t.Errorf("%s: expected %v, got %v", row.name, row.expected, cross)
+		}
+	}
+
+	if _, err := store.IsCrossCommunity(store.NewMemoryStore(), reply); !errors.Is(err, store.ErrMissingParent) {
+		t.Errorf("Expected checking a reply without its parent to fail with ErrMissingParent, got %v", err)
+	}
+}
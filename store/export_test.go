@@ -0,0 +1,9 @@
+package store
+
+// LogFile is the file interface that a LogStore writes to.
+type LogFile = logFile
+
+// WrapLogFile replaces the log file of l with the result of wrap.
+func WrapLogFile(l *LogStore, wrap func(LogFile) LogFile) {
+	l.log = wrap(l.log)
+}
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"fmt"
+
 	forest "git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 )
@@ -13,7 +15,130 @@ type ExtendedStore interface {
 	SubscribeToNewMessages(handler func(n forest.Node)) Subscription
 	UnsubscribeToNewMessages(Subscription)
 	AddAs(forest.Node, Subscription) (err error)
+	CopyMissingInto(forest.Store) error
 	AncestryOf(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error)
 	DescendantsOf(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error)
 	LeavesOf(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error)
 }
+
+// missingCopier is implemented by stores that can efficiently copy only the
+// nodes that another store lacks.
+type missingCopier interface {
+	CopyMissingInto(forest.Store) error
+}
+
+// copyMissingInto copies the nodes from src that are not present in dst into
+// dst. If src does not implement CopyMissingInto, its nodes are visited with
+// ForEach and each one is checked for in dst before it is added, so nodes
+// that dst already holds are never added again.
+func copyMissingInto(src, dst forest.Store) error {
+	if copier, ok := src.(missingCopier); ok {
+		return copier.CopyMissingInto(dst)
+	}
+	return ForEach(src, func(node forest.Node) error {
+		if has, err := dst.Has(node.ID()); err != nil {
+			return fmt.Errorf("failed checking whether %s is present in destination: %w", node.ID(), err)
+		} else if has {
+			return nil
+		}
+		return dst.Add(node)
+	})
+}
+
+// ChildrenPager is implemented by stores that can count and list the children
+// of a node without loading all of them at once. Pages list children in the
+// same order as forest.Store's Children method, so that consecutive pages
+// neither repeat nor skip children (unless children are added or removed in
+// between).
+type ChildrenPager interface {
+	// ChildrenCount returns the number of known children of the node with
+	// the given ID.
+	ChildrenCount(id *fields.QualifiedHash) (int, error)
+	// ChildrenPage returns at most limit children of the node with the given
+	// ID, skipping the first offset children.
+	ChildrenPage(id *fields.QualifiedHash, offset, limit int) ([]*fields.QualifiedHash, error)
+}
+
+// pageBounds validates offset and limit and clamps them to a slice of the given
+// length, returning the bounds of the requested page.
+func pageBounds(length, offset, limit int) (start, end int, err error) {
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("page offset cannot be negative: %d", offset)
+	} else if limit < 0 {
+		return 0, 0, fmt.Errorf("page limit cannot be negative: %d", limit)
+	}
+	if offset > length {
+		offset = length
+	}
+	end = offset + limit
+	if end > length || end < offset {
+		end = length
+	}
+	return offset, end, nil
+}
+
+// Page returns the elements of ids within the requested page, as described by
+// ChildrenPager.ChildrenPage. It is useful for implementing ChildrenPager.
+func Page(ids []*fields.QualifiedHash, offset, limit int) ([]*fields.QualifiedHash, error) {
+	start, end, err := pageBounds(len(ids), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ids[start:end], nil
+}
+
+// ReadWriteStore is a store that can atomically look up a node and add it if
+// it is not already present. This avoids the race inherent in calling Get and
+// then Add when several goroutines may add the same node.
+type ReadWriteStore interface {
+	forest.Store
+	GetOrAdd(node forest.Node) (stored forest.Node, added bool, err error)
+}
+
+// GetOrAdd returns the node already stored in s with the same ID as node, or
+// adds node to s if there is no such node. The added return value is true
+// only if node was added. If s implements ReadWriteStore, this is atomic.
+// Otherwise it is implemented with Get and Add, and another goroutine may add
+// the node in between.
+func GetOrAdd(s forest.Store, node forest.Node) (stored forest.Node, added bool, err error) {
+	if rw, ok := s.(ReadWriteStore); ok {
+		return rw.GetOrAdd(node)
+	}
+	if stored, present, err := s.Get(node.ID()); err != nil {
+		return nil, false, fmt.Errorf("failed looking up %s: %w", node.ID(), err)
+	} else if present {
+		return stored, false, nil
+	}
+	if err := s.Add(node); err != nil {
+		return nil, false, fmt.Errorf("failed adding %s: %w", node.ID(), err)
+	}
+	return node, true, nil
+}
+
+// BatchGetter is implemented by stores that can look up many nodes more
+// efficiently than by calling Get for each of them.
+type BatchGetter interface {
+	// GetMany returns the nodes with the given IDs that are present in the
+	// store, keyed by the string form of their IDs.
+	GetMany(ids []*fields.QualifiedHash) (map[string]forest.Node, error)
+}
+
+// GetMany returns the nodes in s with the given IDs, keyed by the string form
+// of their IDs. IDs of nodes that are not in s are omitted from the result.
+// If s implements BatchGetter, its GetMany method is used. Otherwise each node
+// is looked up with Get.
+func GetMany(s forest.Store, ids []*fields.QualifiedHash) (map[string]forest.Node, error) {
+	if getter, ok := s.(BatchGetter); ok {
+		return getter.GetMany(ids)
+	}
+	nodes := make(map[string]forest.Node, len(ids))
+	for _, id := range ids {
+		node, present, err := s.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up %s: %w", id, err)
+		} else if present {
+			nodes[id.String()] = node
+		}
+	}
+	return nodes, nil
+}
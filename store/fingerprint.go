@@ -0,0 +1,52 @@
+package store
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"sort"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// Fingerprint computes a deterministic digest of the set of IDs of every node
+// of the given type within s. Two stores holding the same set of nodes of that
+// type will always have the same fingerprint, regardless of insertion order,
+// so peers can compare fingerprints to cheaply detect whether they need to
+// exchange any nodes.
+func Fingerprint(s forest.Store, nodeType fields.NodeType) ([]byte, error) {
+	snapshot := NewMemoryStore()
+	if err := s.CopyInto(snapshot); err != nil {
+		return nil, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+	nodes, err := snapshot.Recent(nodeType, len(snapshot.Items))
+	if err != nil {
+		return nil, fmt.Errorf("failed listing nodes of type %d: %w", nodeType, err)
+	}
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID().String()
+	}
+	sort.Strings(ids)
+	digest := sha512.New512_256()
+	for _, id := range ids {
+		// the text form of an ID never contains a newline, so this
+		// unambiguously separates the IDs
+		_, _ = digest.Write([]byte(id))
+		_, _ = digest.Write([]byte{'\n'})
+	}
+	return digest.Sum(nil), nil
+}
+
+// MissingFrom returns the subset of remoteIDs that are not present in local.
+// If local fails to report whether it has a node, the node is considered
+// missing, as fetching it again is harmless.
+func MissingFrom(local forest.Store, remoteIDs []*fields.QualifiedHash) []*fields.QualifiedHash {
+	missing := []*fields.QualifiedHash{}
+	for _, id := range remoteIDs {
+		if has, err := local.Has(id); err != nil || !has {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,80 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func storeWith(t *testing.T, nodes ...forest.Node) *store.MemoryStore {
+	s := store.NewMemoryStore()
+	for _, node := range nodes {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	return s
+}
+
+func fingerprintOf(t *testing.T, s forest.Store) []byte {
+	fingerprint, err := store.Fingerprint(s, fields.NodeTypeIdentity)
+	if err != nil {
+		t.Fatalf("Failed computing fingerprint: %v", err)
+	}
+	return fingerprint
+}
+
+func TestFingerprintOverlapping(t *testing.T) {
+	ids, nodes := testutil.RandomNodeSlice(4, t)
+	local := storeWith(t, nodes[0], nodes[1], nodes[2])
+	remote := storeWith(t, nodes[3], nodes[2], nodes[1])
+
+	if bytes.Equal(fingerprintOf(t, local), fingerprintOf(t, remote)) {
+		t.Errorf("Expected stores with different nodes to have different fingerprints")
+	}
+	missing := store.MissingFrom(local, ids[1:])
+	if len(missing) != 1 || !missing[0].Equals(ids[3]) {
+		t.Errorf("Expected only %v to be missing from local store, got %v", ids[3], missing)
+	}
+
+	// after exchanging the missing nodes, the stores should agree
+	if err := local.Add(nodes[3]); err != nil {
+		t.Fatalf("Failed adding %v to local store: %v", nodes[3].ID(), err)
+	}
+	if err := remote.Add(nodes[0]); err != nil {
+		t.Fatalf("Failed adding %v to remote store: %v", nodes[0].ID(), err)
+	}
+	if !bytes.Equal(fingerprintOf(t, local), fingerprintOf(t, remote)) {
+		t.Errorf("Expected stores with the same nodes to have the same fingerprint")
+	}
+	if missing := store.MissingFrom(local, ids); len(missing) != 0 {
+		t.Errorf("Expected no nodes to be missing from local store, got %v", missing)
+	}
+}
+
+func TestFingerprintDisjoint(t *testing.T) {
+	ids, nodes := testutil.RandomNodeSlice(4, t)
+	local := storeWith(t, nodes[:2]...)
+	remote := storeWith(t, nodes[2:]...)
+
+	if bytes.Equal(fingerprintOf(t, local), fingerprintOf(t, remote)) {
+		t.Errorf("Expected disjoint stores to have different fingerprints")
+	}
+	missing := store.MissingFrom(local, ids[2:])
+	if len(missing) != 2 || !missing[0].Equals(ids[2]) || !missing[1].Equals(ids[3]) {
+		t.Errorf("Expected all remote nodes to be missing from local store, got %v", missing)
+	}
+	// nodes of other types should not affect the fingerprint
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	before := fingerprintOf(t, local)
+	if err := local.Add(community); err != nil {
+		t.Fatalf("Failed adding %v to local store: %v", community.ID(), err)
+	}
+	if !bytes.Equal(before, fingerprintOf(t, local)) {
+		t.Errorf("Expected community node not to change identity fingerprint")
+	}
+}
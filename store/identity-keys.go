@@ -0,0 +1,52 @@
+package store
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"sort"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// KeyFingerprint returns a string identifying the given public key. It is the
+// name of the key's type followed by a colon and the key's own fingerprint
+// (see fields.QualifiedKey.Fingerprint), so keys of different types never
+// share a fingerprint and two encodings of the same key have the same
+// fingerprint. Keys that cannot be parsed are identified by a digest of their
+// bytes.
+func KeyFingerprint(key *fields.QualifiedKey) string {
+	name, known := fields.KeyNames[key.Descriptor.Type]
+	if !known {
+		name = fmt.Sprintf("%d", key.Descriptor.Type)
+	}
+	fingerprint, err := key.Fingerprint()
+	if err != nil {
+		return fmt.Sprintf("%s:%x", name, sha512.Sum512_256(key.Blob))
+	}
+	return name + ":" + fingerprint
+}
+
+// IdentitiesByKey groups the IDs of the identities in s by the fingerprint of
+// their public key (see KeyFingerprint). Every identity appears in exactly one
+// group, and the IDs within each group are sorted by their text form. Groups
+// with more than one ID hold identities that share a key, as happens when a
+// user re-creates their identity.
+func IdentitiesByKey(s forest.Store) (map[string][]*fields.QualifiedHash, error) {
+	groups := make(map[string][]*fields.QualifiedHash)
+	if err := ForEach(s, func(node forest.Node) error {
+		if identity, ok := node.(*forest.Identity); ok {
+			fingerprint := KeyFingerprint(&identity.PublicKey)
+			groups[fingerprint] = append(groups[fingerprint], identity.ID())
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed listing identities in store: %w", err)
+	}
+	for _, ids := range groups {
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i].String() < ids[j].String()
+		})
+	}
+	return groups, nil
+}
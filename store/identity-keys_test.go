@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshKeySigner reports an SSH public key but produces placeholder signatures.
+type sshKeySigner struct {
+	unsignedSigner
+	pubkey ssh.PublicKey
+}
+
+func (s sshKeySigner) PublicKey() ([]byte, error) {
+	return s.pubkey.Marshal(), nil
+}
+
+func (sshKeySigner) KeyType() fields.KeyType {
+	return fields.KeyTypeSSH
+}
+
+func TestIdentitiesByKey(t *testing.T) {
+	shared := testkeys.Signer(t, testkeys.PrivKey1)
+	original, err := forest.NewIdentity(shared, "original", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating identity: %v", err)
+	}
+	recreated, err := forest.NewIdentity(shared, "recreated", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating identity: %v", err)
+	}
+	other, err := forest.NewIdentity(testkeys.Signer(t, testkeys.PrivKey2), "other", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating identity: %v", err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Skipf("Failed generating ed25519 key: %v", err)
+	}
+	sshPubkey, err := ssh.NewPublicKey(edKey)
+	if err != nil {
+		t.Skipf("Failed converting ed25519 key: %v", err)
+	}
+	sshIdentity, err := forest.NewIdentity(sshKeySigner{pubkey: sshPubkey}, "ssh", []byte{})
+	if err != nil {
+		t.Skipf("Failed creating identity: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, identity := range []*forest.Identity{original, recreated, other, sshIdentity} {
+		if err := s.Add(identity); err != nil {
+			t.Fatalf("Failed adding identity: %v", err)
+		}
+	}
+
+	groups, err := store.IdentitiesByKey(s)
+	if err != nil {
+		t.Fatalf("Failed grouping identities: %v", err)
+	}
+	if len(groups) != 3 {
+		t.Errorf("Expected 3 distinct keys, got %d: %v", len(groups), groups)
+	}
+	sharedGroup := groups[store.KeyFingerprint(&original.PublicKey)]
+	if len(sharedGroup) != 2 || !containsID(sharedGroup, original.ID()) || !containsID(sharedGroup, recreated.ID()) {
+		t.Errorf("Expected identities sharing a key to be grouped, got %v", sharedGroup)
+	}
+	if otherGroup := groups[store.KeyFingerprint(&other.PublicKey)]; len(otherGroup) != 1 || !otherGroup[0].Equals(other.ID()) {
+		t.Errorf("Expected identity with a distinct key to be alone, got %v", otherGroup)
+	}
+	sshFingerprint := store.KeyFingerprint(&sshIdentity.PublicKey)
+	if expected := "SSH:" + ssh.FingerprintSHA256(sshPubkey); sshFingerprint != expected {
+		t.Errorf("Expected SSH key fingerprint %s, got %s", expected, sshFingerprint)
+	}
+	if fingerprint := store.KeyFingerprint(&original.PublicKey); !strings.HasPrefix(fingerprint, "OpenPGP-RSA:") {
+		t.Errorf("Expected OpenPGP key fingerprint to name its type, got %s", fingerprint)
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"time"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// Observer receives a report of every lookup, addition, and child listing
+// made through a store returned by NewInstrumentedStore, along with how long
+// it took. It is deliberately small so that it can be bridged to whichever
+// metrics or tracing system an application uses. Its methods are called
+// synchronously after each operation completes, from whichever goroutine
+// made the call, so they should be fast and, if the store is used
+// concurrently, safe for concurrent use.
+type Observer interface {
+	// ObserveGet reports a lookup of a node by ID, whether through Get, Has,
+	// or one of the typed Get methods. hit reports whether the node was
+	// found.
+	ObserveGet(id *fields.QualifiedHash, hit bool, err error, dur time.Duration)
+	// ObserveAdd reports an attempt to add the node with the given ID.
+	ObserveAdd(id *fields.QualifiedHash, err error, dur time.Duration)
+	// ObserveChildren reports a listing of the children of the node with
+	// the given ID. count is the number of children found.
+	ObserveChildren(id *fields.QualifiedHash, count int, err error, dur time.Duration)
+}
+
+// NopObserver is an Observer that ignores everything reported to it.
+type NopObserver struct{}
+
+var _ Observer = NopObserver{}
+
+func (NopObserver) ObserveGet(*fields.QualifiedHash, bool, error, time.Duration) {}
+
+func (NopObserver) ObserveAdd(*fields.QualifiedHash, error, time.Duration) {}
+
+func (NopObserver) ObserveChildren(*fields.QualifiedHash, int, error, time.Duration) {}
+
+// instrumentedStore wraps another store and reports the operations made
+// through it to an Observer. Every method is delegated explicitly, so that no
+// optional interface of the wrapped store can bypass the Observer. The optional
+// query interfaces of this package are implemented by forwarding to the
+// package-level helpers, so that instrumenting a store does not change the
+// cost of those queries.
+type instrumentedStore struct {
+	store    forest.Store
+	observer Observer
+}
+
+var _ forest.Store = instrumentedStore{}
+var _ Reindexer = instrumentedStore{}
+var _ RangeQuerier = instrumentedStore{}
+var _ Iterable = instrumentedStore{}
+var _ StatsReporter = instrumentedStore{}
+var _ ChildrenPager = instrumentedStore{}
+var _ BatchGetter = instrumentedStore{}
+
+// NewInstrumentedStore returns a store that delegates to s and reports the
+// outcome and duration of each Get, Has, GetIdentity, GetCommunity,
+// GetConversation, GetReply, GetMany, Add, Children, and ChildrenPage call to
+// obs. Other methods are delegated without being reported. The returned store
+// implements RangeQuerier, Iterable, StatsReporter, ChildrenPager, and
+// BatchGetter, using the corresponding methods of s when s implements them. If obs is nil, a NopObserver is used.
+func NewInstrumentedStore(s forest.Store, obs Observer) forest.Store {
+	if obs == nil {
+		obs = NopObserver{}
+	}
+	return instrumentedStore{store: s, observer: obs}
+}
+
+// observeGet times the given lookup of the node with the given ID and reports
+// it to the Observer.
+func (i instrumentedStore) observeGet(id *fields.QualifiedHash, get func() (forest.Node, bool, error)) (forest.Node, bool, error) {
+	start := time.Now()
+	node, has, err := get()
+	i.observer.ObserveGet(id, has, err, time.Since(start))
+	return node, has, err
+}
+
+func (i instrumentedStore) CopyInto(other forest.Store) error {
+	return i.store.CopyInto(other)
+}
+
+func (i instrumentedStore) Get(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return i.observeGet(id, func() (forest.Node, bool, error) {
+		return i.store.Get(id)
+	})
+}
+
+func (i instrumentedStore) Has(id *fields.QualifiedHash) (bool, error) {
+	start := time.Now()
+	has, err := i.store.Has(id)
+	i.observer.ObserveGet(id, has, err, time.Since(start))
+	return has, err
+}
+
+func (i instrumentedStore) GetIdentity(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return i.observeGet(id, func() (forest.Node, bool, error) {
+		return i.store.GetIdentity(id)
+	})
+}
+
+func (i instrumentedStore) GetCommunity(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return i.observeGet(id, func() (forest.Node, bool, error) {
+		return i.store.GetCommunity(id)
+	})
+}
+
+func (i instrumentedStore) GetConversation(communityID, conversationID *fields.QualifiedHash) (forest.Node, bool, error) {
+	return i.observeGet(conversationID, func() (forest.Node, bool, error) {
+		return i.store.GetConversation(communityID, conversationID)
+	})
+}
+
+func (i instrumentedStore) GetReply(communityID, conversationID, replyID *fields.QualifiedHash) (forest.Node, bool, error) {
+	return i.observeGet(replyID, func() (forest.Node, bool, error) {
+		return i.store.GetReply(communityID, conversationID, replyID)
+	})
+}
+
+func (i instrumentedStore) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	start := time.Now()
+	children, err := i.store.Children(id)
+	i.observer.ObserveChildren(id, len(children), err, time.Since(start))
+	return children, err
+}
+
+func (i instrumentedStore) Recent(nodeType fields.NodeType, quantity int) ([]forest.Node, error) {
+	return i.store.Recent(nodeType, quantity)
+}
+
+func (i instrumentedStore) Add(node forest.Node) error {
+	start := time.Now()
+	err := i.store.Add(node)
+	i.observer.ObserveAdd(node.ID(), err, time.Since(start))
+	return err
+}
+
+func (i instrumentedStore) RemoveSubtree(id *fields.QualifiedHash) error {
+	return i.store.RemoveSubtree(id)
+}
+
+// GetMany looks up the nodes with the given IDs in a single batch and reports
+// one lookup per ID, sharing the duration of the batch equally among them.
+func (i instrumentedStore) GetMany(ids []*fields.QualifiedHash) (map[string]forest.Node, error) {
+	start := time.Now()
+	nodes, err := GetMany(i.store, ids)
+	if len(ids) > 0 {
+		dur := time.Since(start) / time.Duration(len(ids))
+		for _, id := range ids {
+			_, hit := nodes[id.String()]
+			i.observer.ObserveGet(id, hit, err, dur)
+		}
+	}
+	return nodes, err
+}
+
+func (i instrumentedStore) ChildrenCount(id *fields.QualifiedHash) (int, error) {
+	if pager, ok := i.store.(ChildrenPager); ok {
+		return pager.ChildrenCount(id)
+	}
+	children, err := i.store.Children(id)
+	return len(children), err
+}
+
+func (i instrumentedStore) ChildrenPage(id *fields.QualifiedHash, offset, limit int) ([]*fields.QualifiedHash, error) {
+	start := time.Now()
+	var children []*fields.QualifiedHash
+	var err error
+	if pager, ok := i.store.(ChildrenPager); ok {
+		children, err = pager.ChildrenPage(id, offset, limit)
+	} else if children, err = i.store.Children(id); err == nil {
+		children, err = Page(children, offset, limit)
+	}
+	i.observer.ObserveChildren(id, len(children), err, time.Since(start))
+	return children, err
+}
+
+func (i instrumentedStore) Between(nodeType fields.NodeType, start, end fields.Timestamp) ([]forest.Node, error) {
+	return Between(i.store, nodeType, start, end)
+}
+
+func (i instrumentedStore) ForEach(visitor func(forest.Node) error) error {
+	return ForEach(i.store, visitor)
+}
+
+func (i instrumentedStore) Stats() (StoreStats, error) {
+	return Stats(i.store)
+}
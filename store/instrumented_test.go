@@ -0,0 +1,133 @@
+package store_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+// recordingObserver counts the operations reported to it.
+type recordingObserver struct {
+	hits, misses, adds, children int
+}
+
+func (r *recordingObserver) ObserveGet(id *fields.QualifiedHash, hit bool, err error, dur time.Duration) {
+	if hit {
+		r.hits++
+	} else {
+		r.misses++
+	}
+}
+
+func (r *recordingObserver) ObserveAdd(id *fields.QualifiedHash, err error, dur time.Duration) {
+	r.adds++
+}
+
+func (r *recordingObserver) ObserveChildren(id *fields.QualifiedHash, count int, err error, dur time.Duration) {
+	r.children += count
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	testStandardStoreInterface(t, store.NewInstrumentedStore(store.NewMemoryStore(), nil), "InstrumentedStore")
+
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	other, err := forest.As(identity, signer).NewReply(community, "other", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	observer := &recordingObserver{}
+	s := store.NewInstrumentedStore(store.NewMemoryStore(), observer)
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	if _, has, err := s.Get(reply.ID()); err != nil || !has {
+		t.Errorf("Expected Get to find %v, got %v %v", reply.ID(), has, err)
+	}
+	if _, has, err := s.GetCommunity(community.ID()); err != nil || !has {
+		t.Errorf("Expected GetCommunity to find %v, got %v %v", community.ID(), has, err)
+	}
+	if has, err := s.Has(other.ID()); err != nil || has {
+		t.Errorf("Expected Has not to find %v, got %v %v", other.ID(), has, err)
+	}
+	if children, err := s.Children(community.ID()); err != nil || len(children) != 1 {
+		t.Errorf("Expected 1 child of %v, got %v %v", community.ID(), children, err)
+	}
+
+	if observer.adds != 3 {
+		t.Errorf("Expected 3 adds to be observed, got %d", observer.adds)
+	}
+	if observer.hits != 2 || observer.misses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss to be observed, got %d and %d", observer.hits, observer.misses)
+	}
+	if observer.children != 1 {
+		t.Errorf("Expected 1 child to be observed, got %d", observer.children)
+	}
+}
+
+// uncopyableStore is a MemoryStore that refuses to be copied, so that any
+// query that falls back to copying the whole store fails.
+type uncopyableStore struct {
+	*store.MemoryStore
+}
+
+func (uncopyableStore) CopyInto(forest.Store) error {
+	return errors.New("store must not be copied")
+}
+
+func TestInstrumentedStoreOptionalInterfaces(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	observer := &recordingObserver{}
+	s := store.NewInstrumentedStore(uncopyableStore{store.NewMemoryStore()}, observer)
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+
+	if nodes, err := store.Between(s, fields.NodeTypeReply, 0, math.MaxUint64); err != nil || len(nodes) != 1 {
+		t.Errorf("Expected Between to find 1 reply, got %v %v", nodes, err)
+	}
+	if communities, err := store.AllCommunities(s); err != nil || len(communities) != 1 {
+		t.Errorf("Expected AllCommunities to find 1 community, got %v %v", communities, err)
+	}
+	visited := 0
+	if err := store.ForEach(s, func(forest.Node) error {
+		visited++
+		return nil
+	}); err != nil || visited != 3 {
+		t.Errorf("Expected ForEach to visit 3 nodes, visited %d: %v", visited, err)
+	}
+	if stats, err := store.Stats(s); err != nil || stats.Total != 3 {
+		t.Errorf("Expected Stats to count 3 nodes, got %+v %v", stats, err)
+	}
+
+	pager, ok := s.(store.ChildrenPager)
+	if !ok {
+		t.Fatalf("Expected instrumented store to implement ChildrenPager")
+	}
+	if count, err := pager.ChildrenCount(community.ID()); err != nil || count != 1 {
+		t.Errorf("Expected 1 child of %v, got %d %v", community.ID(), count, err)
+	}
+	if page, err := pager.ChildrenPage(community.ID(), 0, 10); err != nil || len(page) != 1 {
+		t.Errorf("Expected a page with 1 child of %v, got %v %v", community.ID(), page, err)
+	}
+	if observer.children != 1 {
+		t.Errorf("Expected 1 child to be observed, got %d", observer.children)
+	}
+
+	nodes, err := store.GetMany(s, []*fields.QualifiedHash{identity.ID(), reply.ID(), fields.NullHash()})
+	if err != nil || len(nodes) != 2 {
+		t.Errorf("Expected GetMany to find 2 nodes, got %v %v", nodes, err)
+	}
+	if observer.hits != 2 || observer.misses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss to be observed, got %d and %d", observer.hits, observer.misses)
+	}
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+// Iterable is implemented by stores that can visit each of their nodes
+// without first gathering all of them in memory.
+type Iterable interface {
+	// ForEach invokes visitor on every node in the store, in no particular
+	// order, stopping early if visitor returns an error.
+	ForEach(visitor func(forest.Node) error) error
+}
+
+// ForEach invokes visitor on every node in s, in no particular order. The
+// iteration stops as soon as visitor returns non-nil, and that error is
+// returned wrapped so that it can be checked for with errors.Is or errors.As.
+// If s implements Iterable, its ForEach method is used. Otherwise the nodes of
+// s are visited as s copies them into a store that discards them.
+func ForEach(s forest.Store, visitor func(forest.Node) error) error {
+	if s == nil {
+		return fmt.Errorf("store cannot be nil")
+	}
+	if visitor == nil {
+		return fmt.Errorf("visitor cannot be nil")
+	}
+	if iterable, ok := s.(Iterable); ok {
+		return iterable.ForEach(visitor)
+	}
+	return s.CopyInto(&visitingStore{
+		Store:   NewMemoryStore(),
+		visitor: visitor,
+	})
+}
+
+// visitingStore is a forest.Store that passes each node added to it to a
+// visitor instead of storing it. It is always empty.
+type visitingStore struct {
+	forest.Store
+	visitor func(forest.Node) error
+}
+
+func (v *visitingStore) Add(node forest.Node) error {
+	if err := v.visitor(node); err != nil {
+		return fmt.Errorf("visitor function errored on %s: %w", node.ID(), err)
+	}
+	return nil
+}
+
+// ForEach invokes visitor on every node in the store. The visitor is called
+// without holding the store's mutex, so it may modify the store, but nodes
+// added during the iteration will not be visited.
+func (m *MemoryStore) ForEach(visitor func(forest.Node) error) error {
+	for _, node := range m.nodes() {
+		if err := visitor(node); err != nil {
+			return fmt.Errorf("visitor function errored on %s: %w", node.ID(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestForEach(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	backing := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := backing.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	errStop := errors.New("stop")
+	for name, s := range map[string]forest.Store{
+		"iterable": backing,
+		// the read-only wrapper does not implement Iterable
+		"fallback": store.ReadOnly(backing),
+	} {
+		visited := make(map[string]bool)
+		if err := store.ForEach(s, func(node forest.Node) error {
+			visited[node.ID().String()] = true
+			return nil
+		}); err != nil {
+			t.Errorf("%s: Expected iteration to succeed: %v", name, err)
+		}
+		for _, node := range []forest.Node{identity, community, reply} {
+			if !visited[node.ID().String()] {
+				t.Errorf("%s: Expected %v to be visited", name, node.ID())
+			}
+		}
+
+		calls := 0
+		err := store.ForEach(s, func(node forest.Node) error {
+			calls++
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("%s: Expected visitor error to be returned, got %v", name, err)
+		}
+		if calls != 1 {
+			t.Errorf("%s: Expected iteration to stop after the first error, visited %d nodes", name, calls)
+		}
+	}
+	if err := store.ForEach(backing, nil); err == nil {
+		t.Errorf("Expected nil visitor to be rejected")
+	}
+}
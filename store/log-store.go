@@ -0,0 +1,130 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// LogStore wraps another forest.Store and records every node added to it in
+// an append-only log file, so that the history of the store can be replayed
+// in the order that it happened. Nodes are written to the log in the node
+// stream format (see forest.WriteNode). All queries are answered by the
+// wrapped store. It is safe for concurrent use if the wrapped store is.
+//
+// The log only records additions. Nodes removed with RemoveSubtree remain in
+// the log, and nodes that were already present in the wrapped store when
+// added are not logged again.
+type LogStore struct {
+	forest.Store
+	path  string
+	log   logFile
+	mutex sync.RWMutex
+}
+
+// logFile is the subset of *os.File that a LogStore writes to.
+type logFile interface {
+	io.WriteCloser
+	io.Seeker
+	Truncate(size int64) error
+}
+
+var _ forest.Store = &LogStore{}
+var _ Reindexer = &LogStore{}
+
+// NewLogStore wraps s so that nodes added to it are appended to the log file
+// at path. The log file is created if it does not exist. Existing logs are
+// appended to, but their contents are not added to s.
+func NewLogStore(s forest.Store, path string) (*LogStore, error) {
+	if s == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+	log, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening log file %s: %w", path, err)
+	}
+	return &LogStore{
+		Store: s,
+		path:  path,
+		log:   log,
+	}, nil
+}
+
+// Add appends the node to the log and then inserts it into the wrapped store,
+// unless it was already present. If either step fails, the log is truncated
+// to its length before the call so that it records exactly the nodes that
+// were added.
+func (l *LogStore) Add(node forest.Node) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if has, err := l.Store.Has(node.ID()); err != nil {
+		return fmt.Errorf("failed checking whether %s is already present: %w", node.ID(), err)
+	} else if has {
+		return nil
+	}
+	offset, err := l.log.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed finding end of log: %w", err)
+	}
+	if err := forest.WriteNode(l.log, node); err != nil {
+		return l.rollback(offset, fmt.Errorf("failed appending %s to log: %w", node.ID(), err))
+	}
+	if err := l.Store.Add(node); err != nil {
+		return l.rollback(offset, err)
+	}
+	return nil
+}
+
+// rollback truncates the log to the given length after a failed Add and
+// returns cause, noting any failure to truncate.
+func (l *LogStore) rollback(offset int64, cause error) error {
+	if err := l.log.Truncate(offset); err != nil {
+		return fmt.Errorf("%w (and failed truncating log to %d bytes: %v)", cause, offset, err)
+	}
+	return cause
+}
+
+// RemoveSubtree removes the subtree from the wrapped store. The removal is not
+// recorded in the log.
+func (l *LogStore) RemoveSubtree(id *fields.QualifiedHash) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.Store.RemoveSubtree(id)
+}
+
+// Replay invokes visitor on every node in the log in the order in which they
+// were added. It stops at the first error returned by visitor and returns it
+// wrapped. The visitor must not add nodes to the LogStore.
+func (l *LogStore) Replay(visitor func(forest.Node) error) error {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	log, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed opening log file %s: %w", l.path, err)
+	}
+	defer log.Close()
+	reader := forest.NewNodeReader(log)
+	for {
+		node, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed reading log file %s: %w", l.path, err)
+		}
+		if err := visitor(node); err != nil {
+			return fmt.Errorf("visitor function errored on %s: %w", node.ID(), err)
+		}
+	}
+}
+
+// Close closes the log file. The LogStore must not be used afterward, but the
+// wrapped store is unaffected.
+func (l *LogStore) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.log.Close()
+}
@@ -0,0 +1,184 @@
+package store_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func tempLogPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "log-store")
+	if err != nil {
+		t.Skipf("Failed creating temporary directory: %v", err)
+	}
+	return filepath.Join(dir, "nodes.log"), func() { os.RemoveAll(dir) }
+}
+
+func TestLogStore(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+	s, err := store.NewLogStore(store.NewMemoryStore(), path)
+	if err != nil {
+		t.Fatalf("Failed creating LogStore: %v", err)
+	}
+	defer s.Close()
+	testStandardStoreInterface(t, s, "LogStore")
+}
+
+func TestLogStoreReplay(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	reply2, err := forest.As(identity, signer).NewReply(reply, "second", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	s, err := store.NewLogStore(store.NewMemoryStore(), path)
+	if err != nil {
+		t.Fatalf("Failed creating LogStore: %v", err)
+	}
+	// add out of creation order, with a duplicate
+	order := []forest.Node{community, identity, reply, community, reply2}
+	for _, node := range order {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding %v: %v", node.ID(), err)
+		}
+	}
+	expected := []forest.Node{community, identity, reply, reply2}
+	checkReplay := func(s *store.LogStore, expected []forest.Node) {
+		replayed := []forest.Node{}
+		if err := s.Replay(func(node forest.Node) error {
+			replayed = append(replayed, node)
+			return nil
+		}); err != nil {
+			t.Fatalf("Failed replaying log: %v", err)
+		}
+		if len(replayed) != len(expected) {
+			t.Fatalf("Expected %d nodes in log, got %d", len(expected), len(replayed))
+		}
+		for i := range expected {
+			if !expected[i].Equals(replayed[i]) {
+				t.Errorf("Expected node %d in log to be %v, got %v", i, expected[i].ID(), replayed[i].ID())
+			}
+		}
+	}
+	checkReplay(s, expected)
+
+	stop := errors.New("stop")
+	visited := 0
+	if err := s.Replay(func(forest.Node) error {
+		visited++
+		return stop
+	}); !errors.Is(err, stop) {
+		t.Errorf("Expected replay to return visitor error, got %v", err)
+	} else if visited != 1 {
+		t.Errorf("Expected replay to stop after first error, visited %d nodes", visited)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Failed closing LogStore: %v", err)
+	}
+
+	// reopening the log appends to it
+	reopened, err := store.NewLogStore(store.NewMemoryStore(), path)
+	if err != nil {
+		t.Fatalf("Failed reopening LogStore: %v", err)
+	}
+	defer reopened.Close()
+	if err := reopened.Add(identity); err != nil {
+		t.Fatalf("Failed adding %v: %v", identity.ID(), err)
+	}
+	checkReplay(reopened, append(expected, identity))
+}
+
+// failingLog writes only part of each write to the wrapped log file and then
+// fails while fail is set.
+type failingLog struct {
+	store.LogFile
+	fail bool
+}
+
+var errLogWrite = errors.New("log write failed")
+
+func (f *failingLog) Write(data []byte) (int, error) {
+	if !f.fail {
+		return f.LogFile.Write(data)
+	}
+	n, _ := f.LogFile.Write(data[:len(data)/2])
+	return n, errLogWrite
+}
+
+func TestLogStoreAddFailure(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	s, err := store.NewLogStore(store.NewMemoryStore(), path)
+	if err != nil {
+		t.Fatalf("Failed creating LogStore: %v", err)
+	}
+	defer s.Close()
+	log := &failingLog{}
+	store.WrapLogFile(s, func(f store.LogFile) store.LogFile {
+		log.LogFile = f
+		return log
+	})
+	if err := s.Add(identity); err != nil {
+		t.Fatalf("Failed adding %v: %v", identity.ID(), err)
+	}
+
+	log.fail = true
+	if err := s.Add(community); !errors.Is(err, errLogWrite) {
+		t.Fatalf("Expected failed log write to fail Add, got %v", err)
+	}
+	if has, err := s.Has(community.ID()); err != nil || has {
+		t.Errorf("Expected node not to be stored after failed log write (has=%v, err=%v)", has, err)
+	}
+	log.fail = false
+	if err := s.Add(community); err != nil {
+		t.Fatalf("Failed retrying add of %v: %v", community.ID(), err)
+	}
+	if err := s.Add(reply); err != nil {
+		t.Fatalf("Failed adding %v: %v", reply.ID(), err)
+	}
+
+	replayed := []forest.Node{}
+	if err := s.Replay(func(node forest.Node) error {
+		replayed = append(replayed, node)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed replaying log after failed write: %v", err)
+	}
+	expected := []forest.Node{identity, community, reply}
+	if len(replayed) != len(expected) {
+		t.Fatalf("Expected %d nodes in log, got %d", len(expected), len(replayed))
+	}
+	for i := range expected {
+		if !expected[i].Equals(replayed[i]) {
+			t.Errorf("Expected node %d in log to be %v, got %v", i, expected[i].ID(), replayed[i].ID())
+		}
+	}
+}
+
+func TestLogStoreAddRejected(t *testing.T) {
+	path, cleanup := tempLogPath(t)
+	defer cleanup()
+	identity, _, _, _ := testutil.MakeReplyOrSkip(t)
+	s, err := store.NewLogStore(store.ReadOnly(store.NewMemoryStore()), path)
+	if err != nil {
+		t.Fatalf("Failed creating LogStore: %v", err)
+	}
+	defer s.Close()
+	if err := s.Add(identity); !errors.Is(err, store.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly adding to read-only store, got %v", err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatalf("Failed checking log file: %v", err)
+	} else if info.Size() != 0 {
+		t.Errorf("Expected rejected node to be removed from log, but log has %d bytes", info.Size())
+	}
+}
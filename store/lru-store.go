@@ -0,0 +1,179 @@
+package store
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// LRUStore is an in-memory forest.Store holding a bounded number of nodes.
+// When adding a node takes it over capacity, it evicts the least recently
+// used nodes until it is back within capacity. A node is used when it is
+// added or returned by one of the Get methods. Nodes whose children are still
+// in the store are never evicted, so every node's parent remains present for
+// as long as the node itself. If every node has children, the store may
+// briefly exceed its capacity until some of them are evicted.
+//
+// Because of eviction, Get may report that a node added earlier is not
+// present, and Children and Recent only describe the nodes that remain. This
+// makes an LRUStore unsuitable as a store of record, but ideal as the Cache
+// of a CacheStore, which consults its Back store whenever the Cache misses.
+//
+// The methods of an LRUStore are safe for concurrent use.
+type LRUStore struct {
+	maxNodes int
+	nodes    *MemoryStore
+	// order holds the nodes from most to least recently used, and elements
+	// maps the string form of each node's ID to its element in order
+	order    *list.List
+	elements map[string]*list.Element
+	// mutex guards the fields above
+	mutex sync.Mutex
+}
+
+var _ forest.Store = &LRUStore{}
+var _ Reindexer = &LRUStore{}
+
+// NewLRUStore creates an empty LRUStore that holds at most maxNodes nodes. A
+// maxNodes less than one is treated as one.
+func NewLRUStore(maxNodes int) *LRUStore {
+	if maxNodes < 1 {
+		maxNodes = 1
+	}
+	return &LRUStore{
+		maxNodes: maxNodes,
+		nodes:    NewMemoryStore(),
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// Len returns the number of nodes currently in the store.
+func (l *LRUStore) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.order.Len()
+}
+
+// Add inserts the node into the store, or marks it as used if it is already
+// present, and then evicts nodes until the store is within its capacity.
+func (l *LRUStore) Add(node forest.Node) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	id := node.ID().String()
+	if element, has := l.elements[id]; has {
+		l.order.MoveToFront(element)
+		return nil
+	}
+	if err := l.nodes.AddID(id, node); err != nil {
+		return fmt.Errorf("failed adding %s: %w", id, err)
+	}
+	l.elements[id] = l.order.PushFront(node)
+	for l.order.Len() > l.maxNodes {
+		if !l.evictOne() {
+			break
+		}
+	}
+	return nil
+}
+
+// evictOne removes the least recently used node that has no children in the
+// store. It returns false if there is no such node. The caller must hold the
+// mutex.
+func (l *LRUStore) evictOne() bool {
+	for element := l.order.Back(); element != nil; element = element.Prev() {
+		node := element.Value.(forest.Node)
+		if count, err := l.nodes.ChildrenCount(node.ID()); err != nil || count > 0 {
+			continue
+		}
+		if err := l.nodes.RemoveSubtree(node.ID()); err != nil {
+			continue
+		}
+		l.order.Remove(element)
+		delete(l.elements, node.ID().String())
+		return true
+	}
+	return false
+}
+
+// Get returns the node with the given ID if it has not been evicted, and
+// marks it as used.
+func (l *LRUStore) Get(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	element, has := l.elements[id.String()]
+	if !has {
+		return nil, false, nil
+	}
+	l.order.MoveToFront(element)
+	return element.Value.(forest.Node), true, nil
+}
+
+// Has reports whether the node with the given ID is in the store. Unlike Get,
+// it does not mark the node as used.
+func (l *LRUStore) Has(id *fields.QualifiedHash) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, has := l.elements[id.String()]
+	return has, nil
+}
+
+func (l *LRUStore) GetIdentity(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return l.Get(id)
+}
+
+func (l *LRUStore) GetCommunity(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return l.Get(id)
+}
+
+func (l *LRUStore) GetConversation(communityID, conversationID *fields.QualifiedHash) (forest.Node, bool, error) {
+	return l.Get(conversationID)
+}
+
+func (l *LRUStore) GetReply(communityID, conversationID, replyID *fields.QualifiedHash) (forest.Node, bool, error) {
+	return l.Get(replyID)
+}
+
+// CopyInto adds every node currently in the store to other.
+func (l *LRUStore) CopyInto(other forest.Store) error {
+	return l.nodes.CopyInto(other)
+}
+
+// Children returns the IDs of the children of the given node that are in the
+// store.
+func (l *LRUStore) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	return l.nodes.Children(id)
+}
+
+// Recent returns the most recent nodes of the given type that are in the
+// store.
+func (l *LRUStore) Recent(nodeType fields.NodeType, quantity int) ([]forest.Node, error) {
+	return l.nodes.Recent(nodeType, quantity)
+}
+
+// RemoveSubtree removes the given node and all of its descendants that are in
+// the store.
+func (l *LRUStore) RemoveSubtree(id *fields.QualifiedHash) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	subtree := []*fields.QualifiedHash{}
+	if err := Walk(l.nodes, id, func(id *fields.QualifiedHash) error {
+		subtree = append(subtree, id)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed listing subtree of %s: %w", id, err)
+	}
+	if err := l.nodes.RemoveSubtree(id); err != nil {
+		return err
+	}
+	for _, removed := range subtree {
+		if element, has := l.elements[removed.String()]; has {
+			l.order.Remove(element)
+			delete(l.elements, removed.String())
+		}
+	}
+	return nil
+}
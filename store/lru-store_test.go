@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestLRUStore(t *testing.T) {
+	testStandardStoreInterface(t, store.NewLRUStore(100), "LRUStore")
+}
+
+func TestLRUStoreEviction(t *testing.T) {
+	s := store.NewLRUStore(3)
+	// the identity is added first, then root, a, b, and c in that order
+	nodes := testutil.MakeTree(t, s, "root -> a -> b; root -> c")
+	if s.Len() != 3 {
+		t.Errorf("Expected store to be at its capacity of 3, holds %d", s.Len())
+	}
+	// the identity and b were the least recently used nodes without
+	// children when each eviction happened
+	for label, expected := range map[string]bool{"root": true, "a": true, "b": false, "c": true} {
+		if _, has, err := s.Get(nodes[label].ID()); err != nil {
+			t.Fatalf("Failed looking up %s: %v", label, err)
+		} else if has != expected {
+			t.Errorf("Expected %s present=%v, got %v", label, expected, has)
+		}
+	}
+	if has, _ := s.Has(nodes["root"].AuthorID()); has {
+		t.Errorf("Expected identity to be evicted")
+	}
+
+	// re-adding b gives a a child again, which leaves c as the only
+	// evictable node even though root and a were used less recently
+	if err := s.Add(nodes["b"]); err != nil {
+		t.Fatalf("Failed re-adding b: %v", err)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Expected store to be at its capacity of 3, holds %d", s.Len())
+	}
+	for label, expected := range map[string]bool{"root": true, "a": true, "b": true, "c": false} {
+		if has, _ := s.Has(nodes[label].ID()); has != expected {
+			t.Errorf("Expected %s present=%v, got %v", label, expected, has)
+		}
+	}
+	assertParentsPresent(t, s, nodes)
+}
+
+// assertParentsPresent checks that every reply in s has its parent in s.
+func assertParentsPresent(t *testing.T, s forest.Store, nodes map[string]forest.Node) {
+	t.Helper()
+	for label, node := range nodes {
+		if _, isReply := node.(*forest.Reply); !isReply {
+			continue
+		}
+		if has, _ := s.Has(node.ID()); !has {
+			continue
+		}
+		if has, _ := s.Has(node.ParentID()); !has {
+			t.Errorf("Expected parent of %s to be present", label)
+		}
+	}
+}
+
+func TestLRUStoreAsCache(t *testing.T) {
+	lru := store.NewLRUStore(2)
+	c, err := store.NewCacheStore(lru, store.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("Failed constructing CacheStore: %v", err)
+	}
+	nodes := testutil.MakeTree(t, c, "root -> a -> b; a -> c; root -> d")
+	for label, node := range nodes {
+		if _, has, err := c.Get(node.ID()); err != nil {
+			t.Errorf("Failed looking up %s: %v", label, err)
+		} else if !has {
+			t.Errorf("Expected %s to be found through the cache", label)
+		}
+	}
+	if lru.Len() > 4 {
+		t.Errorf("Expected cache to stay near its capacity, holds %d nodes", lru.Len())
+	}
+	assertParentsPresent(t, lru, nodes)
+}
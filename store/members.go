@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+const (
+	// JoinKeyName and JoinKeyVersion identify the twig metadata key that
+	// marks a reply as an explicit request to join the community that it
+	// belongs to. The value is ignored and should be empty. Clients should
+	// generally hide join replies rather than displaying them as messages.
+	JoinKeyName    = "join"
+	JoinKeyVersion = 1
+)
+
+// IsJoin reports whether the given node is a reply marking its author as
+// a member of its community.
+func IsJoin(node forest.Node) bool {
+	if _, isReply := node.(*forest.Reply); !isReply {
+		return false
+	}
+	metadata, err := node.TwigMetadata()
+	if err != nil {
+		return false
+	}
+	return metadata.Contains(JoinKeyName, JoinKeyVersion)
+}
+
+// MembershipHeuristic decides whether a node within a community makes some
+// identity a member of that community. If it does, the heuristic returns the
+// ID of that identity and true.
+type MembershipHeuristic func(node forest.Node) (member *fields.QualifiedHash, isMember bool)
+
+// AuthorMembership treats the author of every reply in a community as a
+// member. Join replies are replies, so their authors are included.
+func AuthorMembership(node forest.Node) (*fields.QualifiedHash, bool) {
+	if _, isReply := node.(*forest.Reply); !isReply {
+		return nil, false
+	}
+	return node.AuthorID(), true
+}
+
+// JoinMembership treats only the authors of join replies (see IsJoin) as
+// members.
+func JoinMembership(node forest.Node) (*fields.QualifiedHash, bool) {
+	if !IsJoin(node) {
+		return nil, false
+	}
+	return node.AuthorID(), true
+}
+
+// DefaultMembership is the heuristic used by MembersOf. It may be replaced
+// to change how membership is determined throughout an application.
+var DefaultMembership MembershipHeuristic = AuthorMembership
+
+// MembersOf returns the IDs of the members of the community with the given ID
+// as determined by DefaultMembership. By default, this is every identity that
+// has replied within the community or has explicitly joined it.
+func MembersOf(s forest.Store, communityID *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	return MembersOfBy(s, communityID, DefaultMembership)
+}
+
+// MembersOfBy returns the IDs of the members of the community with the given
+// ID as determined by the given heuristic, which is consulted for every reply
+// in the community. Each member is listed once, in the order that the
+// traversal of the community first found them.
+func MembersOfBy(s forest.Store, communityID *fields.QualifiedHash, heuristic MembershipHeuristic) ([]*fields.QualifiedHash, error) {
+	if heuristic == nil {
+		return nil, fmt.Errorf("membership heuristic cannot be nil")
+	}
+	community, has, err := s.Get(communityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up community %s: %w", communityID, err)
+	} else if !has {
+		return nil, fmt.Errorf("community %s is not in store", communityID)
+	}
+	members := []*fields.QualifiedHash{}
+	seen := make(map[string]struct{})
+	if err := WalkNodes(s, community, func(node forest.Node) error {
+		member, isMember := heuristic(node)
+		if !isMember {
+			return nil
+		}
+		if _, alreadySeen := seen[member.String()]; alreadySeen {
+			return nil
+		}
+		seen[member.String()] = struct{}{}
+		members = append(members, member)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed finding members of %s: %w", communityID, err)
+	}
+	return members, nil
+}
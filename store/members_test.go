@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+	"git.sr.ht/~whereswaldon/forest-go/twig"
+)
+
+func joinMetadata(t *testing.T) []byte {
+	data, err := twig.New().Set(store.JoinKeyName, store.JoinKeyVersion, []byte{})
+	if err != nil {
+		t.Skipf("Failed building twig metadata: %v", err)
+	}
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling twig metadata: %v", err)
+	}
+	return b
+}
+
+func TestMembersOf(t *testing.T) {
+	poster, posterSigner, community, reply := testutil.MakeReplyOrSkip(t)
+	joinerSigner := testkeys.Signer(t, testkeys.PrivKey2)
+	joiner, err := forest.NewIdentity(joinerSigner, "joiner", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test identity: %v", err)
+	}
+	join, err := forest.As(joiner, joinerSigner).NewReply(community, "", joinMetadata(t))
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	second, err := forest.As(poster, posterSigner).NewReply(reply, "posting again", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{poster, joiner, community, reply, join, second} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+
+	if !store.IsJoin(join) || store.IsJoin(reply) {
+		t.Errorf("Expected only the reply with join metadata to be a join")
+	}
+
+	for _, row := range []struct {
+		name      string
+		heuristic store.MembershipHeuristic
+		expected  []*fields.QualifiedHash
+	}{
+		{"authors", store.AuthorMembership, []*fields.QualifiedHash{poster.ID(), joiner.ID()}},
+		{"joins", store.JoinMembership, []*fields.QualifiedHash{joiner.ID()}},
+	} {
+		t.Run(row.name, func(t *testing.T) {
+			members, err := store.MembersOfBy(s, community.ID(), row.heuristic)
+			if err != nil {
+				t.Fatalf("Failed finding members: %v", err)
+			}
+			if len(members) != len(row.expected) {
+				t.Fatalf("Expected %d members, got %v", len(row.expected), members)
+			}
+			for _, id := range row.expected {
+				if !containsID(members, id) {
+					t.Errorf("Expected %v to be a member, got %v", id, members)
+				}
+			}
+		})
+	}
+
+	members, err := store.MembersOf(s, community.ID())
+	if err != nil {
+		t.Fatalf("Failed finding members: %v", err)
+	} else if len(members) != 2 {
+		t.Errorf("Expected default heuristic to find both members, got %v", members)
+	}
+	if _, err := store.MembersOf(s, testutil.RandomQualifiedHash()); err == nil {
+		t.Errorf("Expected error finding members of a community that is not in the store")
+	}
+}
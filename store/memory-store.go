@@ -3,27 +3,46 @@ package store
 import (
 	"fmt"
 	"sort"
+	"sync"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 )
 
+// MemoryStore is an in-memory implementation of forest.Store. Its methods
+// are safe for concurrent use, but its exported fields must not be accessed
+// directly while other goroutines may be using it.
 type MemoryStore struct {
 	Items    map[string]forest.Node
 	ChildMap map[string][]string
+	// recent holds the nodes of each type ordered from oldest to newest by
+	// creation time. Nodes with the same creation time are ordered from the
+	// most to least recently inserted, so that reading the index backwards
+	// yields the newest nodes first with ties in insertion order.
+	recent map[fields.NodeType][]forest.Node
+	// mutex guards all of the above fields
+	mutex sync.RWMutex
 }
 
 var _ forest.Store = &MemoryStore{}
+var _ ReadWriteStore = &MemoryStore{}
+var _ ChildrenPager = &MemoryStore{}
+var _ StatsReporter = &MemoryStore{}
+var _ RangeQuerier = &MemoryStore{}
+var _ Iterable = &MemoryStore{}
+var _ Reindexer = &MemoryStore{}
+var _ BatchGetter = &MemoryStore{}
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		Items:    make(map[string]forest.Node),
 		ChildMap: make(map[string][]string),
+		recent:   make(map[fields.NodeType][]forest.Node),
 	}
 }
 
 func (m *MemoryStore) CopyInto(other forest.Store) error {
-	for _, node := range m.Items {
+	for _, node := range m.nodes() {
 		if err := other.Add(node); err != nil {
 			return err
 		}
@@ -31,10 +50,72 @@ func (m *MemoryStore) CopyInto(other forest.Store) error {
 	return nil
 }
 
+// CopyMissingInto adds every node in the store that is not already present in
+// other to other.
+func (m *MemoryStore) CopyMissingInto(other forest.Store) error {
+	for _, node := range m.nodes() {
+		if has, err := other.Has(node.ID()); err != nil {
+			return fmt.Errorf("failed checking whether %s is present in destination: %w", node.ID(), err)
+		} else if has {
+			continue
+		}
+		if err := other.Add(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodes returns a snapshot of every node in the store, so that callers can
+// iterate over them without holding the mutex.
+func (m *MemoryStore) nodes() []forest.Node {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	nodes := make([]forest.Node, 0, len(m.Items))
+	for _, node := range m.Items {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Stats counts the nodes in the store. The Bytes field is always zero.
+func (m *MemoryStore) Stats() (StoreStats, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var stats StoreStats
+	for _, node := range m.Items {
+		stats.Count(node)
+	}
+	return stats, nil
+}
+
 func (m *MemoryStore) Get(id *fields.QualifiedHash) (forest.Node, bool, error) {
 	return m.GetID(id.String())
 }
 
+// Has reports whether the node with the given ID is present in the store.
+func (m *MemoryStore) Has(id *fields.QualifiedHash) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	_, has := m.Items[id.String()]
+	return has, nil
+}
+
+// GetMany returns the nodes with the given IDs that are present in the store,
+// keyed by the string form of their IDs. The store is only locked once.
+func (m *MemoryStore) GetMany(ids []*fields.QualifiedHash) (map[string]forest.Node, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	nodes := make(map[string]forest.Node, len(ids))
+	for _, id := range ids {
+		idString := id.String()
+		if node, has := m.Items[idString]; has {
+			nodes[idString] = node
+		}
+	}
+	return nodes, nil
+}
+
 func (m *MemoryStore) GetIdentity(id *fields.QualifiedHash) (forest.Node, bool, error) {
 	return m.Get(id)
 }
@@ -52,11 +133,20 @@ func (m *MemoryStore) GetReply(communityID, conversationID, replyID *fields.Qual
 }
 
 func (m *MemoryStore) GetID(id string) (forest.Node, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 	item, has := m.Items[id]
 	return item, has, nil
 }
 
 func (m *MemoryStore) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.children(id)
+}
+
+// children implements Children. The caller must hold the mutex.
+func (m *MemoryStore) children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
 	idString := id.String()
 	children, any := m.ChildMap[idString]
 	if !any {
@@ -72,48 +162,175 @@ func (m *MemoryStore) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHas
 	return childIDs, nil
 }
 
+// ChildrenCount returns the number of known children of the node with the
+// given ID.
+func (m *MemoryStore) ChildrenCount(id *fields.QualifiedHash) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.ChildMap[id.String()]), nil
+}
+
+// ChildrenPage returns at most limit children of the node with the given ID,
+// skipping the first offset children. Children are in the same order as
+// returned by Children.
+func (m *MemoryStore) ChildrenPage(id *fields.QualifiedHash, offset, limit int) ([]*fields.QualifiedHash, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	children := m.ChildMap[id.String()]
+	start, end, err := pageBounds(len(children), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	childIDs := make([]*fields.QualifiedHash, 0, end-start)
+	for _, childStr := range children[start:end] {
+		childID := &fields.QualifiedHash{}
+		if err := childID.UnmarshalText([]byte(childStr)); err != nil {
+			return nil, fmt.Errorf("failed to transform key back into node id: %w", err)
+		}
+		childIDs = append(childIDs, childID)
+	}
+	return childIDs, nil
+}
+
 func (m *MemoryStore) Add(node forest.Node) error {
 	id := node.ID().String()
 	return m.AddID(id, node)
 }
 
 func (m *MemoryStore) AddID(id string, node forest.Node) error {
-	// safe to ignore error because we know it can't happen
-	if _, has, _ := m.GetID(id); has {
-		return nil
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.getOrAddID(id, node)
+	return nil
+}
+
+// GetOrAdd atomically returns the node already stored with the same ID as node,
+// or adds node to the store if there is no such node. The added return value
+// is true only if node was added.
+func (m *MemoryStore) GetOrAdd(node forest.Node) (stored forest.Node, added bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	stored, added = m.getOrAddID(node.ID().String(), node)
+	return stored, added, nil
+}
+
+// getOrAddID implements GetOrAdd. The caller must hold the mutex exclusively.
+func (m *MemoryStore) getOrAddID(id string, node forest.Node) (stored forest.Node, added bool) {
+	if existing, has := m.Items[id]; has {
+		return existing, false
 	}
 	m.Items[id] = node
+	m.insertChild(id, node)
+	m.insertRecent(node)
+	return node, true
+}
+
+// insertChild adds the node to the list of children of its parent, keeping
+// the list in the order defined by forest.ChildBefore.
+func (m *MemoryStore) insertChild(id string, node forest.Node) {
 	parentID := node.ParentID().String()
-	m.ChildMap[parentID] = append(m.ChildMap[parentID], id)
-	return nil
+	siblings := m.ChildMap[parentID]
+	created, nodeID := node.CreatedAt(), node.ID()
+	index := sort.Search(len(siblings), func(i int) bool {
+		sibling := m.Items[siblings[i]]
+		return forest.ChildBefore(created, nodeID, sibling.CreatedAt(), sibling.ID())
+	})
+	siblings = append(siblings, "")
+	copy(siblings[index+1:], siblings[index:])
+	siblings[index] = id
+	m.ChildMap[parentID] = siblings
 }
 
-func (m *MemoryStore) RemoveSubtree(id *fields.QualifiedHash) error {
-	children, err := m.Children(id)
-	if err != nil {
-		return fmt.Errorf("failed looking up children of %s: %w", id, err)
+// insertRecent adds the node to the recency index for its type. Since nodes
+// usually arrive in creation order, this is normally an append.
+func (m *MemoryStore) insertRecent(node forest.Node) {
+	nodeType, ok := nodeTypeOf(node)
+	if !ok {
+		return
+	}
+	if m.recent == nil {
+		m.recent = make(map[fields.NodeType][]forest.Node)
 	}
-	for _, child := range children {
-		if err := m.RemoveSubtree(child); err != nil {
-			return fmt.Errorf("failed removing children of %s: %w", child, err)
+	nodes := m.recent[nodeType]
+	created := node.CreatedAt()
+	index := sort.Search(len(nodes), func(i int) bool {
+		return !nodes[i].CreatedAt().Before(created)
+	})
+	nodes = append(nodes, nil)
+	copy(nodes[index+1:], nodes[index:])
+	nodes[index] = node
+	m.recent[nodeType] = nodes
+}
+
+// removeRecent removes the node from the recency index for its type.
+func (m *MemoryStore) removeRecent(node forest.Node) {
+	nodeType, ok := nodeTypeOf(node)
+	if !ok {
+		return
+	}
+	nodes := m.recent[nodeType]
+	created := node.CreatedAt()
+	start := sort.Search(len(nodes), func(i int) bool {
+		return !nodes[i].CreatedAt().Before(created)
+	})
+	for i := start; i < len(nodes) && nodes[i].CreatedAt().Equal(created); i++ {
+		if nodes[i].ID().Equals(node.ID()) {
+			m.recent[nodeType] = append(nodes[:i], nodes[i+1:]...)
+			return
 		}
 	}
-	child, _, err := m.Get(id)
-	if err != nil {
-		return fmt.Errorf("failed looking up child %s during removal: %w", id, err)
+}
+
+// nodeTypeOf returns the type of the given node, or false if the node is not
+// of a known concrete type.
+func nodeTypeOf(node forest.Node) (fields.NodeType, bool) {
+	switch node.(type) {
+	case *forest.Identity:
+		return fields.NodeTypeIdentity, true
+	case *forest.Community:
+		return fields.NodeTypeCommunity, true
+	case *forest.Reply:
+		return fields.NodeTypeReply, true
+	default:
+		return 0, false
 	}
+}
+
+func (m *MemoryStore) RemoveSubtree(id *fields.QualifiedHash) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.removeSubtree(id)
+}
+
+// removeSubtree implements RemoveSubtree. The caller must hold the mutex
+// exclusively. The subtree is traversed iteratively so that arbitrarily deep
+// trees cannot exhaust the stack.
+func (m *MemoryStore) removeSubtree(id *fields.QualifiedHash) error {
 	idString := id.String()
-	parentIdString := child.ParentID().String()
-	delete(m.Items, idString)
-	siblings := m.ChildMap[parentIdString]
+	root, has := m.Items[idString]
+	if !has {
+		return nil
+	}
+	pending := []string{idString}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = append(pending[:len(pending)-1], m.ChildMap[current]...)
+		// deleting the child list before visiting the children ensures that
+		// a corrupt ChildMap containing a cycle cannot loop forever
+		delete(m.ChildMap, current)
+		if node, has := m.Items[current]; has {
+			delete(m.Items, current)
+			m.removeRecent(node)
+		}
+	}
+	parentIDString := root.ParentID().String()
+	siblings := m.ChildMap[parentIDString]
 	for i := range siblings {
 		if siblings[i] != idString {
 			continue
 		}
-		for k := i + 1; k < len(siblings); k++ {
-			siblings[i] = siblings[k]
-		}
-		m.ChildMap[parentIdString] = siblings[:len(siblings)-1]
+		copy(siblings[i:], siblings[i+1:])
+		m.ChildMap[parentIDString] = siblings[:len(siblings)-1]
 		break
 	}
 	return nil
@@ -123,35 +340,15 @@ func (m *MemoryStore) RemoveSubtree(id *fields.QualifiedHash) error {
 // These nodes are the most recent (by creation time) nodes of that type known
 // to the store.
 func (m *MemoryStore) Recent(nodeType fields.NodeType, quantity int) ([]forest.Node, error) {
-	// highly inefficient implementation, but it should work for now
-	candidates := make([]forest.Node, 0, quantity)
-	for _, node := range m.Items {
-		switch n := node.(type) {
-		case *forest.Identity:
-			if nodeType == fields.NodeTypeIdentity {
-				candidates = append(candidates, n)
-				sort.SliceStable(candidates, func(i, j int) bool {
-					return candidates[i].(*forest.Identity).Created > candidates[j].(*forest.Identity).Created
-				})
-			}
-		case *forest.Community:
-			if nodeType == fields.NodeTypeCommunity {
-				candidates = append(candidates, n)
-				sort.SliceStable(candidates, func(i, j int) bool {
-					return candidates[i].(*forest.Community).Created > candidates[j].(*forest.Community).Created
-				})
-			}
-		case *forest.Reply:
-			if nodeType == fields.NodeTypeReply {
-				candidates = append(candidates, n)
-				sort.SliceStable(candidates, func(i, j int) bool {
-					return candidates[i].(*forest.Reply).Created > candidates[j].(*forest.Reply).Created
-				})
-			}
-		}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	nodes := m.recent[nodeType]
+	if len(nodes) > quantity {
+		nodes = nodes[len(nodes)-quantity:]
 	}
-	if len(candidates) > quantity {
-		candidates = candidates[:quantity]
+	candidates := make([]forest.Node, len(nodes))
+	for i := range nodes {
+		candidates[i] = nodes[len(nodes)-1-i]
 	}
 	return candidates, nil
 }
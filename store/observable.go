@@ -0,0 +1,110 @@
+package store
+
+import (
+	"sync"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+// Observable wraps a forest.Store and notifies subscribers of each node added
+// through it. Unlike Archive, it adds no other functionality and does not
+// serialize access to the wrapped store, so it is only as safe for concurrent
+// use as the wrapped store is. Nodes added to the wrapped store directly do not
+// produce notifications.
+type Observable struct {
+	forest.Store
+	nextSubscriberKey Subscription
+	subscribers       map[Subscription]func(forest.Node)
+	// mutex guards the subscription fields above
+	mutex sync.RWMutex
+}
+
+var _ forest.Store = &Observable{}
+var _ ReadWriteStore = &Observable{}
+var _ Reindexer = &Observable{}
+
+// NewObservable creates an Observable that wraps s.
+func NewObservable(s forest.Store) *Observable {
+	return &Observable{
+		Store:             s,
+		nextSubscriberKey: firstSubscription,
+		subscribers:       make(map[Subscription]func(forest.Node)),
+	}
+}
+
+// SubscribeToNewMessages establishes the given function as a handler to be
+// invoked on each node added to the store. The returned subscription ID
+// can be used to unsubscribe later, as well as to supress notifications
+// with AddAs().
+//
+// Handler functions are invoked synchronously on the same goroutine that invokes
+// Add() or AddAs(), and should not block. If long-running code is needed in a
+// handler, launch a new goroutine.
+func (o *Observable) SubscribeToNewMessages(handler func(n forest.Node)) Subscription {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	subscriptionID := o.nextSubscriberKey
+	o.nextSubscriberKey++
+	// handler unsigned overflow
+	if o.nextSubscriberKey == neverAssigned {
+		o.nextSubscriberKey = firstSubscription
+	}
+	o.subscribers[subscriptionID] = handler
+	return subscriptionID
+}
+
+// UnsubscribeToNewMessages removes the handler for a given subscription from
+// the store.
+func (o *Observable) UnsubscribeToNewMessages(subscriptionID Subscription) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	delete(o.subscribers, subscriptionID)
+}
+
+// Add inserts a node into the wrapped store and notifies all subscribers if the
+// node was not already present. To suppress notifying the caller's own
+// subscription, use AddAs() instead.
+func (o *Observable) Add(node forest.Node) error {
+	return o.AddAs(node, neverAssigned)
+}
+
+// AddAs works like Add, but does not notify the subscription with the given ID.
+func (o *Observable) AddAs(node forest.Node, addedByID Subscription) error {
+	_, _, err := o.getOrAddAs(node, addedByID)
+	return err
+}
+
+// GetOrAdd returns the node already stored with the same ID as node, or adds
+// node to the wrapped store if there is no such node. The added return value is
+// true only if node was added, in which case subscribers are notified. It is
+// atomic only if the wrapped store implements ReadWriteStore.
+func (o *Observable) GetOrAdd(node forest.Node) (stored forest.Node, added bool, err error) {
+	return o.getOrAddAs(node, neverAssigned)
+}
+
+// getOrAddAs implements GetOrAdd, notifying every subscriber except addedByID
+// if the node is added.
+func (o *Observable) getOrAddAs(node forest.Node, addedByID Subscription) (stored forest.Node, added bool, err error) {
+	stored, added, err = GetOrAdd(o.Store, node)
+	if err != nil || !added {
+		return stored, added, err
+	}
+	o.notify(node, addedByID)
+	return stored, added, nil
+}
+
+// notify invokes every subscribed handler except the one for ignore. The
+// handlers run without holding the mutex, so they may subscribe or unsubscribe.
+func (o *Observable) notify(node forest.Node, ignore Subscription) {
+	o.mutex.RLock()
+	handlers := make([]func(forest.Node), 0, len(o.subscribers))
+	for subscriptionID, handler := range o.subscribers {
+		if subscriptionID != ignore {
+			handlers = append(handlers, handler)
+		}
+	}
+	o.mutex.RUnlock()
+	for _, handler := range handlers {
+		handler(node)
+	}
+}
@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestObservable(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	other, err := forest.As(identity, signer).NewReply(community, "other", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	backing := store.NewMemoryStore()
+	s := store.NewObservable(backing)
+	var first, second []forest.Node
+	firstID := s.SubscribeToNewMessages(func(n forest.Node) {
+		first = append(first, n)
+	})
+	secondID := s.SubscribeToNewMessages(func(n forest.Node) {
+		second = append(second, n)
+	})
+
+	if err := s.Add(community); err != nil {
+		t.Fatalf("Failed adding %v: %v", community.ID(), err)
+	}
+	if len(first) != 1 || len(second) != 1 {
+		t.Errorf("Expected both subscribers to be notified once, got %d and %d", len(first), len(second))
+	}
+	if has, _ := backing.Has(community.ID()); !has {
+		t.Errorf("Expected wrapped store to contain %v", community.ID())
+	}
+
+	// adding a node again should not notify anyone
+	if err := s.Add(community); err != nil {
+		t.Fatalf("Failed re-adding %v: %v", community.ID(), err)
+	}
+	if len(first) != 1 || len(second) != 1 {
+		t.Errorf("Expected re-adding a node not to notify subscribers, got %d and %d", len(first), len(second))
+	}
+
+	if err := s.AddAs(reply, firstID); err != nil {
+		t.Fatalf("Failed adding %v: %v", reply.ID(), err)
+	}
+	if len(first) != 1 || len(second) != 2 {
+		t.Errorf("Expected only the second subscriber to be notified by AddAs, got %d and %d", len(first), len(second))
+	}
+
+	s.UnsubscribeToNewMessages(secondID)
+	if err := s.Add(other); err != nil {
+		t.Fatalf("Failed adding %v: %v", other.ID(), err)
+	}
+	if len(first) != 2 || len(second) != 2 {
+		t.Errorf("Expected only the first subscriber to be notified after unsubscribing, got %d and %d", len(first), len(second))
+	} else if !first[1].Equals(other) {
+		t.Errorf("Expected notification of %v, got %v", other.ID(), first[1].ID())
+	}
+}
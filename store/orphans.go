@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// Orphans returns the IDs of the nodes in s whose parent is not in s, in no
+// particular order. Such nodes can be left behind by removals or partial
+// syncs. Nodes without a parent (identities and communities, whose parent is
+// the null hash) are never orphans. Only nodes whose own parent is missing are
+// returned, not their descendants.
+func Orphans(s forest.Store) ([]*fields.QualifiedHash, error) {
+	// gather the nodes before looking up their parents, since some stores
+	// cannot be queried while they are being iterated over
+	children := []forest.Node{}
+	if err := ForEach(s, func(node forest.Node) error {
+		if !node.ParentID().Equals(fields.NullHash()) {
+			children = append(children, node)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+	orphans := []*fields.QualifiedHash{}
+	for _, node := range children {
+		has, err := s.Has(node.ParentID())
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up parent of %s: %w", node.ID(), err)
+		} else if !has {
+			orphans = append(orphans, node.ID())
+		}
+	}
+	return orphans, nil
+}
+
+// PruneOrphans removes the subtree rooted at every orphan in s (see Orphans).
+// It returns the IDs of every removed node, including the descendants of
+// orphans.
+func PruneOrphans(s forest.Store) ([]*fields.QualifiedHash, error) {
+	orphans, err := Orphans(s)
+	if err != nil {
+		return nil, err
+	}
+	removed := []*fields.QualifiedHash{}
+	for _, orphan := range orphans {
+		subtree := []*fields.QualifiedHash{}
+		if err := Walk(s, orphan, func(id *fields.QualifiedHash) error {
+			subtree = append(subtree, id)
+			return nil
+		}); err != nil {
+			return removed, fmt.Errorf("failed listing subtree of %s: %w", orphan, err)
+		}
+		if err := s.RemoveSubtree(orphan); err != nil {
+			return removed, fmt.Errorf("failed removing subtree of %s: %w", orphan, err)
+		}
+		removed = append(removed, subtree...)
+	}
+	return removed, nil
+}
@@ -0,0 +1,51 @@
+package store_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestOrphans(t *testing.T) {
+	s := store.NewMemoryStore()
+	nodes := testutil.MakeTree(t, s, "root -> a -> b -> e; a -> c; root -> d")
+	if orphans, err := store.Orphans(s); err != nil {
+		t.Fatalf("Failed finding orphans: %v", err)
+	} else if len(orphans) != 0 {
+		t.Fatalf("Expected no orphans in complete tree, got %v", orphans)
+	}
+
+	// remove a without removing its descendants, as a partial sync might
+	delete(s.Items, nodes["a"].ID().String())
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("Failed rebuilding index: %v", err)
+	}
+	orphans, err := store.Orphans(s)
+	if err != nil {
+		t.Fatalf("Failed finding orphans: %v", err)
+	}
+	if len(orphans) != 2 || !containsID(orphans, nodes["b"].ID()) || !containsID(orphans, nodes["c"].ID()) {
+		t.Errorf("Expected children of removed node to be orphans, got %v", orphans)
+	}
+
+	removed, err := store.PruneOrphans(s)
+	if err != nil {
+		t.Fatalf("Failed pruning orphans: %v", err)
+	}
+	if len(removed) != 3 || !containsID(removed, nodes["b"].ID()) || !containsID(removed, nodes["c"].ID()) || !containsID(removed, nodes["e"].ID()) {
+		t.Errorf("Expected orphans and their descendants to be removed, got %v", removed)
+	}
+	for _, label := range []string{"root", "d"} {
+		if has, err := s.Has(nodes[label].ID()); err != nil {
+			t.Fatalf("Failed checking for %s: %v", label, err)
+		} else if !has {
+			t.Errorf("Expected %s to survive pruning orphans", label)
+		}
+	}
+	if orphans, err := store.Orphans(s); err != nil {
+		t.Fatalf("Failed finding orphans: %v", err)
+	} else if len(orphans) != 0 {
+		t.Errorf("Expected no orphans after pruning, got %v", orphans)
+	}
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+const (
+	// ExpiryKeyName and ExpiryKeyVersion identify the twig metadata key that
+	// holds the time after which a node should be considered expired. The
+	// value must be an RFC3339 timestamp.
+	ExpiryKeyName    = "expires"
+	ExpiryKeyVersion = 1
+)
+
+// Expiry returns the expiration time stored in the twig metadata of the given
+// node. If the node has no expiration time or its expiration time cannot be
+// parsed, the second return value will be false and the node should be treated
+// as never expiring.
+func Expiry(node forest.Node) (time.Time, bool) {
+	metadata, err := node.TwigMetadata()
+	if err != nil {
+		return time.Time{}, false
+	}
+	value, has := metadata.Get(ExpiryKeyName, ExpiryKeyVersion)
+	if !has {
+		return time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, string(value))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiry, true
+}
+
+// Prune removes the subtree rooted at every node in s whose expiration time
+// (see Expiry) is not after now. It returns the IDs of every removed node,
+// including the descendants of expired nodes.
+func Prune(s forest.Store, now time.Time) ([]*fields.QualifiedHash, error) {
+	snapshot := NewMemoryStore()
+	if err := s.CopyInto(snapshot); err != nil {
+		return nil, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+	removed := []*fields.QualifiedHash{}
+	removedSet := make(map[string]struct{})
+	for _, node := range snapshot.Items {
+		expiry, expires := Expiry(node)
+		if !expires || expiry.After(now) {
+			continue
+		}
+		if _, alreadyRemoved := removedSet[node.ID().String()]; alreadyRemoved {
+			continue
+		}
+		subtree := []*fields.QualifiedHash{}
+		if err := Walk(snapshot, node.ID(), func(id *fields.QualifiedHash) error {
+			subtree = append(subtree, id)
+			return nil
+		}); err != nil {
+			return removed, fmt.Errorf("failed listing subtree of %s: %w", node.ID(), err)
+		}
+		if err := s.RemoveSubtree(node.ID()); err != nil {
+			return removed, fmt.Errorf("failed removing subtree of %s: %w", node.ID(), err)
+		}
+		if err := snapshot.RemoveSubtree(node.ID()); err != nil {
+			return removed, fmt.Errorf("failed removing subtree of %s from snapshot: %w", node.ID(), err)
+		}
+		for _, id := range subtree {
+			removedSet[id.String()] = struct{}{}
+		}
+		removed = append(removed, subtree...)
+	}
+	return removed, nil
+}
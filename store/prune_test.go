@@ -0,0 +1,65 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+	"git.sr.ht/~whereswaldon/forest-go/twig"
+)
+
+func expiringMetadata(t *testing.T, expiry time.Time) []byte {
+	data, err := twig.New().Set(store.ExpiryKeyName, store.ExpiryKeyVersion, []byte(expiry.Format(time.RFC3339)))
+	if err != nil {
+		t.Skipf("Failed building twig metadata: %v", err)
+	}
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling twig metadata: %v", err)
+	}
+	return b
+}
+
+func TestPrune(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	now := time.Now()
+	builder := forest.As(identity, signer)
+	expired, err := builder.NewReply(community, "expired", expiringMetadata(t, now.Add(-time.Hour)))
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	expiredChild, err := builder.NewReply(expired, "child of expired", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	live, err := builder.NewReply(community, "live", expiringMetadata(t, now.Add(time.Hour)))
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply, expired, expiredChild, live} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+
+	removed, err := store.Prune(s, now)
+	if err != nil {
+		t.Fatalf("Prune failed on valid store: %v", err)
+	}
+	if len(removed) != 2 || !containsID(removed, expired.ID()) || !containsID(removed, expiredChild.ID()) {
+		t.Errorf("Expected expired node and its child to be removed, got %v", removed)
+	}
+	for _, node := range []forest.Node{identity, community, reply, live} {
+		if _, has, _ := s.Get(node.ID()); !has {
+			t.Errorf("Expected unexpired node %v to remain in store", node.ID())
+		}
+	}
+	for _, node := range []forest.Node{expired, expiredChild} {
+		if _, has, _ := s.Get(node.ID()); has {
+			t.Errorf("Expected expired node %v to be removed from store", node.ID())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"fmt"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+const (
+	// ReactionKeyName and ReactionKeyVersion identify the twig metadata key
+	// that marks a reply as a reaction to its parent. The value is the
+	// reaction itself, usually a single emoji, and must not be empty. The
+	// content of a reaction should be empty or a short textual fallback
+	// for clients that do not understand reactions. Reactions are ordinary
+	// replies in every other respect, so they validate and are stored
+	// like any other reply. Clients should generally display them as a
+	// tally on their parent (see ReactionsOf) rather than as messages.
+	ReactionKeyName    = "reaction"
+	ReactionKeyVersion = 1
+)
+
+// ReactionOf returns the reaction expressed by the given node and true if
+// the node is a reply carrying reaction metadata.
+func ReactionOf(node forest.Node) (reaction string, isReaction bool) {
+	if _, isReply := node.(*forest.Reply); !isReply {
+		return "", false
+	}
+	metadata, err := node.TwigMetadata()
+	if err != nil {
+		return "", false
+	}
+	value, has := metadata.Get(ReactionKeyName, ReactionKeyVersion)
+	if !has || len(value) == 0 {
+		return "", false
+	}
+	return string(value), true
+}
+
+// ReactionsOf tallies the reactions among the direct children of the node
+// with the given ID. The returned map is keyed by reaction, and contains
+// only reactions that occur at least once.
+func ReactionsOf(s forest.Store, id *fields.QualifiedHash) (map[string]int, error) {
+	children, err := s.Children(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up children of %s: %w", id, err)
+	}
+	reactions := make(map[string]int)
+	for _, childID := range children {
+		child, has, err := s.Get(childID)
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up child %s: %w", childID, err)
+		} else if !has {
+			continue
+		}
+		if reaction, isReaction := ReactionOf(child); isReaction {
+			reactions[reaction]++
+		}
+	}
+	return reactions, nil
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+	"git.sr.ht/~whereswaldon/forest-go/twig"
+)
+
+func reactionMetadata(t *testing.T, reaction string) []byte {
+	data, err := twig.New().Set(store.ReactionKeyName, store.ReactionKeyVersion, []byte(reaction))
+	if err != nil {
+		t.Skipf("Failed building twig metadata: %v", err)
+	}
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling twig metadata: %v", err)
+	}
+	return b
+}
+
+func TestReactionsOf(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	builder := forest.As(identity, signer)
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	for _, reaction := range []string{"👍", "🎉", "👍"} {
+		node, err := builder.NewReply(reply, "", reactionMetadata(t, reaction))
+		if err != nil {
+			t.Skipf("Failed generating reaction: %v", err)
+		}
+		if _, isReaction := store.ReactionOf(node); !isReaction {
+			t.Errorf("Expected reply with reaction metadata to be a reaction")
+		}
+		if err := node.ValidateDeep(s); err != nil {
+			t.Errorf("Expected reaction to validate as a reply: %v", err)
+		}
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	// an ordinary reply is not counted
+	if other, err := builder.NewReply(reply, "not a reaction", []byte{}); err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	} else if err := s.Add(other); err != nil {
+		t.Skipf("Failed adding %v to store: %v", other.ID(), err)
+	}
+
+	reactions, err := store.ReactionsOf(s, reply.ID())
+	if err != nil {
+		t.Fatalf("Failed tallying reactions: %v", err)
+	}
+	if len(reactions) != 2 || reactions["👍"] != 2 || reactions["🎉"] != 1 {
+		t.Errorf("Expected 2 👍 and 1 🎉, got %v", reactions)
+	}
+}
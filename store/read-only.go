@@ -0,0 +1,77 @@
+package store
+
+import (
+	"errors"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// ErrReadOnly is returned by the methods of a store returned by ReadOnly that
+// would modify it.
+var ErrReadOnly = errors.New("store is read-only")
+
+// readOnlyStore wraps another store and rejects all modifications. Every
+// method is delegated explicitly so that no method of the wrapped store (or
+// optional interface that it implements) can be reached except through the
+// methods below.
+type readOnlyStore struct {
+	store forest.Store
+}
+
+var _ forest.Store = readOnlyStore{}
+var _ Reindexer = readOnlyStore{}
+
+// ReadOnly returns a store that reads from s but returns ErrReadOnly from any
+// method that would modify it. Since adding a node fails, copying another store
+// into the returned store fails as well. Copying the returned store into
+// another store works normally.
+func ReadOnly(s forest.Store) forest.Store {
+	return readOnlyStore{store: s}
+}
+
+func (r readOnlyStore) CopyInto(other forest.Store) error {
+	return r.store.CopyInto(other)
+}
+
+func (r readOnlyStore) Get(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return r.store.Get(id)
+}
+
+func (r readOnlyStore) Has(id *fields.QualifiedHash) (bool, error) {
+	return r.store.Has(id)
+}
+
+func (r readOnlyStore) GetIdentity(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return r.store.GetIdentity(id)
+}
+
+func (r readOnlyStore) GetCommunity(id *fields.QualifiedHash) (forest.Node, bool, error) {
+	return r.store.GetCommunity(id)
+}
+
+func (r readOnlyStore) GetConversation(communityID, conversationID *fields.QualifiedHash) (forest.Node, bool, error) {
+	return r.store.GetConversation(communityID, conversationID)
+}
+
+func (r readOnlyStore) GetReply(communityID, conversationID, replyID *fields.QualifiedHash) (forest.Node, bool, error) {
+	return r.store.GetReply(communityID, conversationID, replyID)
+}
+
+func (r readOnlyStore) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	return r.store.Children(id)
+}
+
+func (r readOnlyStore) Recent(nodeType fields.NodeType, quantity int) ([]forest.Node, error) {
+	return r.store.Recent(nodeType, quantity)
+}
+
+// Add always returns ErrReadOnly.
+func (r readOnlyStore) Add(forest.Node) error {
+	return ErrReadOnly
+}
+
+// RemoveSubtree always returns ErrReadOnly.
+func (r readOnlyStore) RemoveSubtree(*fields.QualifiedHash) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,65 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestReadOnly(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	other, err := forest.As(identity, signer).NewReply(community, "other", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	backing := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := backing.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	s := store.ReadOnly(backing)
+
+	if node, has, err := s.Get(reply.ID()); err != nil || !has || !node.Equals(reply) {
+		t.Errorf("Expected Get to find %v, got %v %v %v", reply.ID(), node, has, err)
+	}
+	if has, err := s.Has(community.ID()); err != nil || !has {
+		t.Errorf("Expected Has to find %v, got %v %v", community.ID(), has, err)
+	}
+	if children, err := s.Children(community.ID()); err != nil || len(children) != 1 {
+		t.Errorf("Expected 1 child of %v, got %v %v", community.ID(), children, err)
+	}
+	if recent, err := s.Recent(fields.NodeTypeReply, 5); err != nil || len(recent) != 1 {
+		t.Errorf("Expected 1 recent reply, got %v %v", recent, err)
+	}
+	copied := store.NewMemoryStore()
+	if err := s.CopyInto(copied); err != nil {
+		t.Errorf("Expected copying out of read-only store to succeed: %v", err)
+	} else if has, _ := copied.Has(reply.ID()); !has {
+		t.Errorf("Expected copy to contain %v", reply.ID())
+	}
+
+	if err := s.Add(other); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Expected Add to fail with ErrReadOnly, got %v", err)
+	}
+	if err := s.RemoveSubtree(reply.ID()); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Expected RemoveSubtree to fail with ErrReadOnly, got %v", err)
+	}
+	source := store.NewMemoryStore()
+	if err := source.Add(other); err != nil {
+		t.Skipf("Failed adding %v to store: %v", other.ID(), err)
+	}
+	if err := source.CopyInto(s); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Expected copying into read-only store to fail with ErrReadOnly, got %v", err)
+	}
+	if has, _ := backing.Has(other.ID()); has {
+		t.Errorf("Expected wrapped store to be unmodified")
+	}
+	if has, _ := backing.Has(reply.ID()); !has {
+		t.Errorf("Expected wrapped store to still contain %v", reply.ID())
+	}
+}
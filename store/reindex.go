@@ -0,0 +1,111 @@
+package store
+
+import (
+	"fmt"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// Reindexer is implemented by stores that maintain indexes derived from the
+// nodes that they hold, such as the children of each node.
+type Reindexer interface {
+	// RebuildIndex discards the store's derived indexes and recomputes them
+	// from its nodes.
+	RebuildIndex() error
+}
+
+// Reindex recomputes any indexes that s derives from its nodes. This is
+// useful after the underlying storage of s has been modified without going
+// through its Add method. Every store in this package that keeps such indexes
+// implements Reindexer, as does every store that wraps another, which rebuilds
+// the indexes of the stores that it wraps. If s does not implement Reindexer,
+// it is assumed to keep no indexes and Reindex does nothing.
+func Reindex(s forest.Store) error {
+	if s == nil {
+		return fmt.Errorf("store cannot be nil")
+	}
+	if reindexer, ok := s.(Reindexer); ok {
+		return reindexer.RebuildIndex()
+	}
+	return nil
+}
+
+// RebuildIndex recomputes ChildMap and the recency index from Items. It never
+// returns an error.
+func (m *MemoryStore) RebuildIndex() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ChildMap = make(map[string][]string)
+	m.recent = make(map[fields.NodeType][]forest.Node)
+	for id, node := range m.Items {
+		m.insertChild(id, node)
+		m.insertRecent(node)
+	}
+	return nil
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any.
+func (m *Archive) RebuildIndex() (err error) {
+	m.executeAsync(func() {
+		err = Reindex(m.store)
+	})
+	return
+}
+
+// RebuildIndex rebuilds the indexes of the Cache and then of the Back store.
+func (m *CacheStore) RebuildIndex() error {
+	if err := Reindex(m.Cache); err != nil {
+		return fmt.Errorf("failed rebuilding cache indexes: %w", err)
+	}
+	if err := Reindex(m.Back); err != nil {
+		return fmt.Errorf("failed rebuilding back store indexes: %w", err)
+	}
+	return nil
+}
+
+// RebuildIndex rebuilds the indexes of the nodes currently held in the store.
+func (l *LRUStore) RebuildIndex() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.nodes.RebuildIndex()
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any.
+func (l *LogStore) RebuildIndex() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return Reindex(l.Store)
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any.
+func (o *Observable) RebuildIndex() error {
+	return Reindex(o.Store)
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any.
+// Buffered nodes are not affected.
+func (s *StrictStore) RebuildIndex() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Reindex(s.Store)
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any.
+// Deferred nodes are not affected.
+func (s *ValidatingStore) RebuildIndex() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Reindex(s.Store)
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any. It
+// does not modify any node, so it is permitted on a read-only store.
+func (r readOnlyStore) RebuildIndex() error {
+	return Reindex(r.store)
+}
+
+// RebuildIndex rebuilds the indexes of the wrapped store, if it has any.
+func (i instrumentedStore) RebuildIndex() error {
+	return Reindex(i.store)
+}
@@ -0,0 +1,116 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestMemoryStoreRebuildIndex(t *testing.T) {
+	s := store.NewMemoryStore()
+	nodes := testutil.MakeTree(t, s, "root -> a -> b; a -> c; root -> d")
+	expected := make(map[string][]string)
+	for label, node := range nodes {
+		children, err := s.Children(node.ID())
+		if err != nil {
+			t.Fatalf("Failed listing children of %s: %v", label, err)
+		}
+		for _, child := range children {
+			expected[label] = append(expected[label], child.String())
+		}
+	}
+
+	// lose the children of one node, reorder those of another, and invent
+	// a child for a leaf
+	delete(s.ChildMap, nodes["root"].ID().String())
+	siblings := s.ChildMap[nodes["a"].ID().String()]
+	siblings[0], siblings[1] = siblings[1], siblings[0]
+	s.ChildMap[nodes["d"].ID().String()] = []string{nodes["b"].ID().String()}
+
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("Failed rebuilding index: %v", err)
+	}
+	for label, node := range nodes {
+		children, err := s.Children(node.ID())
+		if err != nil {
+			t.Fatalf("Failed listing children of %s: %v", label, err)
+		}
+		if len(children) != len(expected[label]) {
+			t.Errorf("Expected %s to have %d children after rebuild, got %d", label, len(expected[label]), len(children))
+			continue
+		}
+		for i, child := range children {
+			if child.String() != expected[label][i] {
+				t.Errorf("Expected child %d of %s to be %s, got %s", i, label, expected[label][i], child)
+			}
+		}
+	}
+}
+
+func TestWrapperRebuildIndex(t *testing.T) {
+	wrappers := map[string]func(*testing.T, forest.Store) forest.Store{
+		"archive":      func(t *testing.T, s forest.Store) forest.Store { return store.NewArchive(s) },
+		"observable":   func(t *testing.T, s forest.Store) forest.Store { return store.NewObservable(s) },
+		"strict":       func(t *testing.T, s forest.Store) forest.Store { return store.NewStrictStore(s) },
+		"validating":   func(t *testing.T, s forest.Store) forest.Store { return store.NewValidatingStore(s) },
+		"read-only":    func(t *testing.T, s forest.Store) forest.Store { return store.ReadOnly(s) },
+		"instrumented": func(t *testing.T, s forest.Store) forest.Store { return store.NewInstrumentedStore(s, nil) },
+		"log": func(t *testing.T, s forest.Store) forest.Store {
+			path, cleanup := tempLogPath(t)
+			t.Cleanup(cleanup)
+			l, err := store.NewLogStore(s, path)
+			if err != nil {
+				t.Fatalf("Failed creating LogStore: %v", err)
+			}
+			t.Cleanup(func() { l.Close() })
+			return l
+		},
+		"cache": func(t *testing.T, s forest.Store) forest.Store {
+			c, err := store.NewCacheStore(store.NewMemoryStore(), s)
+			if err != nil {
+				t.Fatalf("Failed creating CacheStore: %v", err)
+			}
+			return c
+		},
+	}
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			nodes := testutil.MakeTree(t, s, "root -> a; root -> b")
+			wrapper := wrap(t, s)
+			delete(s.ChildMap, nodes["root"].ID().String())
+
+			if err := store.Reindex(wrapper); err != nil {
+				t.Fatalf("Failed rebuilding index: %v", err)
+			}
+			children, err := s.Children(nodes["root"].ID())
+			if err != nil {
+				t.Fatalf("Failed listing children: %v", err)
+			}
+			if len(children) != 2 {
+				t.Errorf("Expected root to have 2 children after rebuild through the wrapper, got %d", len(children))
+			}
+		})
+	}
+}
+
+func TestLRUStoreRebuildIndex(t *testing.T) {
+	s := store.NewLRUStore(10)
+	nodes := testutil.MakeTree(t, s, "root -> a; root -> b")
+	before, err := s.Children(nodes["root"].ID())
+	if err != nil {
+		t.Fatalf("Failed listing children: %v", err)
+	}
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("Failed rebuilding index: %v", err)
+	}
+	after, err := s.Children(nodes["root"].ID())
+	if err != nil {
+		t.Fatalf("Failed listing children: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("Expected %d children after rebuild, got %d", len(before), len(after))
+	}
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+var (
+	// ErrShortIDNotFound is returned by ResolveShort when no node matches.
+	ErrShortIDNotFound = errors.New("no node matches short id")
+	// ErrShortIDAmbiguous is returned by ResolveShort when several nodes
+	// match.
+	ErrShortIDAmbiguous = errors.New("short id matches several nodes")
+)
+
+// ResolveShort returns the ID of the only node in s whose digest, written in
+// hexadecimal, begins with short. Short IDs are produced by
+// fields.QualifiedHash.Short, but any non-empty prefix of the hexadecimal
+// digest is accepted, so a longer prefix can be given to disambiguate. It
+// fails with an error wrapping ErrShortIDNotFound or ErrShortIDAmbiguous if
+// there is not exactly one match. Every node in s is visited (see ForEach),
+// so this is slow for large stores.
+func ResolveShort(s forest.Store, short string) (*fields.QualifiedHash, error) {
+	short = strings.ToLower(short)
+	if short == "" {
+		return nil, fmt.Errorf("short id cannot be empty")
+	}
+	// an odd number of digits is a valid prefix, so check the characters
+	// rather than decoding the whole string
+	if strings.Trim(short, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("short id %q is not hexadecimal", short)
+	}
+	var matches []*fields.QualifiedHash
+	if err := ForEach(s, func(node forest.Node) error {
+		if strings.HasPrefix(hex.EncodeToString(node.ID().Blob), short) {
+			matches = append(matches, node.ID())
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed searching for short id %s: %w", short, err)
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%s: %w", short, ErrShortIDNotFound)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("%s matches %d nodes: %w", short, len(matches), ErrShortIDAmbiguous)
+	}
+}
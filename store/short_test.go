@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestResolveShort(t *testing.T) {
+	// with more nodes than hexadecimal digits, at least two IDs must share
+	// their first digit
+	ids, nodes := testutil.RandomNodeSlice(17, t)
+	s := store.NewMemoryStore()
+	for _, node := range nodes {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	firstDigits := make(map[string]int)
+	for _, id := range ids {
+		short := id.Short()
+		if len(short) != fields.ShortLength {
+			t.Errorf("Expected short id of length %d, got %q", fields.ShortLength, short)
+		}
+		resolved, err := store.ResolveShort(s, short)
+		if err != nil {
+			t.Errorf("Failed resolving %s: %v", short, err)
+		} else if !resolved.Equals(id) {
+			t.Errorf("Expected %s to resolve to %s, got %s", short, id, resolved)
+		}
+		if resolved, err := store.ResolveShort(s, strings.ToUpper(short)); err != nil || !resolved.Equals(id) {
+			t.Errorf("Expected upper-case %s to resolve to %s, got %v (err %v)", short, id, resolved, err)
+		}
+		firstDigits[short[:1]]++
+	}
+	for digit, count := range firstDigits {
+		if count > 1 {
+			if _, err := store.ResolveShort(s, digit); !errors.Is(err, store.ErrShortIDAmbiguous) {
+				t.Errorf("Expected %s to be ambiguous, got %v", digit, err)
+			}
+		}
+	}
+	for _, digit := range "0123456789abcdef" {
+		prefix := string(digit)
+		if firstDigits[prefix] == 0 {
+			if _, err := store.ResolveShort(s, prefix); !errors.Is(err, store.ErrShortIDNotFound) {
+				t.Errorf("Expected %s to match nothing, got %v", prefix, err)
+			}
+		}
+	}
+	for _, bad := range []string{"", "xyz"} {
+		if _, err := store.ResolveShort(s, bad); err == nil {
+			t.Errorf("Expected resolving %q to fail", bad)
+		}
+	}
+}
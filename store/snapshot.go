@@ -0,0 +1,47 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+// Snapshot serializes every node in the store into the node stream format
+// (see forest.WriteNodes), ordered by creation time. LoadMemoryStore restores
+// it. Nodes that cannot be marshalled (which can only happen if a node's
+// fields were modified after it was added) are left out.
+func (m *MemoryStore) Snapshot() []byte {
+	nodes := m.nodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		return forest.ChildBefore(nodes[i].CreatedAt(), nodes[i].ID(), nodes[j].CreatedAt(), nodes[j].ID())
+	})
+	var snapshot bytes.Buffer
+	for _, node := range nodes {
+		_ = forest.WriteNode(&snapshot, node)
+	}
+	return snapshot.Bytes()
+}
+
+// LoadMemoryStore creates a MemoryStore holding the nodes in b, which must be
+// in the node stream format, such as the output of Snapshot. Nodes are not
+// validated. Since a snapshot is ordered by creation time, each node can be
+// appended to the store's indices, which is much faster than adding the nodes
+// one at a time in an arbitrary order.
+func LoadMemoryStore(b []byte) (*MemoryStore, error) {
+	m := NewMemoryStore()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	reader := forest.NewNodeReader(bytes.NewReader(b))
+	for {
+		node, err := reader.Next()
+		if err == io.EOF {
+			return m, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed reading snapshot after %d nodes: %w", len(m.Items), err)
+		}
+		m.getOrAddID(node.ID().String(), node)
+	}
+}
@@ -0,0 +1,66 @@
+package store_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestMemoryStoreSnapshot(t *testing.T) {
+	original := store.NewMemoryStore()
+	nodes := testutil.MakeTree(t, original, "root -> a -> b; a -> c; root -> d; other -> e")
+	restored, err := store.LoadMemoryStore(original.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed loading snapshot: %v", err)
+	}
+	if len(restored.Items) != len(original.Items) {
+		t.Errorf("Expected %d nodes after restoring, got %d", len(original.Items), len(restored.Items))
+	}
+	for label, node := range nodes {
+		if got, has, err := restored.Get(node.ID()); err != nil || !has {
+			t.Errorf("Expected %s to be restored, has=%v err=%v", label, has, err)
+		} else if !got.Equals(node) {
+			t.Errorf("Expected restored %s to equal the original", label)
+		}
+		expected, _ := original.Children(node.ID())
+		children, err := restored.Children(node.ID())
+		if err != nil {
+			t.Errorf("Failed listing restored children of %s: %v", label, err)
+		} else if !sameIDs(expected, children) {
+			t.Errorf("Expected children of %s to be %v, got %v", label, expected, children)
+		}
+	}
+	for _, nodeType := range []fields.NodeType{fields.NodeTypeIdentity, fields.NodeTypeCommunity, fields.NodeTypeReply} {
+		expected, _ := original.Recent(nodeType, 10)
+		recent, err := restored.Recent(nodeType, 10)
+		if err != nil {
+			t.Errorf("Failed listing restored recent nodes: %v", err)
+		} else if len(recent) != len(expected) {
+			t.Errorf("Expected %d recent nodes of type %d, got %d", len(expected), nodeType, len(recent))
+		}
+	}
+
+	if empty, err := store.LoadMemoryStore(nil); err != nil {
+		t.Errorf("Failed loading an empty snapshot: %v", err)
+	} else if len(empty.Items) != 0 {
+		t.Errorf("Expected an empty snapshot to load an empty store, got %d nodes", len(empty.Items))
+	}
+	snapshot := original.Snapshot()
+	if _, err := store.LoadMemoryStore(snapshot[:len(snapshot)-1]); err == nil {
+		t.Errorf("Expected loading a truncated snapshot to fail")
+	}
+}
+
+func sameIDs(a, b []*fields.QualifiedHash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"fmt"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+// StoreStats describes the contents of a store. Its fields are exported so
+// that it can be serialized directly for monitoring.
+type StoreStats struct {
+	// Identities, Communities, and Replies count the nodes of each type
+	Identities  int
+	Communities int
+	Replies     int
+	// Total counts all nodes, including any of unknown type
+	Total int
+	// Bytes is the total size of the store on disk. It is zero for stores
+	// that are not backed by disk.
+	Bytes int64
+}
+
+// Count records the given node in the statistics. It is useful for
+// implementing StatsReporter.
+func (s *StoreStats) Count(node forest.Node) {
+	s.Total++
+	switch node.(type) {
+	case *forest.Identity:
+		s.Identities++
+	case *forest.Community:
+		s.Communities++
+	case *forest.Reply:
+		s.Replies++
+	}
+}
+
+// StatsReporter is implemented by stores that can efficiently describe their
+// contents.
+type StatsReporter interface {
+	Stats() (StoreStats, error)
+}
+
+// Stats returns statistics about the contents of s. If s implements
+// StatsReporter, its Stats method is used. Otherwise every node in s is
+// copied into memory and counted, and the Bytes field will be zero.
+func Stats(s forest.Store) (StoreStats, error) {
+	if reporter, ok := s.(StatsReporter); ok {
+		return reporter.Stats()
+	}
+	snapshot := NewMemoryStore()
+	if err := s.CopyInto(snapshot); err != nil {
+		return StoreStats{}, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+	return snapshot.Stats()
+}
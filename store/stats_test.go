@@ -0,0 +1,48 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestStats(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	reply2, err := forest.As(identity, signer).NewReply(reply, "another", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	back := store.NewMemoryStore()
+	cache, err := store.NewCacheStore(store.NewMemoryStore(), back)
+	if err != nil {
+		t.Skipf("Failed constructing CacheStore: %v", err)
+	}
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	for _, s := range []forest.Store{cache, archive} {
+		for _, node := range []forest.Node{identity, community, reply, reply2} {
+			if err := s.Add(node); err != nil {
+				t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+			}
+		}
+	}
+
+	expected := store.StoreStats{Identities: 1, Communities: 1, Replies: 2, Total: 4}
+	for _, row := range []struct {
+		name  string
+		store forest.Store
+	}{
+		{"MemoryStore", back},
+		{"CacheStore", cache},
+		{"Archive", archive},
+	} {
+		stats, err := store.Stats(row.store)
+		if err != nil {
+			t.Errorf("Failed getting stats for %s: %v", row.name, err)
+		} else if stats != expected {
+			t.Errorf("Expected %s stats %+v, got %+v", row.name, expected, stats)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package store_test
 
 import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	forest "git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
@@ -47,9 +52,19 @@ func testStandardStoreInterface(t *testing.T, s forest.Store, storeImplName stri
 
 	// add each node
 	for _, i := range nodes {
+		if has, err := s.Has(i.ID()); err != nil {
+			t.Errorf("Empty %s Has() should not err with %s", storeImplName, err)
+		} else if has {
+			t.Errorf("Empty %s Has() should not report element %v", storeImplName, i.ID())
+		}
 		if err := s.Add(i); err != nil {
 			t.Errorf("%s Add() should not err on Add(): %s", storeImplName, err)
 		}
+		if has, err := s.Has(i.ID()); err != nil {
+			t.Errorf("%s Has() should not err with %s", storeImplName, err)
+		} else if !has {
+			t.Errorf("%s Has() should report element %v after Add()", storeImplName, i.ID())
+		}
 	}
 
 	// map each node to the getters that should be successful in fetching it
@@ -228,3 +243,404 @@ func TestCacheStoreUpPropagation(t *testing.T) {
 		}
 	}
 }
+
+// failingAddStore is a MemoryStore whose Add method always fails with err.
+type failingAddStore struct {
+	*store.MemoryStore
+	err error
+}
+
+func (f failingAddStore) Add(node forest.Node) error {
+	return f.err
+}
+
+func TestCacheStoreWritePolicy(t *testing.T) {
+	_, _, com, _ := testutil.MakeReplyOrSkip(t)
+	cacheErr := errors.New("cache full")
+	t.Run("fatal", func(t *testing.T) {
+		back := store.NewMemoryStore()
+		cache := failingAddStore{store.NewMemoryStore(), cacheErr}
+		combined, err := store.NewCacheStore(cache, back)
+		if err != nil {
+			t.Fatalf("Unexpected error constructing CacheStore: %v", err)
+		}
+		if err := combined.Add(com); !errors.Is(err, cacheErr) {
+			t.Errorf("Expected Add to fail with %v, got %v", cacheErr, err)
+		}
+		if has, _ := back.Has(com.ID()); !has {
+			t.Errorf("Expected back store to contain %v despite cache failure", com.ID())
+		}
+	})
+	t.Run("best effort", func(t *testing.T) {
+		back := store.NewMemoryStore()
+		cache := failingAddStore{store.NewMemoryStore(), cacheErr}
+		var reported []error
+		combined, err := store.NewCacheStoreWithOptions(cache, back, store.CacheStoreOptions{
+			WritePolicy: store.CacheWriteBestEffort,
+			OnCacheWriteError: func(node forest.Node, err error) {
+				if !node.ID().Equals(com.ID()) {
+					t.Errorf("Expected failure report for %v, got %v", com.ID(), node.ID())
+				}
+				reported = append(reported, err)
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error constructing CacheStore: %v", err)
+		}
+		if err := combined.Add(com); err != nil {
+			t.Errorf("Expected Add to ignore cache failure, got %v", err)
+		}
+		if len(reported) != 1 || !errors.Is(reported[0], cacheErr) {
+			t.Errorf("Expected cache failure to be reported once, got %v", reported)
+		}
+		if n, has, err := combined.Get(com.ID()); err != nil || !has || !n.Equals(com) {
+			t.Errorf("Expected CacheStore to serve %v from back store, got %v %v %v", com.ID(), n, has, err)
+		}
+	})
+}
+
+func TestMemoryStoreCopyMissingInto(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	src := store.NewMemoryStore()
+	dst := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := src.Add(node); err != nil {
+			t.Skipf("Failed adding %v to %v", node, src)
+		}
+	}
+	if err := dst.Add(identity); err != nil {
+		t.Skipf("Failed adding %v to %v", identity, dst)
+	}
+	if err := src.CopyMissingInto(dst); err != nil {
+		t.Errorf("Unexpected error copying missing nodes: %v", err)
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if _, has, err := dst.Get(node.ID()); err != nil {
+			t.Errorf("Unexpected error getting node from destination: %v", err)
+		} else if !has {
+			t.Errorf("Expected destination to contain %v", node.ID())
+		}
+	}
+}
+
+func TestCacheStoreCopyMissingIntoFallback(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	back := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := back.Add(node); err != nil {
+			t.Skipf("Failed adding %v to %v", node, back)
+		}
+	}
+	// a read-only store does not implement CopyMissingInto
+	cache, err := store.NewCacheStore(store.NewMemoryStore(), store.ReadOnly(back))
+	if err != nil {
+		t.Fatalf("Failed creating CacheStore: %v", err)
+	}
+	observer := &recordingObserver{}
+	dst := store.NewInstrumentedStore(store.NewMemoryStore(), observer)
+	if err := dst.Add(identity); err != nil {
+		t.Skipf("Failed adding %v to %v", identity, dst)
+	}
+	if err := cache.CopyMissingInto(dst); err != nil {
+		t.Errorf("Unexpected error copying missing nodes: %v", err)
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if has, err := dst.Has(node.ID()); err != nil || !has {
+			t.Errorf("Expected destination to contain %v, got %v %v", node.ID(), has, err)
+		}
+	}
+	if observer.adds != 3 {
+		t.Errorf("Expected only the 2 missing nodes to be added, got %d additions", observer.adds-1)
+	}
+}
+
+// unsignedSigner produces placeholder signatures so that large numbers of
+// nodes can be built quickly. The resulting nodes do not have valid signatures.
+type unsignedSigner struct{}
+
+func (unsignedSigner) Sign(data []byte) ([]byte, error) {
+	return []byte("unsigned"), nil
+}
+
+func (unsignedSigner) PublicKey() ([]byte, error) {
+	return []byte("unsigned"), nil
+}
+
+func (unsignedSigner) KeyType() fields.KeyType {
+	return fields.KeyTypeOpenPGPRSA
+}
+
+func (unsignedSigner) SignatureType() fields.SignatureType {
+	return fields.SignatureTypeOpenPGPRSA
+}
+
+// makeTimedReplies creates count replies to a single community. Reply i is
+// created at base plus i/tiesPer seconds, so every group of tiesPer
+// consecutive replies shares a creation time.
+func makeTimedReplies(tb testing.TB, count, tiesPer int) []forest.Node {
+	signer := unsignedSigner{}
+	identity, err := forest.NewIdentity(signer, "timed", []byte{})
+	if err != nil {
+		tb.Fatalf("Failed creating identity: %v", err)
+	}
+	builder := forest.As(identity, signer)
+	community, err := builder.NewCommunity("timed", []byte{})
+	if err != nil {
+		tb.Fatalf("Failed creating community: %v", err)
+	}
+	base := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	replies := make([]forest.Node, count)
+	for i := range replies {
+		created := fields.TimestampFrom(base.Add(time.Duration(i/tiesPer) * time.Second))
+		reply, err := builder.WithCreated(created).NewReply(community, testutil.RandomString(8), []byte{})
+		if err != nil {
+			tb.Fatalf("Failed creating reply: %v", err)
+		}
+		replies[i] = reply
+	}
+	return replies
+}
+
+func TestMemoryStoreRecentOrdering(t *testing.T) {
+	replies := makeTimedReplies(t, 9, 3)
+	s := store.NewMemoryStore()
+	// insert the groups of tied replies newest first to ensure that the
+	// order of the index does not depend upon insertion order across groups
+	for group := 2; group >= 0; group-- {
+		for _, reply := range replies[group*3 : group*3+3] {
+			if err := s.Add(reply); err != nil {
+				t.Fatalf("Failed adding reply: %v", err)
+			}
+		}
+	}
+	expected := append(append(append([]forest.Node{}, replies[6:9]...), replies[3:6]...), replies[0:3]...)
+	recent, err := s.Recent(fields.NodeTypeReply, len(replies))
+	if err != nil {
+		t.Fatalf("Recent failed on valid input: %v", err)
+	}
+	for i := range expected {
+		if !recent[i].Equals(expected[i]) {
+			t.Errorf("Expected Recent()[%d] to be %v, got %v", i, expected[i].ID(), recent[i].ID())
+		}
+	}
+
+	if err := s.RemoveSubtree(replies[7].ID()); err != nil {
+		t.Fatalf("Failed removing reply: %v", err)
+	}
+	recent, err = s.Recent(fields.NodeTypeReply, 2)
+	if err != nil {
+		t.Fatalf("Recent failed on valid input: %v", err)
+	}
+	if len(recent) != 2 || !recent[0].Equals(replies[6]) || !recent[1].Equals(replies[8]) {
+		t.Errorf("Expected removed reply to be absent from Recent()")
+	}
+}
+
+// scanRecent finds the most recent nodes of the given type by scanning and
+// sorting every node in the store, as MemoryStore.Recent did before it kept
+// an index. It exists only as a baseline for benchmarks.
+func scanRecent(s *store.MemoryStore, nodeType fields.NodeType, quantity int) []forest.Node {
+	candidates := []forest.Node{}
+	for _, node := range s.Items {
+		if n, ok := node.(*forest.Reply); ok && nodeType == fields.NodeTypeReply {
+			candidates = append(candidates, n)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].(*forest.Reply).Created > candidates[j].(*forest.Reply).Created
+	})
+	if len(candidates) > quantity {
+		candidates = candidates[:quantity]
+	}
+	return candidates
+}
+
+func benchmarkRecent(b *testing.B, recent func(*store.MemoryStore) []forest.Node) {
+	s := store.NewMemoryStore()
+	for _, reply := range makeTimedReplies(b, 100000, 10) {
+		if err := s.Add(reply); err != nil {
+			b.Fatalf("Failed adding reply: %v", err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recent(s)
+	}
+}
+
+func BenchmarkMemoryStoreRecentScan(b *testing.B) {
+	benchmarkRecent(b, func(s *store.MemoryStore) []forest.Node {
+		return scanRecent(s, fields.NodeTypeReply, 50)
+	})
+}
+
+func BenchmarkMemoryStoreRecentIndexed(b *testing.B) {
+	benchmarkRecent(b, func(s *store.MemoryStore) []forest.Node {
+		nodes, _ := s.Recent(fields.NodeTypeReply, 50)
+		return nodes
+	})
+}
+
+// testConcurrentGetOrAdd races many goroutines to add the same node to s and
+// ensures that exactly one of them added it.
+func testConcurrentGetOrAdd(t *testing.T, s store.ReadWriteStore, node forest.Node) {
+	var (
+		wg    sync.WaitGroup
+		added int32
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stored, wasAdded, err := s.GetOrAdd(node)
+			if err != nil {
+				t.Errorf("GetOrAdd failed on valid input: %v", err)
+				return
+			} else if !stored.Equals(node) {
+				t.Errorf("Expected GetOrAdd to return %v, got %v", node.ID(), stored.ID())
+			}
+			if wasAdded {
+				atomic.AddInt32(&added, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if added != 1 {
+		t.Errorf("Expected exactly one GetOrAdd to add the node, %d did", added)
+	}
+}
+
+func TestMemoryStoreGetOrAdd(t *testing.T) {
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	testConcurrentGetOrAdd(t, store.NewMemoryStore(), community)
+}
+
+func TestMemoryStoreChildrenPage(t *testing.T) {
+	replies := makeTimedReplies(t, 5, 1)
+	s := store.NewMemoryStore()
+	for _, reply := range replies {
+		if err := s.Add(reply); err != nil {
+			t.Fatalf("Failed adding reply: %v", err)
+		}
+	}
+	parent := replies[0].ParentID()
+	if count, err := s.ChildrenCount(parent); err != nil {
+		t.Errorf("ChildrenCount failed on valid input: %v", err)
+	} else if count != len(replies) {
+		t.Errorf("Expected %d children, got %d", len(replies), count)
+	}
+	for _, run := range []struct {
+		offset, limit int
+		expected      []forest.Node
+	}{
+		{0, 2, replies[0:2]},
+		{2, 2, replies[2:4]},
+		{4, 2, replies[4:5]},
+		{5, 2, nil},
+		{0, 100, replies},
+	} {
+		page, err := s.ChildrenPage(parent, run.offset, run.limit)
+		if err != nil {
+			t.Errorf("ChildrenPage(%d, %d) failed on valid input: %v", run.offset, run.limit, err)
+			continue
+		}
+		if len(page) != len(run.expected) {
+			t.Errorf("Expected ChildrenPage(%d, %d) to return %d children, got %d", run.offset, run.limit, len(run.expected), len(page))
+			continue
+		}
+		for i := range page {
+			if !page[i].Equals(run.expected[i].ID()) {
+				t.Errorf("Expected ChildrenPage(%d, %d)[%d] to be %v, got %v", run.offset, run.limit, i, run.expected[i].ID(), page[i])
+			}
+		}
+	}
+	if _, err := s.ChildrenPage(parent, -1, 2); err == nil {
+		t.Errorf("Expected ChildrenPage to fail with negative offset")
+	}
+}
+
+func TestMemoryStoreRemoveDeepSubtree(t *testing.T) {
+	const depth = 10000
+	signer := unsignedSigner{}
+	identity, err := forest.NewIdentity(signer, "deep", []byte{})
+	if err != nil {
+		t.Fatalf("Failed creating identity: %v", err)
+	}
+	builder := forest.As(identity, signer)
+	community, err := builder.NewCommunity("deep", []byte{})
+	if err != nil {
+		t.Fatalf("Failed creating community: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community} {
+		if err := s.Add(node); err != nil {
+			t.Fatalf("Failed adding node: %v", err)
+		}
+	}
+	siblings, err := builder.NewReplies(community, []string{"first", "second", "third"}, []byte{})
+	if err != nil {
+		t.Fatalf("Failed creating replies: %v", err)
+	}
+	var parent forest.Node = siblings[1]
+	for _, reply := range siblings {
+		if err := s.Add(reply); err != nil {
+			t.Fatalf("Failed adding node: %v", err)
+		}
+	}
+	for i := 1; i < depth; i++ {
+		reply, err := builder.NewReply(parent, "deeper", []byte{})
+		if err != nil {
+			t.Fatalf("Failed creating reply at depth %d: %v", i+1, err)
+		}
+		if err := s.Add(reply); err != nil {
+			t.Fatalf("Failed adding node: %v", err)
+		}
+		parent = reply
+	}
+
+	if err := s.RemoveSubtree(siblings[1].ID()); err != nil {
+		t.Fatalf("Failed removing deep subtree: %v", err)
+	}
+	if len(s.Items) != 4 {
+		t.Errorf("Expected only the identity, community, and two replies to remain, got %d nodes", len(s.Items))
+	}
+	children, err := s.Children(community.ID())
+	if err != nil {
+		t.Fatalf("Failed listing children: %v", err)
+	}
+	if len(children) != 2 || !children[0].Equals(siblings[0].ID()) || !children[1].Equals(siblings[2].ID()) {
+		t.Errorf("Expected the removed reply's siblings to remain in order, got %v", children)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	s := store.NewMemoryStore()
+	nodes := testutil.MakeTree(t, s, "root -> a; root -> b")
+	absent := testutil.MakeTree(t, store.NewMemoryStore(), "absent")
+	ids := []*fields.QualifiedHash{nodes["a"].ID(), nodes["b"].ID(), absent["absent"].ID()}
+	for name, getMany := range map[string]func([]*fields.QualifiedHash) (map[string]forest.Node, error){
+		"MemoryStore": s.GetMany,
+		// a CacheStore does not implement BatchGetter, so this exercises
+		// the fallback
+		"fallback": func(ids []*fields.QualifiedHash) (map[string]forest.Node, error) {
+			cache, err := store.NewCacheStore(store.NewMemoryStore(), s)
+			if err != nil {
+				return nil, err
+			}
+			return store.GetMany(cache, ids)
+		},
+	} {
+		found, err := getMany(ids)
+		if err != nil {
+			t.Fatalf("%s: failed looking up batch of nodes: %v", name, err)
+		}
+		if len(found) != 2 {
+			t.Errorf("%s: expected 2 nodes, got %d", name, len(found))
+		}
+		for _, label := range []string{"a", "b"} {
+			if node, present := found[nodes[label].ID().String()]; !present || !node.ID().Equals(nodes[label].ID()) {
+				t.Errorf("%s: expected batch to include %s", name, label)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// ErrMissingParent is returned (wrapped) by StrictStore.Add when the parent of
+// the node is not in the store.
+var ErrMissingParent = errors.New("parent node is not in store")
+
+// ErrBufferFull is returned (wrapped) when a store cannot hold a node until
+// the nodes that it depends on are added, because it is already holding as
+// many nodes as it may.
+var ErrBufferFull = errors.New("buffer of waiting nodes is full")
+
+// MaxOrphans is the greatest number of nodes that a StrictStore will buffer
+// while waiting for their parents.
+const MaxOrphans = 4096
+
+// StrictStore wraps a forest.Store and only accepts nodes whose parent is
+// already present, which keeps the wrapped store internally consistent. Nodes
+// that arrive before their parents (as may happen while syncing) can be held
+// with AddOrBuffer until their parents are added. At most MaxOrphans nodes
+// are buffered at once. It is safe for concurrent use if the wrapped store is.
+type StrictStore struct {
+	forest.Store
+	// orphans maps the ID of each missing parent to the buffered nodes that
+	// are waiting for it
+	orphans map[string][]forest.Node
+	// orphanCount is the total number of nodes in orphans
+	orphanCount int
+	// mutex serializes additions and guards orphans
+	mutex sync.Mutex
+}
+
+var _ forest.Store = &StrictStore{}
+var _ Reindexer = &StrictStore{}
+
+// NewStrictStore creates a StrictStore that wraps s.
+func NewStrictStore(s forest.Store) *StrictStore {
+	return &StrictStore{
+		Store:   s,
+		orphans: make(map[string][]forest.Node),
+	}
+}
+
+// Add inserts the node into the wrapped store if its parent is the null hash
+// (as for identities and communities) or is already present. Otherwise it
+// returns an error wrapping ErrMissingParent. Adding a node also adds any
+// buffered nodes waiting for it.
+func (s *StrictStore) Add(node forest.Node) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.checkParent(node); err != nil {
+		return err
+	}
+	return s.addAndFlush(node)
+}
+
+// AddOrBuffer works like Add, but if the node's parent is missing the node is
+// held in memory instead of being rejected. Buffered nodes are added to the
+// wrapped store as soon as their parents are added. The buffered return value
+// reports whether the node was buffered. If MaxOrphans nodes are already
+// buffered, a node whose parent is missing is rejected with an error wrapping
+// ErrBufferFull instead; the nodes already buffered are kept.
+func (s *StrictStore) AddOrBuffer(node forest.Node) (buffered bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.checkParent(node); errors.Is(err, ErrMissingParent) {
+		if s.orphanCount >= MaxOrphans {
+			return false, fmt.Errorf("cannot buffer %s without parent %s: %w", node.ID(), node.ParentID(), ErrBufferFull)
+		}
+		s.buffer(node)
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return false, s.addAndFlush(node)
+}
+
+// Orphans returns the buffered nodes whose parents have not been added yet.
+func (s *StrictStore) Orphans() []forest.Node {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	orphans := []forest.Node{}
+	for _, nodes := range s.orphans {
+		orphans = append(orphans, nodes...)
+	}
+	return orphans
+}
+
+// checkParent returns an error wrapping ErrMissingParent if the node's parent
+// is not the null hash and is not in the wrapped store.
+func (s *StrictStore) checkParent(node forest.Node) error {
+	parentID := node.ParentID()
+	if parentID.Equals(fields.NullHash()) {
+		return nil
+	}
+	if has, err := s.Store.Has(parentID); err != nil {
+		return fmt.Errorf("failed checking for parent %s: %w", parentID, err)
+	} else if !has {
+		return fmt.Errorf("cannot add %s without parent %s: %w", node.ID(), parentID, ErrMissingParent)
+	}
+	return nil
+}
+
+// addAndFlush adds the node to the wrapped store followed by every buffered
+// descendant of it. If adding a descendant fails, it and any others not yet
+// added remain buffered. The caller must hold the mutex.
+func (s *StrictStore) addAndFlush(node forest.Node) error {
+	queue := []forest.Node{node}
+	for len(queue) > 0 {
+		current := queue[0]
+		if err := s.Store.Add(current); err != nil {
+			if current != node {
+				s.buffer(queue...)
+			}
+			return err
+		}
+		queue = queue[1:]
+		id := current.ID().String()
+		queue = append(queue, s.orphans[id]...)
+		s.orphanCount -= len(s.orphans[id])
+		delete(s.orphans, id)
+	}
+	return nil
+}
+
+// buffer holds the given nodes until their parents are added. The caller must
+// hold the mutex.
+func (s *StrictStore) buffer(nodes ...forest.Node) {
+	for _, node := range nodes {
+		parentID := node.ParentID().String()
+		s.orphans[parentID] = append(s.orphans[parentID], node)
+	}
+	s.orphanCount += len(nodes)
+}
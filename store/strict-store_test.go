@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestStrictStore(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	child, err := forest.As(identity, signer).NewReply(reply, "child", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	backing := store.NewMemoryStore()
+	s := store.NewStrictStore(backing)
+
+	if err := s.Add(identity); err != nil {
+		t.Errorf("Expected identity with null parent to be accepted: %v", err)
+	}
+	if err := s.Add(reply); !errors.Is(err, store.ErrMissingParent) {
+		t.Errorf("Expected reply without parent to fail with ErrMissingParent, got %v", err)
+	}
+	if has, _ := backing.Has(reply.ID()); has {
+		t.Errorf("Expected rejected reply not to be added")
+	}
+
+	// buffer the child before its parent to ensure flushing is transitive
+	for _, node := range []forest.Node{child, reply} {
+		if buffered, err := s.AddOrBuffer(node); err != nil {
+			t.Errorf("Failed buffering %v: %v", node.ID(), err)
+		} else if !buffered {
+			t.Errorf("Expected %v to be buffered", node.ID())
+		}
+	}
+	if orphans := s.Orphans(); len(orphans) != 2 {
+		t.Errorf("Expected 2 orphans, got %d", len(orphans))
+	}
+
+	if buffered, err := s.AddOrBuffer(community); err != nil {
+		t.Errorf("Failed adding %v: %v", community.ID(), err)
+	} else if buffered {
+		t.Errorf("Expected community with null parent not to be buffered")
+	}
+	for _, node := range []forest.Node{community, reply, child} {
+		if has, _ := backing.Has(node.ID()); !has {
+			t.Errorf("Expected %v to be in the wrapped store", node.ID())
+		}
+	}
+	if orphans := s.Orphans(); len(orphans) != 0 {
+		t.Errorf("Expected no orphans after adding their ancestor, got %d", len(orphans))
+	}
+}
+
+func TestStrictStoreMaxOrphans(t *testing.T) {
+	replies := makeTimedReplies(t, store.MaxOrphans+1, 1)
+	s := store.NewStrictStore(store.NewMemoryStore())
+	for _, reply := range replies[:store.MaxOrphans] {
+		if _, err := s.AddOrBuffer(reply); err != nil {
+			t.Fatalf("Failed buffering %v: %v", reply.ID(), err)
+		}
+	}
+	extra := replies[store.MaxOrphans]
+	if buffered, err := s.AddOrBuffer(extra); !errors.Is(err, store.ErrBufferFull) {
+		t.Errorf("Expected ErrBufferFull buffering beyond MaxOrphans, got %v", err)
+	} else if buffered {
+		t.Errorf("Expected node beyond MaxOrphans not to be buffered")
+	}
+	if orphans := s.Orphans(); len(orphans) != store.MaxOrphans {
+		t.Errorf("Expected %d orphans to be kept, got %d", store.MaxOrphans, len(orphans))
+	}
+}
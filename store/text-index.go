@@ -0,0 +1,129 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// TextIndex is an inverted index over the words in the content of the replies
+// within an ExtendedStore. It subscribes to the store so that replies are
+// indexed as they are added. It is safe for concurrent use.
+//
+// Content is split into words at every character that is not a letter or a
+// digit, and words are compared without regard to case.
+type TextIndex struct {
+	store        ExtendedStore
+	subscription Subscription
+	mutex        sync.RWMutex
+	// words maps each word to the set of IDs of replies containing it
+	words map[string]map[string]*fields.QualifiedHash
+	// indexed holds the IDs of all replies that have been indexed
+	indexed map[string]struct{}
+}
+
+// NewTextIndex creates a TextIndex over every reply that is already in s and
+// every reply added to s afterward. Call Close to stop indexing new replies.
+func NewTextIndex(s ExtendedStore) (*TextIndex, error) {
+	t := &TextIndex{
+		store:   s,
+		words:   make(map[string]map[string]*fields.QualifiedHash),
+		indexed: make(map[string]struct{}),
+	}
+	// subscribe before taking a snapshot so that no reply can be missed
+	t.subscription = s.SubscribeToNewMessages(t.add)
+	snapshot := NewMemoryStore()
+	if err := s.CopyInto(snapshot); err != nil {
+		s.UnsubscribeToNewMessages(t.subscription)
+		return nil, fmt.Errorf("failed listing existing nodes: %w", err)
+	}
+	for _, node := range snapshot.nodes() {
+		t.add(node)
+	}
+	return t, nil
+}
+
+// Close stops the index from tracking replies added to its store. The
+// existing index remains searchable.
+func (t *TextIndex) Close() {
+	t.store.UnsubscribeToNewMessages(t.subscription)
+}
+
+// tokenize splits text into lowercase words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes the words in the content of node if it is a reply that has not
+// already been indexed. Other nodes are ignored.
+func (t *TextIndex) add(node forest.Node) {
+	reply, isReply := node.(*forest.Reply)
+	if !isReply {
+		return
+	}
+	var content []byte
+	switch reply.Content.Descriptor.Type {
+	case fields.ContentTypeUTF8String:
+		content = reply.Content.Blob
+	case fields.ContentTypeGzipUTF8:
+		raw, err := reply.Content.Decompressed()
+		if err != nil {
+			return
+		}
+		content = raw
+	default:
+		return
+	}
+	id := reply.ID()
+	idString := id.String()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, done := t.indexed[idString]; done {
+		return
+	}
+	t.indexed[idString] = struct{}{}
+	for _, word := range tokenize(string(content)) {
+		ids, ok := t.words[word]
+		if !ok {
+			ids = make(map[string]*fields.QualifiedHash)
+			t.words[word] = ids
+		}
+		ids[idString] = id
+	}
+}
+
+// Search returns the IDs of the replies whose content contains every word in
+// query, sorted by the text form of their IDs. Only whole words match, so a
+// query of "for" will not find a reply containing only "forest".
+func (t *TextIndex) Search(query string) ([]*fields.QualifiedHash, error) {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("query %q contains no words", query)
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	results := []*fields.QualifiedHash{}
+	for idString, id := range t.words[words[0]] {
+		matchesAll := true
+		for _, word := range words[1:] {
+			if _, contains := t.words[word][idString]; !contains {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			results = append(results, id)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].String() < results[j].String()
+	})
+	return results, nil
+}
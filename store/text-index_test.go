@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestTextIndex(t *testing.T) {
+	identity, signer, community, existing := testutil.MakeReplyOrSkip(t)
+	archive := store.NewArchive(store.NewMemoryStore())
+	defer archive.Destroy()
+	for _, node := range []forest.Node{identity, community, existing} {
+		if err := archive.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	index, err := store.NewTextIndex(archive)
+	if err != nil {
+		t.Fatalf("Failed creating text index: %v", err)
+	}
+	defer index.Close()
+
+	builder := forest.As(identity, signer)
+	first, err := builder.NewReply(community, "The quick brown fox", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	second, err := builder.NewReply(first, "a QUICK reply, with punctuation!", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	for _, node := range []forest.Node{first, second} {
+		if err := archive.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+
+	for _, row := range []struct {
+		query    string
+		expected []*fields.QualifiedHash
+	}{
+		{"content", []*fields.QualifiedHash{existing.ID()}},
+		{"quick", []*fields.QualifiedHash{first.ID(), second.ID()}},
+		{"Quick fox", []*fields.QualifiedHash{first.ID()}},
+		{"punctuation", []*fields.QualifiedHash{second.ID()}},
+		{"qui", []*fields.QualifiedHash{}},
+		// community names are not indexed
+		{"community", []*fields.QualifiedHash{}},
+	} {
+		results, err := index.Search(row.query)
+		if err != nil {
+			t.Errorf("Failed searching for %q: %v", row.query, err)
+			continue
+		}
+		if len(results) != len(row.expected) {
+			t.Errorf("Expected %d results for %q, got %v", len(row.expected), row.query, results)
+			continue
+		}
+		for _, id := range row.expected {
+			if !containsID(results, id) {
+				t.Errorf("Expected results for %q to contain %v, got %v", row.query, id, results)
+			}
+		}
+	}
+	if _, err := index.Search(" ,. "); err == nil {
+		t.Errorf("Expected error searching for a query without words")
+	}
+
+	index.Close()
+	late, err := builder.NewReply(community, "quick but late", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	if err := archive.Add(late); err != nil {
+		t.Skipf("Failed adding %v to store: %v", late.ID(), err)
+	}
+	if results, err := index.Search("late"); err != nil || len(results) != 0 {
+		t.Errorf("Expected closed index to ignore new replies, got %v (err %v)", results, err)
+	}
+}
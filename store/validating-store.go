@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+)
+
+const (
+	// MaxPendingPerAuthor is the greatest number of nodes that a
+	// ValidatingStore will defer while waiting for any one author.
+	MaxPendingPerAuthor = 256
+	// MaxPending is the greatest number of nodes that a ValidatingStore will
+	// defer while waiting for their authors.
+	MaxPending = 4096
+)
+
+// ValidatingStore wraps a forest.Store and only adds nodes to it that pass
+// validation, so that forged or malformed nodes never reach the wrapped store.
+// Every node must have an ID matching its content and pass ValidateShallow.
+// If the node's author is in the wrapped store (or the node is an identity,
+// which signs itself), it must also pass ValidateDeep and carry a valid
+// signature by its author.
+//
+// A node whose author is not yet in the wrapped store cannot have its
+// signature checked, so its deep validation is deferred: Add holds it in
+// memory (see Pending) and returns nil without storing it. When the author is
+// added, the deferred nodes are fully validated in the order that they were
+// added, and those that pass are stored. Those that fail (including those
+// whose other references are still missing) are discarded, since there is no
+// longer a caller to report the failure to. Nodes other than authors should
+// therefore still be added parents-first.
+//
+// Since the signatures of deferred nodes cannot be checked, anyone can supply
+// them, so the number held is limited: at most MaxPendingPerAuthor for each
+// missing author and MaxPending in total. Once a limit is reached, Add
+// rejects further nodes that would be deferred with an error wrapping
+// ErrBufferFull, and keeps the nodes already deferred.
+//
+// It is safe for concurrent use if the wrapped store is.
+type ValidatingStore struct {
+	forest.Store
+	// pending maps the ID of each missing author to the nodes waiting for it
+	pending map[string][]forest.Node
+	// pendingCount is the total number of nodes in pending
+	pendingCount int
+	// mutex serializes additions and guards pending
+	mutex sync.Mutex
+}
+
+var _ forest.Store = &ValidatingStore{}
+var _ Reindexer = &ValidatingStore{}
+
+// NewValidatingStore creates a ValidatingStore that wraps s.
+func NewValidatingStore(s forest.Store) *ValidatingStore {
+	return &ValidatingStore{
+		Store:   s,
+		pending: make(map[string][]forest.Node),
+	}
+}
+
+// Add validates the node and inserts it into the wrapped store, returning the
+// validation error instead if it is invalid. If the node's author is not in
+// the wrapped store, the node is deferred until the author is added.
+func (s *ValidatingStore) Add(node forest.Node) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, isIdentity := node.(*forest.Identity); !isIdentity {
+		if has, err := s.Store.Has(node.AuthorID()); err != nil {
+			return fmt.Errorf("failed checking for author %s: %w", node.AuthorID(), err)
+		} else if !has {
+			return s.deferNode(node)
+		}
+	}
+	if err := forest.ValidateDeepCached(node, s.Store, nil); err != nil {
+		return fmt.Errorf("rejected node %s: %w", node.ID(), err)
+	}
+	if err := s.Store.Add(node); err != nil {
+		return err
+	}
+	return s.flush(node)
+}
+
+// Pending returns the nodes whose validation is deferred until their authors
+// are added.
+func (s *ValidatingStore) Pending() []forest.Node {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending := []forest.Node{}
+	for _, nodes := range s.pending {
+		pending = append(pending, nodes...)
+	}
+	return pending
+}
+
+// deferNode checks the parts of the node that do not depend on any other node
+// and holds it until its author is added. The caller must hold the mutex.
+func (s *ValidatingStore) deferNode(node forest.Node) error {
+	hashable, ok := node.(forest.Hashable)
+	if !ok {
+		return fmt.Errorf("rejected node %s: node of type %T cannot be hashed", node.ID(), node)
+	}
+	if valid, err := forest.ValidateID(hashable, *node.ID()); err != nil {
+		return fmt.Errorf("rejected node %s: failed validating id: %w", node.ID(), err)
+	} else if !valid {
+		return fmt.Errorf("rejected node %s: id does not match node content", node.ID())
+	}
+	if err := node.ValidateShallow(); err != nil {
+		return fmt.Errorf("rejected node %s: %w", node.ID(), err)
+	}
+	author := node.AuthorID().String()
+	if len(s.pending[author]) >= MaxPendingPerAuthor {
+		return fmt.Errorf("cannot defer %s: %d nodes already wait for author %s: %w", node.ID(), MaxPendingPerAuthor, author, ErrBufferFull)
+	} else if s.pendingCount >= MaxPending {
+		return fmt.Errorf("cannot defer %s: %d nodes already wait for their authors: %w", node.ID(), MaxPending, ErrBufferFull)
+	}
+	s.pending[author] = append(s.pending[author], node)
+	s.pendingCount++
+	return nil
+}
+
+// flush validates and stores the nodes deferred until the given node (which
+// must already be stored) was added, discarding those that are invalid. The
+// caller must hold the mutex.
+func (s *ValidatingStore) flush(author forest.Node) error {
+	id := author.ID().String()
+	waiting := s.pending[id]
+	s.pendingCount -= len(waiting)
+	delete(s.pending, id)
+	for _, node := range waiting {
+		if err := forest.ValidateDeepCached(node, s.Store, nil); err != nil {
+			continue
+		}
+		if err := s.Store.Add(node); err != nil {
+			return fmt.Errorf("failed adding deferred node %s: %w", node.ID(), err)
+		}
+	}
+	return nil
+}
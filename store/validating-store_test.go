@@ -0,0 +1,151 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestValidatingStore(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	// claims to be by identity, but is signed with another key
+	forged, err := forest.As(identity, testkeys.Signer(t, testkeys.PrivKey2)).NewReply(community, "forged", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	// has a valid signature, but was modified afterward
+	tampered, err := forest.As(identity, signer).NewReply(community, "original", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	tampered.Content.Blob = []byte("tampered")
+	backing := store.NewMemoryStore()
+	s := store.NewValidatingStore(backing)
+
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Errorf("Expected valid node %v to be accepted: %v", node.ID(), err)
+		}
+	}
+	for _, node := range []forest.Node{forged, tampered} {
+		if err := s.Add(node); err == nil {
+			t.Errorf("Expected invalid node %v to be rejected", node.ID())
+		}
+		if has, _ := backing.Has(node.ID()); has {
+			t.Errorf("Expected rejected node %v not to be stored", node.ID())
+		}
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if has, _ := backing.Has(node.ID()); !has {
+			t.Errorf("Expected %v to be in the wrapped store", node.ID())
+		}
+	}
+	if pending := s.Pending(); len(pending) != 0 {
+		t.Errorf("Expected no pending nodes, got %d", len(pending))
+	}
+}
+
+func TestValidatingStoreDeferred(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	forged, err := forest.As(identity, testkeys.Signer(t, testkeys.PrivKey2)).NewReply(community, "forged", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	backing := store.NewMemoryStore()
+	s := store.NewValidatingStore(backing)
+
+	// nodes arriving before their author are deferred rather than rejected
+	for _, node := range []forest.Node{community, reply, forged} {
+		if err := s.Add(node); err != nil {
+			t.Errorf("Expected %v to be deferred, got %v", node.ID(), err)
+		}
+		if has, _ := backing.Has(node.ID()); has {
+			t.Errorf("Expected deferred node %v not to be stored yet", node.ID())
+		}
+	}
+	if pending := s.Pending(); len(pending) != 3 {
+		t.Errorf("Expected 3 pending nodes, got %d", len(pending))
+	}
+
+	if err := s.Add(identity); err != nil {
+		t.Fatalf("Failed adding author: %v", err)
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if has, _ := backing.Has(node.ID()); !has {
+			t.Errorf("Expected %v to be stored once its author was added", node.ID())
+		}
+	}
+	if has, _ := backing.Has(forged.ID()); has {
+		t.Errorf("Expected deferred forgery to be discarded")
+	}
+	if pending := s.Pending(); len(pending) != 0 {
+		t.Errorf("Expected no pending nodes after adding their author, got %d", len(pending))
+	}
+}
+
+func TestValidatingStorePendingLimits(t *testing.T) {
+	// each author has a community and enough replies to it to reach
+	// MaxPendingPerAuthor, and there is one more author than fits within
+	// MaxPending
+	authors := store.MaxPending/store.MaxPendingPerAuthor + 1
+	identities := make([]*forest.Identity, authors)
+	nodes := make([][]forest.Node, authors)
+	for i := range identities {
+		// the first author is real so that it can be added later
+		var signer forest.Signer = unsignedSigner{}
+		if i == 0 {
+			signer = testkeys.Signer(t, testkeys.PrivKey1)
+		}
+		identity, err := forest.NewIdentity(signer, fmt.Sprintf("author-%d", i), []byte{})
+		if err != nil {
+			t.Skipf("Failed creating identity: %v", err)
+		}
+		builder := forest.As(identity, signer)
+		community, err := builder.NewCommunity("pending", []byte{})
+		if err != nil {
+			t.Fatalf("Failed creating community: %v", err)
+		}
+		replies, err := builder.NewReplies(community, make([]string, store.MaxPendingPerAuthor), []byte{})
+		if err != nil {
+			t.Fatalf("Failed creating replies: %v", err)
+		}
+		identities[i] = identity
+		nodes[i] = []forest.Node{community}
+		for _, reply := range replies {
+			nodes[i] = append(nodes[i], reply)
+		}
+	}
+	s := store.NewValidatingStore(store.NewMemoryStore())
+
+	for i := 0; i < authors-1; i++ {
+		for _, node := range nodes[i][:store.MaxPendingPerAuthor] {
+			if err := s.Add(node); err != nil {
+				t.Fatalf("Expected %v to be deferred, got %v", node.ID(), err)
+			}
+		}
+		extra := nodes[i][store.MaxPendingPerAuthor]
+		if err := s.Add(extra); !errors.Is(err, store.ErrBufferFull) {
+			t.Errorf("Expected ErrBufferFull deferring beyond MaxPendingPerAuthor, got %v", err)
+		}
+	}
+	if pending := s.Pending(); len(pending) != store.MaxPending {
+		t.Errorf("Expected %d pending nodes, got %d", store.MaxPending, len(pending))
+	}
+	last := nodes[authors-1][0]
+	if err := s.Add(last); !errors.Is(err, store.ErrBufferFull) {
+		t.Errorf("Expected ErrBufferFull deferring beyond MaxPending, got %v", err)
+	}
+
+	// adding an author releases its deferred nodes, making room for more
+	if err := s.Add(identities[0]); err != nil {
+		t.Fatalf("Failed adding author: %v", err)
+	}
+	if err := s.Add(last); err != nil {
+		t.Errorf("Expected %v to be deferred once there was room, got %v", last.ID(), err)
+	}
+}
@@ -1,16 +1,23 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 
 	"git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
 )
 
+// ErrCycle is returned (wrapped) by Walk when the store claims that a node
+// is its own descendant. This can only happen if the store is corrupt.
+var ErrCycle = errors.New("cycle detected in node hierarchy")
+
 // Walk traverses the subtree rooted at start in a breadth-first fashion invoking the
 // visitor function on each node id in the subtree. The traversal stops either
 // when the visitor function returns non-nil or when the entire subtree
-// rooted at start has been visited.
+// rooted at start has been visited. Each node is visited at most once. If
+// a node is encountered a second time, the store contains a cycle and Walk
+// returns an error wrapping ErrCycle.
 //
 // If the visitor function returns an error, it will be returned wrapped and
 // can be checked for using the errors.Is or errors.As standard library
@@ -27,9 +34,14 @@ func Walk(s forest.Store, start *fields.QualifiedHash, visitor func(*fields.Qual
 	}
 
 	childQueue := []*fields.QualifiedHash{start}
+	visited := make(map[string]struct{})
 	var current *fields.QualifiedHash
 	for len(childQueue) > 0 {
 		current, childQueue = childQueue[0], childQueue[1:]
+		if _, seen := visited[current.String()]; seen {
+			return fmt.Errorf("node %s reached more than once: %w", current, ErrCycle)
+		}
+		visited[current.String()] = struct{}{}
 		err := visitor(current)
 		if err != nil {
 			return fmt.Errorf("visitor function errored on %s: %w", current, err)
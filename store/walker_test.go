@@ -3,11 +3,10 @@ package store_test
 import (
 	"errors"
 	"fmt"
-	"math/rand"
 	"sort"
-	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"git.sr.ht/~whereswaldon/forest-go"
 	"git.sr.ht/~whereswaldon/forest-go/fields"
@@ -16,31 +15,13 @@ import (
 )
 
 func prep(t *testing.T) (s forest.Store, root *fields.QualifiedHash, ids []*fields.QualifiedHash) {
-	id, signer, community, reply := testutil.MakeReplyOrSkip(t)
-	builder := forest.Builder{
-		User:   id,
-		Signer: signer,
-	}
-
 	s = store.NewMemoryStore()
-	s.Add(id)
-
-	nodes := []forest.Node{community, reply}
-	for i := 0; i < 10; i++ {
-		parent := nodes[rand.Intn(len(nodes))]
-		n, err := builder.NewReply(parent, strconv.Itoa(i), []byte{})
-		if err != nil {
-			t.Errorf("failed generating test node: %v", err)
-		}
-		nodes = append(nodes, n)
-	}
+	nodes := testutil.MakeTree(t, s, "root -> a -> b -> c; a -> d -> e; root -> f -> g; f -> h; d -> i; root -> j")
 	ids = []*fields.QualifiedHash{}
 	for _, node := range nodes {
 		ids = append(ids, node.ID())
-		s.Add(node)
 	}
-
-	return s, community.ID(), ids
+	return s, nodes["root"].ID(), ids
 }
 
 func TestWalk(t *testing.T) {
@@ -120,3 +101,30 @@ func TestWalkNils(t *testing.T) {
 		t.Errorf("Walk should error with nil visitor")
 	}
 }
+
+func TestWalkCycle(t *testing.T) {
+	_, _, community, reply := testutil.MakeReplyOrSkip(t)
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	// corrupt the store so that the community is a child of its own child
+	s.ChildMap[reply.ID().String()] = append(s.ChildMap[reply.ID().String()], community.ID().String())
+
+	done := make(chan error)
+	go func() {
+		done <- store.Walk(s, community.ID(), func(*fields.QualifiedHash) error {
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, store.ErrCycle) {
+			t.Errorf("Expected Walk to fail with cycle error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Walk did not terminate on store containing a cycle")
+	}
+}
@@ -0,0 +1,153 @@
+package forest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The node stream format is a simple sequence of binary-marshaled nodes,
+// each preceded by its length in bytes as a 4-byte big-endian unsigned
+// integer. It can be used to bundle any number of nodes into a single file or
+// to send them over a network connection.
+//
+// A node stream may also be compressed as a whole with gzip (see
+// WriteNodesGzip). Readers detect this from the gzip magic bytes at the start
+// of the stream. An uncompressed stream cannot begin with those bytes, since
+// they would be the start of a length prefix of over 500MB, far larger than
+// MaxNodeSize. Readers reject any length prefix above MaxNodeSize before
+// reading the node.
+
+// nodeLengthSize is the size (in bytes) of the length prefix of each node in a stream.
+const nodeLengthSize = 4
+
+// WriteNode writes a single node to w in the node stream format.
+func WriteNode(w io.Writer, node Node) error {
+	b, err := node.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed marshalling node %s: %w", node.ID(), err)
+	}
+	var length [nodeLengthSize]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+	if _, err := w.Write(length[:]); err != nil {
+		return fmt.Errorf("failed writing length of node %s: %w", node.ID(), err)
+	}
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed writing node %s: %w", node.ID(), err)
+	}
+	return nil
+}
+
+// WriteNodes writes all of the given nodes to w in the node stream format.
+func WriteNodes(w io.Writer, nodes []Node) error {
+	for _, node := range nodes {
+		if err := WriteNode(w, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNodesGzip writes all of the given nodes to w in the node stream format,
+// compressed with gzip. The result can be read by ReadNodes or ReadNodesGzip.
+func WriteNodesGzip(w io.Writer, nodes []Node) error {
+	compressor := gzip.NewWriter(w)
+	if err := WriteNodes(compressor, nodes); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("failed finishing compressed node stream: %w", err)
+	}
+	return nil
+}
+
+// gzipMagic is the first two bytes of every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NodeReader reads nodes one at a time from a node stream. Streams compressed
+// with gzip are decompressed transparently.
+type NodeReader struct {
+	r io.Reader
+	// detected is set once r has been checked for gzip compression
+	detected bool
+}
+
+// NewNodeReader creates a NodeReader that reads a node stream from r.
+func NewNodeReader(r io.Reader) *NodeReader {
+	return &NodeReader{r: r}
+}
+
+// detectGzip replaces the reader with a gzip decompressor if the stream
+// begins with the gzip magic bytes.
+func (n *NodeReader) detectGzip() error {
+	n.detected = true
+	buffered := bufio.NewReader(n.r)
+	n.r = buffered
+	if magic, _ := buffered.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return nil
+	}
+	decompressor, err := gzip.NewReader(buffered)
+	if err != nil {
+		return fmt.Errorf("failed reading compressed node stream: %w", err)
+	}
+	n.r = decompressor
+	return nil
+}
+
+// Next reads the next node from the stream. It returns io.EOF (unwrapped) if
+// the stream ended cleanly between two nodes.
+func (n *NodeReader) Next() (Node, error) {
+	if !n.detected {
+		if err := n.detectGzip(); err != nil {
+			return nil, err
+		}
+	}
+	var length [nodeLengthSize]byte
+	if _, err := io.ReadFull(n.r, length[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading node length: %w", err)
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > MaxNodeSize {
+		return nil, fmt.Errorf("node length %d exceeds limit of %d: %w", size, MaxNodeSize, ErrNodeTooLarge)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(n.r, b); err != nil {
+		return nil, fmt.Errorf("failed reading node: %w", err)
+	}
+	node, err := UnmarshalBinaryNode(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshalling node: %w", err)
+	}
+	return node, nil
+}
+
+// ReadNodesGzip reads every node in the gzip-compressed node stream r. Unlike
+// ReadNodes, it fails if r is not compressed.
+func ReadNodesGzip(r io.Reader) ([]Node, error) {
+	decompressor, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading compressed node stream: %w", err)
+	}
+	return ReadNodes(decompressor)
+}
+
+// ReadNodes reads every node in the node stream r, which may be compressed
+// with gzip.
+func ReadNodes(r io.Reader) ([]Node, error) {
+	reader := NewNodeReader(r)
+	nodes := []Node{}
+	for {
+		node, err := reader.Next()
+		if err == io.EOF {
+			return nodes, nil
+		} else if err != nil {
+			return nodes, err
+		}
+		nodes = append(nodes, node)
+	}
+}
@@ -0,0 +1,118 @@
+package forest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestNodeStreamRoundTrip(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	nodes := []forest.Node{identity, community, reply}
+	buf := new(bytes.Buffer)
+	if err := forest.WriteNodes(buf, nodes); err != nil {
+		t.Fatalf("Failed writing nodes: %v", err)
+	}
+	read, err := forest.ReadNodes(buf)
+	if err != nil {
+		t.Fatalf("Failed reading nodes: %v", err)
+	}
+	if len(read) != len(nodes) {
+		t.Fatalf("Expected to read %d nodes, got %d", len(nodes), len(read))
+	}
+	for i := range nodes {
+		if !nodes[i].Equals(read[i]) {
+			t.Errorf("Expected node %d to be %v, got %v", i, nodes[i].ID(), read[i].ID())
+		}
+	}
+}
+
+func TestNodeStreamTruncated(t *testing.T) {
+	_, _, community := testutil.MakeCommunityOrSkip(t)
+	buf := new(bytes.Buffer)
+	if err := forest.WriteNode(buf, community); err != nil {
+		t.Fatalf("Failed writing node: %v", err)
+	}
+	truncated := bytes.NewBuffer(buf.Bytes()[:buf.Len()-1])
+	if _, err := forest.ReadNodes(truncated); err == nil {
+		t.Errorf("Expected error reading truncated node stream")
+	}
+}
+
+func TestNodeStreamTooLarge(t *testing.T) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], forest.MaxNodeSize+1)
+	if _, err := forest.ReadNodes(bytes.NewReader(length[:])); !errors.Is(err, forest.ErrNodeTooLarge) {
+		t.Errorf("Expected ErrNodeTooLarge reading oversized node length, got %v", err)
+	}
+	compressed := new(bytes.Buffer)
+	compressor := gzip.NewWriter(compressed)
+	binary.BigEndian.PutUint32(length[:], math.MaxUint32)
+	if _, err := compressor.Write(length[:]); err != nil {
+		t.Fatalf("Failed compressing length: %v", err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatalf("Failed compressing length: %v", err)
+	}
+	if _, err := forest.ReadNodes(compressed); !errors.Is(err, forest.ErrNodeTooLarge) {
+		t.Errorf("Expected ErrNodeTooLarge reading oversized compressed node length, got %v", err)
+	}
+}
+
+func TestNodeStreamGzip(t *testing.T) {
+	identity, signer, community := testutil.MakeCommunityOrSkip(t)
+	contents := make([]string, 300)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("reply number %d in a long conversation", i)
+	}
+	replies, err := forest.As(identity, signer).NewReplies(community, contents, []byte{})
+	if err != nil {
+		t.Skipf("Failed creating replies: %v", err)
+	}
+	nodes := []forest.Node{identity, community}
+	for _, reply := range replies {
+		nodes = append(nodes, reply)
+	}
+
+	plain, compressed := new(bytes.Buffer), new(bytes.Buffer)
+	if err := forest.WriteNodes(plain, nodes); err != nil {
+		t.Fatalf("Failed writing nodes: %v", err)
+	}
+	if err := forest.WriteNodesGzip(compressed, nodes); err != nil {
+		t.Fatalf("Failed writing compressed nodes: %v", err)
+	}
+	if compressed.Len() >= plain.Len()*3/4 {
+		t.Errorf("Expected compression to save at least a quarter of %d bytes, got %d", plain.Len(), compressed.Len())
+	}
+
+	for name, read := range map[string]func() ([]forest.Node, error){
+		"ReadNodes plain":      func() ([]forest.Node, error) { return forest.ReadNodes(bytes.NewReader(plain.Bytes())) },
+		"ReadNodes compressed": func() ([]forest.Node, error) { return forest.ReadNodes(bytes.NewReader(compressed.Bytes())) },
+		"ReadNodesGzip":        func() ([]forest.Node, error) { return forest.ReadNodesGzip(bytes.NewReader(compressed.Bytes())) },
+	} {
+		readNodes, err := read()
+		if err != nil {
+			t.Errorf("%s: failed reading nodes: %v", name, err)
+			continue
+		}
+		if len(readNodes) != len(nodes) {
+			t.Errorf("%s: expected %d nodes, got %d", name, len(nodes), len(readNodes))
+			continue
+		}
+		for i := range nodes {
+			if !nodes[i].Equals(readNodes[i]) {
+				t.Errorf("%s: expected node %d to be %v, got %v", name, i, nodes[i].ID(), readNodes[i].ID())
+			}
+		}
+	}
+	if _, err := forest.ReadNodesGzip(bytes.NewReader(plain.Bytes())); err == nil {
+		t.Errorf("Expected ReadNodesGzip to reject an uncompressed stream")
+	}
+}
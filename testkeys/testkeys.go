@@ -31,7 +31,7 @@ func getKey(privKey, passphrase string) (*openpgp.Entity, error) {
 }
 
 // Signer creates a signer suitable ONLY FOR USE IN TEST CASES.
-func Signer(t *testing.T, privKey string) forest.Signer {
+func Signer(t testing.TB, privKey string) forest.Signer {
 	privkey, err := getKey(privKey, TestKeyPassphrase)
 	if err != nil {
 		t.Skip("Failed to create private key", err)
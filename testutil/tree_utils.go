@@ -0,0 +1,88 @@
+package testutil
+
+import (
+	"strings"
+	"testing"
+
+	"git.sr.ht/~whereswaldon/forest-go"
+)
+
+// MakeTree builds the tree of nodes described by spec, adds every node (and
+// the identity that authored them) to s, and returns the nodes by label.
+//
+// The spec is a semicolon-separated list of chains of labels separated by
+// "->", where each label is the parent of the label that follows it. Labels
+// that never appear on the right of an arrow become communities, and all
+// others become replies. For example, "a -> b -> c; b -> d" describes a
+// community a with a reply b that has two replies, c and d. Whitespace
+// around labels is ignored.
+func MakeTree(t *testing.T, s forest.Store, spec string) map[string]forest.Node {
+	parents := make(map[string]string)
+	labels := []string{}
+	addLabel := func(label string) {
+		if label == "" {
+			t.Fatalf("Tree spec %q contains an empty label", spec)
+		}
+		if _, known := parents[label]; !known {
+			parents[label] = ""
+			labels = append(labels, label)
+		}
+	}
+	for _, chain := range strings.Split(spec, ";") {
+		if strings.TrimSpace(chain) == "" {
+			continue
+		}
+		links := strings.Split(chain, "->")
+		for i := range links {
+			label := strings.TrimSpace(links[i])
+			addLabel(label)
+			if i == 0 {
+				continue
+			}
+			parent := strings.TrimSpace(links[i-1])
+			if existing := parents[label]; existing != "" && existing != parent {
+				t.Fatalf("Tree spec %q gives %s two parents: %s and %s", spec, label, existing, parent)
+			}
+			parents[label] = parent
+		}
+	}
+
+	identity, signer := MakeIdentityOrSkip(t)
+	if err := s.Add(identity); err != nil {
+		t.Skipf("Failed adding identity to store: %v", err)
+	}
+	builder := forest.As(identity, signer)
+	nodes := make(map[string]forest.Node)
+	inProgress := make(map[string]bool)
+	var build func(label string) forest.Node
+	build = func(label string) forest.Node {
+		if node, built := nodes[label]; built {
+			return node
+		}
+		if inProgress[label] {
+			t.Fatalf("Tree spec %q contains a cycle through %s", spec, label)
+		}
+		inProgress[label] = true
+		var (
+			node forest.Node
+			err  error
+		)
+		if parent := parents[label]; parent == "" {
+			node, err = builder.NewCommunity(label, []byte{})
+		} else {
+			node, err = builder.NewReply(build(parent), label, []byte{})
+		}
+		if err != nil {
+			t.Skipf("Failed generating test node %s: %v", label, err)
+		}
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding test node %s to store: %v", label, err)
+		}
+		nodes[label] = node
+		return node
+	}
+	for _, label := range labels {
+		build(label)
+	}
+	return nodes
+}
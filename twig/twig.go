@@ -23,7 +23,9 @@ package twig
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"math"
 )
 
 // Key represents a key within the twig data
@@ -104,15 +106,107 @@ func (d *Data) Contains(name string, version uint) bool {
 	return inValues
 }
 
-// UnmarshalBinary populates a Data from raw binary in Twig format
+// Merge copies every entry in other into d and returns d. When both contain
+// the same key and version, onConflict is called with the key, d's value, and
+// other's value, and its result is stored. A nil onConflict keeps d's value.
+// Values are copied, so other is never mutated and d never shares storage
+// with it. Any value containing a NULL byte (whether in other or returned by
+// onConflict) is invalid in twig and is skipped, leaving d's entry unchanged.
+func (d *Data) Merge(other *Data, onConflict func(key Key, a, b []byte) []byte) *Data {
+	if d.Values == nil {
+		d.Values = make(map[Key][]byte)
+	}
+	for key, value := range other.Values {
+		if existing, ok := d.Values[key]; ok {
+			if onConflict == nil {
+				continue
+			}
+			value = onConflict(key, existing, value)
+		}
+		if bytes.IndexByte(value, 0) >= 0 {
+			continue
+		}
+		d.Values[key] = append([]byte{}, value...)
+	}
+	return d
+}
+
+// MergePreferOther merges other into d, replacing d's values with other's
+// when both contain the same key and version.
+func (d *Data) MergePreferOther(other *Data) *Data {
+	return d.Merge(other, func(_ Key, _, b []byte) []byte {
+		return b
+	})
+}
+
+// MergePreferSelf merges other into d, keeping d's values when both contain
+// the same key and version.
+func (d *Data) MergePreferSelf(other *Data) *Data {
+	return d.Merge(other, func(_ Key, a, _ []byte) []byte {
+		return a
+	})
+}
+
+// MaxEntries and MaxTotalBytes limit the twig data accepted by
+// Data.UnmarshalBinary, so that enormous metadata is rejected when it is
+// parsed. A limit of zero disables that check. They may be changed to suit an
+// application, but only before any twig data is parsed, since they are read
+// without synchronization. Use ValidateWithLimits to apply other limits to
+// particular data.
+var (
+	// MaxEntries is the largest number of key-value pairs accepted.
+	MaxEntries = 1024
+	// MaxTotalBytes is the largest size of binary twig data accepted. The
+	// default is the largest content that can be stored in a node.
+	MaxTotalBytes = math.MaxUint16
+)
+
+// ErrLimitExceeded is wrapped by the errors returned when twig data exceeds
+// its Limits.
+var ErrLimitExceeded = errors.New("twig data exceeds limit")
+
+// Limits bounds the size of twig data. A limit of zero disables that check.
+type Limits struct {
+	MaxEntries    int
+	MaxTotalBytes int
+}
+
+// CurrentLimits returns the limits given by MaxEntries and MaxTotalBytes.
+func CurrentLimits() Limits {
+	return Limits{
+		MaxEntries:    MaxEntries,
+		MaxTotalBytes: MaxTotalBytes,
+	}
+}
+
+// ValidateWithLimits returns an error if b is not valid binary twig data or
+// exceeds the given limits. Errors caused by exceeding the limits wrap
+// ErrLimitExceeded.
+func ValidateWithLimits(b []byte, limits Limits) error {
+	return New().unmarshalWithLimits(b, limits)
+}
+
+// UnmarshalBinary populates a Data from raw binary in Twig format. It fails
+// with an error wrapping ErrLimitExceeded if b exceeds the CurrentLimits.
 func (d *Data) UnmarshalBinary(b []byte) error {
+	return d.unmarshalWithLimits(b, CurrentLimits())
+}
+
+// unmarshalWithLimits implements UnmarshalBinary with the given limits.
+func (d *Data) unmarshalWithLimits(b []byte, limits Limits) error {
 	if len(b) == 0 {
 		return nil
 	}
+	if limits.MaxTotalBytes > 0 && len(b) > limits.MaxTotalBytes {
+		return fmt.Errorf("twig data is %d bytes, more than the maximum of %d: %w", len(b), limits.MaxTotalBytes, ErrLimitExceeded)
+	}
 	components := bytes.Split(b, []byte{0})
 	if len(components)%2 != 0 {
 		return fmt.Errorf("key with no value")
 	}
+	if entries := len(components) / 2; limits.MaxEntries > 0 && entries > limits.MaxEntries {
+		return fmt.Errorf("twig data has %d entries, more than the maximum of %d: %w", entries, limits.MaxEntries, ErrLimitExceeded)
+	}
 	for i := 0; i < len(components); i += 2 {
 		key, err := FromString(string(components[i]))
 		if err != nil {
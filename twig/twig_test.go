@@ -2,6 +2,7 @@ package twig_test
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"git.sr.ht/~whereswaldon/forest-go/twig"
@@ -103,3 +104,119 @@ func TestDataSet(t *testing.T) {
 		t.Fatalf("successfully set illegal key value pair containing null byte")
 	}
 }
+
+func TestDataMergeDisjoint(t *testing.T) {
+	a := twig.New()
+	a.Set("foo", 1, []byte("a"))
+	b := twig.New()
+	b.Set("bar", 1, []byte("b"))
+	b.Set("foo", 2, []byte("b2"))
+	merged := a.Merge(b, func(key twig.Key, _, _ []byte) []byte {
+		t.Errorf("unexpected conflict on disjoint keys: %v", key)
+		return nil
+	})
+	if merged != a {
+		t.Errorf("expected Merge to return its receiver")
+	}
+	for _, expected := range []struct {
+		name    string
+		version uint
+		value   string
+	}{{"foo", 1, "a"}, {"bar", 1, "b"}, {"foo", 2, "b2"}} {
+		if value, ok := merged.Get(expected.name, expected.version); !ok || string(value) != expected.value {
+			t.Errorf("expected %s/%d to be %q, got %q", expected.name, expected.version, expected.value, value)
+		}
+	}
+	if len(b.Values) != 2 {
+		t.Errorf("merge should not modify its argument, has %d values", len(b.Values))
+	}
+}
+
+func TestDataMergeOverlapping(t *testing.T) {
+	makeData := func(value string) *twig.Data {
+		d := twig.New()
+		d.Set("foo", 1, []byte(value))
+		return d
+	}
+	if value, _ := makeData("self").MergePreferSelf(makeData("other")).Get("foo", 1); string(value) != "self" {
+		t.Errorf("expected MergePreferSelf to keep %q, got %q", "self", value)
+	}
+	if value, _ := makeData("self").MergePreferOther(makeData("other")).Get("foo", 1); string(value) != "other" {
+		t.Errorf("expected MergePreferOther to keep %q, got %q", "other", value)
+	}
+	merged := makeData("a").Merge(makeData("b"), func(_ twig.Key, a, b []byte) []byte {
+		return append(append([]byte{}, a...), b...)
+	})
+	if value, _ := merged.Get("foo", 1); string(value) != "ab" {
+		t.Errorf("expected conflict callback result %q, got %q", "ab", value)
+	}
+	other := makeData("b")
+	merged = makeData("a").Merge(other, func(_ twig.Key, _, _ []byte) []byte {
+		return []byte{1, 0, 2}
+	})
+	if value, _ := merged.Get("foo", 1); string(value) != "a" {
+		t.Errorf("expected value containing null byte to be rejected, got %q", value)
+	}
+	merged = makeData("a").MergePreferOther(other)
+	merged.Values[twig.Key{Name: "foo", Version: 1}][0] = 'z'
+	if value, _ := other.Get("foo", 1); string(value) != "b" {
+		t.Errorf("merge should not modify its argument, got %q", value)
+	}
+}
+
+func manyEntries(t *testing.T, count int) []byte {
+	data := twig.New()
+	for i := 0; i < count; i++ {
+		if _, err := data.Set("entry", uint(i), []byte("value")); err != nil {
+			t.Fatalf("Failed setting entry %d: %v", i, err)
+		}
+	}
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed marshalling twig data: %v", err)
+	}
+	return b
+}
+
+func TestValidateWithLimits(t *testing.T) {
+	b := manyEntries(t, 10)
+	table := []struct {
+		CaseName    string
+		Limits      twig.Limits
+		ShouldError bool
+	}{
+		{"unlimited", twig.Limits{}, false},
+		{"within limits", twig.Limits{MaxEntries: 10, MaxTotalBytes: len(b)}, false},
+		{"too many entries", twig.Limits{MaxEntries: 9}, true},
+		{"too many bytes", twig.Limits{MaxTotalBytes: len(b) - 1}, true},
+	}
+	for _, row := range table {
+		err := twig.ValidateWithLimits(b, row.Limits)
+		if row.ShouldError && !errors.Is(err, twig.ErrLimitExceeded) {
+			t.Errorf("%s: expected error wrapping ErrLimitExceeded, got %v", row.CaseName, err)
+		} else if !row.ShouldError && err != nil {
+			t.Errorf("%s: expected no error, got %v", row.CaseName, err)
+		}
+	}
+	if err := twig.ValidateWithLimits([]byte("no-value/1"), twig.Limits{}); err == nil {
+		t.Errorf("Expected malformed twig data to fail validation")
+	}
+}
+
+func TestUnmarshalBinaryLimits(t *testing.T) {
+	defer func(entries, size int) {
+		twig.MaxEntries, twig.MaxTotalBytes = entries, size
+	}(twig.MaxEntries, twig.MaxTotalBytes)
+	b := manyEntries(t, 10)
+	if err := twig.New().UnmarshalBinary(b); err != nil {
+		t.Errorf("Expected default limits to accept small data: %v", err)
+	}
+	twig.MaxEntries = 5
+	if err := twig.New().UnmarshalBinary(b); !errors.Is(err, twig.ErrLimitExceeded) {
+		t.Errorf("Expected too many entries to fail with ErrLimitExceeded, got %v", err)
+	}
+	twig.MaxEntries, twig.MaxTotalBytes = 0, 10
+	if err := twig.New().UnmarshalBinary(b); !errors.Is(err, twig.ErrLimitExceeded) {
+		t.Errorf("Expected too many bytes to fail with ErrLimitExceeded, got %v", err)
+	}
+}
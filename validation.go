@@ -0,0 +1,249 @@
+package forest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+)
+
+// ValidateStore checks the ID and signature of every node in the store s,
+// using concurrency worker goroutines to share the work. It returns the
+// IDs of all nodes that failed validation (in no particular order). Nodes
+// whose signing Identity cannot be found in s are considered invalid.
+// The returned error is only non-nil if the contents of the store could not
+// be enumerated.
+func ValidateStore(s Store, concurrency int) (invalid []*fields.QualifiedHash, err error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+	}
+	var nodes nodeCollector
+	if err := s.CopyInto(&nodes); err != nil {
+		return nil, fmt.Errorf("failed listing nodes in store: %w", err)
+	}
+
+	work := make(chan Node)
+	results := make(chan *fields.QualifiedHash)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for node := range work {
+				if !validateNodeIn(s, node) {
+					results <- node.ID()
+				}
+			}
+		}()
+	}
+	go func() {
+		for _, node := range nodes {
+			work <- node
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	invalid = []*fields.QualifiedHash{}
+	for id := range results {
+		invalid = append(invalid, id)
+	}
+	return invalid, nil
+}
+
+// validateNodeIn reports whether the given node has a correct ID and a
+// valid signature from an Identity resolvable within s.
+func validateNodeIn(s Store, node Node) bool {
+	hashable, ok := node.(Hashable)
+	if !ok {
+		return false
+	}
+	if valid, err := ValidateID(hashable, *node.ID()); err != nil || !valid {
+		return false
+	}
+	return validateSignatureIn(s, node) == nil
+}
+
+// validateSignatureIn checks that the node carries a valid signature from an
+// Identity resolvable within s.
+func validateSignatureIn(s Store, node Node) error {
+	validator, ok := node.(SignatureValidator)
+	if !ok {
+		return fmt.Errorf("node of type %T does not carry a signature", node)
+	}
+	var author *Identity
+	if validator.IsIdentity() {
+		author, ok = node.(*Identity)
+		if !ok {
+			return fmt.Errorf("node of type %T claims to be an identity", node)
+		}
+	} else {
+		authorNode, has, err := s.Get(node.AuthorID())
+		if err != nil {
+			return fmt.Errorf("failed looking up author %v: %w", node.AuthorID(), err)
+		} else if !has {
+			return fmt.Errorf("Missing author node %v", node.AuthorID())
+		}
+		if author, ok = authorNode.(*Identity); !ok {
+			return fmt.Errorf("author %v is not an identity", node.AuthorID())
+		}
+	}
+	valid, err := ValidateSignature(validator, author)
+	if err != nil {
+		return err
+	} else if !valid {
+		return fmt.Errorf("invalid signature on %v", node.ID())
+	}
+	return nil
+}
+
+// ValidationCache records the IDs of nodes that have passed validation, so
+// that they need not be validated again. A node's ID is a hash of its
+// content, so a node with a given ID can never change after it has been
+// validated. The methods of a ValidationCache are safe for concurrent use.
+type ValidationCache struct {
+	mutex sync.RWMutex
+	valid map[string]struct{}
+}
+
+// NewValidationCache creates an empty ValidationCache.
+func NewValidationCache() *ValidationCache {
+	return &ValidationCache{
+		valid: make(map[string]struct{}),
+	}
+}
+
+// Has returns whether the node with the given ID has passed validation.
+func (c *ValidationCache) Has(id *fields.QualifiedHash) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, valid := c.valid[id.String()]
+	return valid
+}
+
+// Add records that the node with the given ID has passed validation.
+func (c *ValidationCache) Add(id *fields.QualifiedHash) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.valid[id.String()] = struct{}{}
+}
+
+// Len returns the number of nodes recorded in the cache.
+func (c *ValidationCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.valid)
+}
+
+// ValidateDeepCached fully validates the node: its fields, its references
+// to other nodes within s, and its signature. Nodes that pass are recorded in
+// cache, and nodes already in cache skip everything but the check of their
+// ID. The ID is always recomputed from the node's content (which is cheap
+// compared to checking a signature), so a node that has been modified since it
+// was created cannot match a cache entry for its original content. The cache
+// may be nil, in which case nothing is cached.
+func ValidateDeepCached(node Node, s Store, cache *ValidationCache) error {
+	hashable, ok := node.(Hashable)
+	if !ok {
+		return fmt.Errorf("node of type %T cannot be hashed", node)
+	}
+	if valid, err := ValidateID(hashable, *node.ID()); err != nil {
+		return fmt.Errorf("failed validating id: %w", err)
+	} else if !valid {
+		return fmt.Errorf("id %v does not match node content", node.ID())
+	}
+	if cache != nil && cache.Has(node.ID()) {
+		return nil
+	}
+	if err := node.ValidateShallow(); err != nil {
+		return err
+	}
+	if err := node.ValidateDeep(s); err != nil {
+		return err
+	}
+	if err := validateSignatureIn(s, node); err != nil {
+		return err
+	}
+	if cache != nil {
+		cache.Add(node.ID())
+	}
+	return nil
+}
+
+// nodeCollector is a minimal Store implementation that simply records every
+// node added to it. It is used to enumerate the contents of other stores
+// via CopyInto.
+type nodeCollector []Node
+
+var _ Store = &nodeCollector{}
+
+func (c *nodeCollector) Add(node Node) error {
+	*c = append(*c, node)
+	return nil
+}
+
+func (c *nodeCollector) CopyInto(other Store) error {
+	for _, node := range *c {
+		if err := other.Add(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *nodeCollector) Get(id *fields.QualifiedHash) (Node, bool, error) {
+	for _, node := range *c {
+		if node.ID().Equals(id) {
+			return node, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (c *nodeCollector) Has(id *fields.QualifiedHash) (bool, error) {
+	_, has, err := c.Get(id)
+	return has, err
+}
+
+func (c *nodeCollector) GetIdentity(id *fields.QualifiedHash) (Node, bool, error) {
+	return c.Get(id)
+}
+
+func (c *nodeCollector) GetCommunity(id *fields.QualifiedHash) (Node, bool, error) {
+	return c.Get(id)
+}
+
+func (c *nodeCollector) GetConversation(communityID, conversationID *fields.QualifiedHash) (Node, bool, error) {
+	return c.Get(conversationID)
+}
+
+func (c *nodeCollector) GetReply(communityID, conversationID, replyID *fields.QualifiedHash) (Node, bool, error) {
+	return c.Get(replyID)
+}
+
+func (c *nodeCollector) Children(id *fields.QualifiedHash) ([]*fields.QualifiedHash, error) {
+	nodes := []Node{}
+	for _, node := range *c {
+		if node.ParentID().Equals(id) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return ChildBefore(nodes[i].CreatedAt(), nodes[i].ID(), nodes[j].CreatedAt(), nodes[j].ID())
+	})
+	children := make([]*fields.QualifiedHash, len(nodes))
+	for i, node := range nodes {
+		children[i] = node.ID()
+	}
+	return children, nil
+}
+
+func (c *nodeCollector) Recent(nodeType fields.NodeType, quantity int) ([]Node, error) {
+	return nil, fmt.Errorf("Recent is not supported by nodeCollector")
+}
+
+func (c *nodeCollector) RemoveSubtree(id *fields.QualifiedHash) error {
+	return fmt.Errorf("RemoveSubtree is not supported by nodeCollector")
+}
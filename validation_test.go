@@ -0,0 +1,217 @@
+package forest_test
+
+import (
+	"testing"
+
+	forest "git.sr.ht/~whereswaldon/forest-go"
+	"git.sr.ht/~whereswaldon/forest-go/fields"
+	"git.sr.ht/~whereswaldon/forest-go/store"
+	"git.sr.ht/~whereswaldon/forest-go/testkeys"
+	"git.sr.ht/~whereswaldon/forest-go/testutil"
+)
+
+func TestValidateStore(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	invalid, err := forest.ValidateStore(s, 2)
+	if err != nil {
+		t.Fatalf("ValidateStore failed on valid store: %v", err)
+	}
+	if len(invalid) != 0 {
+		t.Errorf("Expected no invalid nodes, got %v", invalid)
+	}
+}
+
+func TestValidateStoreMissingIdentity(t *testing.T) {
+	_, _, community, reply := testutil.MakeReplyOrSkip(t)
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	invalid, err := forest.ValidateStore(s, 4)
+	if err != nil {
+		t.Fatalf("ValidateStore failed on store: %v", err)
+	}
+	if len(invalid) != 2 {
+		t.Errorf("Expected both nodes to be invalid without their identity, got %v", invalid)
+	}
+}
+
+func TestValidateStoreTampered(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	reply.Content.Blob = fields.Blob([]byte("tampered"))
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	invalid, err := forest.ValidateStore(s, 1)
+	if err != nil {
+		t.Fatalf("ValidateStore failed on store: %v", err)
+	}
+	if len(invalid) != 1 || !invalid[0].Equals(reply.ID()) {
+		t.Errorf("Expected only the tampered reply to be invalid, got %v", invalid)
+	}
+}
+
+func TestReplyValidateDeepCommunity(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	builder := forest.As(identity, signer)
+	nested, err := builder.NewReply(reply, "nested", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	other, err := builder.NewCommunity("other community", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	// claims to belong to the other community despite replying within the
+	// first one
+	forged, err := builder.NewReplyInConversation(other.ID(), reply.ID(), reply.ID(), reply.Depth, "forged", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply, nested, other} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	for _, valid := range []*forest.Reply{reply, nested} {
+		if err := valid.ValidateDeep(s); err != nil {
+			t.Errorf("Expected reply at depth %d to pass deep validation: %v", valid.Depth, err)
+		}
+	}
+	if err := forged.ValidateShallow(); err != nil {
+		t.Skipf("Forged reply should be shallowly valid: %v", err)
+	}
+	if err := forged.ValidateDeep(s); err == nil {
+		t.Errorf("Expected reply claiming the wrong community to fail deep validation")
+	}
+}
+
+func TestReplyValidateDeepDepth(t *testing.T) {
+	identity, signer, community, reply := testutil.MakeReplyOrSkip(t)
+	builder := forest.As(identity, signer)
+	nested, err := builder.NewReply(reply, "nested", []byte{})
+	if err != nil {
+		t.Skipf("Failed generating test node: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply, nested} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	if err := nested.ValidateDeep(s); err != nil {
+		t.Errorf("Expected reply one level below its parent to pass deep validation: %v", err)
+	}
+	for _, claimedParentDepth := range []fields.TreeDepth{nested.Depth + 2, nested.Depth - 1} {
+		conversation := reply.ID()
+		if claimedParentDepth == 1 {
+			conversation = nested.ID()
+		}
+		forged, err := builder.NewReplyInConversation(community.ID(), conversation, nested.ID(), claimedParentDepth, "forged", []byte{})
+		if err != nil {
+			t.Skipf("Failed generating test node: %v", err)
+		}
+		if err := forged.ValidateShallow(); err != nil {
+			t.Skipf("Forged reply should be shallowly valid: %v", err)
+		}
+		if err := forged.ValidateDeep(s); err == nil {
+			t.Errorf("Expected reply at depth %d below a parent at depth %d to fail deep validation", forged.Depth, nested.Depth)
+		}
+	}
+}
+
+func TestValidateDeepCached(t *testing.T) {
+	identity, _, community, reply := testutil.MakeReplyOrSkip(t)
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			t.Skipf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	cache := forest.NewValidationCache()
+	if err := forest.ValidateDeepCached(reply, store.NewMemoryStore(), cache); err == nil {
+		t.Errorf("Expected reply to fail validation without its ancestors")
+	} else if cache.Len() != 0 {
+		t.Errorf("Expected failed validation not to be cached")
+	}
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := forest.ValidateDeepCached(node, s, cache); err != nil {
+			t.Errorf("Expected %T to pass validation: %v", node, err)
+		} else if !cache.Has(node.ID()) {
+			t.Errorf("Expected %T to be cached after passing validation", node)
+		}
+	}
+	// a cached node is not checked against the store again
+	if err := forest.ValidateDeepCached(reply, store.NewMemoryStore(), cache); err != nil {
+		t.Errorf("Expected cached reply to pass validation: %v", err)
+	}
+
+	tampered, err := forest.UnmarshalReply(mustMarshal(t, reply))
+	if err != nil {
+		t.Skipf("Failed copying reply: %v", err)
+	}
+	tampered.Content.Blob = fields.Blob("tampered")
+	if !tampered.ID().Equals(reply.ID()) {
+		t.Skipf("Expected tampered reply to retain the original ID")
+	}
+	if err := forest.ValidateDeepCached(tampered, s, cache); err == nil {
+		t.Errorf("Expected tampered reply to fail validation despite its ID being cached")
+	}
+}
+
+func mustMarshal(t testing.TB, node forest.Node) []byte {
+	data, err := node.MarshalBinary()
+	if err != nil {
+		t.Skipf("Failed marshalling %v: %v", node.ID(), err)
+	}
+	return data
+}
+
+func benchmarkValidateDeep(b *testing.B, cache *forest.ValidationCache) {
+	signer := testkeys.Signer(b, testkeys.PrivKey1)
+	identity, err := forest.NewIdentity(signer, "benchmark", []byte{})
+	if err != nil {
+		b.Fatalf("Failed creating identity: %v", err)
+	}
+	builder := forest.As(identity, signer)
+	community, err := builder.NewCommunity("benchmark", []byte{})
+	if err != nil {
+		b.Fatalf("Failed creating community: %v", err)
+	}
+	reply, err := builder.NewReply(community, "benchmark", []byte{})
+	if err != nil {
+		b.Fatalf("Failed creating reply: %v", err)
+	}
+	s := store.NewMemoryStore()
+	for _, node := range []forest.Node{identity, community, reply} {
+		if err := s.Add(node); err != nil {
+			b.Fatalf("Failed adding %v to store: %v", node.ID(), err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := forest.ValidateDeepCached(reply, s, cache); err != nil {
+			b.Fatalf("Failed validating reply: %v", err)
+		}
+	}
+}
+
+func BenchmarkValidateDeepUncached(b *testing.B) {
+	benchmarkValidateDeep(b, nil)
+}
+
+func BenchmarkValidateDeepCached(b *testing.B) {
+	benchmarkValidateDeep(b, forest.NewValidationCache())
+}